@@ -135,6 +135,7 @@ func main() {
 		}
 
 		ids.RunSync(image)
+		ids.RunHealth()
 
 		err = eng.Run(phase)
 		if err != nil {
@@ -0,0 +1,88 @@
+package imds
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/agent/utils"
+	"github.com/pritunl/pritunl-cloud/imds/types"
+	"github.com/pritunl/tools/logger"
+)
+
+const diskCheckRate = 30 * time.Second
+
+var (
+	diskChecks    = map[primitive.ObjectID]diskCheck{}
+	diskSizesLock = sync.Mutex{}
+	diskGrowing   = map[primitive.ObjectID]bool{}
+)
+
+type diskCheck struct {
+	size      int
+	timestamp time.Time
+}
+
+func growDisk(dsk *types.Disk) {
+	defer func() {
+		diskSizesLock.Lock()
+		delete(diskGrowing, dsk.Id)
+		diskSizesLock.Unlock()
+	}()
+
+	grown, err := utils.GrowDisk(dsk.Uuid, dsk.FileSystem, dsk.Index == "0")
+	if err != nil {
+		logger.WithFields(logger.Fields{
+			"disk_id": dsk.Id.Hex(),
+			"index":   dsk.Index,
+			"error":   err,
+		}).Error("agent: Failed to grow expanded disk")
+		return
+	}
+
+	if grown {
+		logger.WithFields(logger.Fields{
+			"disk_id": dsk.Id.Hex(),
+			"index":   dsk.Index,
+			"size":    dsk.Size,
+		}).Info("agent: Grew expanded disk")
+	}
+}
+
+// Grow partition and filesystem of disks that are smaller than the block
+// device. Disks are checked when the size changes and periodically to
+// retry failed or interrupted expansions.
+func UpdateDisks(dsks []*types.Disk) {
+	diskSizesLock.Lock()
+	defer diskSizesLock.Unlock()
+
+	ids := map[primitive.ObjectID]bool{}
+	for _, dsk := range dsks {
+		ids[dsk.Id] = true
+
+		if diskGrowing[dsk.Id] {
+			continue
+		}
+
+		check, ok := diskChecks[dsk.Id]
+		if ok && dsk.Size == check.size &&
+			time.Since(check.timestamp) < diskCheckRate {
+
+			continue
+		}
+
+		diskChecks[dsk.Id] = diskCheck{
+			size:      dsk.Size,
+			timestamp: time.Now(),
+		}
+
+		diskGrowing[dsk.Id] = true
+		go growDisk(dsk)
+	}
+
+	for dskId := range diskChecks {
+		if !ids[dskId] {
+			delete(diskChecks, dskId)
+		}
+	}
+}
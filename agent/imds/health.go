@@ -0,0 +1,212 @@
+package imds
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pritunl/pritunl-cloud/imds/types"
+	"github.com/pritunl/tools/logger"
+)
+
+var (
+	healthChecks     = map[string]*healthCheck{}
+	healthChecksLock = sync.Mutex{}
+	healthTransport  = &http.Transport{
+		DisableKeepAlives: true,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			MinVersion:         tls.VersionTLS12,
+		},
+	}
+)
+
+type healthCheck struct {
+	conf      *types.HealthCheck
+	status    string
+	message   string
+	successes int
+	failures  int
+	running   bool
+	lastRun   time.Time
+}
+
+func (h *healthCheck) check() (msg string) {
+	timeout := time.Duration(h.conf.Timeout) * time.Second
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(h.conf.Port))
+
+	switch h.conf.Kind {
+	case "http", "https":
+		client := &http.Client{
+			Transport: healthTransport,
+			Timeout:   timeout,
+		}
+
+		resp, err := client.Get(fmt.Sprintf(
+			"%s://%s%s", h.conf.Kind, addr, h.conf.Path))
+		if err != nil {
+			msg = err.Error()
+			return
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			msg = fmt.Sprintf("Bad status code %d", resp.StatusCode)
+			return
+		}
+		break
+	case "tcp":
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			msg = err.Error()
+			return
+		}
+		conn.Close()
+		break
+	case "exec":
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		output, err := exec.CommandContext(
+			ctx, "/bin/sh", "-c", h.conf.Command).CombinedOutput()
+		if err != nil {
+			msg = err.Error()
+
+			outputStr := strings.TrimSpace(string(output))
+			if len(outputStr) > 200 {
+				outputStr = outputStr[:200]
+			}
+			if outputStr != "" {
+				msg += ": " + outputStr
+			}
+			return
+		}
+		break
+	default:
+		msg = "Unknown check kind " + h.conf.Kind
+		return
+	}
+
+	return
+}
+
+func (h *healthCheck) run() {
+	msg := h.check()
+
+	healthChecksLock.Lock()
+	defer healthChecksLock.Unlock()
+
+	h.running = false
+	h.message = msg
+
+	if msg != "" {
+		h.successes = 0
+		h.failures += 1
+
+		if h.failures >= h.conf.UnhealthyThreshold &&
+			h.status != types.Unhealthy {
+
+			h.status = types.Unhealthy
+
+			logger.WithFields(logger.Fields{
+				"name":    h.conf.Name,
+				"kind":    h.conf.Kind,
+				"message": msg,
+			}).Error("agent: Health check failing")
+		}
+	} else {
+		h.failures = 0
+		h.successes += 1
+
+		if h.successes >= h.conf.HealthyThreshold {
+			h.status = types.Healthy
+		}
+	}
+}
+
+func UpdateHealthChecks(checks []*types.HealthCheck) {
+	healthChecksLock.Lock()
+	defer healthChecksLock.Unlock()
+
+	names := map[string]bool{}
+	for _, conf := range checks {
+		names[conf.Name] = true
+
+		check := healthChecks[conf.Name]
+		if check != nil && *check.conf == *conf {
+			continue
+		}
+
+		healthChecks[conf.Name] = &healthCheck{
+			conf:   conf,
+			status: types.Unhealthy,
+		}
+	}
+
+	for name := range healthChecks {
+		if !names[name] {
+			delete(healthChecks, name)
+		}
+	}
+}
+
+func GetHealth() (health string, states []*types.HealthCheckState) {
+	healthChecksLock.Lock()
+	defer healthChecksLock.Unlock()
+
+	if len(healthChecks) == 0 {
+		return
+	}
+
+	health = types.Healthy
+	for _, check := range healthChecks {
+		if check.status != types.Healthy {
+			health = types.Unhealthy
+		}
+
+		states = append(states, &types.HealthCheckState{
+			Name:    check.conf.Name,
+			Status:  check.status,
+			Message: check.message,
+		})
+	}
+
+	return
+}
+
+func runHealthChecks() {
+	healthChecksLock.Lock()
+	defer healthChecksLock.Unlock()
+
+	for _, check := range healthChecks {
+		interval := time.Duration(check.conf.Interval) * time.Second
+		if check.running || time.Since(check.lastRun) < interval {
+			continue
+		}
+
+		check.running = true
+		check.lastRun = time.Now()
+
+		go check.run()
+	}
+}
+
+func (m *Imds) RunHealth() {
+	m.waiter.Add(1)
+
+	go func() {
+		defer m.waiter.Done()
+
+		for {
+			runHealthChecks()
+			time.Sleep(1 * time.Second)
+		}
+	}()
+}
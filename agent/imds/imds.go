@@ -30,14 +30,16 @@ var (
 )
 
 type Imds struct {
-	Address     string            `json:"address"`
-	Port        int               `json:"port"`
-	Secret      string            `json:"secret"`
-	engine      *engine.Engine    `json:"-"`
-	initialized bool              `json:"-"`
-	waiter      sync.WaitGroup    `json:"-"`
-	syncLock    sync.Mutex        `json:"-"`
-	logger      *logging.Redirect `json:"-"`
+	Address      string            `json:"address"`
+	Port         int               `json:"port"`
+	Secret       string            `json:"secret"`
+	engine       *engine.Engine    `json:"-"`
+	initialized  bool              `json:"-"`
+	waiter       sync.WaitGroup    `json:"-"`
+	syncLock     sync.Mutex        `json:"-"`
+	secretLock   sync.Mutex        `json:"-"`
+	secretReload time.Time         `json:"-"`
+	logger       *logging.Redirect `json:"-"`
 }
 
 func (m *Imds) NewRequest(method, pth string, data interface{}) (
@@ -93,6 +95,10 @@ func (m *Imds) Get(query string) (val string, err error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == 401 {
+		m.ReloadSecret()
+	}
+
 	if resp.StatusCode != 200 {
 		body := ""
 		data, _ := ioutil.ReadAll(resp.Body)
@@ -132,8 +138,10 @@ func (m *Imds) Get(query string) (val string, err error) {
 }
 
 type SyncResp struct {
-	Spec string `json:"spec"`
-	Hash uint32 `json:"hash"`
+	Spec         string               `json:"spec"`
+	Hash         uint32               `json:"hash"`
+	HealthChecks []*types.HealthCheck `json:"health_checks"`
+	Disks        []*types.Disk        `json:"disks"`
 }
 
 func (m *Imds) Sync() (ready bool, err error) {
@@ -163,6 +171,10 @@ func (m *Imds) Sync() (ready bool, err error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == 401 {
+		m.ReloadSecret()
+	}
+
 	if resp.StatusCode != 200 {
 		body := ""
 		data, _ := ioutil.ReadAll(resp.Body)
@@ -196,6 +208,11 @@ func (m *Imds) Sync() (ready bool, err error) {
 		return
 	}
 
+	if respData.Hash != 0 {
+		UpdateHealthChecks(respData.HealthChecks)
+		UpdateDisks(respData.Disks)
+	}
+
 	ready = true
 	if respData.Hash == 0 {
 		ready = false
@@ -0,0 +1,109 @@
+package imds
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/agent/constants"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/tools/logger"
+)
+
+const secretReloadRate = 30 * time.Second
+
+func readIsoConf() (conf *Imds, err error) {
+	mountDir, err := ioutil.TempDir("", "pritunl-imds")
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "agent: Failed to create iso mount dir"),
+		}
+		return
+	}
+	defer os.RemoveAll(mountDir)
+
+	if runtime.GOOS == "freebsd" {
+		err = utils.Exec("", "mount", "-t", "cd9660", "-o", "ro",
+			"/dev/cd0", mountDir)
+	} else {
+		err = utils.Exec("", "mount", "-o", "ro", "/dev/sr0", mountDir)
+	}
+	if err != nil {
+		return
+	}
+	defer utils.Exec("", "umount", mountDir)
+
+	confData, err := utils.Read(path.Join(mountDir, "imds.json"))
+	if err != nil {
+		return
+	}
+
+	conf = &Imds{}
+	err = json.Unmarshal([]byte(confData), conf)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "agent: Failed to unmarshal iso imds conf"),
+		}
+		return
+	}
+
+	return
+}
+
+// ReloadSecret loads the client secret from the cloud-init drive after
+// the imds server rejects the current secret. The secret is replaced when
+// the instance is migrated to another node.
+func (m *Imds) ReloadSecret() {
+	m.secretLock.Lock()
+	defer m.secretLock.Unlock()
+
+	if time.Since(m.secretReload) < secretReloadRate {
+		return
+	}
+	m.secretReload = time.Now()
+
+	conf, err := readIsoConf()
+	if err != nil {
+		logger.WithFields(logger.Fields{
+			"error": err,
+		}).Error("agent: Failed to read imds secret from drive")
+		return
+	}
+
+	if conf.Secret == "" || conf.Secret == m.Secret {
+		return
+	}
+
+	confData, err := json.Marshal(conf)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "agent: Failed to marshal imds conf"),
+		}
+		logger.WithFields(logger.Fields{
+			"error": err,
+		}).Error("agent: Failed to update imds secret")
+		return
+	}
+
+	err = utils.CreateWrite(constants.ImdsConfPath, string(confData), 0600)
+	if err != nil {
+		logger.WithFields(logger.Fields{
+			"error": err,
+		}).Error("agent: Failed to update imds secret")
+		return
+	}
+
+	m.Address = conf.Address
+	m.Port = conf.Port
+	m.Secret = conf.Secret
+
+	logger.WithFields(logger.Fields{
+		"address": m.Address,
+		"port":    m.Port,
+	}).Info("agent: Reloaded imds secret")
+}
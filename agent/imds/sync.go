@@ -23,6 +23,7 @@ func (m *Imds) GetState(curHash uint32) (data *StateData, err error) {
 
 	data.Hash = curHash
 	data.Status = curStatus
+	data.Health, data.Checks = GetHealth()
 
 	mem, err := utils.GetMemInfo()
 	if err != nil {
@@ -45,6 +46,15 @@ func (m *Imds) GetState(curHash uint32) (data *StateData, err error) {
 		data.Load15 = load.Load15
 	}
 
+	uptime, err := utils.GetUptime()
+	if err != nil {
+		logger.WithFields(logger.Fields{
+			"error": err,
+		}).Limit(30 * time.Minute).Error("imds: Failed to get uptime")
+	} else {
+		data.Uptime = uptime
+	}
+
 	return
 }
 
@@ -0,0 +1,320 @@
+package utils
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	pritunl_utils "github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/tools/logger"
+)
+
+const growSlack = 4 * 1024 * 1024
+
+var (
+	xfsBlocksReg = regexp.MustCompile(
+		`data\s+=\s+bsize=(\d+)\s+blocks=(\d+)`)
+)
+
+func parseSize(val string) (size int64, err error) {
+	size, err = strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "agent: Failed to parse size"),
+		}
+		return
+	}
+
+	return
+}
+
+func getDeviceSize(devPath string) (size int64, err error) {
+	output, err := pritunl_utils.ExecOutput("",
+		"blockdev", "--getsize64", devPath)
+	if err != nil {
+		return
+	}
+
+	size, err = parseSize(output)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func getFilesystemSize(devPath, mountPath, fileSystem string) (
+	size int64, err error) {
+
+	switch fileSystem {
+	case "xfs":
+		output, e := pritunl_utils.ExecOutput("", "xfs_info", mountPath)
+		if e != nil {
+			err = e
+			return
+		}
+
+		match := xfsBlocksReg.FindStringSubmatch(output)
+		if match == nil {
+			err = &errortypes.ParseError{
+				errors.New("agent: Failed to parse xfs info"),
+			}
+			return
+		}
+
+		blockSize, e := parseSize(match[1])
+		if e != nil {
+			err = e
+			return
+		}
+
+		blocks, e := parseSize(match[2])
+		if e != nil {
+			err = e
+			return
+		}
+
+		size = blockSize * blocks
+		break
+	case "ext4", "ext3", "ext2":
+		output, e := pritunl_utils.ExecOutput("", "tune2fs", "-l", devPath)
+		if e != nil {
+			err = e
+			return
+		}
+
+		var blockSize, blocks int64
+		for _, line := range strings.Split(output, "\n") {
+			parts := strings.SplitN(line, ":", 2)
+			if len(parts) != 2 {
+				continue
+			}
+
+			switch strings.TrimSpace(parts[0]) {
+			case "Block count":
+				blocks, err = parseSize(parts[1])
+				break
+			case "Block size":
+				blockSize, err = parseSize(parts[1])
+				break
+			}
+			if err != nil {
+				return
+			}
+		}
+
+		if blockSize == 0 || blocks == 0 {
+			err = &errortypes.ParseError{
+				errors.New("agent: Failed to parse ext info"),
+			}
+			return
+		}
+
+		size = blockSize * blocks
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.Newf("agent: Unsupported filesystem %s", fileSystem),
+		}
+		return
+	}
+
+	return
+}
+
+func findMount(column, target string) (val string, err error) {
+	output, err := pritunl_utils.ExecOutput("",
+		"findmnt", "-n", "-o", column, target)
+	if err != nil {
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	val = strings.TrimSpace(lines[0])
+
+	return
+}
+
+func getPartition(devPath string) (parent, partNum string, err error) {
+	realPath, err := filepath.EvalSymlinks(devPath)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "agent: Failed to resolve device path"),
+		}
+		return
+	}
+
+	partPath := filepath.Join(
+		"/sys/class/block", filepath.Base(realPath), "partition")
+
+	exists, err := pritunl_utils.ExistsFile(partPath)
+	if err != nil || !exists {
+		return
+	}
+
+	partNum, err = pritunl_utils.Read(partPath)
+	if err != nil {
+		return
+	}
+	partNum = strings.TrimSpace(partNum)
+
+	parent, err = pritunl_utils.ExecOutput("",
+		"lsblk", "-n", "-d", "-o", "PKNAME", realPath)
+	if err != nil {
+		return
+	}
+	parent = strings.TrimSpace(parent)
+
+	if parent == "" || partNum == "" {
+		parent = ""
+		partNum = ""
+		return
+	}
+
+	return
+}
+
+// Check if the partition is smaller than the space available on the
+// parent block device.
+func partitionGrowable(parent, partNum string) (growable bool, err error) {
+	output, e := pritunl_utils.ExecCombinedOutput("",
+		"growpart", "--dry-run", filepath.Join("/dev", parent), partNum)
+	if e != nil {
+		if !strings.Contains(output, "NOCHANGE") {
+			err = e
+		}
+		return
+	}
+
+	growable = true
+
+	return
+}
+
+func growPartition(parent, partNum string) (err error) {
+	logger.WithFields(logger.Fields{
+		"disk":      parent,
+		"partition": partNum,
+	}).Info("agent: Growing disk partition")
+
+	output, err := pritunl_utils.ExecCombinedOutput("",
+		"growpart", filepath.Join("/dev", parent), partNum)
+	if err != nil {
+		if strings.Contains(output, "NOCHANGE") {
+			err = nil
+		}
+		return
+	}
+
+	return
+}
+
+func growFilesystem(devPath, mountPath, fileSystem string) (err error) {
+	logger.WithFields(logger.Fields{
+		"device":      devPath,
+		"mount":       mountPath,
+		"file_system": fileSystem,
+	}).Info("agent: Growing filesystem")
+
+	switch fileSystem {
+	case "xfs":
+		err = pritunl_utils.Exec("", "xfs_growfs", mountPath)
+		if err != nil {
+			return
+		}
+		break
+	case "ext4", "ext3", "ext2":
+		err = pritunl_utils.Exec("", "resize2fs", devPath)
+		if err != nil {
+			return
+		}
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.Newf("agent: Unsupported filesystem %s", fileSystem),
+		}
+		return
+	}
+
+	return
+}
+
+// Grow the partition and filesystem of an expanded disk, disks formatted
+// by the host are found by filesystem uuid and the root disk by the root
+// mount. Disks that are not mounted or already fill the block device are
+// ignored.
+func GrowDisk(uuid, fileSystem string, root bool) (
+	grown bool, err error) {
+	mountPath := ""
+	if uuid != "" {
+		mountPath, err = findMount("TARGET", "UUID="+uuid)
+		if err != nil {
+			err = nil
+			return
+		}
+	} else if root {
+		mountPath = "/"
+	}
+
+	if mountPath == "" {
+		return
+	}
+
+	devPath, err := findMount("SOURCE", mountPath)
+	if err != nil {
+		return
+	}
+
+	if fileSystem == "" {
+		fileSystem, err = findMount("FSTYPE", mountPath)
+		if err != nil {
+			return
+		}
+	}
+
+	parent, partNum, err := getPartition(devPath)
+	if err != nil {
+		return
+	}
+
+	partGrow := false
+	if partNum != "" {
+		partGrow, err = partitionGrowable(parent, partNum)
+		if err != nil {
+			return
+		}
+
+		if partGrow {
+			err = growPartition(parent, partNum)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	devSize, err := getDeviceSize(devPath)
+	if err != nil {
+		return
+	}
+
+	fsSize, err := getFilesystemSize(devPath, mountPath, fileSystem)
+	if err != nil {
+		return
+	}
+
+	if devSize-fsSize < growSlack {
+		grown = partGrow
+		return
+	}
+
+	err = growFilesystem(devPath, mountPath, fileSystem)
+	if err != nil {
+		return
+	}
+	grown = true
+
+	return
+}
@@ -20,18 +20,19 @@ import (
 )
 
 type balancerData struct {
-	Id           primitive.ObjectID   `json:"id"`
-	Name         string               `json:"name"`
-	Comment      string               `json:"comment"`
-	State        bool                 `json:"state"`
-	Type         string               `json:"type"`
-	Organization primitive.ObjectID   `json:"organization"`
-	Datacenter   primitive.ObjectID   `json:"datacenter"`
-	Certificates []primitive.ObjectID `json:"certificates"`
-	WebSockets   bool                 `json:"websockets"`
-	Domains      []*balancer.Domain   `json:"domains"`
-	Backends     []*balancer.Backend  `json:"backends"`
-	CheckPath    string               `json:"check_path"`
+	Id           primitive.ObjectID      `json:"id"`
+	Name         string                  `json:"name"`
+	Comment      string                  `json:"comment"`
+	State        bool                    `json:"state"`
+	Type         string                  `json:"type"`
+	Organization primitive.ObjectID      `json:"organization"`
+	Datacenter   primitive.ObjectID      `json:"datacenter"`
+	Certificates []primitive.ObjectID    `json:"certificates"`
+	WebSockets   bool                    `json:"websockets"`
+	Domains      []*balancer.Domain      `json:"domains"`
+	Backends     []*balancer.Backend     `json:"backends"`
+	UnitBackends []*balancer.UnitBackend `json:"unit_backends"`
+	CheckPath    string                  `json:"check_path"`
 }
 
 type balancersData struct {
@@ -78,6 +79,7 @@ func balancerPut(c *gin.Context) {
 	balnc.WebSockets = data.WebSockets
 	balnc.Domains = data.Domains
 	balnc.Backends = data.Backends
+	balnc.UnitBackends = data.UnitBackends
 	balnc.CheckPath = data.CheckPath
 
 	fields := set.NewSet(
@@ -91,6 +93,7 @@ func balancerPut(c *gin.Context) {
 		"websockets",
 		"domains",
 		"backends",
+		"unit_backends",
 		"check_path",
 	)
 
@@ -148,6 +151,7 @@ func balancerPost(c *gin.Context) {
 		WebSockets:   data.WebSockets,
 		Domains:      data.Domains,
 		Backends:     data.Backends,
+		UnitBackends: data.UnitBackends,
 		CheckPath:    data.CheckPath,
 	}
 
@@ -0,0 +1,338 @@
+package ahandlers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/build"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type buildData struct {
+	Id           primitive.ObjectID `json:"id"`
+	Name         string             `json:"name"`
+	Comment      string             `json:"comment"`
+	Organization primitive.ObjectID `json:"organization"`
+	Node         primitive.ObjectID `json:"node"`
+	Vpc          primitive.ObjectID `json:"vpc"`
+	Subnet       primitive.ObjectID `json:"subnet"`
+	BaseImage    primitive.ObjectID `json:"base_image"`
+	Processors   int                `json:"processors"`
+	Memory       int                `json:"memory"`
+	DiskSize     int                `json:"disk_size"`
+	Script       string             `json:"script"`
+}
+
+type buildsData struct {
+	Builds []*build.Build `json:"builds"`
+	Count  int64          `json:"count"`
+}
+
+func buildPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &buildData{}
+
+	buildId, ok := utils.ParseObjectId(c.Param("build_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	bld, err := build.Get(db, buildId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if bld.IsActive() {
+		errData := &errortypes.ErrorData{
+			Error:   "build_active",
+			Message: "Cannot modify running build",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	bld.Name = data.Name
+	bld.Comment = data.Comment
+	bld.Organization = data.Organization
+	bld.Node = data.Node
+	bld.Vpc = data.Vpc
+	bld.Subnet = data.Subnet
+	bld.BaseImage = data.BaseImage
+	bld.Processors = data.Processors
+	bld.Memory = data.Memory
+	bld.DiskSize = data.DiskSize
+	bld.Script = data.Script
+
+	fields := set.NewSet(
+		"name",
+		"comment",
+		"organization",
+		"zone",
+		"node",
+		"vpc",
+		"subnet",
+		"base_image",
+		"processors",
+		"memory",
+		"disk_size",
+		"script",
+	)
+
+	errData, err := bld.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = bld.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "build.change")
+
+	c.JSON(200, bld)
+}
+
+func buildPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &buildData{
+		Name: "New Build",
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	bld := &build.Build{
+		Name:         data.Name,
+		Comment:      data.Comment,
+		Organization: data.Organization,
+		Node:         data.Node,
+		Vpc:          data.Vpc,
+		Subnet:       data.Subnet,
+		BaseImage:    data.BaseImage,
+		Processors:   data.Processors,
+		Memory:       data.Memory,
+		DiskSize:     data.DiskSize,
+		Script:       data.Script,
+	}
+
+	errData, err := bld.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = bld.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "build.change")
+
+	c.JSON(200, bld)
+}
+
+func buildRunPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	buildId, ok := utils.ParseObjectId(c.Param("build_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	bld, err := build.Get(db, buildId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if bld.IsActive() {
+		errData := &errortypes.ErrorData{
+			Error:   "build_active",
+			Message: "Build is already running",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	bld.State = build.Queued
+	bld.Error = ""
+	err = bld.CommitFields(db, set.NewSet("state", "error"))
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "build.change")
+
+	c.JSON(200, bld)
+}
+
+func buildDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	buildId, ok := utils.ParseObjectId(c.Param("build_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	bld, err := build.Get(db, buildId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if bld.IsActive() {
+		errData := &errortypes.ErrorData{
+			Error:   "build_active",
+			Message: "Cannot delete running build",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	err = build.Remove(db, buildId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "build.change")
+
+	c.JSON(200, nil)
+}
+
+func buildsDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := []primitive.ObjectID{}
+
+	err := c.Bind(&data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = build.RemoveMulti(db, data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "build.change")
+
+	c.JSON(200, nil)
+}
+
+func buildGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	buildId, ok := utils.ParseObjectId(c.Param("build_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	bld, err := build.Get(db, buildId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, bld)
+}
+
+func buildsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{}
+
+	buildId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = buildId
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		query["name"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", regexp.QuoteMeta(name)),
+			"$options": "i",
+		}
+	}
+
+	organization, ok := utils.ParseObjectId(c.Query("organization"))
+	if ok {
+		query["organization"] = organization
+	}
+
+	builds, count, err := build.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &buildsData{
+		Builds: builds,
+		Count:  count,
+	}
+
+	c.JSON(200, data)
+}
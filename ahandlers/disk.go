@@ -2,6 +2,7 @@ package ahandlers
 
 import (
 	"fmt"
+	"mime"
 	"regexp"
 	"strconv"
 	"strings"
@@ -19,29 +20,38 @@ import (
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
 )
 
 type diskData struct {
-	Id               primitive.ObjectID `json:"id"`
-	Name             string             `json:"name"`
-	Comment          string             `json:"comment"`
-	Organization     primitive.ObjectID `json:"organization"`
-	Instance         primitive.ObjectID `json:"instance"`
-	Index            string             `json:"index"`
-	Type             string             `json:"type"`
-	Node             primitive.ObjectID `json:"node"`
-	Pool             primitive.ObjectID `json:"pool"`
-	DeleteProtection bool               `json:"delete_protection"`
-	FileSystem       string             `json:"file_system"`
-	Image            primitive.ObjectID `json:"image"`
-	RestoreImage     primitive.ObjectID `json:"restore_image"`
-	Backing          bool               `json:"backing"`
-	State            string             `json:"state"`
-	Size             int                `json:"size"`
-	NewSize          int                `json:"new_size"`
-	Backup           bool               `json:"backup"`
+	Id               primitive.ObjectID   `json:"id"`
+	Name             string               `json:"name"`
+	Comment          string               `json:"comment"`
+	Organization     primitive.ObjectID   `json:"organization"`
+	Instance         primitive.ObjectID   `json:"instance"`
+	Index            string               `json:"index"`
+	Type             string               `json:"type"`
+	Node             primitive.ObjectID   `json:"node"`
+	Pool             primitive.ObjectID   `json:"pool"`
+	DeleteProtection bool                 `json:"delete_protection"`
+	FileSystem       string               `json:"file_system"`
+	Image            primitive.ObjectID   `json:"image"`
+	RestoreImage     primitive.ObjectID   `json:"restore_image"`
+	Backing          bool                 `json:"backing"`
+	State            string               `json:"state"`
+	Size             int                  `json:"size"`
+	NewSize          int                  `json:"new_size"`
+	Backup           bool                 `json:"backup"`
+	BackupSchedule   *disk.BackupSchedule `json:"backup_schedule"`
+	Qos              *vm.DiskQos          `json:"qos"`
+	Import           *disk.Transfer       `json:"import"`
+}
+
+type diskExportData struct {
+	Format string `json:"format"`
 }
 
 type disksMultiData struct {
@@ -91,7 +101,10 @@ func diskPut(c *gin.Context) {
 		"delete_protection",
 		"index",
 		"backup",
+		"backup_schedule",
+		"next_backup",
 		"new_size",
+		"qos",
 	)
 
 	dsk.PreCommit()
@@ -102,7 +115,10 @@ func diskPut(c *gin.Context) {
 	dsk.DeleteProtection = dta.DeleteProtection
 	dsk.Index = dta.Index
 	dsk.Backup = dta.Backup
+	dsk.BackupSchedule = dta.BackupSchedule
+	dsk.Qos = dta.Qos
 
+	expand := false
 	if dsk.State == disk.Available && dta.State == disk.Snapshot {
 		dsk.State = disk.Snapshot
 		fields.Add("state")
@@ -112,6 +128,7 @@ func diskPut(c *gin.Context) {
 	} else if dsk.State == disk.Available && dta.State == disk.Expand {
 		dsk.State = disk.Expand
 		dsk.NewSize = dta.NewSize
+		expand = true
 		fields.Add("state")
 	} else if dta.State == disk.Restore {
 		if dsk.State != disk.Available {
@@ -158,6 +175,19 @@ func diskPut(c *gin.Context) {
 		return
 	}
 
+	if expand {
+		errData, err = dsk.ValidateExpandCapacity(db)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		if errData != nil {
+			c.JSON(400, errData)
+			return
+		}
+	}
+
 	err = dsk.CommitFields(db, fields)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -244,6 +274,108 @@ func diskPost(c *gin.Context) {
 		Backing:          dta.Backing,
 		Size:             dta.Size,
 		Backup:           dta.Backup,
+		BackupSchedule:   dta.BackupSchedule,
+		Qos:              dta.Qos,
+	}
+
+	if dta.Import != nil {
+		dsk.Import = &disk.Transfer{
+			Format: dta.Import.Format,
+			Url:    dta.Import.Url,
+		}
+
+		if dsk.Import.Url == "" {
+			dsk.State = disk.Upload
+		}
+	}
+
+	errData, err := dsk.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	errData, err = dsk.ValidateCapacity(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = dsk.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "disk.change")
+
+	c.JSON(200, dsk)
+}
+
+func diskClonePost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	dta := &diskData{}
+
+	diskId, ok := utils.ParseObjectId(c.Param("disk_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(dta)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	srcDsk, err := disk.Get(db, diskId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if dta.Name == "" {
+		dta.Name = fmt.Sprintf("%s-clone", srcDsk.Name)
+	}
+	if dta.Type == "" {
+		dta.Type = srcDsk.Type
+	}
+	if dta.Node.IsZero() && dta.Pool.IsZero() {
+		dta.Node = srcDsk.Node
+		dta.Pool = srcDsk.Pool
+	}
+
+	dsk := &disk.Disk{
+		Name:             dta.Name,
+		Comment:          dta.Comment,
+		Organization:     srcDsk.Organization,
+		Instance:         dta.Instance,
+		Index:            dta.Index,
+		Type:             dta.Type,
+		Node:             dta.Node,
+		Pool:             dta.Pool,
+		DeleteProtection: dta.DeleteProtection,
+		SystemType:       srcDsk.SystemType,
+		Size:             utils.Max(dta.Size, srcDsk.Size),
+		CloneSource:      srcDsk.Id,
+		Qos:              srcDsk.Qos.Copy(),
 	}
 
 	errData, err := dsk.Validate(db)
@@ -257,6 +389,17 @@ func diskPost(c *gin.Context) {
 		return
 	}
 
+	errData, err = dsk.ValidateCapacity(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
 	err = dsk.Insert(db)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -268,6 +411,181 @@ func diskPost(c *gin.Context) {
 	c.JSON(200, dsk)
 }
 
+func diskUploadPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	diskId, ok := utils.ParseObjectId(c.Param("disk_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	if c.Request.ContentLength <= 0 {
+		utils.AbortWithStatus(c, 411)
+		return
+	}
+
+	if c.Request.ContentLength > int64(
+		settings.Hypervisor.DiskImportMaxSize)*1073741824 {
+
+		errData := &errortypes.ErrorData{
+			Error:   "disk_upload_size",
+			Message: "Disk upload exceeds maximum import size",
+		}
+		c.JSON(413, errData)
+		return
+	}
+
+	dsk, err := disk.Get(db, diskId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if dsk.State != disk.Upload || dsk.Import == nil {
+		errData := &errortypes.ErrorData{
+			Error:   "disk_not_uploading",
+			Message: "Disk is not waiting for an upload",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	err = data.UploadDiskImport(db, dsk, c.Request.Body,
+		c.Request.ContentLength)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	updated, err := disk.SetUploaded(db, dsk.Id, dsk.Import)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if !updated {
+		errData := &errortypes.ErrorData{
+			Error:   "disk_not_uploading",
+			Message: "Disk is not waiting for an upload",
+		}
+		c.JSON(400, errData)
+		return
+	}
+	dsk.State = disk.Provision
+
+	event.PublishDispatch(db, "disk.change")
+
+	c.JSON(200, dsk)
+}
+
+func diskExportPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	dta := &diskExportData{}
+
+	diskId, ok := utils.ParseObjectId(c.Param("disk_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(dta)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	dsk, err := disk.Get(db, diskId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if dsk.State != disk.Available {
+		errData := &errortypes.ErrorData{
+			Error:   "disk_not_available",
+			Message: "Disk must be available to export",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	newExport := &disk.Transfer{
+		Format: dta.Format,
+	}
+
+	// Keep the previous export object to remove it before the new export
+	if dsk.Export != nil {
+		newExport.Storage = dsk.Export.Storage
+		newExport.Key = dsk.Export.Key
+	}
+	dsk.Export = newExport
+
+	errData := dsk.Export.Validate(true)
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	dsk.State = disk.Export
+	err = dsk.CommitFields(db, set.NewSet("state", "export"))
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "disk.change")
+
+	c.JSON(200, dsk)
+}
+
+func diskExportGet(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	diskId, ok := utils.ParseObjectId(c.Param("disk_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	dsk, err := disk.Get(db, diskId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	obj, size, err := data.GetDiskExport(db, dsk)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	defer obj.Close()
+
+	c.DataFromReader(200, size, "application/octet-stream", obj,
+		map[string]string{
+			"Content-Disposition": mime.FormatMediaType("attachment",
+				map[string]string{
+					"filename": fmt.Sprintf("%s.%s",
+						dsk.Name, dsk.Export.Format),
+				}),
+		})
+}
+
 func disksPut(c *gin.Context) {
 	if demo.Blocked(c) {
 		return
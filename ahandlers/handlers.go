@@ -75,6 +75,14 @@ func Register(engine *gin.Engine) {
 	csrfGroup.POST("/block", blockPost)
 	csrfGroup.DELETE("/block/:block_id", blockDelete)
 
+	csrfGroup.GET("/build", buildsGet)
+	csrfGroup.GET("/build/:build_id", buildGet)
+	csrfGroup.PUT("/build/:build_id", buildPut)
+	csrfGroup.POST("/build", buildPost)
+	csrfGroup.POST("/build/:build_id/run", buildRunPost)
+	csrfGroup.DELETE("/build", buildsDelete)
+	csrfGroup.DELETE("/build/:build_id", buildDelete)
+
 	csrfGroup.GET("/certificate", certificatesGet)
 	csrfGroup.GET("/certificate/:cert_id", certificateGet)
 	csrfGroup.PUT("/certificate/:cert_id", certificatePut)
@@ -107,6 +115,10 @@ func Register(engine *gin.Engine) {
 	csrfGroup.PUT("/disk", disksPut)
 	csrfGroup.PUT("/disk/:disk_id", diskPut)
 	csrfGroup.POST("/disk", diskPost)
+	csrfGroup.POST("/disk/:disk_id/clone", diskClonePost)
+	csrfGroup.PUT("/disk/:disk_id/upload", diskUploadPut)
+	csrfGroup.POST("/disk/:disk_id/export", diskExportPost)
+	csrfGroup.GET("/disk/:disk_id/export", diskExportGet)
 	csrfGroup.DELETE("/disk", disksDelete)
 	csrfGroup.DELETE("/disk/:disk_id", diskDelete)
 
@@ -137,6 +149,12 @@ func Register(engine *gin.Engine) {
 	csrfGroup.GET("/instance/:instance_id", instanceGet)
 	csrfGroup.GET("/instance/:instance_id/vnc", instanceVncGet)
 	csrfGroup.PUT("/instance/:instance_id", instancePut)
+	csrfGroup.POST("/instance/:instance_id/migrate", instanceMigratePost)
+	csrfGroup.POST("/instance/:instance_id/relocate", instanceRelocatePost)
+	csrfGroup.POST("/instance/:instance_id/snapshot", instanceSnapshotPost)
+	csrfGroup.POST("/instance/:instance_id/snapshot/restore",
+		instanceSnapshotRestorePost)
+	csrfGroup.POST("/instance/:instance_id/clone", instanceClonePost)
 	csrfGroup.POST("/instance", instancePost)
 	csrfGroup.DELETE("/instance", instancesDelete)
 	csrfGroup.DELETE("/instance/:instance_id", instanceDelete)
@@ -202,6 +220,14 @@ func Register(engine *gin.Engine) {
 		podUnitDeploymentsPut)
 	csrfGroup.POST("/pod/:pod_id/unit/:unit_id/deployment",
 		podUnitDeploymentPost)
+	csrfGroup.PUT("/pod/:pod_id/unit/:unit_id/rollout",
+		podUnitRolloutPut)
+	csrfGroup.POST("/pod/:pod_id/unit/:unit_id/preview",
+		podUnitPreviewPost)
+	csrfGroup.POST("/pod/:pod_id/unit/:unit_id/canary",
+		podUnitCanaryPost)
+	csrfGroup.PUT("/pod/:pod_id/unit/:unit_id/canary",
+		podUnitCanaryPut)
 	csrfGroup.PUT("/pod/:pod_id/unit/:unit_id/deployment/:deployment_id",
 		podUnitDeploymentPut)
 	csrfGroup.GET(
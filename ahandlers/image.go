@@ -24,6 +24,10 @@ type imageData struct {
 	Name         string             `json:"name"`
 	Comment      string             `json:"comment"`
 	Organization primitive.ObjectID `json:"organization"`
+	Family       string             `json:"family"`
+	Version      int                `json:"version"`
+	Channel      string             `json:"channel"`
+	Lifecycle    string             `json:"lifecycle"`
 }
 
 type imagesData struct {
@@ -60,11 +64,19 @@ func imagePut(c *gin.Context) {
 	img.Name = dta.Name
 	img.Comment = dta.Comment
 	img.Organization = dta.Organization
+	img.Family = dta.Family
+	img.Version = dta.Version
+	img.Channel = dta.Channel
+	img.Lifecycle = dta.Lifecycle
 
 	fields := set.NewSet(
 		"name",
 		"comment",
 		"organization",
+		"family",
+		"version",
+		"channel",
+		"lifecycle",
 	)
 
 	errData, err := img.Validate(db)
@@ -102,12 +114,17 @@ func imageDelete(c *gin.Context) {
 		return
 	}
 
-	err := data.DeleteImage(db, imageId)
+	errData, err := data.DeleteImage(db, imageId)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
 	event.PublishDispatch(db, "image.change")
 	event.PublishDispatch(db, "pod.change")
 
@@ -128,12 +145,17 @@ func imagesDelete(c *gin.Context) {
 		return
 	}
 
-	err = data.DeleteImages(db, dta)
+	errData, err := data.DeleteImages(db, dta)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
 	event.PublishDispatch(db, "image.change")
 	event.PublishDispatch(db, "pod.change")
 
@@ -250,6 +272,16 @@ func imagesGet(c *gin.Context) {
 			query["type"] = typ
 		}
 
+		family := strings.TrimSpace(c.Query("family"))
+		if family != "" {
+			query["family"] = family
+		}
+
+		channel := strings.TrimSpace(c.Query("channel"))
+		if channel != "" {
+			query["channel"] = channel
+		}
+
 		organization, ok := utils.ParseObjectId(c.Query("organization"))
 		if ok {
 			query["organization"] = organization
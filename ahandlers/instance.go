@@ -60,6 +60,8 @@ type instanceData struct {
 	Memory              int                `json:"memory"`
 	Processors          int                `json:"processors"`
 	NetworkRoles        []string           `json:"network_roles"`
+	NetworkIngress      int                `json:"network_ingress"`
+	NetworkEgress       int                `json:"network_egress"`
 	Isos                []*iso.Iso         `json:"isos"`
 	UsbDevices          []*usb.Device      `json:"usb_devices"`
 	PciDevices          []*pci.Device      `json:"pci_devices"`
@@ -74,6 +76,18 @@ type instanceData struct {
 	Count               int                `json:"count"`
 }
 
+type instanceMigrateData struct {
+	Node primitive.ObjectID `json:"node"`
+}
+
+type instanceSnapshotData struct {
+	SnapshotSet primitive.ObjectID `json:"snapshot_set"`
+}
+
+type instanceCloneData struct {
+	Name string `json:"name"`
+}
+
 type instanceMultiData struct {
 	Ids   []primitive.ObjectID `json:"ids"`
 	State string               `json:"state"`
@@ -143,6 +157,8 @@ func instancePut(c *gin.Context) {
 	inst.NoPublicAddress = dta.NoPublicAddress
 	inst.NoPublicAddress6 = dta.NoPublicAddress6
 	inst.NoHostAddress = dta.NoHostAddress
+	inst.NetworkIngress = dta.NetworkIngress
+	inst.NetworkEgress = dta.NetworkEgress
 
 	fields := set.NewSet(
 		"unix_id",
@@ -183,6 +199,8 @@ func instancePut(c *gin.Context) {
 		"no_public_address",
 		"no_public_address6",
 		"no_host_address",
+		"network_ingress",
+		"network_egress",
 	)
 
 	errData, err := inst.Validate(db)
@@ -254,7 +272,7 @@ func instancePost(c *gin.Context) {
 			return
 		}
 
-		if dta.DiskType == disk.Lvm {
+		if dta.DiskType == disk.Lvm || dta.DiskType == disk.Rbd {
 			poolMatch := false
 			for _, plId := range nde.Pools {
 				if plId == dta.DiskPool {
@@ -288,6 +306,26 @@ func instancePost(c *gin.Context) {
 			return
 		}
 
+		if img.IsObsolete() {
+			errData := &errortypes.ErrorData{
+				Error:   "image_obsolete",
+				Message: "Image is obsolete",
+			}
+			c.JSON(400, errData)
+			return
+		}
+
+		errData, err := img.CheckSignature(db)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		if errData != nil {
+			c.JSON(400, errData)
+			return
+		}
+
 		stre, err := storage.Get(db, img.Storage)
 		if err != nil {
 			utils.AbortWithError(c, 500, err)
@@ -359,6 +397,8 @@ func instancePost(c *gin.Context) {
 			Memory:              dta.Memory,
 			Processors:          dta.Processors,
 			NetworkRoles:        dta.NetworkRoles,
+			NetworkIngress:      dta.NetworkIngress,
+			NetworkEgress:       dta.NetworkEgress,
 			Isos:                dta.Isos,
 			UsbDevices:          dta.UsbDevices,
 			PciDevices:          dta.PciDevices,
@@ -402,6 +442,212 @@ func instancePost(c *gin.Context) {
 	}
 }
 
+func instanceMigratePost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	dta := &instanceMigrateData{}
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(dta)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	inst, err := instance.Get(db, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	errData, err := inst.StartMigration(db, dta.Node)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, inst)
+}
+
+func instanceRelocatePost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	dta := &instanceMigrateData{}
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(dta)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	inst, err := instance.Get(db, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	errData, err := inst.Relocate(db, dta.Node)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, inst)
+}
+
+func instanceSnapshotPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	inst, err := instance.Get(db, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	setId, errData, err := inst.StartSnapshot(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	event.PublishDispatch(db, "disk.change")
+
+	c.JSON(200, &instanceSnapshotData{
+		SnapshotSet: setId,
+	})
+}
+
+func instanceSnapshotRestorePost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	dta := &instanceSnapshotData{}
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(dta)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	inst, err := instance.Get(db, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	errData, err := inst.RestoreSnapshot(db, dta.SnapshotSet)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	event.PublishDispatch(db, "disk.change")
+
+	c.JSON(200, inst)
+}
+
+func instanceClonePost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	dta := &instanceCloneData{}
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(dta)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	inst, err := instance.Get(db, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	clone, errData, err := inst.Clone(db, dta.Name)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	event.PublishDispatch(db, "instance.change")
+	event.PublishDispatch(db, "disk.change")
+
+	c.JSON(200, clone)
+}
+
 func instancesPut(c *gin.Context) {
 	if demo.Blocked(c) {
 		return
@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/gin-gonic/gin"
@@ -247,10 +248,6 @@ func nodeOperationPut(c *gin.Context) {
 	}
 
 	operation := c.Param("operation")
-	if operation != node.Restart {
-		utils.AbortWithStatus(c, 400)
-		return
-	}
 
 	nde, err := node.Get(db, nodeId)
 	if err != nil {
@@ -258,7 +255,35 @@ func nodeOperationPut(c *gin.Context) {
 		return
 	}
 
-	nde.Operation = node.Restart
+	fields := set.NewSet()
+
+	switch operation {
+	case node.Restart:
+		nde.Operation = node.Restart
+		fields.Add("operation")
+		break
+	case node.Maintenance:
+		if !nde.Maintenance {
+			nde.Maintenance = true
+			nde.Drain = &node.Drain{
+				State:     node.Draining,
+				Started:   time.Now(),
+				Timestamp: time.Now(),
+			}
+		}
+		fields.Add("maintenance")
+		fields.Add("drain")
+		break
+	case node.Resume:
+		nde.Maintenance = false
+		nde.Drain = nil
+		fields.Add("maintenance")
+		fields.Add("drain")
+		break
+	default:
+		utils.AbortWithStatus(c, 400)
+		return
+	}
 
 	errData, err := nde.Validate(db)
 	if err != nil {
@@ -271,7 +296,7 @@ func nodeOperationPut(c *gin.Context) {
 		return
 	}
 
-	err = nde.CommitFields(db, set.NewSet("operation"))
+	err = nde.CommitFields(db, fields)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
@@ -12,10 +12,12 @@ import (
 )
 
 type organizationData struct {
-	Id      primitive.ObjectID `json:"id"`
-	Name    string             `json:"name"`
-	Comment string             `json:"comment"`
-	Roles   []string           `json:"roles"`
+	Id            primitive.ObjectID `json:"id"`
+	Name          string             `json:"name"`
+	Comment       string             `json:"comment"`
+	Roles         []string           `json:"roles"`
+	Encryption    primitive.ObjectID `json:"encryption"`
+	RequireSigned bool               `json:"require_signed"`
 }
 
 func organizationPut(c *gin.Context) {
@@ -47,11 +49,15 @@ func organizationPut(c *gin.Context) {
 	org.Name = data.Name
 	org.Comment = data.Comment
 	org.Roles = data.Roles
+	org.Encryption = data.Encryption
+	org.RequireSigned = data.RequireSigned
 
 	fields := set.NewSet(
 		"name",
 		"comment",
 		"roles",
+		"encryption",
+		"require_signed",
 	)
 
 	errData, err := org.Validate(db)
@@ -93,9 +99,11 @@ func organizationPost(c *gin.Context) {
 	}
 
 	org := &organization.Organization{
-		Name:    data.Name,
-		Comment: data.Comment,
-		Roles:   data.Roles,
+		Name:          data.Name,
+		Comment:       data.Comment,
+		Roles:         data.Roles,
+		Encryption:    data.Encryption,
+		RequireSigned: data.RequireSigned,
 	}
 
 	errData, err := org.Validate(db)
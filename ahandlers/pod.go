@@ -16,8 +16,11 @@ import (
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/deployment"
 	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/eval"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/journal"
+	"github.com/pritunl/pritunl-cloud/plan"
+	"github.com/pritunl/pritunl-cloud/planner"
 	"github.com/pritunl/pritunl-cloud/pod"
 	"github.com/pritunl/pritunl-cloud/scheduler"
 	"github.com/pritunl/pritunl-cloud/spec"
@@ -44,6 +47,22 @@ type podsDeployData struct {
 	Spec  primitive.ObjectID `json:"spec"`
 }
 
+type podRolloutData struct {
+	State string `json:"state"`
+}
+
+type podPreviewData struct {
+	Plan       primitive.ObjectID `json:"plan"`
+	Statements []*plan.Statement  `json:"statements"`
+}
+
+type podCanaryData struct {
+	Action string             `json:"action"`
+	Commit primitive.ObjectID `json:"commit"`
+	Count  int                `json:"count"`
+	Weight int                `json:"weight"`
+}
+
 type deploymentData struct {
 	Id   primitive.ObjectID `json:"id"`
 	Tags []string           `json:"tags"`
@@ -509,6 +528,304 @@ func podUnitDeploymentPost(c *gin.Context) {
 	c.JSON(200, nil)
 }
 
+func podUnitRolloutPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &podRolloutData{}
+
+	podId, ok := utils.ParseObjectId(c.Param("pod_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	unitId, ok := utils.ParseObjectId(c.Param("unit_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	pd, err := pod.Get(db, podId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	unit := pd.GetUnit(unitId)
+	if unit == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	errData, err := unit.SetRolloutState(db, data.State)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	event.PublishDispatch(db, "pod.change")
+
+	c.JSON(200, unit.Rollout)
+}
+
+func podUnitPreviewPost(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	data := &podPreviewData{}
+
+	podId, ok := utils.ParseObjectId(c.Param("pod_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	unitId, ok := utils.ParseObjectId(c.Param("unit_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	pd, err := pod.Get(db, podId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	unit := pd.GetUnit(unitId)
+	if unit == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	statements := data.Statements
+	if len(statements) > 0 {
+		for _, statement := range statements {
+			if statement.Id.IsZero() {
+				statement.Id = primitive.NewObjectID()
+			}
+
+			err = eval.Validate(statement.Statement)
+			if err != nil {
+				c.JSON(400, &errortypes.ErrorData{
+					Error:   "statement_invalid",
+					Message: err.Error(),
+				})
+				return
+			}
+		}
+	} else {
+		planId := data.Plan
+		if planId.IsZero() {
+			spc, e := spec.Get(db, unit.DeployCommit)
+			if e != nil {
+				utils.AbortWithError(c, 500, e)
+				return
+			}
+
+			if spc.Instance != nil {
+				planId = spc.Instance.Plan
+			}
+		}
+
+		if planId.IsZero() {
+			c.JSON(400, &errortypes.ErrorData{
+				Error:   "plan_missing",
+				Message: "Unit does not have a plan",
+			})
+			return
+		}
+
+		pln, e := plan.Get(db, planId)
+		if e != nil {
+			utils.AbortWithError(c, 500, e)
+			return
+		}
+
+		statements = pln.Statements
+	}
+
+	results, err := planner.Preview(db, pd, unit, statements)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, results)
+}
+
+func podUnitCanaryPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &podCanaryData{}
+
+	podId, ok := utils.ParseObjectId(c.Param("pod_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	unitId, ok := utils.ParseObjectId(c.Param("unit_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	pd, err := pod.Get(db, podId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	unit := pd.GetUnit(unitId)
+	if unit == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	errData, err := unit.StartCanary(db, data.Commit, data.Weight)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	if data.Count > 0 {
+		errData, err = scheduler.ManualSchedule(
+			db, unit, data.Commit, data.Count)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		if errData != nil {
+			c.JSON(400, errData)
+			return
+		}
+	}
+
+	event.PublishDispatch(db, "instance.change")
+	event.PublishDispatch(db, "pod.change")
+	event.PublishDispatch(db, "balancer.change")
+
+	c.JSON(200, unit.Canary)
+}
+
+func podUnitCanaryPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &podCanaryData{}
+
+	podId, ok := utils.ParseObjectId(c.Param("pod_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	unitId, ok := utils.ParseObjectId(c.Param("unit_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	pd, err := pod.Get(db, podId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	unit := pd.GetUnit(unitId)
+	if unit == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	var errData *errortypes.ErrorData
+	switch data.Action {
+	case "weight":
+		errData, err = unit.SetCanaryWeight(db, data.Weight)
+		break
+	case "promote":
+		errData, err = unit.PromoteCanary(db)
+		break
+	case "abort":
+		errData, err = unit.AbortCanary(db)
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "unit_canary_action_invalid",
+			Message: "Invalid canary action",
+		}
+	}
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	event.PublishDispatch(db, "instance.change")
+	event.PublishDispatch(db, "pod.change")
+	event.PublishDispatch(db, "balancer.change")
+
+	c.JSON(200, unit.Canary)
+}
+
 func podUnitDeploymentPut(c *gin.Context) {
 	if demo.Blocked(c) {
 		return
@@ -13,6 +13,7 @@ import (
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/pool"
 	"github.com/pritunl/pritunl-cloud/utils"
@@ -26,6 +27,10 @@ type poolData struct {
 	Zone             primitive.ObjectID `json:"zone"`
 	Type             string             `json:"type"`
 	VgName           string             `json:"vg_name"`
+	ThinPool         string             `json:"thin_pool"`
+	Overcommit       float64            `json:"overcommit"`
+	CephPool         string             `json:"ceph_pool"`
+	CephUser         string             `json:"ceph_user"`
 }
 
 type poolsData struct {
@@ -59,16 +64,31 @@ func poolPut(c *gin.Context) {
 		return
 	}
 
+	if data.CephPool != pl.CephPool {
+		errData := &errortypes.ErrorData{
+			Error:   "ceph_pool_immutable",
+			Message: "Ceph pool name cannot be changed",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
 	pl.Name = data.Name
 	pl.Comment = data.Comment
 	pl.DeleteProtection = data.DeleteProtection
 	pl.Type = data.Type
+	pl.ThinPool = data.ThinPool
+	pl.Overcommit = data.Overcommit
+	pl.CephUser = data.CephUser
 
 	fields := set.NewSet(
 		"name",
 		"comment",
 		"delete_protection",
 		"type",
+		"thin_pool",
+		"overcommit",
+		"ceph_user",
 	)
 
 	errData, err := pl.Validate(db)
@@ -116,6 +136,10 @@ func poolPost(c *gin.Context) {
 		Zone:             data.Zone,
 		Type:             data.Type,
 		VgName:           data.VgName,
+		ThinPool:         data.ThinPool,
+		Overcommit:       data.Overcommit,
+		CephPool:         data.CephPool,
+		CephUser:         data.CephUser,
 	}
 
 	errData, err := pl.Validate(db)
@@ -10,6 +10,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/image"
 	"github.com/pritunl/pritunl-cloud/secret"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
@@ -160,7 +161,22 @@ func secretDelete(c *gin.Context) {
 		return
 	}
 
-	err := secret.Remove(db, secrId)
+	exists, err := image.ExistsEncryption(db, secrId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if exists {
+		errData := &errortypes.ErrorData{
+			Error:   "secret_in_use",
+			Message: "Encryption secret is used by encrypted images",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	err = secret.Remove(db, secrId)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
@@ -16,6 +16,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/shape"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
 )
 
 type shapeData struct {
@@ -29,6 +30,9 @@ type shapeData struct {
 	Flexible         bool               `json:"flexible"`
 	DiskType         string             `json:"disk_type"`
 	DiskPool         primitive.ObjectID `json:"disk_pool"`
+	DiskQos          *vm.DiskQos        `json:"disk_qos"`
+	NetworkIngress   int                `json:"network_ingress"`
+	NetworkEgress    int                `json:"network_egress"`
 	Memory           int                `json:"memory"`
 	Processors       int                `json:"processors"`
 }
@@ -73,6 +77,9 @@ func shapePut(c *gin.Context) {
 	shpe.Flexible = data.Flexible
 	shpe.DiskType = data.DiskType
 	shpe.DiskPool = data.DiskPool
+	shpe.DiskQos = data.DiskQos
+	shpe.NetworkIngress = data.NetworkIngress
+	shpe.NetworkEgress = data.NetworkEgress
 	shpe.Memory = data.Memory
 	shpe.Processors = data.Processors
 
@@ -86,6 +93,9 @@ func shapePut(c *gin.Context) {
 		"flexible",
 		"disk_type",
 		"disk_pool",
+		"disk_qos",
+		"network_ingress",
+		"network_egress",
 		"memory",
 		"processors",
 	)
@@ -137,6 +147,9 @@ func shapePost(c *gin.Context) {
 		Flexible:         data.Flexible,
 		DiskType:         data.DiskType,
 		DiskPool:         data.DiskPool,
+		DiskQos:          data.DiskQos,
+		NetworkIngress:   data.NetworkIngress,
+		NetworkEgress:    data.NetworkEgress,
 		Memory:           data.Memory,
 		Processors:       data.Processors,
 	}
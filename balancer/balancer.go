@@ -10,6 +10,8 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/pod"
+	"github.com/pritunl/pritunl-cloud/spec"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
@@ -22,6 +24,15 @@ type Backend struct {
 	Protocol string `bson:"protocol" json:"protocol"`
 	Hostname string `bson:"hostname" json:"hostname"`
 	Port     int    `bson:"port" json:"port"`
+	Weight   int    `bson:"weight" json:"weight"`
+}
+
+type UnitBackend struct {
+	Pod      primitive.ObjectID `bson:"pod" json:"pod"`
+	Unit     primitive.ObjectID `bson:"unit" json:"unit"`
+	Protocol string             `bson:"protocol" json:"protocol"`
+	Port     int                `bson:"port" json:"port"`
+	Selector string             `bson:"selector" json:"selector"`
 }
 
 type State struct {
@@ -49,6 +60,7 @@ type Balancer struct {
 	WebSockets      bool                 `bson:"websockets" json:"websockets"`
 	Domains         []*Domain            `bson:"domains" json:"domains"`
 	Backends        []*Backend           `bson:"backends" json:"backends"`
+	UnitBackends    []*UnitBackend       `bson:"unit_backends" json:"unit_backends"`
 	States          map[string]*State    `bson:"states" json:"states"`
 	CheckPath       string               `bson:"check_path" json:"check_path"`
 }
@@ -70,6 +82,10 @@ func (b *Balancer) Validate(db *database.Database) (
 		b.Backends = []*Backend{}
 	}
 
+	if b.UnitBackends == nil {
+		b.UnitBackends = []*UnitBackend{}
+	}
+
 	if b.Certificates == nil {
 		b.Certificates = []primitive.ObjectID{}
 	}
@@ -102,6 +118,73 @@ func (b *Balancer) Validate(db *database.Database) (
 			}
 			return
 		}
+
+		if backend.Weight < 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "balancer_weight_invalid",
+				Message: "Invalid balancer backend weight",
+			}
+			return
+		} else if backend.Weight == 0 {
+			backend.Weight = 1
+		}
+	}
+
+	for _, unitBackend := range b.UnitBackends {
+		if unitBackend.Protocol != "http" &&
+			unitBackend.Protocol != "https" {
+
+			errData = &errortypes.ErrorData{
+				Error:   "balancer_protocol_invalid",
+				Message: "Invalid balancer unit backend protocol",
+			}
+			return
+		}
+
+		if unitBackend.Port == 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "balancer_port_invalid",
+				Message: "Invalid balancer unit backend port",
+			}
+			return
+		}
+
+		switch unitBackend.Selector {
+		case "":
+			unitBackend.Selector = spec.Private
+			break
+		case spec.Private, spec.Private6, spec.Public, spec.Public6,
+			spec.OraclePrivate, spec.OraclePublic:
+
+			break
+		default:
+			errData = &errortypes.ErrorData{
+				Error:   "balancer_selector_invalid",
+				Message: "Invalid balancer unit backend selector",
+			}
+			return
+		}
+
+		pd, e := pod.GetOrg(db, b.Organization, unitBackend.Pod)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				errData = &errortypes.ErrorData{
+					Error:   "balancer_unit_invalid",
+					Message: "Invalid balancer unit backend pod",
+				}
+			} else {
+				err = e
+			}
+			return
+		}
+
+		if pd.GetUnit(unitBackend.Unit) == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "balancer_unit_invalid",
+				Message: "Invalid balancer unit backend unit",
+			}
+			return
+		}
 	}
 
 	if b.State {
@@ -137,7 +220,7 @@ func (b *Balancer) Validate(db *database.Database) (
 			return
 		}
 
-		if b.Backends == nil || len(b.Backends) == 0 {
+		if len(b.Backends) == 0 && len(b.UnitBackends) == 0 {
 			errData = &errortypes.ErrorData{
 				Error:   "backend_required",
 				Message: "Missing required backend",
@@ -0,0 +1,133 @@
+package balancer
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/deployment"
+	"github.com/pritunl/pritunl-cloud/pod"
+	"github.com/pritunl/pritunl-cloud/spec"
+)
+
+func getDeploymentAddr(deply *deployment.Deployment,
+	selector string) string {
+
+	if deply.InstanceData == nil {
+		return ""
+	}
+
+	var addrs []string
+	switch selector {
+	case spec.Private:
+		addrs = deply.InstanceData.PrivateIps
+		break
+	case spec.Private6:
+		addrs = deply.InstanceData.PrivateIps6
+		break
+	case spec.Public:
+		addrs = deply.InstanceData.PublicIps
+		break
+	case spec.Public6:
+		addrs = deply.InstanceData.PublicIps6
+		break
+	case spec.OraclePrivate:
+		addrs = deply.InstanceData.OraclePrivateIps
+		break
+	case spec.OraclePublic:
+		addrs = deply.InstanceData.OraclePublicIps
+		break
+	}
+
+	if len(addrs) == 0 {
+		return ""
+	}
+
+	return addrs[0]
+}
+
+// Resolve unit backends to deployment addresses. Backends are only
+// appended in memory and must not be committed.
+func (b *Balancer) LoadUnitBackends(db *database.Database) (err error) {
+	if len(b.UnitBackends) == 0 {
+		return
+	}
+
+	for _, unitBackend := range b.UnitBackends {
+		pd, e := pod.GetOrg(db, b.Organization, unitBackend.Pod)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				continue
+			}
+			err = e
+			return
+		}
+
+		unit := pd.GetUnit(unitBackend.Unit)
+		if unit == nil {
+			continue
+		}
+
+		deplys, e := deployment.GetAll(db, &bson.M{
+			"pod":   pd.Id,
+			"unit":  unit.Id,
+			"state": deployment.Deployed,
+		})
+		if e != nil {
+			err = e
+			return
+		}
+
+		stableAddrs := []string{}
+		canaryAddrs := []string{}
+		for _, deply := range deplys {
+			if !deply.IsHealthy() {
+				continue
+			}
+
+			addr := getDeploymentAddr(deply, unitBackend.Selector)
+			if addr == "" {
+				continue
+			}
+
+			if unit.Canary != nil && deply.Spec == unit.Canary.Commit {
+				canaryAddrs = append(canaryAddrs, addr)
+			} else {
+				stableAddrs = append(stableAddrs, addr)
+			}
+		}
+
+		stableWeight := 1
+		canaryWeight := 0
+		if unit.Canary != nil && len(canaryAddrs) > 0 {
+			if len(stableAddrs) == 0 {
+				canaryWeight = 1
+			} else {
+				canaryWeight = unit.Canary.Weight * len(stableAddrs)
+				stableWeight = (100 - unit.Canary.Weight) * len(canaryAddrs)
+			}
+		}
+
+		if stableWeight > 0 {
+			for _, addr := range stableAddrs {
+				b.Backends = append(b.Backends, &Backend{
+					Protocol: unitBackend.Protocol,
+					Hostname: addr,
+					Port:     unitBackend.Port,
+					Weight:   stableWeight,
+				})
+			}
+		}
+
+		if canaryWeight > 0 {
+			for _, addr := range canaryAddrs {
+				b.Backends = append(b.Backends, &Backend{
+					Protocol: unitBackend.Protocol,
+					Hostname: addr,
+					Port:     unitBackend.Port,
+					Weight:   canaryWeight,
+				})
+			}
+		}
+	}
+
+	return
+}
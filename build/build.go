@@ -0,0 +1,227 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vpc"
+)
+
+type Build struct {
+	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name         string             `bson:"name" json:"name"`
+	Comment      string             `bson:"comment" json:"comment"`
+	Organization primitive.ObjectID `bson:"organization,omitempty" json:"organization"`
+	Zone         primitive.ObjectID `bson:"zone,omitempty" json:"zone"`
+	Node         primitive.ObjectID `bson:"node,omitempty" json:"node"`
+	Vpc          primitive.ObjectID `bson:"vpc,omitempty" json:"vpc"`
+	Subnet       primitive.ObjectID `bson:"subnet,omitempty" json:"subnet"`
+	BaseImage    primitive.ObjectID `bson:"base_image,omitempty" json:"base_image"`
+	Processors   int                `bson:"processors" json:"processors"`
+	Memory       int                `bson:"memory" json:"memory"`
+	DiskSize     int                `bson:"disk_size" json:"disk_size"`
+	Script       string             `bson:"script" json:"script"`
+	State        string             `bson:"state" json:"state"`
+	Error        string             `bson:"error" json:"error"`
+	Version      int                `bson:"version" json:"version"`
+	Timestamp    time.Time          `bson:"timestamp" json:"timestamp"`
+	Spec         primitive.ObjectID `bson:"spec,omitempty" json:"spec"`
+	Deployment   primitive.ObjectID `bson:"deployment,omitempty" json:"deployment"`
+	Image        primitive.ObjectID `bson:"image,omitempty" json:"image"`
+}
+
+func (b *Build) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	b.Name = utils.FilterName(b.Name)
+
+	if b.Organization.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "organization_required",
+			Message: "Missing required organization",
+		}
+		return
+	}
+
+	if b.Node.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "node_required",
+			Message: "Missing required node",
+		}
+		return
+	}
+
+	nde, err := node.Get(db, b.Node)
+	if err != nil {
+		return
+	}
+	b.Zone = nde.Zone
+
+	if b.Zone.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "node_zone_invalid",
+			Message: "Node is not in a zone",
+		}
+		return
+	}
+
+	if b.Vpc.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_required",
+			Message: "Missing required VPC",
+		}
+		return
+	}
+
+	vc, err := vpc.Get(db, b.Vpc)
+	if err != nil {
+		return
+	}
+
+	if vc.Organization != b.Organization {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_invalid",
+			Message: "VPC does not belong to build organization",
+		}
+		return
+	}
+
+	if vc.GetSubnet(b.Subnet) == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "subnet_invalid",
+			Message: "Invalid VPC subnet",
+		}
+		return
+	}
+
+	if b.BaseImage.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "base_image_required",
+			Message: "Missing required base image",
+		}
+		return
+	}
+
+	img, err := image.Get(db, b.BaseImage)
+	if err != nil {
+		return
+	}
+
+	if !img.Organization.IsZero() && img.Organization != b.Organization {
+		errData = &errortypes.ErrorData{
+			Error:   "base_image_invalid",
+			Message: "Base image does not belong to build organization",
+		}
+		return
+	}
+
+	if img.IsObsolete() {
+		errData = &errortypes.ErrorData{
+			Error:   "base_image_obsolete",
+			Message: "Base image is obsolete",
+		}
+		return
+	}
+
+	if b.DiskSize != 0 && b.DiskSize < 10 {
+		errData = &errortypes.ErrorData{
+			Error:   "disk_size_invalid",
+			Message: "Disk size below minimum",
+		}
+		return
+	}
+
+	if strings.TrimSpace(b.Script) == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "script_required",
+			Message: "Missing required provisioning script",
+		}
+		return
+	}
+
+	if strings.Contains(b.Script, "```") {
+		errData = &errortypes.ErrorData{
+			Error:   "script_invalid",
+			Message: "Provisioning script cannot contain code fences",
+		}
+		return
+	}
+
+	if b.State == "" {
+		b.State = Idle
+	}
+
+	return
+}
+
+func (b *Build) IsActive() bool {
+	return b.State == Queued || b.State == Building
+}
+
+func (b *Build) GetImageName() string {
+	return fmt.Sprintf("%s-v%d", b.Name, b.Version+1)
+}
+
+func (b *Build) GetSpecData() string {
+	return fmt.Sprintf(
+		"# %s\n\n```yaml\n---\nname: %s\nkind: image\n```\n\n"+
+			"```shell\n%s\n```\n",
+		b.GetImageName(),
+		b.Name,
+		strings.TrimRight(b.Script, "\n"),
+	)
+}
+
+func (b *Build) Commit(db *database.Database) (err error) {
+	coll := db.Builds()
+
+	err = coll.Commit(b.Id, b)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (b *Build) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.Builds()
+
+	err = coll.CommitFields(b.Id, b, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (b *Build) Insert(db *database.Database) (err error) {
+	coll := db.Builds()
+
+	if !b.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("build: Build already exists"),
+		}
+		return
+	}
+
+	resp, err := coll.InsertOne(db, b)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	b.Id = resp.InsertedID.(primitive.ObjectID)
+
+	return
+}
@@ -0,0 +1,14 @@
+package build
+
+import (
+	"time"
+)
+
+const (
+	Idle     = "idle"
+	Queued   = "queued"
+	Building = "building"
+	Failed   = "failed"
+
+	Timeout = 2 * time.Hour
+)
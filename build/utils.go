@@ -0,0 +1,244 @@
+package build
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func Get(db *database.Database, bldId primitive.ObjectID) (
+	bld *Build, err error) {
+
+	coll := db.Builds()
+	bld = &Build{}
+
+	err = coll.FindOneId(bldId, bld)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetOrg(db *database.Database, orgId, bldId primitive.ObjectID) (
+	bld *Build, err error) {
+
+	coll := db.Builds()
+	bld = &Build{}
+
+	err = coll.FindOne(db, &bson.M{
+		"_id":          bldId,
+		"organization": orgId,
+	}).Decode(bld)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M) (
+	blds []*Build, err error) {
+
+	coll := db.Builds()
+	blds = []*Build{}
+
+	cursor, err := coll.Find(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		bld := &Build{}
+		err = cursor.Decode(bld)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		blds = append(blds, bld)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M,
+	page, pageCount int64) (blds []*Build, count int64, err error) {
+
+	coll := db.Builds()
+	blds = []*Build{}
+
+	if len(*query) == 0 {
+		count, err = coll.EstimatedDocumentCount(db)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+	} else {
+		count, err = coll.CountDocuments(db, query)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+	}
+
+	maxPage := count / pageCount
+	if count == pageCount {
+		maxPage = 0
+	}
+	page = utils.Min64(page, maxPage)
+	skip := utils.Min64(page*pageCount, count)
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+			Skip:  &skip,
+			Limit: &pageCount,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		bld := &Build{}
+		err = cursor.Decode(bld)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		blds = append(blds, bld)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAllNode(db *database.Database, ndeId primitive.ObjectID) (
+	blds []*Build, err error) {
+
+	blds, err = GetAll(db, &bson.M{
+		"node": ndeId,
+		"state": &bson.M{
+			"$in": []string{Queued, Building},
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, bldId primitive.ObjectID) (err error) {
+	coll := db.Builds()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": bldId,
+		"state": &bson.M{
+			"$nin": []string{Queued, Building},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveOrg(db *database.Database, orgId, bldId primitive.ObjectID) (
+	err error) {
+
+	coll := db.Builds()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": bldId,
+		"state": &bson.M{
+			"$nin": []string{Queued, Building},
+		},
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveMulti(db *database.Database, bldIds []primitive.ObjectID) (
+	err error) {
+
+	coll := db.Builds()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": bldIds,
+		},
+		"state": &bson.M{
+			"$nin": []string{Queued, Building},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func RemoveMultiOrg(db *database.Database, orgId primitive.ObjectID,
+	bldIds []primitive.ObjectID) (err error) {
+
+	coll := db.Builds()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": bldIds,
+		},
+		"state": &bson.M{
+			"$nin": []string{Queued, Building},
+		},
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -228,6 +228,26 @@ type imdsConfig struct {
 	Secret  string `json:"secret"`
 }
 
+func getImdsConf(virt *vm.VirtualMachine) (conf string, err error) {
+	imdsConf := &imdsConfig{
+		Address: strings.Split(settings.Hypervisor.ImdsAddress, "/")[0],
+		Port:    settings.Hypervisor.ImdsPort,
+		Secret:  virt.ImdsClientSecret,
+	}
+
+	imdsConfContent, err := json.Marshal(imdsConf)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "cloudinit: Failed to marshal imds conf"),
+		}
+		return
+	}
+
+	conf = string(imdsConfContent)
+
+	return
+}
+
 func getUserData(db *database.Database, inst *instance.Instance,
 	virt *vm.VirtualMachine, deply *deployment.Deployment,
 	deployUnit *pod.Unit, deploySpec *spec.Spec, initial bool,
@@ -306,22 +326,13 @@ func getUserData(db *database.Database, inst *instance.Instance,
 		})
 	}
 
-	imdsConf := &imdsConfig{
-		Address: strings.Split(settings.Hypervisor.ImdsAddress, "/")[0],
-		Port:    settings.Hypervisor.ImdsPort,
-		Secret:  virt.ImdsClientSecret,
-	}
-
-	imdsConfContent, err := json.Marshal(imdsConf)
+	imdsConfContent, err := getImdsConf(virt)
 	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "cloudinit: Failed to marshal imds conf"),
-		}
 		return
 	}
 
 	writeFiles = append(writeFiles, &fileData{
-		Content:     string(imdsConfContent),
+		Content:     imdsConfContent,
 		Owner:       owner,
 		Path:        "/etc/pritunl-imds.json",
 		Permissions: "0600",
@@ -336,7 +347,9 @@ func getUserData(db *database.Database, inst *instance.Instance,
 		deployScriptTmpl = deploymentScriptTmpl
 	}
 
-	if deply != nil && deployUnit != nil && deploySpec != nil {
+	if deply != nil && deploySpec != nil &&
+		(deployUnit != nil || !deply.Build.IsZero()) {
+
 		if deply.Mounts != nil && len(deply.Mounts) > 0 {
 			data.HasMounts = true
 			for _, mnt := range deply.Mounts {
@@ -347,7 +360,7 @@ func getUserData(db *database.Database, inst *instance.Instance,
 			}
 		}
 
-		if deployUnit.Kind == deployment.Image {
+		if deply.Kind == deployment.Image {
 			deployScript = fmt.Sprintf(
 				deployScriptTmpl,
 				settings.Hypervisor.AgentGuestPath,
@@ -628,6 +641,7 @@ func Write(db *database.Database, inst *instance.Instance,
 	userPath := path.Join(tempDir, "user-data")
 	netPath := path.Join(tempDir, "network-config")
 	pciPath := path.Join(tempDir, "pci")
+	imdsPath := path.Join(tempDir, "imds.json")
 	initPath := paths.GetInitPath(inst.Id)
 
 	defer os.RemoveAll(tempDir)
@@ -646,18 +660,20 @@ func Write(db *database.Database, inst *instance.Instance,
 			return
 		}
 
-		servc, e := pod.Get(db, deply.Pod)
-		if e != nil {
-			err = e
-			return
-		}
+		if deply.Build.IsZero() {
+			servc, e := pod.Get(db, deply.Pod)
+			if e != nil {
+				err = e
+				return
+			}
 
-		deployUnit = servc.GetUnit(deply.Unit)
-		if deployUnit == nil {
-			err = &errortypes.NotFoundError{
-				errors.Newf("cloudinit: Pod unit not found"),
+			deployUnit = servc.GetUnit(deply.Unit)
+			if deployUnit == nil {
+				err = &errortypes.NotFoundError{
+					errors.Newf("cloudinit: Pod unit not found"),
+				}
+				return
 			}
-			return
 		}
 	}
 
@@ -704,6 +720,16 @@ func Write(db *database.Database, inst *instance.Instance,
 		return
 	}
 
+	imdsConf, err := getImdsConf(virt)
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(imdsPath, imdsConf, 0600)
+	if err != nil {
+		return
+	}
+
 	if virt.CloudType == instance.BSD {
 		err = utils.Exec("", "cp",
 			settings.Hypervisor.AgentBsdHostPath, pciPath)
@@ -731,7 +757,7 @@ func Write(db *database.Database, inst *instance.Instance,
 		args = append(args, "network-config")
 	}
 
-	args = append(args, pciPath)
+	args = append(args, pciPath, imdsPath)
 
 	_, err = utils.ExecCombinedOutputLoggedDir(
 		nil, tempDir,
@@ -13,6 +13,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/defaults"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/peer"
 	"github.com/pritunl/pritunl-cloud/router"
 	"github.com/pritunl/pritunl-cloud/setup"
 	"github.com/pritunl/pritunl-cloud/sync"
@@ -66,6 +67,14 @@ func Node(testing bool) (err error) {
 		}
 	}()
 
+	peerServer := &peer.Server{}
+	go func() {
+		e := peerServer.Run()
+		if e != nil && !constants.Shutdown {
+			panic(e)
+		}
+	}()
+
 	if testing {
 		time.Sleep(300 * time.Second)
 	} else {
@@ -78,6 +87,7 @@ func Node(testing bool) (err error) {
 
 	constants.Shutdown = true
 	go routr.Shutdown()
+	go peerServer.Shutdown()
 
 	if constants.Production {
 		//time.Sleep(20 * time.Second)
@@ -0,0 +1,172 @@
+package cron
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type Schedule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	dayStar  bool
+	weekStar bool
+}
+
+func parseField(field string, min, max int) (bits uint64, star bool,
+	err error) {
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		start := min
+		end := max
+
+		rangePart := part
+		if i := strings.Index(part, "/"); i != -1 {
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				err = &errortypes.ParseError{
+					errors.Newf("cron: Invalid step '%s'", part),
+				}
+				return
+			}
+			rangePart = part[:i]
+		}
+
+		if rangePart == "*" {
+			if step == 1 && len(field) == 1 {
+				star = true
+			}
+		} else {
+			bounds := strings.SplitN(rangePart, "-", 2)
+
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				err = &errortypes.ParseError{
+					errors.Newf("cron: Invalid value '%s'", part),
+				}
+				return
+			}
+
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					err = &errortypes.ParseError{
+						errors.Newf("cron: Invalid value '%s'", part),
+					}
+					return
+				}
+			} else if step == 1 {
+				end = start
+			}
+		}
+
+		if start < min || end > max || start > end {
+			err = &errortypes.ParseError{
+				errors.Newf("cron: Value out of range '%s'", part),
+			}
+			return
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return
+}
+
+func (s *Schedule) matchDay(t time.Time) bool {
+	day := s.days&(1<<uint(t.Day())) != 0
+	weekday := s.weekdays&(1<<uint(t.Weekday())) != 0
+
+	if s.dayStar || s.weekStar {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// Get the next matching minute after the time, returns zero time if no
+// match is found within five years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1,
+				0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if s.hours&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+
+		if s.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// Parse standard five field cron expression of minute, hour, day of
+// month, month and day of week evaluated in UTC.
+func Parse(expr string) (sched *Schedule, err error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		err = &errortypes.ParseError{
+			errors.Newf("cron: Invalid expression '%s'", expr),
+		}
+		return
+	}
+
+	sched = &Schedule{}
+
+	sched.minutes, _, err = parseField(fields[0], 0, 59)
+	if err != nil {
+		return
+	}
+
+	sched.hours, _, err = parseField(fields[1], 0, 23)
+	if err != nil {
+		return
+	}
+
+	sched.days, sched.dayStar, err = parseField(fields[2], 1, 31)
+	if err != nil {
+		return
+	}
+
+	sched.months, _, err = parseField(fields[3], 1, 12)
+	if err != nil {
+		return
+	}
+
+	sched.weekdays, sched.weekStar, err = parseField(fields[4], 0, 7)
+	if err != nil {
+		return
+	}
+
+	if sched.weekdays&(1<<7) != 0 {
+		sched.weekdays |= 1
+	}
+
+	return
+}
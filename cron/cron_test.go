@@ -0,0 +1,202 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func parseTime(t *testing.T, value string) time.Time {
+	tm, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+func TestParse(t *testing.T) {
+	valid := []string{
+		"* * * * *",
+		"0 0 1 1 0",
+		"59 23 31 12 7",
+		"*/15 * * * *",
+		"0 */6 * * 1-5",
+		"0,30 8-18/2 1,15 */3 *",
+		"  5   4  *  *  0 ",
+	}
+
+	for _, expr := range valid {
+		_, err := Parse(expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", expr, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-b * * * *",
+		"1,,2 * * * *",
+		"5 4 * * sun",
+	}
+
+	for _, expr := range invalid {
+		_, err := Parse(expr)
+		if err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		expr string
+		from string
+		next string
+	}{
+		{"* * * * *", "2026-03-10T10:15:30Z", "2026-03-10T10:16:00Z"},
+		{"*/15 * * * *", "2026-03-10T10:15:00Z", "2026-03-10T10:30:00Z"},
+		{"0 * * * *", "2026-03-10T10:15:00Z", "2026-03-10T11:00:00Z"},
+		{"30 2 * * *", "2026-03-10T02:30:00Z", "2026-03-11T02:30:00Z"},
+		{"0 0 1 * *", "2026-01-31T12:00:00Z", "2026-02-01T00:00:00Z"},
+		{"0 0 1 1 *", "2026-06-15T00:00:00Z", "2027-01-01T00:00:00Z"},
+		{"59 23 31 12 *", "2026-12-31T23:59:00Z", "2027-12-31T23:59:00Z"},
+		{"0 0 29 2 *", "2026-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"0 0 31 * *", "2026-04-01T00:00:00Z", "2026-05-31T00:00:00Z"},
+		{"0 12 * * 0", "2026-03-10T00:00:00Z", "2026-03-15T12:00:00Z"},
+		{"0 12 * * 7", "2026-03-10T00:00:00Z", "2026-03-15T12:00:00Z"},
+		{"0 9 * * 1-5", "2026-03-13T09:00:00Z", "2026-03-16T09:00:00Z"},
+		{"0 0 * */3 *", "2026-02-15T00:00:00Z", "2026-04-01T00:00:00Z"},
+		{"0,30 8-18/2 * * *", "2026-03-10T18:30:00Z",
+			"2026-03-11T08:00:00Z"},
+	}
+
+	for _, test := range tests {
+		sched, err := Parse(test.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.expr, err)
+			continue
+		}
+
+		next := sched.Next(parseTime(t, test.from))
+		expected := parseTime(t, test.next)
+		if !next.Equal(expected) {
+			t.Errorf("%s: next from %s %s != %s", test.expr, test.from,
+				next.Format(time.RFC3339), test.next)
+		}
+	}
+}
+
+func TestNextDayOr(t *testing.T) {
+	// When both day of month and day of week are restricted a day matches
+	// if either field matches, the 13th of March 2026 is a Friday.
+	sched, err := Parse("0 0 13 * 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"2026-03-06T00:00:00Z",
+		"2026-03-13T00:00:00Z",
+		"2026-03-20T00:00:00Z",
+		"2026-03-27T00:00:00Z",
+		"2026-04-03T00:00:00Z",
+		"2026-04-10T00:00:00Z",
+		"2026-04-13T00:00:00Z",
+		"2026-04-17T00:00:00Z",
+	}
+
+	cur := parseTime(t, "2026-03-01T00:00:00Z")
+	for _, value := range expected {
+		cur = sched.Next(cur)
+		if !cur.Equal(parseTime(t, value)) {
+			t.Errorf("or: next %s != %s", cur.Format(time.RFC3339), value)
+		}
+	}
+
+	// A star in either field restricts to the other field only.
+	tests := []struct {
+		expr string
+		next string
+	}{
+		{"0 0 13 * *", "2026-03-13T00:00:00Z"},
+		{"0 0 * * 5", "2026-03-06T00:00:00Z"},
+		{"0 0 */1 * 5", "2026-03-02T00:00:00Z"},
+	}
+
+	for _, test := range tests {
+		sched, err = Parse(test.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.expr, err)
+			continue
+		}
+
+		next := sched.Next(parseTime(t, "2026-03-01T00:00:00Z"))
+		if !next.Equal(parseTime(t, test.next)) {
+			t.Errorf("%s: next %s != %s", test.expr,
+				next.Format(time.RFC3339), test.next)
+		}
+	}
+}
+
+func TestNextDst(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data unavailable")
+	}
+
+	// Schedules are evaluated in UTC and are not shifted by local daylight
+	// saving transitions, on the 8th of March 2026 local clocks skip from
+	// 02:00 to 03:00 and on the 1st of November 2026 repeat 01:00.
+	sched, err := Parse("30 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, start := range []time.Time{
+		time.Date(2026, 3, 8, 0, 0, 0, 0, loc),
+		time.Date(2026, 11, 1, 0, 0, 0, 0, loc),
+	} {
+		prev := sched.Next(start)
+		if prev.Location() != time.UTC || prev.Minute() != 30 {
+			t.Errorf("dst: invalid next %s", prev)
+		}
+
+		for i := 0; i < 6; i++ {
+			next := sched.Next(prev)
+			if next.Sub(prev) != time.Hour {
+				t.Errorf("dst: next %s after %s is not one hour",
+					next.Format(time.RFC3339), prev.Format(time.RFC3339))
+			}
+			prev = next
+		}
+	}
+
+	sched, err = Parse("0 7 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := sched.Next(time.Date(2026, 3, 7, 12, 0, 0, 0, loc))
+	if !next.Equal(parseTime(t, "2026-03-08T07:00:00Z")) {
+		t.Errorf("dst: next %s != 2026-03-08T07:00:00Z",
+			next.Format(time.RFC3339))
+	}
+
+	next = sched.Next(next)
+	if !next.Equal(parseTime(t, "2026-03-09T07:00:00Z")) {
+		t.Errorf("dst: next %s != 2026-03-09T07:00:00Z",
+			next.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,97 @@
+package data
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/qmp"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/storage"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+// Get the image an incremental backup can be chained to, returns nil when
+// a full backup is required.
+func getBackupParent(db *database.Database, dsk *disk.Disk,
+	virt *vm.VirtualMachine, store *storage.Storage) (
+	parentImg *image.Image, err error) {
+
+	if virt == nil || !virt.Running() ||
+		settings.System.DiskBackupFullInterval <= 1 ||
+		dsk.BackupParent.IsZero() || dsk.BackupChainSize < 1 ||
+		dsk.BackupChainSize >= settings.System.DiskBackupFullInterval {
+
+		return
+	}
+
+	img, err := image.Get(db, dsk.BackupParent)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	if img.Disk != dsk.Id || img.Storage != store.Id {
+		return
+	}
+
+	exists, err := qmp.HasBackupBitmap(virt.Id, dsk)
+	if err != nil {
+		if _, ok := err.(*qmp.DiskNotFound); ok {
+			err = nil
+		}
+		return
+	}
+
+	if exists {
+		parentImg = img
+	}
+
+	return
+}
+
+// Get the backup chain ending with the image ordered from the full backup
+// to the image.
+func getBackupChain(db *database.Database, img *image.Image) (
+	chain []*image.Image, err error) {
+
+	chain = []*image.Image{img}
+	visited := set.NewSet(img.Id)
+
+	for !img.Parent.IsZero() {
+		if visited.Contains(img.Parent) {
+			err = &errortypes.VerificationError{
+				errors.New("data: Backup chain loop"),
+			}
+			return
+		}
+		visited.Add(img.Parent)
+
+		parentImg, e := image.Get(db, img.Parent)
+		if e != nil {
+			err = e
+			if _, ok := err.(*database.NotFoundError); ok {
+				err = &errortypes.NotFoundError{
+					errors.Wrap(err, "data: Backup chain parent missing"),
+				}
+			}
+			return
+		}
+
+		if parentImg.Disk != img.Disk {
+			err = &errortypes.VerificationError{
+				errors.New("data: Backup chain parent invalid"),
+			}
+			return
+		}
+
+		chain = append([]*image.Image{parentImg}, chain...)
+		img = parentImg
+	}
+
+	return
+}
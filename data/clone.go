@@ -0,0 +1,309 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/lock"
+	"github.com/pritunl/pritunl-cloud/lvm"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/pool"
+	"github.com/pritunl/pritunl-cloud/qmp"
+	"github.com/pritunl/pritunl-cloud/rbd"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/sirupsen/logrus"
+)
+
+func withLvmLock(db *database.Database, vgName, lvName string,
+	handler func() error) (err error) {
+
+	acquired, err := lock.LvmLock(db, vgName, lvName)
+	if err != nil {
+		return
+	}
+
+	if !acquired {
+		err = &errortypes.WriteError{
+			errors.New("data: Failed to acquire LVM lock"),
+		}
+		return
+	}
+	defer func() {
+		err2 := lock.LvmUnlock(db, vgName, lvName)
+		if err2 != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err2,
+			}).Error("data: Failed to unlock lvm")
+		}
+	}()
+
+	err = handler()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Read LVM disk to a qcow2 image, disks on thin pools are read from a
+// temporary thin snapshot to get a consistent copy while the disk is in use.
+func readDiskLvm(db *database.Database, dsk *disk.Disk,
+	dstPth string) (err error) {
+
+	pl, err := pool.Get(db, dsk.Pool)
+	if err != nil {
+		return
+	}
+
+	vgName := pl.VgName
+	lvName := dsk.Id.Hex()
+
+	err = withLvmLock(db, vgName, lvName, func() (err error) {
+		if !pl.IsThin() {
+			err = lvm.ActivateLv(vgName, lvName)
+			if err != nil {
+				return
+			}
+
+			err = lvm.ReadLv(vgName, lvName, dstPth)
+			if err != nil {
+				return
+			}
+
+			return
+		}
+
+		snapName := fmt.Sprintf("%s-snap", lvName)
+
+		err = lvm.SnapshotThinLv(vgName, lvName, snapName)
+		if err != nil {
+			return
+		}
+		defer func() {
+			err2 := lvm.RemoveLv(vgName, snapName)
+			if err2 != nil {
+				logrus.WithFields(logrus.Fields{
+					"disk_id": dsk.Id.Hex(),
+					"error":   err2,
+				}).Error("data: Failed to remove thin snapshot")
+			}
+		}()
+
+		err = lvm.ReadLv(vgName, snapName, dstPth)
+		if err != nil {
+			return
+		}
+
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func readDiskPool(db *database.Database, dsk *disk.Disk,
+	dstPth string) (err error) {
+
+	switch dsk.Type {
+	case disk.Lvm:
+		err = readDiskLvm(db, dsk, dstPth)
+		if err != nil {
+			return
+		}
+		break
+	case disk.Rbd:
+		err = readDiskRbd(db, dsk, dstPth)
+		if err != nil {
+			return
+		}
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.Newf("data: Unknown pool disk type %s", dsk.Type),
+		}
+		return
+	}
+
+	return
+}
+
+// Copy source disk to a qcow2 image, running disks are copied with a
+// point in time backup job and backing images are flattened. Pool disks are
+// read from a pool snapshot when available.
+func copySourceDisk(db *database.Database, srcDsk *disk.Disk,
+	virt *vm.VirtualMachine, dstPth string) (err error) {
+
+	if virt != nil && virt.Running() && !srcDsk.IsPooled() {
+		err = qmp.BackupDisk(virt.Id, srcDsk, dstPth)
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	switch srcDsk.Type {
+	case disk.Lvm, disk.Rbd:
+		err = readDiskPool(db, srcDsk, dstPth)
+		if err != nil {
+			return
+		}
+		break
+	case "", disk.Qcow2:
+		err = utils.Exec("", "qemu-img", "convert", "-f", "qcow2",
+			"-O", "qcow2", paths.GetDiskPath(srcDsk.Id), dstPth)
+		if err != nil {
+			return
+		}
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.Newf("data: Unknown disk type %s", srcDsk.Type),
+		}
+		return
+	}
+
+	return
+}
+
+// Write qcow2 source image to a new disk of size in gigabytes, the source
+// image is moved to the disk path for qcow2 disks.
+func writeDiskSource(db *database.Database, dsk *disk.Disk, srcPth string,
+	srcSize, size int) (err error) {
+
+	switch dsk.Type {
+	case disk.Lvm:
+		pl, e := pool.Get(db, dsk.Pool)
+		if e != nil {
+			err = e
+			return
+		}
+
+		vgName := pl.VgName
+		lvName := dsk.Id.Hex()
+
+		err = withLvmLock(db, vgName, lvName, func() (err error) {
+			err = lvm.CreatePoolLv(pl, lvName, size)
+			if err != nil {
+				return
+			}
+			defer func() {
+				if err == nil {
+					return
+				}
+
+				err2 := lvm.RemoveLv(vgName, lvName)
+				if err2 != nil {
+					logrus.WithFields(logrus.Fields{
+						"disk_id": dsk.Id.Hex(),
+						"error":   err2,
+					}).Error("data: Failed to remove partial disk lv")
+				}
+			}()
+
+			err = lvm.ActivateLv(vgName, lvName)
+			if err != nil {
+				return
+			}
+
+			err = lvm.WriteLv(vgName, lvName, srcPth)
+			if err != nil {
+				return
+			}
+
+			return
+		})
+		if err != nil {
+			return
+		}
+		break
+	case disk.Rbd:
+		pl, e := pool.Get(db, dsk.Pool)
+		if e != nil {
+			err = e
+			return
+		}
+
+		err = writeDiskRbd(db, dsk, pl, srcPth, size)
+		if err != nil {
+			err2 := rbd.RemoveImage(pl, dsk.Id.Hex())
+			if err2 != nil {
+				logrus.WithFields(logrus.Fields{
+					"disk_id": dsk.Id.Hex(),
+					"error":   err2,
+				}).Error("data: Failed to remove partial disk image")
+			}
+			return
+		}
+		break
+	case "", disk.Qcow2:
+		diskPath := paths.GetDiskPath(dsk.Id)
+
+		if size > srcSize {
+			err = utils.Exec("", "qemu-img", "resize",
+				srcPth, fmt.Sprintf("%dG", size))
+			if err != nil {
+				return
+			}
+		}
+
+		err = utils.Exec("", "mv", "-f", srcPth, diskPath)
+		if err != nil {
+			return
+		}
+
+		err = utils.Chmod(diskPath, 0600)
+		if err != nil {
+			return
+		}
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.Newf("data: Unknown disk type %s", dsk.Type),
+		}
+		return
+	}
+
+	return
+}
+
+// Provision disk as a copy of the source disk, the source disk can be
+// qcow2 or a pool disk and the copy can be on a different pool or disk
+// type. Only full copies are supported, qcow2 backing files and LVM
+// snapshots are not used so the clone does not depend on the source disk,
+// which can be deleted or modified after the clone completes.
+func CloneDisk(db *database.Database, dsk, srcDsk *disk.Disk,
+	virt *vm.VirtualMachine) (newSize int, err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"disk_id":        dsk.Id.Hex(),
+		"source_disk_id": srcDsk.Id.Hex(),
+		"disk_type":      dsk.Type,
+		"source_type":    srcDsk.Type,
+	}).Info("data: Cloning disk")
+
+	tmpPth := paths.GetDiskTempPath()
+	defer utils.Remove(tmpPth)
+
+	err = copySourceDisk(db, srcDsk, virt, tmpPth)
+	if err != nil {
+		return
+	}
+
+	size := utils.Max(dsk.Size, srcDsk.Size)
+
+	err = writeDiskSource(db, dsk, tmpPth, srcDsk.Size, size)
+	if err != nil {
+		return
+	}
+
+	newSize = size
+
+	return
+}
@@ -63,7 +63,7 @@ func createDiskLvm(db *database.Database, dsk *disk.Disk) (
 			return
 		}
 	} else {
-		err = lvm.CreateLv(pl.VgName, dsk.Id.Hex(), dsk.Size)
+		err = lvm.CreatePoolLv(pl, dsk.Id.Hex(), dsk.Size)
 		if err != nil {
 			return
 		}
@@ -75,6 +75,14 @@ func createDiskLvm(db *database.Database, dsk *disk.Disk) (
 func CreateDisk(db *database.Database, dsk *disk.Disk) (
 	newSize int, backingImage string, err error) {
 
+	if dsk.Import != nil {
+		newSize, err = ImportDisk(db, dsk)
+		if err != nil {
+			return
+		}
+		return
+	}
+
 	switch dsk.Type {
 	case disk.Lvm:
 		newSize, err = createDiskLvm(db, dsk)
@@ -82,6 +90,12 @@ func CreateDisk(db *database.Database, dsk *disk.Disk) (
 			return
 		}
 		break
+	case disk.Rbd:
+		newSize, err = createDiskRbd(db, dsk)
+		if err != nil {
+			return
+		}
+		break
 	case "", disk.Qcow2:
 		newSize, backingImage, err = createDiskQcow(db, dsk)
 		if err != nil {
@@ -0,0 +1,399 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/organization"
+	"github.com/pritunl/pritunl-cloud/secret"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	encryptionMagic     = "PCLDENC1"
+	encryptionChunkSize = 1048576
+	encryptionFinal     = 0x80000000
+)
+
+func getEncryptionCipher(key []byte) (gcm cipher.AEAD, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "data: Failed to load cipher"),
+		}
+		return
+	}
+
+	gcm, err = cipher.NewGCM(block)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "data: Failed to load gcm cipher"),
+		}
+		return
+	}
+
+	return
+}
+
+func encryptionNonce(prefix []byte, counter uint64) (nonce []byte) {
+	nonce = make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint64(nonce[4:], counter)
+	return
+}
+
+// Encrypt file with AES-GCM in authenticated chunks, the final chunk is
+// marked to detect truncation.
+func encryptFile(srcPth, dstPth string, key []byte) (err error) {
+	gcm, err := getEncryptionCipher(key)
+	if err != nil {
+		return
+	}
+
+	src, err := os.Open(srcPth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to open file"),
+		}
+		return
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(dstPth, os.O_CREATE|os.O_TRUNC|os.O_WRONLY,
+		0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to create file"),
+		}
+		return
+	}
+	defer dst.Close()
+
+	writer := bufio.NewWriter(dst)
+
+	prefix := make([]byte, 4)
+	_, err = rand.Read(prefix)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to generate nonce"),
+		}
+		return
+	}
+
+	_, err = writer.WriteString(encryptionMagic)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to write file"),
+		}
+		return
+	}
+	_, err = writer.Write(prefix)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to write file"),
+		}
+		return
+	}
+
+	reader := bufio.NewReaderSize(src, encryptionChunkSize)
+	chunk := make([]byte, encryptionChunkSize)
+	header := make([]byte, 4)
+	counter := uint64(0)
+
+	for {
+		n, e := io.ReadFull(reader, chunk)
+		if e != nil && e != io.EOF && e != io.ErrUnexpectedEOF {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "data: Failed to read file"),
+			}
+			return
+		}
+
+		final := false
+		if n < encryptionChunkSize {
+			final = true
+		} else {
+			_, e = reader.Peek(1)
+			if e == io.EOF {
+				final = true
+			} else if e != nil {
+				err = &errortypes.ReadError{
+					errors.Wrap(e, "data: Failed to read file"),
+				}
+				return
+			}
+		}
+
+		length := uint32(n)
+		aad := []byte{0}
+		if final {
+			length |= encryptionFinal
+			aad[0] = 1
+		}
+		binary.BigEndian.PutUint32(header, length)
+
+		ciphertext := gcm.Seal(nil, encryptionNonce(prefix, counter),
+			chunk[:n], aad)
+		counter += 1
+
+		_, err = writer.Write(header)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "data: Failed to write file"),
+			}
+			return
+		}
+		_, err = writer.Write(ciphertext)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "data: Failed to write file"),
+			}
+			return
+		}
+
+		if final {
+			break
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to write file"),
+		}
+		return
+	}
+
+	return
+}
+
+func decryptFile(srcPth, dstPth string, key []byte) (err error) {
+	gcm, err := getEncryptionCipher(key)
+	if err != nil {
+		return
+	}
+
+	src, err := os.Open(srcPth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to open file"),
+		}
+		return
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(dstPth, os.O_CREATE|os.O_TRUNC|os.O_WRONLY,
+		0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to create file"),
+		}
+		return
+	}
+	defer dst.Close()
+
+	reader := bufio.NewReaderSize(src, encryptionChunkSize)
+	writer := bufio.NewWriter(dst)
+
+	magic := make([]byte, len(encryptionMagic)+4)
+	_, err = io.ReadFull(reader, magic)
+	if err != nil || !bytes.Equal(
+		magic[:len(encryptionMagic)], []byte(encryptionMagic)) {
+
+		err = &errortypes.ParseError{
+			errors.New("data: Invalid encrypted file header"),
+		}
+		return
+	}
+	prefix := magic[len(encryptionMagic):]
+
+	header := make([]byte, 4)
+	chunk := make([]byte, encryptionChunkSize+gcm.Overhead())
+	counter := uint64(0)
+
+	for {
+		_, err = io.ReadFull(reader, header)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "data: Encrypted file truncated"),
+			}
+			return
+		}
+
+		length := binary.BigEndian.Uint32(header)
+		final := length&encryptionFinal != 0
+		length &^= encryptionFinal
+
+		if length > encryptionChunkSize {
+			err = &errortypes.ParseError{
+				errors.New("data: Invalid encrypted chunk length"),
+			}
+			return
+		}
+
+		ciphertext := chunk[:int(length)+gcm.Overhead()]
+		_, err = io.ReadFull(reader, ciphertext)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "data: Encrypted file truncated"),
+			}
+			return
+		}
+
+		aad := []byte{0}
+		if final {
+			aad[0] = 1
+		}
+
+		plaintext, e := gcm.Open(ciphertext[:0],
+			encryptionNonce(prefix, counter), ciphertext, aad)
+		if e != nil {
+			err = &errortypes.VerificationError{
+				errors.Wrap(e, "data: Failed to authenticate chunk"),
+			}
+			return
+		}
+		counter += 1
+
+		_, err = writer.Write(plaintext)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "data: Failed to write file"),
+			}
+			return
+		}
+
+		if final {
+			break
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to write file"),
+		}
+		return
+	}
+
+	return
+}
+
+// Encrypt image file in place with a new data key wrapped by the
+// organization encryption secret, no changes are made if the organization
+// does not have an encryption secret.
+func encryptImage(db *database.Database, img *image.Image,
+	pth string) (err error) {
+
+	if img.Organization.IsZero() {
+		return
+	}
+
+	org, err := organization.Get(db, img.Organization)
+	if err != nil {
+		return
+	}
+
+	if org.Encryption.IsZero() {
+		return
+	}
+
+	secr, err := secret.GetOrg(db, org.Id, org.Encryption)
+	if err != nil {
+		return
+	}
+
+	if secr.Type != secret.Encryption {
+		err = &errortypes.VerificationError{
+			errors.New("data: Invalid organization encryption secret"),
+		}
+		return
+	}
+
+	dataKey := make([]byte, 32)
+	_, err = rand.Read(dataKey)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to generate data key"),
+		}
+		return
+	}
+
+	wrapped, err := secr.WrapKey(dataKey)
+	if err != nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"image_id":  img.Id.Hex(),
+		"secret_id": secr.Id.Hex(),
+	}).Info("data: Encrypting image")
+
+	encPth := pth + ".enc"
+	defer utils.Remove(encPth)
+
+	err = encryptFile(pth, encPth, dataKey)
+	if err != nil {
+		return
+	}
+
+	err = utils.Exec("", "mv", "-f", encPth, pth)
+	if err != nil {
+		return
+	}
+
+	img.Encryption = secr.Id
+	img.DataKey = wrapped
+
+	return
+}
+
+// Decrypt image file in place if the image is encrypted.
+func decryptImage(db *database.Database, img *image.Image,
+	pth string) (err error) {
+
+	if img.Encryption.IsZero() {
+		return
+	}
+
+	secr, err := secret.GetOrg(db, img.Organization, img.Encryption)
+	if err != nil {
+		return
+	}
+
+	dataKey, err := secr.UnwrapKey(img.DataKey)
+	if err != nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"image_id":  img.Id.Hex(),
+		"secret_id": secr.Id.Hex(),
+	}).Info("data: Decrypting image")
+
+	decPth := pth + ".dec"
+	defer utils.Remove(decPth)
+
+	err = decryptFile(pth, decPth, dataKey)
+	if err != nil {
+		return
+	}
+
+	err = utils.Exec("", "mv", "-f", decPth, pth)
+	if err != nil {
+		return
+	}
+
+	return
+}
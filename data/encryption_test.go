@@ -0,0 +1,168 @@
+package data
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func encryptionTestKey(t *testing.T) (key []byte) {
+	key = make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func encryptionTestFile(t *testing.T, dir, name string,
+	size int) (pth string, data []byte) {
+
+	data = make([]byte, size)
+	_, err := rand.Read(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pth = filepath.Join(dir, name)
+	err = os.WriteFile(pth, data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+func TestEncryptFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	key := encryptionTestKey(t)
+
+	sizes := []int{
+		0,
+		1,
+		encryptionChunkSize - 1,
+		encryptionChunkSize,
+		encryptionChunkSize + 1,
+		3*encryptionChunkSize + 517,
+	}
+
+	for _, size := range sizes {
+		srcPth, data := encryptionTestFile(t, dir, "plain", size)
+		encPth := filepath.Join(dir, "enc")
+		decPth := filepath.Join(dir, "dec")
+
+		err := encryptFile(srcPth, encPth, key)
+		if err != nil {
+			t.Fatalf("size %d: encrypt failed %s", size, err)
+		}
+
+		encData, err := os.ReadFile(encPth)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if size > 0 && bytes.Contains(encData, data) {
+			t.Errorf("size %d: plaintext found in encrypted file", size)
+		}
+
+		err = decryptFile(encPth, decPth, key)
+		if err != nil {
+			t.Fatalf("size %d: decrypt failed %s", size, err)
+		}
+
+		decData, err := os.ReadFile(decPth)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(data, decData) {
+			t.Errorf("size %d: decrypted data mismatch", size)
+		}
+	}
+}
+
+func TestDecryptFileInvalid(t *testing.T) {
+	dir := t.TempDir()
+	key := encryptionTestKey(t)
+
+	srcPth, _ := encryptionTestFile(t, dir, "plain",
+		2*encryptionChunkSize+100)
+	encPth := filepath.Join(dir, "enc")
+
+	err := encryptFile(srcPth, encPth, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encData, err := os.ReadFile(encPth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headerLen := len(encryptionMagic) + 4
+	chunkLen := 4 + encryptionChunkSize + 16
+
+	tamper := func(pos int) []byte {
+		data := append([]byte{}, encData...)
+		data[pos] ^= 0x01
+		return data
+	}
+
+	swapped := append([]byte{}, encData[:headerLen]...)
+	swapped = append(swapped,
+		encData[headerLen+chunkLen:headerLen+2*chunkLen]...)
+	swapped = append(swapped, encData[headerLen:headerLen+chunkLen]...)
+	swapped = append(swapped, encData[headerLen+2*chunkLen:]...)
+
+	unfinal := append([]byte{}, encData...)
+	finalPos := headerLen + 2*chunkLen
+	binary.BigEndian.PutUint32(unfinal[finalPos:],
+		binary.BigEndian.Uint32(unfinal[finalPos:])&^encryptionFinal)
+
+	final := append([]byte{}, encData...)
+	binary.BigEndian.PutUint32(final[headerLen:],
+		binary.BigEndian.Uint32(final[headerLen:])|encryptionFinal)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"magic", tamper(0)},
+		{"header", encData[:headerLen-1]},
+		{"nonce", tamper(len(encryptionMagic))},
+		{"first_chunk", tamper(headerLen + 4 + 10)},
+		{"last_chunk", tamper(len(encData) - 1)},
+		{"length", tamper(headerLen + 1)},
+		{"swapped", swapped},
+		{"unfinal", unfinal},
+		{"early_final", final},
+		{"truncated_boundary", encData[:headerLen+chunkLen]},
+		{"truncated_chunk", encData[:headerLen+chunkLen+100]},
+		{"truncated_tag", encData[:len(encData)-1]},
+	}
+
+	for _, test := range tests {
+		badPth := filepath.Join(dir, "bad")
+		decPth := filepath.Join(dir, "dec")
+
+		err = os.WriteFile(badPth, test.data, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = decryptFile(badPth, decPth, key)
+		if err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+
+	err = decryptFile(encPth, filepath.Join(dir, "dec"),
+		encryptionTestKey(t))
+	if err == nil {
+		t.Error("key: expected error")
+	}
+}
@@ -31,8 +31,10 @@ import (
 	"github.com/pritunl/pritunl-cloud/lvm"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/peer"
 	"github.com/pritunl/pritunl-cloud/pool"
 	"github.com/pritunl/pritunl-cloud/qmp"
+	"github.com/pritunl/pritunl-cloud/secret"
 	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/utils"
@@ -222,6 +224,131 @@ func getImageWeb(db *database.Database, store *storage.Storage,
 	return
 }
 
+// Get the keyring used to verify an image. Private images of organizations
+// with signing key secrets are verified against the organization keys.
+func getImageKeyring(db *database.Database, img *image.Image) (
+	keyring openpgp.EntityList, keyIds map[uint64]primitive.ObjectID,
+	err error) {
+
+	keyIds = map[uint64]primitive.ObjectID{}
+
+	if !img.Organization.IsZero() && !img.IsSystem() {
+		secrs, e := secret.GetAllSigningOrg(db, img.Organization)
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, secr := range secrs {
+			secrKeyring, e := secr.GetKeyring()
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"secret_id": secr.Id.Hex(),
+					"error":     e,
+				}).Error("data: Failed to parse organization signing key")
+				continue
+			}
+
+			for _, entity := range secrKeyring {
+				keyIds[entity.PrimaryKey.KeyId] = secr.Id
+			}
+			keyring = append(keyring, secrKeyring...)
+		}
+
+		if len(keyring) > 0 {
+			return
+		}
+
+		if len(secrs) > 0 {
+			err = &errortypes.ParseError{
+				errors.New("data: Failed to parse all organization " +
+					"signing keys"),
+			}
+			return
+		}
+	}
+
+	keyring, err = openpgp.ReadArmoredKeyRing(
+		strings.NewReader(constants.PritunlKeyring))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "data: Failed to parse Pritunl keyring"),
+		}
+		return
+	}
+
+	return
+}
+
+func setImageSignature(db *database.Database, img *image.Image,
+	state string, keyId primitive.ObjectID, fingerprint,
+	message string) (err error) {
+
+	img.Signature = &image.Signature{
+		State:       state,
+		Etag:        img.Etag,
+		Key:         keyId,
+		Fingerprint: fingerprint,
+		Message:     message,
+		Timestamp:   time.Now(),
+	}
+
+	err = img.CommitFields(db, set.NewSet("signature"))
+	if err != nil {
+		return
+	}
+
+	event.PublishDispatch(db, "image.change")
+
+	return
+}
+
+func verifyImageSig(db *database.Database, store *storage.Storage,
+	img *image.Image, tmpImg io.Reader, signature io.Reader) (err error) {
+
+	keyring, keyIds, err := getImageKeyring(db, img)
+	if err != nil {
+		return
+	}
+
+	entity, e := openpgp.CheckArmoredDetachedSignature(
+		keyring, tmpImg, signature)
+	if e != nil || entity == nil {
+		message := "Signature does not match signing keys"
+		if e != nil {
+			message = e.Error()
+		}
+
+		err = setImageSignature(db, img, image.SignatureInvalid,
+			primitive.NilObjectID, "", message)
+		if err != nil {
+			return
+		}
+
+		err = &errortypes.VerificationError{
+			errors.Wrap(e, "data: Image signature verification failed"),
+		}
+		return
+	}
+
+	fingerprint := fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+
+	err = setImageSignature(db, img, image.SignatureValid,
+		keyIds[entity.PrimaryKey.KeyId], fingerprint, "")
+	if err != nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"id":          img.Id.Hex(),
+		"storage_id":  store.Id.Hex(),
+		"key":         img.Key,
+		"fingerprint": fingerprint,
+	}).Info("data: Image signature successfully validated")
+
+	return
+}
+
 func checkImageSigS3(db *database.Database, store *storage.Storage,
 	img *image.Image, tmpPth string) (err error) {
 
@@ -270,30 +397,11 @@ func checkImageSigS3(db *database.Database, store *storage.Storage,
 	}
 	defer tmpImg.Close()
 
-	keyring, err := openpgp.ReadArmoredKeyRing(
-		strings.NewReader(constants.PritunlKeyring))
+	err = verifyImageSig(db, store, img, tmpImg, signature)
 	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "data: Failed to parse Pritunl keyring"),
-		}
 		return
 	}
 
-	entity, err := openpgp.CheckArmoredDetachedSignature(
-		keyring, tmpImg, signature)
-	if err != nil || entity == nil {
-		err = &errortypes.VerificationError{
-			errors.Wrap(err, "data: Image signature verification failed"),
-		}
-		return
-	}
-
-	logrus.WithFields(logrus.Fields{
-		"id":         img.Id.Hex(),
-		"storage_id": store.Id.Hex(),
-		"key":        img.Key,
-	}).Info("data: Image signature successfully validated")
-
 	return
 }
 
@@ -344,62 +452,107 @@ func checkImageSigWeb(db *database.Database, store *storage.Storage,
 	}
 	defer tmpImg.Close()
 
-	keyring, err := openpgp.ReadArmoredKeyRing(
-		strings.NewReader(constants.PritunlKeyring))
+	err = verifyImageSig(db, store, img, tmpImg, resp.Body)
 	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "data: Failed to parse Pritunl keyring"),
-		}
 		return
 	}
 
-	entity, err := openpgp.CheckArmoredDetachedSignature(
-		keyring, tmpImg, resp.Body)
-	if err != nil || entity == nil {
-		err = &errortypes.VerificationError{
-			errors.Wrap(err, "data: Image signature verification failed"),
-		}
+	return
+}
+
+func getImagePeer(db *database.Database, img *image.Image) (
+	tmpPth, hash string, err error) {
+
+	if settings.Hypervisor.NoImagePeer {
 		return
 	}
 
-	logrus.WithFields(logrus.Fields{
-		"id":         img.Id.Hex(),
-		"storage_id": store.Id.Hex(),
-		"key":        img.Key,
-	}).Info("data: Image signature successfully validated")
+	caches, err := image.GetCaches(db, img, node.Self.Zone, node.Self.Id)
+	if err != nil {
+		return
+	}
 
-	return
-}
+	attempts := 0
+	for _, cache := range caches {
+		if attempts >= settings.Hypervisor.ImagePeerMax {
+			break
+		}
 
-func getImage(db *database.Database, img *image.Image,
-	pth string) (err error) {
+		nde, e := node.Get(db, cache.Node)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				_ = image.RemoveCache(db, cache.Image, cache.Node)
+				continue
+			}
+			err = e
+			return
+		}
+
+		if !nde.IsOnline() || !nde.IsHypervisor() {
+			continue
+		}
+		attempts += 1
 
-	if imageLock.Locked(pth) {
 		logrus.WithFields(logrus.Fields{
 			"image_id": img.Id.Hex(),
 			"key":      img.Key,
-			"path":     pth,
-		}).Info("data: Waiting for image")
-	}
+			"peer_id":  nde.Id.Hex(),
+			"hash":     cache.Hash,
+		}).Info("data: Downloading image from peer")
 
-	lockId := imageLock.Lock(pth)
-	defer imageLock.Unlock(pth, lockId)
+		pth := paths.GetImageTempPath()
 
-	exists, err := utils.Exists(pth)
-	if err != nil {
-		return
-	}
+		e = peer.GetImage(nde, img, cache, pth)
+		if e != nil {
+			os.Remove(pth)
 
-	if exists {
+			logrus.WithFields(logrus.Fields{
+				"image_id": img.Id.Hex(),
+				"key":      img.Key,
+				"peer_id":  nde.Id.Hex(),
+				"error":    e,
+			}).Warn("data: Failed to download image from peer")
+			continue
+		}
+
+		tmpPth = pth
+		hash = cache.Hash
 		return
 	}
 
+	return
+}
+
+func getImageStorage(db *database.Database, img *image.Image) (
+	tmpPth string, err error) {
+
 	store, err := storage.Get(db, img.Storage)
 	if err != nil {
 		return
 	}
 
-	tmpPth := ""
+	if !img.Signed && store.Endpoint != "images.pritunl.com" {
+		required, e := img.IsSignatureRequired(db)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if required {
+			err = setImageSignature(db, img, image.SignatureMissing,
+				primitive.NilObjectID, "",
+				"Organization requires signed images")
+			if err != nil {
+				return
+			}
+
+			err = &errortypes.VerificationError{
+				errors.New("data: Organization requires signed image"),
+			}
+			return
+		}
+	}
+
 	if img.Type == storage.Web {
 		tmpPth, err = getImageWeb(db, store, img)
 		if err != nil {
@@ -438,19 +591,205 @@ func getImage(db *database.Database, img *image.Image,
 		}
 	}
 
+	err = decryptImage(db, img, tmpPth)
+	if err != nil {
+		if tmpPth != "" {
+			os.Remove(tmpPth)
+		}
+		return
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"image_id":   img.Id.Hex(),
 		"storage_id": store.Id.Hex(),
 		"key":        img.Key,
 		"temp_path":  tmpPth,
-		"path":       pth,
 	}).Info("data: Downloaded image")
 
+	return
+}
+
+// Record the cached image of this node to allow peers in the same zone to
+// transfer the image. The hash is calculated when not provided.
+func updateImageCache(db *database.Database, img *image.Image,
+	pth, hash string) (err error) {
+
+	if hash == "" {
+		cache, e := image.GetCacheNode(db, img.Id, node.Self.Id)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); !ok {
+				err = e
+				return
+			}
+			cache = nil
+		}
+
+		if cache != nil && cache.Etag == img.Etag && cache.Hash != "" {
+			return
+		}
+
+		hash, err = utils.Sha256File(pth)
+		if err != nil {
+			return
+		}
+	}
+
+	stat, err := os.Stat(pth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to stat cached image"),
+		}
+		return
+	}
+
+	cache := &image.Cache{
+		Image:     img.Id,
+		Node:      node.Self.Id,
+		Zone:      node.Self.Zone,
+		Etag:      img.Etag,
+		Hash:      hash,
+		Size:      stat.Size(),
+		Timestamp: time.Now(),
+	}
+
+	err = cache.Upsert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Get image to path, cached images are first requested from peer nodes in
+// the same zone before falling back to the image storage.
+// Check that a peer or cached copy of an image can be used without
+// verification, images of organizations that require signed images must
+// have a valid signature for the current etag from a current signing key.
+func imageSignatureTrusted(db *database.Database, img *image.Image) (
+	trusted bool, err error) {
+
+	required, err := img.IsSignatureRequired(db)
+	if err != nil {
+		return
+	}
+
+	if !required {
+		trusted = true
+		return
+	}
+
+	if img.GetSignatureState() != image.SignatureValid {
+		return
+	}
+
+	secrs, err := secret.GetAllSigningOrg(db, img.Organization)
+	if err != nil {
+		return
+	}
+
+	for _, secr := range secrs {
+		if secr.Id == img.Signature.Key {
+			trusted = true
+			return
+		}
+	}
+
+	return
+}
+
+func getImage(db *database.Database, img *image.Image,
+	pth string, cache bool) (err error) {
+
+	if imageLock.Locked(pth) {
+		logrus.WithFields(logrus.Fields{
+			"image_id": img.Id.Hex(),
+			"key":      img.Key,
+			"path":     pth,
+		}).Info("data: Waiting for image")
+	}
+
+	lockId := imageLock.Lock(pth)
+	defer imageLock.Unlock(pth, lockId)
+
+	trusted, err := imageSignatureTrusted(db, img)
+	if err != nil {
+		return
+	}
+
+	exists, err := utils.Exists(pth)
+	if err != nil {
+		return
+	}
+
+	if exists && !trusted {
+		logrus.WithFields(logrus.Fields{
+			"image_id": img.Id.Hex(),
+			"path":     pth,
+		}).Warn("data: Removing cached image without valid signature")
+
+		err = os.Remove(pth)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "data: Failed to remove cached image"),
+			}
+			return
+		}
+		exists = false
+	}
+
+	if exists {
+		if cache {
+			err = updateImageCache(db, img, pth, "")
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"image_id": img.Id.Hex(),
+					"path":     pth,
+					"error":    err,
+				}).Warn("data: Failed to update image cache")
+				err = nil
+			}
+		}
+		return
+	}
+
+	tmpPth := ""
+	hash := ""
+	if cache && trusted {
+		tmpPth, hash, err = getImagePeer(db, img)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"image_id": img.Id.Hex(),
+				"key":      img.Key,
+				"error":    err,
+			}).Warn("data: Failed to get image from peers")
+			err = nil
+		}
+	}
+
+	if tmpPth == "" {
+		tmpPth, err = getImageStorage(db, img)
+		if err != nil {
+			return
+		}
+	}
+
 	err = utils.Exec("", "mv", tmpPth, pth)
 	if err != nil {
 		return
 	}
 
+	if cache {
+		err = updateImageCache(db, img, pth, hash)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"image_id": img.Id.Hex(),
+				"path":     pth,
+				"error":    err,
+			}).Warn("data: Failed to update image cache")
+			err = nil
+		}
+	}
+
 	return
 }
 
@@ -607,7 +946,7 @@ func writeImageQcow(db *database.Database, dsk *disk.Disk) (
 		}
 
 		if !backingImageExists {
-			err = getImage(db, img, imagePth)
+			err = getImage(db, img, imagePth, true)
 			if err != nil {
 				return
 			}
@@ -696,12 +1035,12 @@ func writeImageQcow(db *database.Database, dsk *disk.Disk) (
 		}
 	} else {
 		if dsk.Backing {
-			err = getImage(db, img, backingImagePth)
+			err = getImage(db, img, backingImagePth, false)
 			if err != nil {
 				return
 			}
 		} else {
-			err = getImage(db, img, diskTempPath)
+			err = getImage(db, img, diskTempPath, false)
 			if err != nil {
 				return
 			}
@@ -806,7 +1145,7 @@ func writeFsLvm(db *database.Database, dsk *disk.Disk,
 		}
 	}()
 
-	err = lvm.CreateLv(vgName, lvName, size)
+	err = lvm.CreatePoolLv(pl, lvName, size)
 	if err != nil {
 		return
 	}
@@ -844,19 +1183,17 @@ func writeFsLvm(db *database.Database, dsk *disk.Disk,
 	return
 }
 
-func writeImageLvm(db *database.Database, dsk *disk.Disk,
-	pl *pool.Pool) (newSize int, err error) {
+// Get source image of a pool disk, public images are cached on the node and
+// private images are downloaded to the temporary path. The disk size is
+// raised to the minimum size of the image.
+func getPoolImage(db *database.Database, dsk *disk.Disk, tmpPth string) (
+	sourcePth string, size, newSize int, err error) {
 
-	size := dsk.Size
-	vgName := pl.VgName
-	lvName := dsk.Id.Hex()
-	sourcePth := ""
-	diskTempPath := paths.GetDiskTempPath()
-	defer utils.Remove(diskTempPath)
+	size = dsk.Size
 
 	if dsk.Backing {
 		err = &errortypes.ParseError{
-			errors.New("data: Cannot create LVM disk with linked image"),
+			errors.New("data: Cannot create pool disk with linked image"),
 		}
 		return
 	}
@@ -881,7 +1218,7 @@ func writeImageLvm(db *database.Database, dsk *disk.Disk,
 			return
 		}
 
-		err = getImage(db, img, imagePth)
+		err = getImage(db, img, imagePth, true)
 		if err != nil {
 			return
 		}
@@ -896,12 +1233,12 @@ func writeImageLvm(db *database.Database, dsk *disk.Disk,
 			newSize = 10
 		}
 	} else {
-		err = getImage(db, img, diskTempPath)
+		err = getImage(db, img, tmpPth, false)
 		if err != nil {
 			return
 		}
 
-		sourcePth = diskTempPath
+		sourcePth = tmpPth
 
 		if largeBase && size < 16 {
 			size = 16
@@ -909,6 +1246,22 @@ func writeImageLvm(db *database.Database, dsk *disk.Disk,
 		}
 	}
 
+	return
+}
+
+func writeImageLvm(db *database.Database, dsk *disk.Disk,
+	pl *pool.Pool) (newSize int, err error) {
+
+	vgName := pl.VgName
+	lvName := dsk.Id.Hex()
+	diskTempPath := paths.GetDiskTempPath()
+	defer utils.Remove(diskTempPath)
+
+	sourcePth, size, newSize, err := getPoolImage(db, dsk, diskTempPath)
+	if err != nil {
+		return
+	}
+
 	acquired, err := lock.LvmLock(db, vgName, lvName)
 	if err != nil {
 		return
@@ -929,7 +1282,7 @@ func writeImageLvm(db *database.Database, dsk *disk.Disk,
 		}
 	}()
 
-	err = lvm.CreateLv(vgName, lvName, size)
+	err = lvm.CreatePoolLv(pl, lvName, size)
 	if err != nil {
 		return
 	}
@@ -947,8 +1300,28 @@ func writeImageLvm(db *database.Database, dsk *disk.Disk,
 	return
 }
 
+// Check that no incremental backups depend on the image.
+func checkImageChildren(db *database.Database, img *image.Image) (
+	errData *errortypes.ErrorData, err error) {
+
+	exists, err := image.ExistsParent(db, img.Id)
+	if err != nil {
+		return
+	}
+
+	if exists {
+		errData = &errortypes.ErrorData{
+			Error:   "image_has_children",
+			Message: "Cannot delete backup required by incremental backups",
+		}
+		return
+	}
+
+	return
+}
+
 func DeleteImage(db *database.Database, imgId primitive.ObjectID) (
-	err error) {
+	errData *errortypes.ErrorData, err error) {
 
 	img, err := image.Get(db, imgId)
 	if err != nil {
@@ -959,6 +1332,11 @@ func DeleteImage(db *database.Database, imgId primitive.ObjectID) (
 		return
 	}
 
+	errData, err = checkImageChildren(db, img)
+	if err != nil || errData != nil {
+		return
+	}
+
 	store, err := storage.Get(db, img.Storage)
 	if err != nil {
 		return
@@ -1005,6 +1383,18 @@ func WriteImage(db *database.Database, dsk *disk.Disk) (
 			return
 		}
 		break
+	case disk.Rbd:
+		pl, e := pool.Get(db, dsk.Pool)
+		if e != nil {
+			err = e
+			return
+		}
+
+		newSize, err = writeImageRbd(db, dsk, pl)
+		if err != nil {
+			return
+		}
+		break
 	case "", disk.Qcow2:
 		newSize, backingImageName, err = writeImageQcow(db, dsk)
 		if err != nil {
@@ -1021,21 +1411,38 @@ func WriteImage(db *database.Database, dsk *disk.Disk) (
 	return
 }
 
+// Delete images, images with incremental backups depending on them are
+// retried after the other images to allow deleting a chain.
 func DeleteImages(db *database.Database, imgIds []primitive.ObjectID) (
-	err error) {
+	errData *errortypes.ErrorData, err error) {
 
-	for _, imgId := range imgIds {
-		err = DeleteImage(db, imgId)
-		if err != nil {
+	for len(imgIds) > 0 {
+		remaining := []primitive.ObjectID{}
+
+		for _, imgId := range imgIds {
+			errData, err = DeleteImage(db, imgId)
+			if err != nil {
+				return
+			}
+
+			if errData != nil {
+				remaining = append(remaining, imgId)
+			}
+		}
+
+		if len(remaining) == len(imgIds) {
 			return
 		}
+
+		errData = nil
+		imgIds = remaining
 	}
 
 	return
 }
 
 func DeleteImageOrg(db *database.Database, orgId, imgId primitive.ObjectID) (
-	err error) {
+	errData *errortypes.ErrorData, err error) {
 
 	img, err := image.GetOrg(db, orgId, imgId)
 	if err != nil {
@@ -1046,6 +1453,11 @@ func DeleteImageOrg(db *database.Database, orgId, imgId primitive.ObjectID) (
 		return
 	}
 
+	errData, err = checkImageChildren(db, img)
+	if err != nil || errData != nil {
+		return
+	}
+
 	store, err := storage.Get(db, img.Storage)
 	if err != nil {
 		return
@@ -1076,14 +1488,31 @@ func DeleteImageOrg(db *database.Database, orgId, imgId primitive.ObjectID) (
 	return
 }
 
+// Delete organization images, images with incremental backups depending on
+// them are retried after the other images to allow deleting a chain.
 func DeleteImagesOrg(db *database.Database, orgId primitive.ObjectID,
-	imgIds []primitive.ObjectID) (err error) {
+	imgIds []primitive.ObjectID) (errData *errortypes.ErrorData, err error) {
 
-	for _, imgId := range imgIds {
-		err = DeleteImageOrg(db, orgId, imgId)
-		if err != nil {
+	for len(imgIds) > 0 {
+		remaining := []primitive.ObjectID{}
+
+		for _, imgId := range imgIds {
+			errData, err = DeleteImageOrg(db, orgId, imgId)
+			if err != nil {
+				return
+			}
+
+			if errData != nil {
+				remaining = append(remaining, imgId)
+			}
+		}
+
+		if len(remaining) == len(imgIds) {
 			return
 		}
+
+		errData = nil
+		imgIds = remaining
 	}
 
 	return
@@ -1095,12 +1524,7 @@ func CreateSnapshot(db *database.Database, dsk *disk.Disk,
 	dskPth := paths.GetDiskPath(dsk.Id)
 	cacheDir := node.Self.GetCachePath()
 
-	nde, err := node.Get(db, dsk.Node)
-	if err != nil {
-		return
-	}
-
-	zne, err := zone.Get(db, nde.Zone)
+	zne, err := zone.Get(db, node.Self.Zone)
 	if err != nil {
 		return
 	}
@@ -1179,7 +1603,11 @@ func CreateSnapshot(db *database.Database, dsk *disk.Disk,
 	}
 
 	if !available {
-		err = utils.Exec("", "cp", dskPth, tmpPath)
+		if dsk.IsPooled() {
+			err = readDiskPool(db, dsk, tmpPath)
+		} else {
+			err = utils.Exec("", "cp", dskPth, tmpPath)
+		}
 		if err != nil {
 			return
 		}
@@ -1190,6 +1618,11 @@ func CreateSnapshot(db *database.Database, dsk *disk.Disk,
 		return
 	}
 
+	err = encryptImage(db, img, tmpPath)
+	if err != nil {
+		return
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"disk_id":    dsk.Id.Hex(),
 		"disk_path":  dskPth,
@@ -1283,17 +1716,12 @@ func CreateSnapshot(db *database.Database, dsk *disk.Disk,
 }
 
 func CreateBackup(db *database.Database, dsk *disk.Disk,
-	virt *vm.VirtualMachine) (err error) {
+	virt *vm.VirtualMachine, scheduled bool) (err error) {
 
 	dskPth := paths.GetDiskPath(dsk.Id)
 	cacheDir := node.Self.GetCachePath()
 
-	nde, err := node.Get(db, dsk.Node)
-	if err != nil {
-		return
-	}
-
-	zne, err := zone.Get(db, nde.Zone)
+	zne, err := zone.Get(db, node.Self.Zone)
 	if err != nil {
 		return
 	}
@@ -1360,13 +1788,36 @@ func CreateBackup(db *database.Database, dsk *disk.Disk,
 		Firmware:     image.Unknown,
 		Storage:      store.Id,
 		Key:          fmt.Sprintf("backup/%s.qcow2", imgId.Hex()),
+		Scheduled:    scheduled,
 	}
 
 	defer utils.Remove(tmpPath)
 
+	running := virt != nil && virt.Running()
+	parentImg, err := getBackupParent(db, dsk, virt, store)
+	if err != nil {
+		return
+	}
+
+	chainSize := 1
+	if parentImg != nil {
+		img.Parent = parentImg.Id
+		chainSize = dsk.BackupChainSize + 1
+	}
+
+	// Break the chain until the upload completes, the dirty bitmap is
+	// cleared by the backup job and a failed upload would leave a gap
+	dsk.BackupParent = primitive.NilObjectID
+	dsk.BackupChainSize = 0
+	err = dsk.CommitFields(db, set.NewSet(
+		"backup_parent", "backup_chain_size"))
+	if err != nil {
+		return
+	}
+
 	available := false
-	if virt != nil && virt.Running() {
-		err = qmp.BackupDisk(virt.Id, dsk, tmpPath)
+	if running {
+		err = qmp.BackupDiskBitmap(virt.Id, dsk, tmpPath, parentImg != nil)
 		if err != nil {
 			if _, ok := err.(*qmp.DiskNotFound); ok {
 				err = nil
@@ -1379,7 +1830,14 @@ func CreateBackup(db *database.Database, dsk *disk.Disk,
 	}
 
 	if !available {
-		err = utils.Exec("", "cp", dskPth, tmpPath)
+		img.Parent = primitive.NilObjectID
+		chainSize = 1
+
+		if dsk.IsPooled() {
+			err = readDiskPool(db, dsk, tmpPath)
+		} else {
+			err = utils.Exec("", "cp", dskPth, tmpPath)
+		}
 		if err != nil {
 			return
 		}
@@ -1390,6 +1848,11 @@ func CreateBackup(db *database.Database, dsk *disk.Disk,
 		return
 	}
 
+	err = encryptImage(db, img, tmpPath)
+	if err != nil {
+		return
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"disk_id":    dsk.Id.Hex(),
 		"disk_path":  dskPth,
@@ -1449,6 +1912,14 @@ func CreateBackup(db *database.Database, dsk *disk.Disk,
 		return
 	}
 
+	dsk.BackupParent = img.Id
+	dsk.BackupChainSize = chainSize
+	err = dsk.CommitFields(db, set.NewSet(
+		"backup_parent", "backup_chain_size"))
+	if err != nil {
+		return
+	}
+
 	event.PublishDispatch(db, "image.change")
 
 	return
@@ -1505,18 +1976,73 @@ func RestoreBackup(db *database.Database, dsk *disk.Disk) (err error) {
 		return
 	}
 
-	imgId := primitive.NewObjectID()
-	tmpPath := path.Join(cacheDir,
-		fmt.Sprintf("restore-%s", imgId.Hex()))
+	chain, err := getBackupChain(db, img)
+	if err != nil {
+		return
+	}
 
+	restoreId := primitive.NewObjectID()
+	tmpPath := path.Join(cacheDir,
+		fmt.Sprintf("restore-%s", restoreId.Hex()))
 	defer utils.Remove(tmpPath)
-	err = client.FGetObject(context.Background(), store.Bucket,
-		img.Key, tmpPath, minio.GetObjectOptions{})
-	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "data: Failed to download restore image"),
+
+	chainPaths := []string{}
+	defer func() {
+		for _, chainPth := range chainPaths {
+			utils.Remove(chainPth)
+		}
+	}()
+
+	for i, chainImg := range chain {
+		if chainImg.Storage != store.Id {
+			err = &errortypes.VerificationError{
+				errors.New("data: Backup chain storage invalid"),
+			}
+			return
+		}
+
+		chainPth := tmpPath
+		if len(chain) > 1 {
+			chainPth = path.Join(cacheDir, fmt.Sprintf(
+				"restore-%s-%d", restoreId.Hex(), i))
+			chainPaths = append(chainPaths, chainPth)
+		}
+
+		err = client.FGetObject(context.Background(), store.Bucket,
+			chainImg.Key, chainPth, minio.GetObjectOptions{})
+		if err != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "data: Failed to download restore image"),
+			}
+			return
+		}
+
+		err = decryptImage(db, chainImg, chainPth)
+		if err != nil {
+			return
+		}
+
+		if i > 0 {
+			err = utils.Exec("", "qemu-img", "rebase", "-u",
+				"-F", "qcow2", "-b", chainPaths[i-1], chainPth)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	if len(chain) > 1 {
+		logrus.WithFields(logrus.Fields{
+			"disk_id":      dsk.Id.Hex(),
+			"image_id":     img.Id.Hex(),
+			"chain_length": len(chain),
+		}).Info("data: Merging incremental disk backups")
+
+		err = utils.Exec("", "qemu-img", "convert", "-O", "qcow2",
+			chainPaths[len(chainPaths)-1], tmpPath)
+		if err != nil {
+			return
 		}
-		return
 	}
 
 	err = utils.Chmod(tmpPath, 0600)
@@ -1529,6 +2055,14 @@ func RestoreBackup(db *database.Database, dsk *disk.Disk) (err error) {
 		return
 	}
 
+	dsk.BackupParent = primitive.NilObjectID
+	dsk.BackupChainSize = 0
+	err = dsk.CommitFields(db, set.NewSet(
+		"backup_parent", "backup_chain_size"))
+	if err != nil {
+		return
+	}
+
 	return
 }
 
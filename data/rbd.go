@@ -0,0 +1,252 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/pool"
+	"github.com/pritunl/pritunl-cloud/qmp"
+	"github.com/pritunl/pritunl-cloud/rbd"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/sirupsen/logrus"
+)
+
+// Create rbd image and write the qcow2 source image to it.
+func writeDiskRbd(db *database.Database, dsk *disk.Disk, pl *pool.Pool,
+	sourcePth string, size int) (err error) {
+
+	imgName := dsk.Id.Hex()
+
+	err = withLvmLock(db, pl.CephPool, imgName, func() (err error) {
+		err = rbd.CreateImage(pl, imgName, size)
+		if err != nil {
+			return
+		}
+
+		if sourcePth != "" {
+			err = rbd.WriteImage(pl, imgName, sourcePth)
+			if err != nil {
+				return
+			}
+		}
+
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func writeImageRbd(db *database.Database, dsk *disk.Disk,
+	pl *pool.Pool) (newSize int, err error) {
+
+	diskTempPath := paths.GetDiskTempPath()
+	defer utils.Remove(diskTempPath)
+
+	sourcePth, size, newSize, err := getPoolImage(db, dsk, diskTempPath)
+	if err != nil {
+		return
+	}
+
+	err = writeDiskRbd(db, dsk, pl, sourcePth, size)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func writeFsRbd(db *database.Database, dsk *disk.Disk,
+	pl *pool.Pool) (err error) {
+
+	imgName := dsk.Id.Hex()
+
+	err = withLvmLock(db, pl.CephPool, imgName, func() (err error) {
+		err = rbd.CreateImage(pl, imgName, dsk.Size)
+		if err != nil {
+			return
+		}
+
+		devPth, err := rbd.MapImage(pl, imgName)
+		if err != nil {
+			return
+		}
+		defer func() {
+			err2 := rbd.UnmapImage(pl, imgName)
+			if err2 != nil {
+				logrus.WithFields(logrus.Fields{
+					"disk_id": dsk.Id.Hex(),
+					"error":   err2,
+				}).Error("data: Failed to unmap rbd image")
+			}
+		}()
+
+		err = utils.Exec("", "mkfs", "-t", dsk.FileSystem, devPth)
+		if err != nil {
+			return
+		}
+
+		output, err := utils.ExecOutput("", "blkid", "-s", "UUID",
+			"-o", "value", devPth)
+		if err != nil {
+			return
+		}
+
+		dsk.Uuid = strings.TrimSpace(output)
+
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	err = dsk.CommitFields(db, set.NewSet("uuid"))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func createDiskRbd(db *database.Database, dsk *disk.Disk) (
+	newSize int, err error) {
+
+	pl, err := pool.Get(db, dsk.Pool)
+	if err != nil {
+		return
+	}
+
+	if !dsk.Image.IsZero() {
+		newSize, err = writeImageRbd(db, dsk, pl)
+		if err != nil {
+			return
+		}
+	} else if dsk.FileSystem != "" {
+		err = writeFsRbd(db, dsk, pl)
+		if err != nil {
+			return
+		}
+	} else {
+		err = writeDiskRbd(db, dsk, pl, "", dsk.Size)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Read rbd disk to a qcow2 image from a temporary snapshot to get a
+// consistent copy while the disk is in use.
+func readDiskRbd(db *database.Database, dsk *disk.Disk,
+	dstPth string) (err error) {
+
+	pl, err := pool.Get(db, dsk.Pool)
+	if err != nil {
+		return
+	}
+
+	imgName := dsk.Id.Hex()
+	snapName := fmt.Sprintf("snap-%s", primitive.NewObjectID().Hex())
+
+	err = withLvmLock(db, pl.CephPool, imgName, func() (err error) {
+		err = rbd.CreateSnapshot(pl, imgName, snapName)
+		if err != nil {
+			return
+		}
+		defer func() {
+			err2 := rbd.RemoveSnapshot(pl, imgName, snapName)
+			if err2 != nil {
+				logrus.WithFields(logrus.Fields{
+					"disk_id": dsk.Id.Hex(),
+					"error":   err2,
+				}).Error("data: Failed to remove rbd snapshot")
+			}
+		}()
+
+		err = rbd.ReadImage(pl, fmt.Sprintf("%s@%s", imgName, snapName),
+			dstPth)
+		if err != nil {
+			return
+		}
+
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func expandDiskRbd(db *database.Database, dsk *disk.Disk,
+	virt *vm.VirtualMachine) (err error) {
+
+	pl, err := pool.Get(db, dsk.Pool)
+	if err != nil {
+		return
+	}
+
+	imgName := dsk.Id.Hex()
+
+	logrus.WithFields(logrus.Fields{
+		"disk_id":   dsk.Id.Hex(),
+		"ceph_pool": pl.CephPool,
+		"image":     imgName,
+		"new_size":  dsk.NewSize,
+	}).Info("data: Expanding rbd disk")
+
+	curSize, err := rbd.GetSizeImage(pl, imgName)
+	if err != nil {
+		return
+	}
+
+	if curSize >= dsk.NewSize {
+		logrus.WithFields(logrus.Fields{
+			"disk_id":      dsk.Id.Hex(),
+			"ceph_pool":    pl.CephPool,
+			"image":        imgName,
+			"current_size": curSize,
+			"new_size":     dsk.NewSize,
+		}).Warn("data: Disk size larger then new size")
+
+		dsk.Size = curSize
+		return
+	}
+
+	err = withLvmLock(db, pl.CephPool, imgName, func() (err error) {
+		err = rbd.ResizeImage(pl, imgName, dsk.NewSize)
+		if err != nil {
+			return
+		}
+
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	curSize, err = rbd.GetSizeImage(pl, imgName)
+	if err != nil {
+		return
+	}
+
+	if virt != nil && virt.Running() {
+		err = qmp.ResizeDisk(virt.Id, dsk, curSize)
+		if err != nil {
+			return
+		}
+	}
+
+	dsk.Size = curSize
+
+	return
+}
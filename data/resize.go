@@ -11,7 +11,9 @@ import (
 	"github.com/pritunl/pritunl-cloud/lvm"
 	"github.com/pritunl/pritunl-cloud/paths"
 	"github.com/pritunl/pritunl-cloud/pool"
+	"github.com/pritunl/pritunl-cloud/qmp"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/sirupsen/logrus"
 )
 
@@ -26,7 +28,7 @@ func getDiskSizeQcow(dsk *disk.Disk) (size int, err error) {
 	dskPth := paths.GetDiskPath(dsk.Id)
 
 	output, err := utils.ExecOutput("",
-		"qemu-img", "info", "--output=json", dskPth)
+		"qemu-img", "info", "--force-share", "--output=json", dskPth)
 	if err != nil {
 		return
 	}
@@ -46,7 +48,8 @@ func getDiskSizeQcow(dsk *disk.Disk) (size int, err error) {
 	return
 }
 
-func expandDiskQcow(db *database.Database, dsk *disk.Disk) (err error) {
+func expandDiskQcow(db *database.Database, dsk *disk.Disk,
+	virt *vm.VirtualMachine) (err error) {
 	dskPth := paths.GetDiskPath(dsk.Id)
 
 	logrus.WithFields(logrus.Fields{
@@ -72,11 +75,18 @@ func expandDiskQcow(db *database.Database, dsk *disk.Disk) (err error) {
 		return
 	}
 
-	expandSize := dsk.NewSize - curSize
-	_, err = utils.ExecCombinedOutputLogged(nil,
-		"qemu-img", "resize", dskPth, fmt.Sprintf("+%dG", expandSize))
-	if err != nil {
-		return
+	if virt != nil && virt.Running() {
+		err = qmp.ResizeDisk(virt.Id, dsk, dsk.NewSize)
+		if err != nil {
+			return
+		}
+	} else {
+		expandSize := dsk.NewSize - curSize
+		_, err = utils.ExecCombinedOutputLogged(nil,
+			"qemu-img", "resize", dskPth, fmt.Sprintf("+%dG", expandSize))
+		if err != nil {
+			return
+		}
 	}
 
 	curSize, err = getDiskSizeQcow(dsk)
@@ -88,7 +98,8 @@ func expandDiskQcow(db *database.Database, dsk *disk.Disk) (err error) {
 	return
 }
 
-func expandDiskLvm(db *database.Database, dsk *disk.Disk) (err error) {
+func expandDiskLvm(db *database.Database, dsk *disk.Disk,
+	virt *vm.VirtualMachine) (err error) {
 	pl, err := pool.Get(db, dsk.Pool)
 	if err != nil {
 		return
@@ -158,21 +169,38 @@ func expandDiskLvm(db *database.Database, dsk *disk.Disk) (err error) {
 		return
 	}
 
+	if virt != nil && virt.Running() {
+		err = qmp.ResizeDisk(virt.Id, dsk, curSize)
+		if err != nil {
+			return
+		}
+	}
+
 	dsk.Size = curSize
 
 	return
 }
 
-func ExpandDisk(db *database.Database, dsk *disk.Disk) (err error) {
+// Expand disk to the new size, disks of running instances are resized
+// online and the guest agent grows the partition and filesystem.
+func ExpandDisk(db *database.Database, dsk *disk.Disk,
+	virt *vm.VirtualMachine) (err error) {
+
 	switch dsk.Type {
 	case disk.Lvm:
-		err = expandDiskLvm(db, dsk)
+		err = expandDiskLvm(db, dsk, virt)
+		if err != nil {
+			return
+		}
+		break
+	case disk.Rbd:
+		err = expandDiskRbd(db, dsk, virt)
 		if err != nil {
 			return
 		}
 		break
 	case "", disk.Qcow2:
-		err = expandDiskQcow(db, dsk)
+		err = expandDiskQcow(db, dsk, virt)
 		if err != nil {
 			return
 		}
@@ -0,0 +1,119 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/sirupsen/logrus"
+)
+
+// Remove scheduled backups not retained by the hourly, daily or weekly
+// retention of the disk backup schedule, the newest backup in each period
+// is retained along with the parents of retained incremental backups.
+func PruneBackups(db *database.Database, dsk *disk.Disk) (err error) {
+	sched := dsk.BackupSchedule
+	if sched == nil || !sched.HasRetention() {
+		return
+	}
+
+	imgs, err := image.GetAllScheduled(db, dsk.Id)
+	if err != nil {
+		return
+	}
+
+	keep := set.NewSet()
+	hourly := set.NewSet()
+	daily := set.NewSet()
+	weekly := set.NewSet()
+
+	for _, img := range imgs {
+		if img.LastModified.IsZero() {
+			keep.Add(img.Id)
+			continue
+		}
+
+		modified := img.LastModified.UTC()
+		hourKey := modified.Format("2006010215")
+		dayKey := modified.Format("20060102")
+		year, week := modified.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%d", year, week)
+
+		if hourly.Len() < sched.KeepHourly && !hourly.Contains(hourKey) {
+			hourly.Add(hourKey)
+			keep.Add(img.Id)
+		}
+		if daily.Len() < sched.KeepDaily && !daily.Contains(dayKey) {
+			daily.Add(dayKey)
+			keep.Add(img.Id)
+		}
+		if weekly.Len() < sched.KeepWeekly && !weekly.Contains(weekKey) {
+			weekly.Add(weekKey)
+			keep.Add(img.Id)
+		}
+	}
+
+	// Incremental backups require every parent in the chain
+	imgsMap := map[primitive.ObjectID]*image.Image{}
+	for _, img := range imgs {
+		imgsMap[img.Id] = img
+	}
+	for _, img := range imgs {
+		if !keep.Contains(img.Id) {
+			continue
+		}
+
+		parentId := img.Parent
+		for !parentId.IsZero() && !keep.Contains(parentId) {
+			keep.Add(parentId)
+
+			parentImg := imgsMap[parentId]
+			if parentImg == nil {
+				break
+			}
+			parentId = parentImg.Parent
+		}
+	}
+
+	removed := 0
+	for _, img := range imgs {
+		if keep.Contains(img.Id) {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"disk_id":  dsk.Id.Hex(),
+			"image_id": img.Id.Hex(),
+			"key":      img.Key,
+		}).Info("data: Removing expired disk backup")
+
+		errData, e := DeleteImage(db, img.Id)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); !ok {
+				err = e
+				return
+			}
+			continue
+		}
+
+		if errData != nil {
+			logrus.WithFields(logrus.Fields{
+				"disk_id":  dsk.Id.Hex(),
+				"image_id": img.Id.Hex(),
+				"error":    errData.Message,
+			}).Warn("data: Cannot remove expired disk backup")
+			continue
+		}
+		removed += 1
+	}
+
+	if removed > 0 {
+		event.PublishDispatch(db, "image.change")
+	}
+
+	return
+}
@@ -0,0 +1,292 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/datacenter"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/qga"
+	"github.com/pritunl/pritunl-cloud/qmp"
+	"github.com/pritunl/pritunl-cloud/storage"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/zone"
+	"github.com/sirupsen/logrus"
+)
+
+func snapshotSetRunning(virt *vm.VirtualMachine, dsks []*disk.Disk,
+	tmpPaths []string) (err error) {
+
+	guestPath := paths.GetGuestPath(virt.Id)
+
+	frozen := false
+	count, e := qga.FsFreeze(guestPath)
+	if e != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": virt.Id.Hex(),
+			"error":       e,
+		}).Warn("data: Failed to freeze guest filesystems")
+	} else {
+		frozen = true
+
+		logrus.WithFields(logrus.Fields{
+			"instance_id": virt.Id.Hex(),
+			"filesystems": count,
+		}).Info("data: Froze guest filesystems")
+	}
+
+	deviceNames, err := qmp.StartBackupDisks(virt.Id, dsks, tmpPaths)
+
+	if frozen {
+		_, e = qga.FsThaw(guestPath)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": virt.Id.Hex(),
+				"error":       e,
+			}).Error("data: Failed to thaw guest filesystems")
+		}
+	}
+
+	if err != nil {
+		return
+	}
+
+	err = qmp.WaitBackupDisks(virt.Id, deviceNames)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Snapshot all disks of an instance to the same point in time, guest
+// filesystems are frozen during the snapshot when the guest agent is
+// available. The snapshot images share the snapshot set and can be restored
+// together.
+func CreateSnapshotSet(db *database.Database, setId primitive.ObjectID,
+	dsks []*disk.Disk, virt *vm.VirtualMachine) (err error) {
+
+	if len(dsks) == 0 {
+		return
+	}
+	cacheDir := node.Self.GetCachePath()
+
+	zne, err := zone.Get(db, node.Self.Zone)
+	if err != nil {
+		return
+	}
+
+	dc, err := datacenter.Get(db, zne.Datacenter)
+	if err != nil {
+		return
+	}
+
+	if dc.PrivateStorage.IsZero() {
+		logrus.WithFields(logrus.Fields{
+			"snapshot_set": setId.Hex(),
+		}).Error("data: Cannot snapshot disks without private storage")
+		return
+	}
+
+	store, err := storage.Get(db, dc.PrivateStorage)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			logrus.WithFields(logrus.Fields{
+				"snapshot_set": setId.Hex(),
+			}).Error("data: Cannot snapshot disks without private storage")
+		}
+		return
+	}
+
+	if store.Type != storage.Private {
+		err = &errortypes.ConnectionError{
+			errors.New("data: Cannot upload to non-private storage"),
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"snapshot_set": setId.Hex(),
+		"storage_id":   store.Id.Hex(),
+		"disks":        len(dsks),
+	}).Info("data: Creating disk snapshot set")
+
+	err = utils.ExistsMkdir(cacheDir, 0755)
+	if err != nil {
+		return
+	}
+
+	timestamp := time.Now().Format("20060102-150405")
+	imgs := []*image.Image{}
+	tmpPaths := []string{}
+	for _, dsk := range dsks {
+		imgId := primitive.NewObjectID()
+		tmpPath := path.Join(cacheDir,
+			fmt.Sprintf("snapshot-%s", imgId.Hex()))
+
+		imgs = append(imgs, &image.Image{
+			Id:           imgId,
+			Disk:         dsk.Id,
+			Name:         fmt.Sprintf("%s-%s", dsk.Name, timestamp),
+			Organization: dsk.Organization,
+			Deployment:   dsk.Deployment,
+			Type:         storage.Private,
+			SystemType:   dsk.SystemType,
+			Firmware:     image.Unknown,
+			Storage:      store.Id,
+			Key:          fmt.Sprintf("snapshot/%s.qcow2", imgId.Hex()),
+			SnapshotSet:  setId,
+		})
+		tmpPaths = append(tmpPaths, tmpPath)
+	}
+
+	defer func() {
+		for _, tmpPath := range tmpPaths {
+			utils.Remove(tmpPath)
+		}
+	}()
+
+	if virt != nil && virt.Running() {
+		err = snapshotSetRunning(virt, dsks, tmpPaths)
+		if err != nil {
+			return
+		}
+	} else {
+		for i, dsk := range dsks {
+			if dsk.IsPooled() {
+				err = readDiskPool(db, dsk, tmpPaths[i])
+			} else {
+				err = utils.Exec("", "cp", paths.GetDiskPath(dsk.Id),
+					tmpPaths[i])
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	client, err := minio.New(store.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(store.AccessKey, store.SecretKey, ""),
+		Secure: !store.Insecure,
+	})
+	if err != nil {
+		err = &errortypes.ConnectionError{
+			errors.Wrap(err, "data: Failed to connect to storage"),
+		}
+		return
+	}
+
+	putOpts := minio.PutObjectOptions{}
+	storageClass := storage.FormatStorageClass(dc.PrivateStorageClass)
+	if storageClass != "" {
+		putOpts.StorageClass = storageClass
+	}
+
+	// Remove all uploaded objects and image records if any disk of the set
+	// fails to keep the snapshot set complete
+	uploaded := []*image.Image{}
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		for _, img := range uploaded {
+			e := client.RemoveObject(context.Background(),
+				store.Bucket, img.Key, minio.RemoveObjectOptions{})
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"snapshot_set": setId.Hex(),
+					"object_key":   img.Key,
+					"error":        e,
+				}).Error("data: Failed to remove snapshot set object")
+			}
+
+			e = image.Remove(db, img.Id)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"snapshot_set": setId.Hex(),
+					"image_id":     img.Id.Hex(),
+					"error":        e,
+				}).Error("data: Failed to remove snapshot set image")
+			}
+		}
+	}()
+
+	for i, img := range imgs {
+		tmpPath := tmpPaths[i]
+
+		err = utils.Chmod(tmpPath, 0600)
+		if err != nil {
+			return
+		}
+
+		err = encryptImage(db, img, tmpPath)
+		if err != nil {
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"disk_id":      img.Disk.Hex(),
+			"snapshot_set": setId.Hex(),
+			"storage_id":   store.Id.Hex(),
+			"object_key":   img.Key,
+		}).Info("data: Uploading disk snapshot")
+
+		_, err = client.FPutObject(context.Background(),
+			store.Bucket, img.Key, tmpPath, putOpts)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "data: Failed to write object"),
+			}
+			return
+		}
+		uploaded = append(uploaded, img)
+	}
+
+	time.Sleep(3 * time.Second)
+
+	for _, img := range imgs {
+		obj, e := client.StatObject(context.Background(),
+			store.Bucket, img.Key, minio.StatObjectOptions{})
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "data: Failed to stat object"),
+			}
+			return
+		}
+
+		img.Etag = image.GetEtag(obj)
+		img.LastModified = obj.LastModified
+
+		if store.IsOracle() {
+			img.StorageClass = storage.ParseStorageClass(obj)
+		} else {
+			img.StorageClass = dc.PrivateStorageClass
+		}
+	}
+
+	for _, img := range imgs {
+		err = img.Upsert(db)
+		if err != nil {
+			return
+		}
+	}
+
+	event.PublishDispatch(db, "image.change")
+
+	return
+}
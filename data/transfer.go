@@ -0,0 +1,979 @@
+package data
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/datacenter"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/pool"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/storage"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/zone"
+	"github.com/sirupsen/logrus"
+)
+
+// Get private storage of the datacenter that contains the disk, disk
+// imports and exports are staged in the private storage bucket.
+func GetDiskStorage(db *database.Database, dsk *disk.Disk) (
+	store *storage.Storage, dc *datacenter.Datacenter, err error) {
+
+	zoneId := primitive.NilObjectID
+	if dsk.IsPooled() {
+		pl, e := pool.Get(db, dsk.Pool)
+		if e != nil {
+			err = e
+			return
+		}
+		zoneId = pl.Zone
+	} else {
+		nde, e := node.Get(db, dsk.Node)
+		if e != nil {
+			err = e
+			return
+		}
+		zoneId = nde.Zone
+	}
+
+	zne, err := zone.Get(db, zoneId)
+	if err != nil {
+		return
+	}
+
+	dc, err = datacenter.Get(db, zne.Datacenter)
+	if err != nil {
+		return
+	}
+
+	if dc.PrivateStorage.IsZero() {
+		err = &errortypes.NotFoundError{
+			errors.New("data: Datacenter missing private storage"),
+		}
+		return
+	}
+
+	store, err = storage.Get(db, dc.PrivateStorage)
+	if err != nil {
+		return
+	}
+
+	if store.Type != storage.Private {
+		err = &errortypes.ConnectionError{
+			errors.New("data: Cannot transfer with non-private storage"),
+		}
+		return
+	}
+
+	return
+}
+
+func getStorageClient(store *storage.Storage) (
+	client *minio.Client, err error) {
+
+	client, err = minio.New(store.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(store.AccessKey, store.SecretKey, ""),
+		Secure: !store.Insecure,
+	})
+	if err != nil {
+		err = &errortypes.ConnectionError{
+			errors.Wrap(err, "data: Failed to connect to storage"),
+		}
+		return
+	}
+
+	return
+}
+
+// Upload disk import source to the private storage of the disk datacenter.
+func UploadDiskImport(db *database.Database, dsk *disk.Disk,
+	reader io.Reader, size int64) (err error) {
+
+	store, _, err := GetDiskStorage(db, dsk)
+	if err != nil {
+		return
+	}
+
+	client, err := getStorageClient(store)
+	if err != nil {
+		return
+	}
+
+	key := fmt.Sprintf("import/%s.%s", dsk.Id.Hex(), dsk.Import.Format)
+
+	_, err = client.PutObject(context.Background(), store.Bucket, key,
+		reader, size, minio.PutObjectOptions{})
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to write object"),
+		}
+		return
+	}
+
+	dsk.Import.Storage = store.Id
+	dsk.Import.Key = key
+	dsk.Import.Timestamp = time.Now()
+
+	return
+}
+
+// Get reader of an exported disk from the private storage.
+func GetDiskExport(db *database.Database, dsk *disk.Disk) (
+	obj *minio.Object, size int64, err error) {
+
+	if dsk.Export == nil || dsk.Export.Key == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("data: Disk export not available"),
+		}
+		return
+	}
+
+	store, err := storage.Get(db, dsk.Export.Storage)
+	if err != nil {
+		return
+	}
+
+	client, err := getStorageClient(store)
+	if err != nil {
+		return
+	}
+
+	obj, err = client.GetObject(context.Background(), store.Bucket,
+		dsk.Export.Key, minio.GetObjectOptions{})
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to read object"),
+		}
+		return
+	}
+
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		obj = nil
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to stat object"),
+		}
+		return
+	}
+	size = info.Size
+
+	return
+}
+
+const (
+	importMemoryLimit = 4294967296
+	importCpuLimit    = 3600
+)
+
+var (
+	importDeniedNets = []*net.IPNet{
+		parseImportNet("0.0.0.0/8"),
+		parseImportNet("100.64.0.0/10"),
+		parseImportNet("192.0.0.0/24"),
+		parseImportNet("198.18.0.0/15"),
+		parseImportNet("240.0.0.0/4"),
+		parseImportNet("64:ff9b::/96"),
+	}
+	importDialer = &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	importClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext:         importDialContext,
+			DisableKeepAlives:   true,
+			TLSHandshakeTimeout: 10 * time.Second,
+			TLSClientConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				MaxVersion: tls.VersionTLS13,
+			},
+		},
+		CheckRedirect: importCheckRedirect,
+		Timeout:       2 * time.Hour,
+	}
+)
+
+func parseImportNet(cidr string) (network *net.IPNet) {
+	_, network, _ = net.ParseCIDR(cidr)
+	return
+}
+
+type importInfo struct {
+	Format          string              `json:"format"`
+	BackingFilename string              `json:"backing-filename"`
+	FormatSpecific  *importInfoSpecific `json:"format-specific"`
+}
+
+type importInfoSpecific struct {
+	Type string                  `json:"type"`
+	Data *importInfoSpecificData `json:"data"`
+}
+
+type importInfoSpecificData struct {
+	DataFile   string              `json:"data-file"`
+	CreateType string              `json:"create-type"`
+	Extents    []*importInfoExtent `json:"extents"`
+}
+
+type importInfoExtent struct {
+	Filename string `json:"filename"`
+}
+
+type ovfEnvelope struct {
+	Files []*ovfFile `xml:"References>File"`
+	Disks []*ovfDisk `xml:"DiskSection>Disk"`
+}
+
+type ovfFile struct {
+	Id   string `xml:"id,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type ovfDisk struct {
+	FileRef string `xml:"fileRef,attr"`
+}
+
+// Check that an import address is not a loopback, link-local, private or
+// node internal address.
+func checkImportIp(ip net.IP) (err error) {
+	denied := ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsPrivate() || ip.IsUnspecified()
+
+	if !denied {
+		for _, network := range importDeniedNets {
+			if network.Contains(ip) {
+				denied = true
+				break
+			}
+		}
+	}
+
+	if !denied {
+		internalNets := []string{
+			settings.Hypervisor.NodePortNetwork,
+			settings.Hypervisor.ImdsAddress,
+		}
+		for _, internalNet := range internalNets {
+			_, network, e := net.ParseCIDR(internalNet)
+			if e == nil && network.Contains(ip) {
+				denied = true
+				break
+			}
+		}
+	}
+
+	if !denied && node.Self != nil {
+		addrs := []string{}
+		addrs = append(addrs, node.Self.PublicIps...)
+		addrs = append(addrs, node.Self.PublicIps6...)
+		for _, addr := range node.Self.PrivateIps {
+			addrs = append(addrs, addr)
+		}
+
+		for _, addr := range addrs {
+			nodeIp := net.ParseIP(addr)
+			if nodeIp != nil && nodeIp.Equal(ip) {
+				denied = true
+				break
+			}
+		}
+	}
+
+	if denied {
+		err = &errortypes.RequestError{
+			errors.Newf("data: Import address %s not allowed", ip),
+		}
+		return
+	}
+
+	return
+}
+
+// Resolve the import host and only connect to allowed addresses, the
+// check is done on each connection to include redirects.
+func importDialContext(ctx context.Context, network, addr string) (
+	conn net.Conn, err error) {
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "data: Failed to parse import address"),
+		}
+		return
+	}
+
+	ipAddrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "data: Failed to resolve import host"),
+		}
+		return
+	}
+
+	if len(ipAddrs) == 0 {
+		err = &errortypes.RequestError{
+			errors.New("data: Import host has no addresses"),
+		}
+		return
+	}
+
+	for _, ipAddr := range ipAddrs {
+		err = checkImportIp(ipAddr.IP)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, ipAddr := range ipAddrs {
+		conn, err = importDialer.DialContext(ctx, network,
+			net.JoinHostPort(ipAddr.IP.String(), port))
+		if err == nil {
+			return
+		}
+	}
+
+	err = &errortypes.RequestError{
+		errors.Wrap(err, "data: Failed to connect to import host"),
+	}
+	return
+}
+
+func importCheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 5 {
+		return &errortypes.RequestError{
+			errors.New("data: Too many import redirects"),
+		}
+	}
+
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return &errortypes.RequestError{
+			errors.New("data: Import redirect scheme invalid"),
+		}
+	}
+
+	return nil
+}
+
+// Get import url host without credentials, path or query for logging.
+func getImportHost(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func downloadImport(dsk *disk.Disk, dstPth string) (err error) {
+	req, err := http.NewRequest("GET", dsk.Import.Url, nil)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.New("data: Failed to create import request"),
+		}
+		return
+	}
+
+	req.Header.Set("User-Agent", "pritunl-cloud")
+
+	resp, err := importClient.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "data: Import request error"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = &errortypes.RequestError{
+			errors.Newf(
+				"data: Bad status %d from import request",
+				resp.StatusCode,
+			),
+		}
+		return
+	}
+
+	maxSize := int64(settings.Hypervisor.DiskImportMaxSize) * 1073741824
+	if resp.ContentLength > maxSize {
+		err = &errortypes.RequestError{
+			errors.New("data: Import exceeds maximum size"),
+		}
+		return
+	}
+
+	out, err := os.OpenFile(dstPth, os.O_CREATE|os.O_WRONLY|os.O_TRUNC,
+		0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to create temporary file"),
+		}
+		return
+	}
+	defer out.Close()
+
+	written, err := io.Copy(out, io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "data: Failed to download import"),
+		}
+		return
+	}
+
+	if written > maxSize {
+		err = &errortypes.RequestError{
+			errors.New("data: Import exceeds maximum size"),
+		}
+		return
+	}
+
+	return
+}
+
+// Get the vmdk file of the first disk referenced in the ova descriptor.
+func getOvaDisk(ovfPth string) (name string, err error) {
+	data, err := os.ReadFile(ovfPth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to read ova descriptor"),
+		}
+		return
+	}
+
+	envelope := &ovfEnvelope{}
+	err = xml.Unmarshal(data, envelope)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "data: Failed to parse ova descriptor"),
+		}
+		return
+	}
+
+	if len(envelope.Disks) == 0 {
+		err = &errortypes.NotFoundError{
+			errors.New("data: Ova descriptor missing disk"),
+		}
+		return
+	}
+
+	for _, file := range envelope.Files {
+		if file.Id == envelope.Disks[0].FileRef {
+			name = file.Href
+			break
+		}
+	}
+
+	if name == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("data: Ova descriptor missing disk file"),
+		}
+		return
+	}
+
+	return
+}
+
+// Check that all members of the ova archive are regular files in the root
+// of the archive and get the name of the ovf descriptor.
+func checkOva(ovaPth string) (ovfName string, err error) {
+	file, err := os.Open(ovaPth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to open ova archive"),
+		}
+		return
+	}
+	defer file.Close()
+
+	reader := tar.NewReader(file)
+	for {
+		header, e := reader.Next()
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "data: Failed to read ova archive"),
+			}
+			return
+		}
+
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			err = &errortypes.ParseError{
+				errors.Newf("data: Ova member '%s' is not a regular file",
+					header.Name),
+			}
+			return
+		}
+
+		name := strings.TrimPrefix(header.Name, "./")
+		if name == "" || name != filepath.Base(name) ||
+			name == "." || name == ".." {
+
+			err = &errortypes.ParseError{
+				errors.Newf("data: Ova member '%s' path invalid",
+					header.Name),
+			}
+			return
+		}
+
+		if ovfName == "" && strings.HasSuffix(
+			strings.ToLower(name), ".ovf") {
+
+			ovfName = name
+		}
+	}
+
+	if ovfName == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("data: Ova archive missing ovf descriptor"),
+		}
+		return
+	}
+
+	return
+}
+
+// Extract ova archive and get the path of the disk referenced in the ovf
+// descriptor. Archives with links, devices or nested paths are rejected.
+func extractOva(ovaPth, dstDir string) (diskPth string, err error) {
+	ovfName, err := checkOva(ovaPth)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(dstDir, 0700)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(nil,
+		"tar", "--no-same-owner", "--no-same-permissions",
+		"--no-overwrite-dir", "-xf", ovaPth, "-C", dstDir)
+	if err != nil {
+		return
+	}
+
+	diskName, err := getOvaDisk(path.Join(dstDir, ovfName))
+	if err != nil {
+		return
+	}
+
+	if diskName != filepath.Base(diskName) || diskName == "." ||
+		diskName == ".." {
+
+		err = &errortypes.ParseError{
+			errors.New("data: Ova disk path invalid"),
+		}
+		return
+	}
+
+	diskPth = path.Join(dstDir, diskName)
+
+	info, err := os.Lstat(diskPth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "data: Failed to stat ova disk"),
+		}
+		return
+	}
+
+	if !info.Mode().IsRegular() {
+		err = &errortypes.ParseError{
+			errors.New("data: Ova disk is not a regular file"),
+		}
+		return
+	}
+
+	return
+}
+
+// Get qemu-img image options for an import source with file locking
+// disabled, qcow2 backing files are disabled.
+func getImportOpts(pth, format string) string {
+	opts := fmt.Sprintf(
+		"driver=%s,file.driver=file,file.filename=%s,file.locking=off",
+		format, pth,
+	)
+	if format == disk.Qcow2 {
+		opts += ",backing=null"
+	}
+	return opts
+}
+
+// Run qemu-img on an import source with memory and cpu limits.
+func execImportQemuImg(arg ...string) (output string, err error) {
+	args := []string{
+		"--as=" + strconv.Itoa(importMemoryLimit),
+		"--cpu=" + strconv.Itoa(importCpuLimit),
+		"qemu-img",
+	}
+	args = append(args, arg...)
+
+	output, err = utils.ExecCombinedOutputLogged(nil, "prlimit", args...)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Inspect an import source before conversion, sources that reference other
+// files with backing files, data files or extents are rejected.
+func inspectImport(pth, format string) (err error) {
+	if strings.Contains(pth, ",") {
+		err = &errortypes.ParseError{
+			errors.New("data: Import path invalid"),
+		}
+		return
+	}
+
+	output, err := execImportQemuImg("info", "--output=json",
+		"--image-opts", getImportOpts(pth, format))
+	if err != nil {
+		return
+	}
+
+	info := &importInfo{}
+	err = json.Unmarshal([]byte(output), info)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "data: Failed to parse import disk info"),
+		}
+		return
+	}
+
+	if info.Format != format {
+		err = &errortypes.ParseError{
+			errors.Newf("data: Import format '%s' does not match '%s'",
+				info.Format, format),
+		}
+		return
+	}
+
+	if info.BackingFilename != "" {
+		err = &errortypes.ParseError{
+			errors.New("data: Import disk has backing file"),
+		}
+		return
+	}
+
+	if info.FormatSpecific == nil || info.FormatSpecific.Data == nil {
+		return
+	}
+	data := info.FormatSpecific.Data
+
+	if data.DataFile != "" {
+		err = &errortypes.ParseError{
+			errors.New("data: Import disk has data file"),
+		}
+		return
+	}
+
+	if format == disk.Vmdk {
+		switch data.CreateType {
+		case "monolithicSparse", "streamOptimized":
+			break
+		default:
+			err = &errortypes.ParseError{
+				errors.Newf("data: Import vmdk type '%s' not supported",
+					data.CreateType),
+			}
+			return
+		}
+	}
+
+	for _, extent := range data.Extents {
+		if extent.Filename != pth {
+			err = &errortypes.ParseError{
+				errors.New("data: Import disk has external extent"),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func getVirtualSize(pth string) (size int, err error) {
+	output, err := utils.ExecOutput("",
+		"qemu-img", "info", "--output=json", pth)
+	if err != nil {
+		return
+	}
+
+	info := &diskInfo{}
+	err = json.Unmarshal([]byte(output), info)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "data: Failed to parse qemu disk info"),
+		}
+		return
+	}
+
+	size = (info.VirtualSize + 1073741823) / 1073741824
+
+	return
+}
+
+// Import disk from a url or an uploaded object, the source is converted to
+// qcow2 and written to the disk. The disk size is raised to the virtual size
+// of the source.
+func ImportDisk(db *database.Database, dsk *disk.Disk) (
+	newSize int, err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"disk_id":  dsk.Id.Hex(),
+		"format":   dsk.Import.Format,
+		"url_host": getImportHost(dsk.Import.Url),
+		"key":      dsk.Import.Key,
+	}).Info("data: Importing disk")
+
+	cacheDir := node.Self.GetCachePath()
+	err = utils.ExistsMkdir(cacheDir, 0755)
+	if err != nil {
+		return
+	}
+
+	srcPth := path.Join(cacheDir, fmt.Sprintf("import-%s", dsk.Id.Hex()))
+	extractDir := path.Join(cacheDir,
+		fmt.Sprintf("import-%s-ova", dsk.Id.Hex()))
+	tmpPth := paths.GetDiskTempPath()
+	defer func() {
+		utils.Remove(srcPth)
+		utils.RemoveAll(extractDir)
+		utils.Remove(tmpPth)
+	}()
+
+	var store *storage.Storage
+	var client *minio.Client
+	if dsk.Import.Url != "" {
+		err = downloadImport(dsk, srcPth)
+		if err != nil {
+			return
+		}
+	} else if dsk.Import.Key != "" {
+		store, err = storage.Get(db, dsk.Import.Storage)
+		if err != nil {
+			return
+		}
+
+		client, err = getStorageClient(store)
+		if err != nil {
+			return
+		}
+
+		err = client.FGetObject(context.Background(), store.Bucket,
+			dsk.Import.Key, srcPth, minio.GetObjectOptions{})
+		if err != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "data: Failed to download import object"),
+			}
+			return
+		}
+	} else {
+		err = &errortypes.NotFoundError{
+			errors.New("data: Disk import missing source"),
+		}
+		return
+	}
+
+	diskPth := srcPth
+	if dsk.Import.Format == disk.Ova {
+		diskPth, err = extractOva(srcPth, extractDir)
+		if err != nil {
+			return
+		}
+	}
+
+	format := dsk.Import.QemuFormat()
+
+	err = inspectImport(diskPth, format)
+	if err != nil {
+		return
+	}
+
+	_, err = execImportQemuImg("convert", "--image-opts",
+		getImportOpts(diskPth, format), "-O", "qcow2", tmpPth)
+	if err != nil {
+		return
+	}
+
+	srcSize, err := getVirtualSize(tmpPth)
+	if err != nil {
+		return
+	}
+
+	size := utils.Max(dsk.Size, srcSize)
+
+	err = writeDiskSource(db, dsk, tmpPth, srcSize, size)
+	if err != nil {
+		return
+	}
+
+	if client != nil {
+		err = client.RemoveObject(context.Background(), store.Bucket,
+			dsk.Import.Key, minio.RemoveObjectOptions{})
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"disk_id": dsk.Id.Hex(),
+				"error":   err,
+			}).Warn("data: Failed to remove disk import object")
+			err = nil
+		}
+	}
+
+	newSize = size
+
+	return
+}
+
+// Remove the previous export object of a disk from the private storage.
+func RemoveDiskExport(db *database.Database, dsk *disk.Disk) (err error) {
+	if dsk.Export == nil || dsk.Export.Key == "" ||
+		dsk.Export.Storage.IsZero() {
+
+		return
+	}
+
+	store, err := storage.Get(db, dsk.Export.Storage)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			dsk.Export.Key = ""
+		}
+		return
+	}
+
+	client, err := getStorageClient(store)
+	if err != nil {
+		return
+	}
+
+	err = client.RemoveObject(context.Background(), store.Bucket,
+		dsk.Export.Key, minio.RemoveObjectOptions{})
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to remove export object"),
+		}
+		return
+	}
+
+	dsk.Export.Key = ""
+
+	return
+}
+
+// Export disk to the private storage of the datacenter in the export
+// format, running disks are copied with a point in time backup.
+func ExportDisk(db *database.Database, dsk *disk.Disk,
+	virt *vm.VirtualMachine) (err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"disk_id": dsk.Id.Hex(),
+		"format":  dsk.Export.Format,
+	}).Info("data: Exporting disk")
+
+	err = RemoveDiskExport(db, dsk)
+	if err != nil {
+		return
+	}
+
+	err = dsk.CommitFields(db, set.NewSet("export"))
+	if err != nil {
+		return
+	}
+
+	store, dc, err := GetDiskStorage(db, dsk)
+	if err != nil {
+		return
+	}
+
+	tmpPth := paths.GetDiskTempPath()
+	exportPth := paths.GetDiskTempPath()
+	defer func() {
+		utils.Remove(tmpPth)
+		utils.Remove(exportPth)
+	}()
+
+	err = copySourceDisk(db, dsk, virt, tmpPth)
+	if err != nil {
+		return
+	}
+
+	if dsk.Export.Format == disk.Qcow2 {
+		exportPth = tmpPth
+	} else {
+		_, err = utils.ExecCombinedOutputLogged(nil,
+			"qemu-img", "convert", "-f", "qcow2",
+			"-O", dsk.Export.QemuFormat(), tmpPth, exportPth)
+		if err != nil {
+			return
+		}
+	}
+
+	client, err := getStorageClient(store)
+	if err != nil {
+		return
+	}
+
+	putOpts := minio.PutObjectOptions{}
+	storageClass := storage.FormatStorageClass(dc.PrivateStorageClass)
+	if storageClass != "" {
+		putOpts.StorageClass = storageClass
+	}
+
+	key := fmt.Sprintf("export/%s.%s", dsk.Id.Hex(), dsk.Export.Format)
+
+	_, err = client.FPutObject(context.Background(),
+		store.Bucket, key, exportPth, putOpts)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "data: Failed to write object"),
+		}
+		return
+	}
+
+	dsk.Export.Storage = store.Id
+	dsk.Export.Key = key
+	dsk.Export.Error = ""
+	dsk.Export.Timestamp = time.Now()
+
+	err = dsk.CommitFields(db, set.NewSet("export"))
+	if err != nil {
+		return
+	}
+
+	return
+}
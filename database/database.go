@@ -208,6 +208,11 @@ func (d *Database) Images() (coll *Collection) {
 	return
 }
 
+func (d *Database) ImageCaches() (coll *Collection) {
+	coll = d.getCollection("image_caches")
+	return
+}
+
 func (d *Database) Datacenters() (coll *Collection) {
 	coll = d.getCollection("datacenters")
 	return
@@ -228,6 +233,11 @@ func (d *Database) Balancers() (coll *Collection) {
 	return
 }
 
+func (d *Database) Builds() (coll *Collection) {
+	coll = d.getCollection("builds")
+	return
+}
+
 func (d *Database) Instances() (coll *Collection) {
 	coll = d.getCollection("instances")
 	return
@@ -754,6 +764,18 @@ func addIndexes() (err error) {
 	if err != nil {
 		return
 	}
+	index = &Index{
+		Collection: db.Images(),
+		Keys: &bson.D{
+			{"family", 1},
+			{"channel", 1},
+			{"version", -1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
 	index = &Index{
 		Collection: db.Images(),
 		Keys: &bson.D{
@@ -777,6 +799,31 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.ImageCaches(),
+		Keys: &bson.D{
+			{"image", 1},
+			{"node", 1},
+		},
+		Unique: true,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.ImageCaches(),
+		Keys: &bson.D{
+			{"image", 1},
+			{"etag", 1},
+			{"zone", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.LvmLock(),
 		Keys: &bson.D{
@@ -1043,6 +1090,29 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.Builds(),
+		Keys: &bson.D{
+			{"organization", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.Builds(),
+		Keys: &bson.D{
+			{"node", 1},
+			{"state", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Authorities(),
 		Keys: &bson.D{
@@ -1151,6 +1221,16 @@ func addIndexes() (err error) {
 	if err != nil {
 		return
 	}
+	index = &Index{
+		Collection: db.Instances(),
+		Keys: &bson.D{
+			{"migration.node", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
 	index = &Index{
 		Collection: db.Instances(),
 		Keys: &bson.D{
@@ -0,0 +1,346 @@
+package deploy
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/build"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/deployment"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/spec"
+	"github.com/pritunl/pritunl-cloud/state"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	buildsLock = utils.NewMultiTimeoutLock(3 * time.Minute)
+)
+
+type Builds struct {
+	stat *state.State
+}
+
+func (b *Builds) start(db *database.Database, bld *build.Build) (err error) {
+	logrus.WithFields(logrus.Fields{
+		"build_id":   bld.Id.Hex(),
+		"base_image": bld.BaseImage.Hex(),
+		"image_name": bld.GetImageName(),
+	}).Info("deploy: Starting image build")
+
+	img, err := image.Get(db, bld.BaseImage)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = b.fail(db, bld, "Base image not found")
+		}
+		return
+	}
+
+	spc := spec.New(primitive.NilObjectID, primitive.NilObjectID,
+		bld.Organization, bld.GetSpecData())
+	spc.Name = bld.Name
+	spc.Kind = deployment.Image
+	spc.Instance = &spec.Instance{
+		Zone:       bld.Zone,
+		Node:       bld.Node,
+		Vpc:        bld.Vpc,
+		Subnet:     bld.Subnet,
+		Processors: bld.Processors,
+		Memory:     bld.Memory,
+		Image:      bld.BaseImage,
+		DiskSize:   bld.DiskSize,
+	}
+
+	err = spc.Insert(db)
+	if err != nil {
+		return
+	}
+	bld.Spec = spc.Id
+
+	deply := &deployment.Deployment{
+		Build:     bld.Id,
+		Timestamp: time.Now(),
+		Spec:      spc.Id,
+		Zone:      node.Self.Zone,
+		Node:      node.Self.Id,
+		Kind:      deployment.Image,
+		State:     deployment.Reserved,
+	}
+
+	errData, err := deply.Validate(db)
+	if err != nil {
+		return
+	}
+
+	if errData != nil {
+		err = b.fail(db, bld, errData.Message)
+		return
+	}
+
+	err = deply.Insert(db)
+	if err != nil {
+		return
+	}
+	bld.Deployment = deply.Id
+
+	inst := &instance.Instance{
+		Organization: bld.Organization,
+		Zone:         bld.Zone,
+		Vpc:          bld.Vpc,
+		Subnet:       bld.Subnet,
+		Node:         node.Self.Id,
+		Image:        bld.BaseImage,
+		Uefi:         img.Firmware != image.Bios,
+		SecureBoot:   false,
+		CloudType:    instance.Linux,
+		Name:         bld.Name,
+		Comment:      "Image build",
+		InitDiskSize: bld.DiskSize,
+		Memory:       bld.Memory,
+		Processors:   bld.Processors,
+		Deployment:   deply.Id,
+	}
+
+	err = inst.GenerateId()
+	if err != nil {
+		return
+	}
+
+	errData, err = inst.Validate(db)
+	if err != nil {
+		return
+	}
+
+	if errData != nil {
+		err = b.fail(db, bld, errData.Message)
+		return
+	}
+
+	err = inst.Insert(db)
+	if err != nil {
+		return
+	}
+
+	deply.State = deployment.Deployed
+	deply.Instance = inst.Id
+	err = deply.CommitFields(db, set.NewSet("state", "instance"))
+	if err != nil {
+		return
+	}
+
+	bld.State = build.Building
+	bld.Error = ""
+	bld.Timestamp = time.Now()
+	err = bld.CommitFields(db, set.NewSet(
+		"state", "error", "timestamp", "spec", "deployment"))
+	if err != nil {
+		return
+	}
+
+	event.PublishDispatch(db, "instance.change")
+	event.PublishDispatch(db, "build.change")
+
+	return
+}
+
+func (b *Builds) finish(db *database.Database, bld *build.Build,
+	deply *deployment.Deployment) (err error) {
+
+	img, err := image.Get(db, deply.Image)
+	if err != nil {
+		return
+	}
+
+	img.Name = bld.GetImageName()
+	img.Deployment = primitive.NilObjectID
+	err = img.CommitFields(db, set.NewSet("name", "deployment"))
+	if err != nil {
+		return
+	}
+
+	// Clear the image before destroying the deployment to keep the built
+	// image, the build instance is removed with the deployment.
+	deply.Image = primitive.NilObjectID
+	deply.State = deployment.Destroy
+	err = deply.CommitFields(db, set.NewSet("image", "state"))
+	if err != nil {
+		return
+	}
+
+	err = spec.Remove(db, bld.Spec)
+	if err != nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"build_id":   bld.Id.Hex(),
+		"image_id":   img.Id.Hex(),
+		"image_name": img.Name,
+	}).Info("deploy: Image build complete")
+
+	bld.Version += 1
+	bld.Image = img.Id
+	bld.State = build.Idle
+	bld.Error = ""
+	bld.Spec = primitive.NilObjectID
+	bld.Deployment = primitive.NilObjectID
+	err = bld.CommitFields(db, set.NewSet(
+		"version", "image", "state", "error", "spec", "deployment"))
+	if err != nil {
+		return
+	}
+
+	event.PublishDispatch(db, "image.change")
+	event.PublishDispatch(db, "build.change")
+
+	return
+}
+
+func (b *Builds) fail(db *database.Database, bld *build.Build,
+	message string) (err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"build_id": bld.Id.Hex(),
+		"message":  message,
+	}).Error("deploy: Image build failed")
+
+	if !bld.Deployment.IsZero() {
+		deply, e := deployment.Get(db, bld.Deployment)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); !ok {
+				err = e
+				return
+			}
+			deply = nil
+		}
+
+		if deply != nil {
+			deply.State = deployment.Destroy
+			err = deply.CommitFields(db, set.NewSet("state"))
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	if !bld.Spec.IsZero() {
+		err = spec.Remove(db, bld.Spec)
+		if err != nil {
+			return
+		}
+	}
+
+	bld.State = build.Failed
+	bld.Error = message
+	bld.Spec = primitive.NilObjectID
+	bld.Deployment = primitive.NilObjectID
+	err = bld.CommitFields(db, set.NewSet(
+		"state", "error", "spec", "deployment"))
+	if err != nil {
+		return
+	}
+
+	event.PublishDispatch(db, "build.change")
+
+	return
+}
+
+func (b *Builds) check(db *database.Database, bld *build.Build) (err error) {
+	deply, err := deployment.Get(db, bld.Deployment)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = b.fail(db, bld, "Build deployment lost")
+		}
+		return
+	}
+
+	switch deply.GetImageState() {
+	case deployment.Complete:
+		if deply.ImageReady() {
+			err = b.finish(db, bld, deply)
+			return
+		}
+		break
+	case deployment.Failed:
+		err = b.fail(db, bld, "Failed to create image from build disk")
+		return
+	}
+
+	if time.Since(bld.Timestamp) > build.Timeout {
+		err = b.fail(db, bld, "Build timed out")
+		return
+	}
+
+	return
+}
+
+func (b *Builds) process(bld *build.Build) {
+	acquired, lockId := buildsLock.LockOpen(bld.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(1 * time.Second)
+			buildsLock.Unlock(bld.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		var err error
+		switch bld.State {
+		case build.Queued:
+			err = b.start(db, bld)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"build_id": bld.Id.Hex(),
+					"error":    err,
+				}).Error("deploy: Failed to start image build")
+
+				err = b.fail(db, bld, "Failed to start image build")
+			}
+			break
+		case build.Building:
+			err = b.check(db, bld)
+			break
+		}
+
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"build_id": bld.Id.Hex(),
+				"error":    err,
+			}).Error("deploy: Failed to process image build")
+			return
+		}
+	}()
+}
+
+func (b *Builds) Deploy(db *database.Database) (err error) {
+	if b.stat.Node().Maintenance {
+		return
+	}
+
+	blds, err := build.GetAllNode(db, b.stat.Node().Id)
+	if err != nil {
+		return
+	}
+
+	for _, bld := range blds {
+		b.process(bld)
+	}
+
+	return
+}
+
+func NewBuilds(stat *state.State) *Builds {
+	return &Builds{
+		stat: stat,
+	}
+}
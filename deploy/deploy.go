@@ -56,6 +56,14 @@ func Deploy(stat *state.State, runtimes *state.Runtimes) (err error) {
 	}
 	runtimes.Instances = time.Since(start)
 
+	start = time.Now()
+	migrations := NewMigrations(stat)
+	err = migrations.Deploy(db)
+	if err != nil {
+		return
+	}
+	runtimes.Migrations = time.Since(start)
+
 	start = time.Now()
 	namespaces := NewNamespace(stat)
 	err = namespaces.Deploy()
@@ -80,6 +88,14 @@ func Deploy(stat *state.State, runtimes *state.Runtimes) (err error) {
 	}
 	runtimes.Deployments = time.Since(start)
 
+	start = time.Now()
+	builds := NewBuilds(stat)
+	err = builds.Deploy(db)
+	if err != nil {
+		return
+	}
+	runtimes.Builds = time.Since(start)
+
 	start = time.Now()
 	imds := NewImds(stat)
 	err = imds.Deploy(db)
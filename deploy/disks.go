@@ -5,6 +5,8 @@ import (
 
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/constants"
 	"github.com/pritunl/pritunl-cloud/data"
 	"github.com/pritunl/pritunl-cloud/database"
@@ -22,6 +24,7 @@ import (
 var (
 	disksLock     = utils.NewMultiTimeoutLock(5 * time.Minute)
 	backupLimiter = utils.NewLimiter(3)
+	cloneTimeout  = 1 * time.Hour
 )
 
 type Disks struct {
@@ -49,6 +52,13 @@ func (d *Disks) provision(dsk *disk.Disk) {
 			logrus.WithFields(logrus.Fields{
 				"error": err,
 			}).Error("deploy: Failed to provision disk")
+
+			if dsk.Import != nil {
+				dsk.State = disk.Failed
+				dsk.Import.Error = err.Error()
+				_ = dsk.CommitFields(db, set.NewSet("state", "import"))
+				event.PublishDispatch(db, "disk.change")
+			}
 			return
 		}
 
@@ -71,7 +81,149 @@ func (d *Disks) provision(dsk *disk.Disk) {
 	}()
 }
 
+func (d *Disks) cloneFail(db *database.Database, dsk *disk.Disk,
+	message string) {
+
+	logrus.WithFields(logrus.Fields{
+		"disk_id":        dsk.Id.Hex(),
+		"source_disk_id": dsk.CloneSource.Hex(),
+		"message":        message,
+	}).Error("deploy: Disk clone failed")
+
+	dsk.State = disk.Failed
+	err := dsk.CommitFields(db, set.NewSet("state"))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"disk_id": dsk.Id.Hex(),
+			"error":   err,
+		}).Error("deploy: Failed update disk state")
+		return
+	}
+
+	event.PublishDispatch(db, "disk.change")
+}
+
+func (d *Disks) clone(db *database.Database, dsk *disk.Disk) {
+	// Clones that cannot be served by any node are failed after the
+	// timeout instead of waiting for the source disk indefinitely
+	expired := time.Since(dsk.Id.Timestamp()) > cloneTimeout
+
+	srcDsk, err := disk.Get(db, dsk.CloneSource)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			d.cloneFail(db, dsk, "Clone source disk not found")
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"disk_id":        dsk.Id.Hex(),
+			"source_disk_id": dsk.CloneSource.Hex(),
+			"error":          err,
+		}).Error("deploy: Failed to get clone source disk")
+		return
+	}
+
+	if srcDsk.State != disk.Available {
+		if expired {
+			d.cloneFail(db, dsk, "Clone source disk not available")
+		}
+		return
+	}
+
+	nde := d.stat.Node()
+	if dsk.IsPooled() && !nde.HasPool(dsk.Pool) {
+		return
+	}
+
+	var virt *vm.VirtualMachine
+	if !srcDsk.Instance.IsZero() {
+		inst, e := instance.Get(db, srcDsk.Instance)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"disk_id":        dsk.Id.Hex(),
+				"source_disk_id": srcDsk.Id.Hex(),
+				"error":          e,
+			}).Error("deploy: Failed to get clone source instance")
+			return
+		}
+
+		if inst.Node != nde.Id {
+			if expired {
+				d.cloneFail(db, dsk,
+					"Clone source instance not on disk node")
+			}
+			return
+		}
+		virt = d.stat.GetVirt(inst.Id)
+	} else if srcDsk.IsPooled() && !nde.HasPool(srcDsk.Pool) {
+		if expired {
+			d.cloneFail(db, dsk, "Clone source pool not on disk node")
+		}
+		return
+	} else if !srcDsk.IsPooled() && srcDsk.Node != nde.Id {
+		if expired {
+			d.cloneFail(db, dsk, "Clone source disk not on disk node")
+		}
+		return
+	}
+
+	acquired, lockId := disksLock.LockOpen(dsk.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	srcAcquired, srcLockId := disksLock.LockOpen(srcDsk.Id.Hex())
+	if !srcAcquired {
+		disksLock.Unlock(dsk.Id.Hex(), lockId)
+		return
+	}
+
+	go func() {
+		defer func() {
+			disksLock.Unlock(srcDsk.Id.Hex(), srcLockId)
+			disksLock.Unlock(dsk.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		if constants.Interrupt {
+			return
+		}
+
+		newSize, err := data.CloneDisk(db, dsk, srcDsk, virt)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"disk_id":        dsk.Id.Hex(),
+				"source_disk_id": srcDsk.Id.Hex(),
+				"error":          err,
+			}).Error("deploy: Failed to clone disk")
+
+			d.cloneFail(db, dsk, "Failed to copy clone source disk")
+			return
+		}
+
+		dsk.State = disk.Available
+		dsk.CloneSource = primitive.NilObjectID
+		dsk.Size = newSize
+
+		err = dsk.CommitFields(db, set.NewSet(
+			"state", "clone_source", "size"))
+		if err != nil {
+			return
+		}
+
+		event.PublishDispatch(db, "disk.change")
+	}()
+}
+
 func (d *Disks) snapshot(dsk *disk.Disk) {
+	if dsk.IsPooled() && !dsk.Instance.IsZero() &&
+		d.stat.GetInstace(dsk.Instance) == nil {
+
+		return
+	}
+
 	acquired, lockId := disksLock.LockOpen(dsk.Id.Hex())
 	if !acquired {
 		return
@@ -87,11 +239,16 @@ func (d *Disks) snapshot(dsk *disk.Disk) {
 			return
 		}
 
-		if dsk.Type != disk.Qcow2 {
+		if dsk.Type != disk.Qcow2 && !dsk.IsPooled() {
 			logrus.WithFields(logrus.Fields{
 				"disk_id":   dsk.Id.Hex(),
 				"disk_type": dsk.Type,
 			}).Error("deploy: Disk type does not support snapshot")
+		} else if dsk.IsPooled() && d.stat.GetVirt(dsk.Instance) == nil {
+			logrus.WithFields(logrus.Fields{
+				"disk_id":   dsk.Id.Hex(),
+				"disk_type": dsk.Type,
+			}).Error("deploy: Pool disk must be attached to snapshot")
 		} else {
 			virt := d.stat.GetVirt(dsk.Instance)
 			if virt == nil {
@@ -129,14 +286,14 @@ func (d *Disks) snapshot(dsk *disk.Disk) {
 	}()
 }
 
-func (d *Disks) expand(dsk *disk.Disk) {
-	acquired, lockId := disksLock.LockOpen(dsk.Id.Hex())
+func (d *Disks) snapshotSet(setId primitive.ObjectID, dsks []*disk.Disk) {
+	acquired, lockId := disksLock.LockOpen(setId.Hex())
 	if !acquired {
 		return
 	}
 
 	go func() {
-		defer disksLock.Unlock(dsk.Id.Hex(), lockId)
+		defer disksLock.Unlock(setId.Hex(), lockId)
 
 		db := database.GetDatabase()
 		defer db.Close()
@@ -145,36 +302,132 @@ func (d *Disks) expand(dsk *disk.Disk) {
 			return
 		}
 
-		inst := d.stat.GetInstace(dsk.Instance)
-		if inst != nil {
-			if inst.State != instance.Stop {
-				inst.State = instance.Stop
+		instId := dsks[0].Instance
 
+		setDsks, err := disk.GetAll(db, &bson.M{
+			"snapshot_set": setId,
+			"state":        disk.Snapshot,
+		})
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"snapshot_set": setId.Hex(),
+				"error":        err,
+			}).Error("deploy: Failed to load snapshot set disks")
+			return
+		}
+
+		instDsks, err := disk.GetInstance(db, instId)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"snapshot_set": setId.Hex(),
+				"error":        err,
+			}).Error("deploy: Failed to load instance disks")
+			return
+		}
+
+		if len(setDsks) == 0 || len(setDsks) != len(instDsks) {
+			logrus.WithFields(logrus.Fields{
+				"snapshot_set":   setId.Hex(),
+				"set_disks":      len(setDsks),
+				"instance_disks": len(instDsks),
+			}).Error("deploy: Snapshot set does not match instance disks")
+			d.clearSnapshotSet(db, setId)
+			return
+		}
+
+		for _, dsk := range setDsks {
+			if dsk.Instance != instId {
 				logrus.WithFields(logrus.Fields{
-					"instance_id": inst.Id.Hex(),
-					"disk_id":     dsk.Id.Hex(),
-				}).Info("deploy: Stopping instance for resize")
+					"snapshot_set": setId.Hex(),
+					"disk_id":      dsk.Id.Hex(),
+				}).Error("deploy: Snapshot set disk instance mismatch")
+				d.clearSnapshotSet(db, setId)
+				return
+			}
+		}
 
-				err := inst.CommitFields(db, set.NewSet("state"))
-				if err != nil {
-					logrus.WithFields(logrus.Fields{
-						"error": err,
-					}).Error("deploy: Failed to commit instance state")
-					return
-				}
+		supported := true
+		for _, dsk := range setDsks {
+			if dsk.Type != disk.Qcow2 {
+				logrus.WithFields(logrus.Fields{
+					"disk_id":   dsk.Id.Hex(),
+					"disk_type": dsk.Type,
+				}).Error("deploy: Disk type does not support snapshot")
+				supported = false
+			}
+		}
 
+		if supported {
+			virt := d.stat.GetVirt(instId)
+			if virt == nil {
+				err := &errortypes.ReadError{
+					errors.New("deploy: Failed to load virt"),
+				}
+				logrus.WithFields(logrus.Fields{
+					"snapshot_set": setId.Hex(),
+					"error":        err,
+				}).Error("deploy: Failed to load virt")
+				d.clearSnapshotSet(db, setId)
 				return
 			}
 
-			virt := d.stat.GetVirt(inst.Id)
-			if virt != nil && virt.State != vm.Stopped &&
-				virt.State != vm.Failed {
+			err = data.CreateSnapshotSet(db, setId, setDsks, virt)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"snapshot_set": setId.Hex(),
+					"error":        err,
+				}).Error("deploy: Failed to snapshot disk set")
+			}
+		}
+
+		d.clearSnapshotSet(db, setId)
+	}()
+}
+
+func (d *Disks) clearSnapshotSet(db *database.Database,
+	setId primitive.ObjectID) {
+
+	err := disk.ClearSnapshotSet(db, setId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"snapshot_set": setId.Hex(),
+			"error":        err,
+		}).Error("deploy: Failed update disk state")
+		time.Sleep(5 * time.Second)
+		return
+	}
+
+	event.PublishDispatch(db, "disk.change")
+}
+
+func (d *Disks) expand(dsk *disk.Disk) {
+	acquired, lockId := disksLock.LockOpen(dsk.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer disksLock.Unlock(dsk.Id.Hex(), lockId)
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		if constants.Interrupt {
+			return
+		}
+
+		var virt *vm.VirtualMachine
+		inst := d.stat.GetInstace(dsk.Instance)
+		if inst != nil {
+			virt = d.stat.GetVirt(inst.Id)
+			if virt != nil && virt.State != vm.Running &&
+				virt.State != vm.Stopped && virt.State != vm.Failed {
 
 				return
 			}
 		}
 
-		err := data.ExpandDisk(db, dsk)
+		err := data.ExpandDisk(db, dsk, virt)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"error": err,
@@ -196,7 +449,73 @@ func (d *Disks) expand(dsk *disk.Disk) {
 	}()
 }
 
+func (d *Disks) export(dsk *disk.Disk) {
+	if dsk.IsPooled() && !dsk.Instance.IsZero() &&
+		d.stat.GetVirt(dsk.Instance) == nil {
+
+		return
+	}
+
+	acquired, lockId := disksLock.LockOpen(dsk.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer disksLock.Unlock(dsk.Id.Hex(), lockId)
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		if constants.Interrupt {
+			return
+		}
+
+		var virt *vm.VirtualMachine
+		if !dsk.Instance.IsZero() {
+			virt = d.stat.GetVirt(dsk.Instance)
+		}
+
+		fields := set.NewSet("state")
+		if dsk.Export == nil {
+			logrus.WithFields(logrus.Fields{
+				"disk_id": dsk.Id.Hex(),
+			}).Error("deploy: Disk export missing format")
+		} else {
+			err := data.ExportDisk(db, dsk, virt)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"disk_id": dsk.Id.Hex(),
+					"error":   err,
+				}).Error("deploy: Failed to export disk")
+
+				dsk.Export.Error = err.Error()
+				fields.Add("export")
+			}
+		}
+
+		dsk.State = disk.Available
+		err := dsk.CommitFields(db, fields)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"disk_id": dsk.Id.Hex(),
+				"error":   err,
+			}).Error("deploy: Failed update disk state")
+			time.Sleep(5 * time.Second)
+			return
+		}
+
+		event.PublishDispatch(db, "disk.change")
+	}()
+}
+
 func (d *Disks) backup(dsk *disk.Disk) {
+	if dsk.IsPooled() && !dsk.Instance.IsZero() &&
+		d.stat.GetInstace(dsk.Instance) == nil {
+
+		return
+	}
+
 	if !backupLimiter.Acquire() {
 		return
 	}
@@ -221,11 +540,16 @@ func (d *Disks) backup(dsk *disk.Disk) {
 			return
 		}
 
-		if dsk.Type != disk.Qcow2 {
+		if dsk.Type != disk.Qcow2 && !dsk.IsPooled() {
 			logrus.WithFields(logrus.Fields{
 				"disk_id":   dsk.Id.Hex(),
 				"disk_type": dsk.Type,
 			}).Error("deploy: Disk type does not support backup")
+		} else if dsk.IsPooled() && d.stat.GetVirt(dsk.Instance) == nil {
+			logrus.WithFields(logrus.Fields{
+				"disk_id":   dsk.Id.Hex(),
+				"disk_type": dsk.Type,
+			}).Error("deploy: Pool disk must be attached to backup")
 		} else {
 			virt := d.stat.GetVirt(dsk.Instance)
 			if virt == nil {
@@ -239,7 +563,7 @@ func (d *Disks) backup(dsk *disk.Disk) {
 				return
 			}
 
-			err := data.CreateBackup(db, dsk, virt)
+			err := data.CreateBackup(db, dsk, virt, false)
 			if err != nil {
 				logrus.WithFields(logrus.Fields{
 					"error": err,
@@ -379,7 +703,16 @@ func (d *Disks) destroy(db *database.Database, dsk *disk.Disk) {
 			return
 		}
 
-		err := dsk.Destroy(db)
+		err := data.RemoveDiskExport(db, dsk)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"disk_id": dsk.Id.Hex(),
+				"error":   err,
+			}).Warn("deploy: Failed to remove disk export object")
+			err = nil
+		}
+
+		err = dsk.Destroy(db)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"error": err,
@@ -392,8 +725,8 @@ func (d *Disks) destroy(db *database.Database, dsk *disk.Disk) {
 	}()
 }
 
-func (d *Disks) scheduleBackup(dsk *disk.Disk) {
-	if time.Since(dsk.LastBackup) < 24*time.Hour {
+func (d *Disks) scheduleBackup(dsk *disk.Disk, scheduled bool) {
+	if !scheduled && time.Since(dsk.LastBackup) < 24*time.Hour {
 		return
 	}
 
@@ -429,9 +762,25 @@ func (d *Disks) scheduleBackup(dsk *disk.Disk) {
 			"disk_id": dsk.Id.Hex(),
 		}).Info("deploy: Scheduling automatic disk backup")
 
+		fields := set.NewSet("state", "last_backup")
+
 		dsk.State = disk.Backup
 		dsk.LastBackup = time.Now()
-		err := dsk.CommitFields(db, set.NewSet("state", "last_backup"))
+
+		if scheduled {
+			next, err := dsk.BackupSchedule.Next(time.Now())
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"disk_id": dsk.Id.Hex(),
+					"error":   err,
+				}).Error("deploy: Failed to parse disk backup schedule")
+			}
+
+			dsk.NextBackup = next
+			fields.Add("next_backup")
+		}
+
+		err := dsk.CommitFields(db, fields)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"error": err,
@@ -454,11 +803,19 @@ func (d *Disks) scheduleBackup(dsk *disk.Disk) {
 			return
 		}
 
-		err = data.CreateBackup(db, dsk, virt)
+		err = data.CreateBackup(db, dsk, virt, scheduled)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"error": err,
 			}).Error("deploy: Failed to backup disk")
+		} else if scheduled {
+			err = data.PruneBackups(db, dsk)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"disk_id": dsk.Id.Hex(),
+					"error":   err,
+				}).Error("deploy: Failed to prune disk backups")
+			}
 		}
 
 		dsk.State = disk.Available
@@ -487,13 +844,24 @@ func (d *Disks) Deploy(db *database.Database) (err error) {
 		backupActive = true
 	}
 
+	snapshotSets := map[primitive.ObjectID][]*disk.Disk{}
+
 	for _, dsk := range disks {
 		switch dsk.State {
 		case disk.Provision:
-			d.provision(dsk)
+			if !dsk.CloneSource.IsZero() {
+				d.clone(db, dsk)
+			} else {
+				d.provision(dsk)
+			}
 			break
 		case disk.Snapshot:
-			d.snapshot(dsk)
+			if !dsk.SnapshotSet.IsZero() {
+				snapshotSets[dsk.SnapshotSet] = append(
+					snapshotSets[dsk.SnapshotSet], dsk)
+			} else {
+				d.snapshot(dsk)
+			}
 			break
 		case disk.Backup:
 			d.backup(dsk)
@@ -504,17 +872,34 @@ func (d *Disks) Deploy(db *database.Database) (err error) {
 		case disk.Expand:
 			d.expand(dsk)
 			break
+		case disk.Export:
+			d.export(dsk)
+			break
 		case disk.Destroy:
 			d.destroy(db, dsk)
 			break
 		case disk.Available:
-			if backupActive && dsk.Backup {
-				d.scheduleBackup(dsk)
+			if !dsk.Backup {
+				break
+			}
+
+			if dsk.BackupSchedule != nil {
+				if !dsk.NextBackup.IsZero() &&
+					time.Now().After(dsk.NextBackup) {
+
+					d.scheduleBackup(dsk, true)
+				}
+			} else if backupActive {
+				d.scheduleBackup(dsk, false)
 			}
 			break
 		}
 	}
 
+	for setId, dsks := range snapshotSets {
+		d.snapshotSet(setId, dsks)
+	}
+
 	return
 }
 
@@ -35,7 +35,7 @@ func (s *Imds) buildInstance(db *database.Database,
 	}
 
 	conf, err = imds.BuildConfig(
-		inst, virt, nil,
+		inst, virt, s.stat.GetInstaceDisks(inst.Id), nil,
 		vc, subnet,
 		[]*pod.Pod{},
 		map[primitive.ObjectID]*deployment.Deployment{},
@@ -116,7 +116,7 @@ func (s *Imds) buildDeployInstance(db *database.Database,
 	}
 
 	conf, err = imds.BuildConfig(
-		inst, virt, spc,
+		inst, virt, s.stat.GetInstaceDisks(inst.Id), spc,
 		vc, subnet,
 		pods,
 		s.stat.DeploymentsDeployed(),
@@ -1,18 +1,23 @@
 package deploy
 
 import (
+	"fmt"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/arp"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/drive"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/iproute"
 	"github.com/pritunl/pritunl-cloud/netconf"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/permission"
@@ -28,8 +33,13 @@ import (
 )
 
 var (
-	instancesLock = utils.NewMultiTimeoutLock(5 * time.Minute)
-	limiter       = utils.NewLimiter(5)
+	instancesLock     = utils.NewMultiTimeoutLock(5 * time.Minute)
+	limiter           = utils.NewLimiter(5)
+	diskQosStates     = map[primitive.ObjectID]*limitState{}
+	diskQosStatesLock = sync.Mutex{}
+
+	networkLimitStates     = map[primitive.ObjectID]*limitState{}
+	networkLimitStatesLock = sync.Mutex{}
 )
 
 type Instances struct {
@@ -459,6 +469,184 @@ func (s *Instances) diskRemove(inst *instance.Instance,
 	}()
 }
 
+type limitState struct {
+	timestamp time.Time
+	key       string
+}
+
+func pruneLimitStates(activeIds set.Set) {
+	diskQosStatesLock.Lock()
+	for instId := range diskQosStates {
+		if !activeIds.Contains(instId) {
+			delete(diskQosStates, instId)
+		}
+	}
+	diskQosStatesLock.Unlock()
+
+	networkLimitStatesLock.Lock()
+	for instId := range networkLimitStates {
+		if !activeIds.Contains(instId) {
+			delete(networkLimitStates, instId)
+		}
+	}
+	networkLimitStatesLock.Unlock()
+}
+
+func (s *Instances) diskQos(inst *instance.Instance,
+	virt *vm.VirtualMachine) {
+
+	dskIds := []primitive.ObjectID{}
+	dskQoss := []*vm.DiskQos{}
+	devIds := []string{}
+	qoss := []*vm.DiskQos{}
+	active := false
+	keys := []string{}
+
+	for _, dsk := range inst.Virt.Disks {
+		dskIds = append(dskIds, dsk.Id)
+		dskQoss = append(dskQoss, dsk.Qos)
+		keys = append(keys, fmt.Sprintf(
+			"tg_%s:%s", dsk.Id.Hex(), dsk.Qos.Key()))
+		if !dsk.Qos.IsZero() {
+			active = true
+		}
+	}
+
+	for _, device := range inst.Virt.DriveDevices {
+		if device.Type != vm.Lvm && device.Type != vm.Rbd {
+			continue
+		}
+
+		devIds = append(devIds, fmt.Sprintf("pdd_%s",
+			drive.GetDriveHashId(device.Id)))
+		qoss = append(qoss, device.Qos)
+		if !device.Qos.IsZero() {
+			active = true
+		}
+	}
+
+	for i, devId := range devIds {
+		keys = append(keys, devId+":"+qoss[i].Key())
+	}
+	key := strings.Join(keys, ",")
+
+	diskQosStatesLock.Lock()
+	curState, ok := diskQosStates[inst.Id]
+	diskQosStatesLock.Unlock()
+
+	newState := &limitState{
+		timestamp: virt.Timestamp,
+		key:       key,
+	}
+
+	if ok && curState.timestamp.Equal(newState.timestamp) {
+		if curState.key == newState.key {
+			return
+		}
+	} else if !active {
+		diskQosStatesLock.Lock()
+		diskQosStates[inst.Id] = newState
+		diskQosStatesLock.Unlock()
+		return
+	}
+
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer instancesLock.Unlock(inst.Id.Hex(), lockId)
+
+		for i, dskId := range dskIds {
+			err := qmp.SetDiskGroupQos(inst.Id, dskId, dskQoss[i])
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": inst.Id.Hex(),
+					"disk_id":     dskId.Hex(),
+					"error":       err,
+				}).Error("sync: Failed to set disk qos")
+				return
+			}
+		}
+
+		for i, devId := range devIds {
+			err := qmp.SetDiskQos(inst.Id, devId, qoss[i])
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": inst.Id.Hex(),
+					"device_id":   devId,
+					"error":       err,
+				}).Error("sync: Failed to set disk qos")
+				return
+			}
+		}
+
+		diskQosStatesLock.Lock()
+		diskQosStates[inst.Id] = newState
+		diskQosStatesLock.Unlock()
+	}()
+}
+
+func (s *Instances) networkLimit(inst *instance.Instance,
+	virt *vm.VirtualMachine) {
+
+	key := fmt.Sprintf("%d-%d", inst.NetworkIngress, inst.NetworkEgress)
+	active := inst.NetworkIngress != 0 || inst.NetworkEgress != 0
+
+	networkLimitStatesLock.Lock()
+	curState, ok := networkLimitStates[inst.Id]
+	networkLimitStatesLock.Unlock()
+
+	newState := &limitState{
+		timestamp: virt.Timestamp,
+		key:       key,
+	}
+
+	if ok && curState.timestamp.Equal(newState.timestamp) {
+		if curState.key == newState.key {
+			return
+		}
+	} else if !active {
+		networkLimitStatesLock.Lock()
+		networkLimitStates[inst.Id] = newState
+		networkLimitStatesLock.Unlock()
+		return
+	}
+
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer instancesLock.Unlock(inst.Id.Hex(), lockId)
+
+		namespace := vm.GetNamespace(inst.Id, 0)
+		iface := vm.GetIface(inst.Id, 0)
+
+		logrus.WithFields(logrus.Fields{
+			"instance_id": inst.Id.Hex(),
+			"ingress":     inst.NetworkIngress,
+			"egress":      inst.NetworkEgress,
+		}).Info("sync: Setting network bandwidth limits")
+
+		err := iproute.ShapingSet(namespace, iface,
+			inst.NetworkIngress, inst.NetworkEgress)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("sync: Failed to set network bandwidth limits")
+			return
+		}
+
+		networkLimitStatesLock.Lock()
+		networkLimitStates[inst.Id] = newState
+		networkLimitStatesLock.Unlock()
+	}()
+}
+
 func (s *Instances) usbAdd(inst *instance.Instance, virt *vm.VirtualMachine,
 	addUsbs []*vm.UsbDevice) {
 
@@ -597,6 +785,16 @@ func (s *Instances) diff(db *database.Database,
 		s.diskAdd(inst, curVirt, addDisks)
 	}
 
+	if len(addDisks) == 0 && len(remDisks) == 0 &&
+		curVirt.State == vm.Running {
+
+		s.diskQos(inst, curVirt)
+	}
+
+	if curVirt.State == vm.Running {
+		s.networkLimit(inst, curVirt)
+	}
+
 	if len(remUsbs) > 0 {
 		s.usbRemove(inst, curVirt, remUsbs)
 	}
@@ -834,9 +1032,13 @@ func (s *Instances) Deploy(db *database.Database) (err error) {
 
 	cpuUnits := 0
 	memoryUnits := 0.0
+	activeIds := set.NewSet()
 
 	for _, inst := range instances {
 		curVirt := s.stat.GetVirt(inst.Id)
+		if curVirt != nil {
+			activeIds.Add(inst.Id)
+		}
 
 		if inst.State == instance.Destroy {
 			if inst.DeleteProtection {
@@ -864,9 +1066,25 @@ func (s *Instances) Deploy(db *database.Database) (err error) {
 		cpuUnits += inst.Processors
 		memoryUnits += float64(inst.Memory) / float64(1024)
 
+		if inst.Migration != nil &&
+			inst.Migration.State != instance.MigrationFailed {
+
+			continue
+		}
+
 		if curVirt == nil {
 			if inst.State == instance.Start {
-				s.create(inst)
+				provisioning := false
+				for _, dsk := range s.stat.GetInstaceDisks(inst.Id) {
+					if dsk.State == disk.Provision {
+						provisioning = true
+						break
+					}
+				}
+
+				if !provisioning {
+					s.create(inst)
+				}
 			}
 
 			continue
@@ -943,6 +1161,8 @@ func (s *Instances) Deploy(db *database.Database) (err error) {
 		}
 	}
 
+	pruneLimitStates(activeIds)
+
 	node.Self.CpuUnitsRes = cpuUnits
 	node.Self.MemoryUnitsRes = memoryUnits
 
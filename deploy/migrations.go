@@ -0,0 +1,638 @@
+package deploy
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/iptables"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/qemu"
+	"github.com/pritunl/pritunl-cloud/qmp"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/state"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	migrationsLimiter = utils.NewLimiter(2)
+)
+
+type Migrations struct {
+	stat *state.State
+}
+
+func (s *Migrations) getTimeout() time.Duration {
+	return time.Duration(
+		settings.Hypervisor.MigrationTimeout)*time.Second + 5*time.Minute
+}
+
+// Check if a transfer or finalize has exceeded the migration timeout and is
+// not running on this node.
+func (s *Migrations) isExpired(inst *instance.Instance) bool {
+	if instancesLock.Locked(inst.Id.Hex()) {
+		return false
+	}
+
+	return time.Since(inst.Migration.Timestamp) > s.getTimeout()
+}
+
+func (s *Migrations) fail(db *database.Database, inst *instance.Instance,
+	curState string, err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": inst.Id.Hex(),
+		"node":        inst.Migration.Node.Hex(),
+		"state":       curState,
+		"error":       err,
+	}).Error("deploy: Instance migration failed")
+
+	e := instance.FailMigration(db, inst.Id, curState, err.Error())
+	if e != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": inst.Id.Hex(),
+			"error":       e,
+		}).Error("deploy: Failed to update instance migration")
+	}
+
+	event.PublishDispatch(db, "instance.change")
+}
+
+func (s *Migrations) prepare(inst *instance.Instance) {
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		curVirt := s.stat.GetVirt(inst.Id)
+		if curVirt == nil || curVirt.State != vm.Running {
+			s.fail(db, inst, instance.MigrationPending,
+				&errortypes.ReadError{
+					errors.New("deploy: Instance not running"),
+				})
+			return
+		}
+
+		sizes, err := qmp.GetDiskSizes(inst.Id)
+		if err != nil {
+			s.fail(db, inst, instance.MigrationPending, err)
+			return
+		}
+
+		for _, dsk := range inst.Migration.Disks {
+			size, ok := sizes[dsk.Id]
+			if !ok || size == 0 {
+				s.fail(db, inst, instance.MigrationPending,
+					&errortypes.NotFoundError{
+						errors.Newf("deploy: Migration disk %s not attached",
+							dsk.Id.Hex()),
+					})
+				return
+			}
+			dsk.Size = size
+		}
+
+		sourceCert, err := qemu.InitMigrateTls(inst.Virt, qemu.MigrateClient,
+			node.Self.Id.Hex(), "")
+		if err != nil {
+			s.fail(db, inst, instance.MigrationPending, err)
+			return
+		}
+
+		_, err = instance.UpdateMigration(db, inst.Id,
+			instance.MigrationPending, bson.M{
+				"migration.state":       instance.MigrationPrepare,
+				"migration.disks":       inst.Migration.Disks,
+				"migration.source_cert": sourceCert,
+			})
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to update instance migration")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
+func (s *Migrations) incoming(inst *instance.Instance) {
+	if !migrationsLimiter.Acquire() {
+		return
+	}
+
+	acquired, lockId := instancesLock.LockOpenTimeout(
+		inst.Id.Hex(), 10*time.Minute)
+	if !acquired {
+		migrationsLimiter.Release()
+		return
+	}
+
+	go func() {
+		defer func() {
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+			migrationsLimiter.Release()
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		addr := s.stat.Node().GetPrivateAddress()
+		if addr == "" {
+			s.fail(db, inst, instance.MigrationPrepare,
+				&errortypes.NotFoundError{
+					errors.New("deploy: Migration node missing address"),
+				})
+			return
+		}
+
+		nodeCert, err := qemu.PowerOnIncoming(db, inst, inst.Virt, addr)
+		if err != nil {
+			s.fail(db, inst, instance.MigrationPrepare, err)
+			s.abort(db, inst)
+			return
+		}
+
+		updated, err := instance.UpdateMigration(db, inst.Id,
+			instance.MigrationPrepare, bson.M{
+				"migration.state":     instance.MigrationReady,
+				"migration.address":   addr,
+				"migration.node_cert": nodeCert,
+			})
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to update instance migration")
+			return
+		}
+
+		if !updated {
+			s.abort(db, inst)
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
+func (s *Migrations) cancelMirrors(inst *instance.Instance) {
+	for _, dsk := range inst.Migration.Disks {
+		_ = qmp.CancelMirror(inst.Id, dsk.Id)
+	}
+}
+
+func (s *Migrations) waitMirrors(inst *instance.Instance,
+	timeout time.Time) (err error) {
+
+	for {
+		if time.Now().After(timeout) {
+			err = &errortypes.TimeoutError{
+				errors.New("deploy: Migration disk mirror timeout"),
+			}
+			return
+		}
+
+		jobs, e := qmp.GetBlockJobs(inst.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		jobsMap := map[string]*qmp.BlockJob{}
+		for _, job := range jobs {
+			jobsMap[job.Device] = job
+		}
+
+		ready := true
+		for _, dsk := range inst.Migration.Disks {
+			job := jobsMap["mirror_"+dsk.Id.Hex()]
+			if job == nil {
+				err = &errortypes.ApiError{
+					errors.Newf("deploy: Migration disk %s mirror stopped",
+						dsk.Id.Hex()),
+				}
+				return
+			}
+
+			if !job.Ready {
+				ready = false
+			}
+		}
+
+		if ready {
+			return
+		}
+
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func (s *Migrations) waitMigrate(inst *instance.Instance,
+	timeout time.Time) (err error) {
+
+	for {
+		if time.Now().After(timeout) {
+			_ = qmp.MigrateCancel(inst.Id)
+			err = &errortypes.TimeoutError{
+				errors.New("deploy: Migration timeout"),
+			}
+			return
+		}
+
+		status, e := qmp.GetMigrateStatus(inst.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		switch status.Status {
+		case "completed":
+			return
+		case "failed", "cancelled":
+			err = &errortypes.ApiError{
+				errors.Newf("deploy: Migration %s '%s'",
+					status.Status, status.ErrorDesc),
+			}
+			return
+		}
+
+		time.Sleep(1 * time.Second)
+	}
+}
+
+func (s *Migrations) transfer(inst *instance.Instance) {
+	if !migrationsLimiter.Acquire() {
+		return
+	}
+
+	acquired, lockId := instancesLock.LockOpenTimeout(
+		inst.Id.Hex(), s.getTimeout())
+	if !acquired {
+		migrationsLimiter.Release()
+		return
+	}
+
+	go func() {
+		defer func() {
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+			migrationsLimiter.Release()
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		migration := inst.Migration
+		timeout := time.Now().Add(time.Duration(
+			settings.Hypervisor.MigrationTimeout) * time.Second)
+
+		updated, err := instance.UpdateMigration(db, inst.Id,
+			instance.MigrationReady, bson.M{
+				"migration.state": instance.MigrationTransfer,
+			})
+		if err != nil || !updated {
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+
+		err = qemu.SetMigrateTlsPeer(inst.Virt, migration.NodeCert)
+		if err != nil {
+			s.fail(db, inst, instance.MigrationTransfer, err)
+			return
+		}
+
+		err = qmp.MigrateTls(inst.Id, paths.GetMigrateTlsPath(inst.Id))
+		if err != nil {
+			s.fail(db, inst, instance.MigrationTransfer, err)
+			return
+		}
+
+		for _, dsk := range migration.Disks {
+			err = qmp.MirrorDisk(inst.Id, dsk.Id, migration.Address,
+				settings.Hypervisor.MigrationNbdPort)
+			if err != nil {
+				s.cancelMirrors(inst)
+				s.fail(db, inst, instance.MigrationTransfer, err)
+				return
+			}
+		}
+
+		err = s.waitMirrors(inst, timeout)
+		if err != nil {
+			s.cancelMirrors(inst)
+			s.fail(db, inst, instance.MigrationTransfer, err)
+			return
+		}
+
+		err = qmp.Migrate(inst.Id, migration.Address,
+			settings.Hypervisor.MigrationPort,
+			int64(settings.Hypervisor.MigrationSpeed)*1048576)
+		if err != nil {
+			s.cancelMirrors(inst)
+			s.fail(db, inst, instance.MigrationTransfer, err)
+			return
+		}
+
+		err = s.waitMigrate(inst, timeout)
+		if err != nil {
+			s.cancelMirrors(inst)
+			s.fail(db, inst, instance.MigrationTransfer, err)
+			return
+		}
+
+		s.cancelMirrors(inst)
+
+		updated, err = instance.CompleteMigration(db, inst)
+		if err != nil || !updated {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"node":        migration.Node.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to complete instance migration")
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"instance_id": inst.Id.Hex(),
+			"node":        migration.Node.Hex(),
+		}).Info("deploy: Instance migration complete")
+
+		err = qemu.MigrateCleanup(db, inst.Virt, migration)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to cleanup migrated instance")
+		}
+
+		event.PublishDispatch(db, "instance.change")
+		event.PublishDispatch(db, "disk.change")
+	}()
+}
+
+func (s *Migrations) finalize(inst *instance.Instance) {
+	curVirt := s.stat.GetVirt(inst.Id)
+	if curVirt == nil || curVirt.State != vm.Running {
+		return
+	}
+
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		err := qemu.FinalizeIncoming(db, inst, inst.Virt, curVirt)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to finalize instance migration")
+			return
+		}
+
+		err = instance.ClearMigration(db, inst.Id)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to update instance migration")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
+// Remove the incoming virtual machine and disks after a failed migration,
+// the instance must not be owned by this node.
+func (s *Migrations) abort(db *database.Database, inst *instance.Instance) {
+	if inst.Node == node.Self.Id {
+		return
+	}
+
+	err := qemu.MigrateCleanup(db, inst.Virt, inst.Migration)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": inst.Id.Hex(),
+			"error":       err,
+		}).Error("deploy: Failed to cleanup failed instance migration")
+	}
+}
+
+func getNodeAddresses(nde *node.Node) (addrs []string) {
+	addrs = []string{}
+
+	for _, addr := range nde.PrivateIps {
+		if addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	addrs = append(addrs, nde.PublicIps...)
+	addrs = append(addrs, nde.PublicIps6...)
+
+	return
+}
+
+func (s *Migrations) Deploy(db *database.Database) (err error) {
+	nodeSelf := s.stat.Node()
+
+	for _, inst := range s.stat.Instances() {
+		migration := inst.Migration
+		if migration == nil {
+			continue
+		}
+
+		switch migration.State {
+		case instance.MigrationPending, instance.MigrationPrepare,
+			instance.MigrationReady:
+
+			if migration.IsExpired() {
+				err = instance.FailMigration(db, inst.Id, migration.State,
+					"Migration timed out")
+				if err != nil {
+					return
+				}
+
+				event.PublishDispatch(db, "instance.change")
+			} else if migration.State == instance.MigrationPending {
+				s.prepare(inst)
+			} else if migration.State == instance.MigrationReady {
+				s.transfer(inst)
+			}
+			break
+		case instance.MigrationTransfer:
+			if s.isExpired(inst) {
+				err = instance.FailMigration(db, inst.Id,
+					instance.MigrationTransfer, "Migration transfer timed out")
+				if err != nil {
+					return
+				}
+
+				event.PublishDispatch(db, "instance.change")
+			}
+			break
+		case instance.MigrationComplete:
+			if migration.Node != nodeSelf.Id {
+				break
+			}
+
+			if s.isExpired(inst) {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": inst.Id.Hex(),
+				}).Error("deploy: Instance migration finalize timed out")
+
+				err = instance.FailMigration(db, inst.Id,
+					instance.MigrationComplete,
+					"Migration finalize timed out")
+				if err != nil {
+					return
+				}
+
+				event.PublishDispatch(db, "instance.change")
+			} else {
+				s.finalize(inst)
+			}
+			break
+		}
+	}
+
+	insts, err := instance.GetAll(db, &bson.M{
+		"node": &bson.M{
+			"$ne": nodeSelf.Id,
+		},
+		"migration.node": nodeSelf.Id,
+		"migration.state": &bson.M{
+			"$in": []string{
+				instance.MigrationPrepare,
+				instance.MigrationReady,
+				instance.MigrationTransfer,
+				instance.MigrationFailed,
+			},
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	nodesMap := map[primitive.ObjectID]*node.Node{}
+	for _, nde := range s.stat.Nodes() {
+		nodesMap[nde.Id] = nde
+	}
+
+	migrationRules := []*iptables.Migration{}
+	for _, inst := range insts {
+		if inst.Migration.State == instance.MigrationFailed {
+			continue
+		}
+
+		srcNode := nodesMap[inst.Migration.Source]
+		if srcNode == nil {
+			srcNode, err = node.Get(db, inst.Migration.Source)
+			if err != nil {
+				return
+			}
+		}
+
+		migrationRules = append(migrationRules, &iptables.Migration{
+			Instance:  inst.Id,
+			Addresses: getNodeAddresses(srcNode),
+		})
+	}
+
+	err = iptables.UpdateMigrations(migrationRules)
+	if err != nil {
+		return
+	}
+
+	for _, inst := range insts {
+		if inst.Migration.State == instance.MigrationTransfer {
+			// Source node lost during transfer, fail the migration to
+			// remove the incoming virtual machine and disks
+			if s.isExpired(inst) {
+				err = instance.FailMigration(db, inst.Id,
+					instance.MigrationTransfer,
+					"Migration transfer timed out")
+				if err != nil {
+					return
+				}
+
+				event.PublishDispatch(db, "instance.change")
+			}
+			continue
+		}
+
+		if inst.Migration.State != instance.MigrationPrepare &&
+			inst.Migration.State != instance.MigrationFailed {
+
+			continue
+		}
+
+		if instancesLock.Locked(inst.Id.Hex()) {
+			continue
+		}
+
+		if inst.Migration.State == instance.MigrationFailed {
+			exists, e := utils.Exists(paths.GetUnitPath(inst.Id))
+			if e != nil {
+				err = e
+				return
+			}
+
+			if !exists {
+				continue
+			}
+		}
+
+		dsks, e := disk.GetInstance(db, inst.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		inst.LoadVirt(nil, dsks)
+
+		switch inst.Migration.State {
+		case instance.MigrationPrepare:
+			s.incoming(inst)
+			break
+		case instance.MigrationFailed:
+			s.abort(db, inst)
+			break
+		}
+	}
+
+	return
+}
+
+func NewMigrations(stat *state.State) *Migrations {
+	return &Migrations{
+		stat: stat,
+	}
+}
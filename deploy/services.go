@@ -107,6 +107,21 @@ func (s *Pods) deploySchedule(schd *scheduler.Scheduler) (err error) {
 					return
 				}
 
+				allowed, e := scheduler.CheckPlacement(
+					db, schd, spc, s.stat.Node())
+				if e != nil {
+					err = e
+					return
+				}
+
+				if !allowed {
+					logrus.WithFields(logrus.Fields{
+						"pod":  schd.Id.Pod.Hex(),
+						"unit": schd.Id.Unit.Hex(),
+					}).Info("deploy: Pod deploy blocked by placement")
+					continue
+				}
+
 				reserved, e := s.DeploySpec(db, schd, unit, spc)
 				if e != nil {
 					err = e
@@ -358,6 +373,10 @@ func (s *Pods) DeploySpec(db *database.Database,
 }
 
 func (s *Pods) Deploy(db *database.Database) (err error) {
+	if s.stat.Node().Maintenance {
+		return
+	}
+
 	schds := s.stat.Schedulers()
 
 	for _, schd := range schds {
@@ -27,6 +27,7 @@ type Deployment struct {
 	Node         primitive.ObjectID             `bson:"node,omitempty" json:"node"`
 	Instance     primitive.ObjectID             `bson:"instance,omitempty" json:"instance"`
 	Image        primitive.ObjectID             `bson:"image,omitempty" json:"image"`
+	Build        primitive.ObjectID             `bson:"build,omitempty" json:"build"`
 	Mounts       []*Mount                       `bson:"mounts" json:"mounts"`
 	InstanceData *InstanceData                  `bson:"instance_data,omitempty" json:"instance_data"`
 	ImageData    *ImageData                     `bson:"image_data,omitempty" json:"image_data"`
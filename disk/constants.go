@@ -8,9 +8,18 @@ const (
 	Expand    = "expand"
 	Restore   = "restore"
 	Destroy   = "destroy"
+	Upload    = "upload"
+	Export    = "export"
+	Failed    = "failed"
 
 	Qcow2 = "qcow2"
 	Lvm   = "lvm"
+	Rbd   = "rbd"
+
+	Raw  = "raw"
+	Vmdk = "vmdk"
+	Vhdx = "vhdx"
+	Ova  = "ova"
 
 	Xfs  = "xfs"
 	Ext4 = "ext4"
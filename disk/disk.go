@@ -15,9 +15,12 @@ import (
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/lock"
 	"github.com/pritunl/pritunl-cloud/lvm"
+	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
 	"github.com/pritunl/pritunl-cloud/pool"
+	"github.com/pritunl/pritunl-cloud/rbd"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/sirupsen/logrus"
 )
 
@@ -46,6 +49,15 @@ type Disk struct {
 	NewSize          int                `bson:"new_size" json:"new_size"`
 	Backup           bool               `bson:"backup" json:"backup"`
 	LastBackup       time.Time          `bson:"last_backup" json:"last_backup"`
+	NextBackup       time.Time          `bson:"next_backup" json:"next_backup"`
+	BackupSchedule   *BackupSchedule    `bson:"backup_schedule,omitempty" json:"backup_schedule"`
+	BackupParent     primitive.ObjectID `bson:"backup_parent,omitempty" json:"backup_parent"`
+	BackupChainSize  int                `bson:"backup_chain_size" json:"backup_chain_size"`
+	SnapshotSet      primitive.ObjectID `bson:"snapshot_set,omitempty" json:"snapshot_set"`
+	CloneSource      primitive.ObjectID `bson:"clone_source,omitempty" json:"clone_source"`
+	Qos              *vm.DiskQos        `bson:"qos,omitempty" json:"qos"`
+	Import           *Transfer          `bson:"import,omitempty" json:"import"`
+	Export           *Transfer          `bson:"export,omitempty" json:"export"`
 	curIndex         string             `bson:"-" json:"-"`
 	curInstance      primitive.ObjectID `bson:"-" json:"-"`
 }
@@ -91,7 +103,7 @@ func (d *Disk) Validate(db *database.Database) (
 			return
 		}
 		break
-	case Lvm:
+	case Lvm, Rbd:
 		d.Node = primitive.NilObjectID
 		if d.Pool.IsZero() {
 			errData = &errortypes.ErrorData{
@@ -104,7 +116,7 @@ func (d *Disk) Validate(db *database.Database) (
 		if d.Backing || d.BackingImage != "" {
 			errData = &errortypes.ErrorData{
 				Error:   "backing_image_invalid",
-				Message: "LVM disk cannot have backing image",
+				Message: "Pool disk cannot have backing image",
 			}
 			return
 		}
@@ -153,6 +165,73 @@ func (d *Disk) Validate(db *database.Database) (
 		return
 	}
 
+	if d.BackupSchedule != nil && d.BackupSchedule.Cron == "" {
+		d.BackupSchedule = nil
+	}
+
+	if d.BackupSchedule != nil {
+		if d.BackupSchedule.KeepHourly < 0 ||
+			d.BackupSchedule.KeepDaily < 0 ||
+			d.BackupSchedule.KeepWeekly < 0 {
+
+			errData = &errortypes.ErrorData{
+				Error:   "backup_schedule_retention_invalid",
+				Message: "Backup schedule retention is invalid",
+			}
+			return
+		}
+
+		next, e := d.BackupSchedule.Next(time.Now())
+		if e != nil || next.IsZero() {
+			errData = &errortypes.ErrorData{
+				Error:   "backup_schedule_invalid",
+				Message: "Backup schedule cron expression is invalid",
+			}
+			return
+		}
+
+		if d.Backup {
+			d.NextBackup = next
+		} else {
+			d.NextBackup = time.Time{}
+		}
+	} else {
+		d.NextBackup = time.Time{}
+	}
+
+	if d.Qos != nil {
+		errData = d.Qos.Validate()
+		if errData != nil {
+			return
+		}
+
+		if d.Qos.IsZero() {
+			d.Qos = nil
+		}
+	}
+
+	if d.Import != nil {
+		errData = d.Import.Validate(false)
+		if errData != nil {
+			return
+		}
+
+		if !d.Image.IsZero() || d.FileSystem != "" || d.Backing {
+			errData = &errortypes.ErrorData{
+				Error:   "import_source_invalid",
+				Message: "Imported disk cannot have image or file system",
+			}
+			return
+		}
+	}
+
+	if d.Export != nil {
+		errData = d.Export.Validate(true)
+		if errData != nil {
+			return
+		}
+	}
+
 	if d.State == Restore && d.RestoreImage.IsZero() {
 		errData = &errortypes.ErrorData{
 			Error:   "restore_missing_image",
@@ -191,6 +270,71 @@ func (d *Disk) Validate(db *database.Database) (
 		d.Size = 10
 	}
 
+	if !d.CloneSource.IsZero() && d.State == Provision {
+		srcDsk, e := Get(db, d.CloneSource)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); !ok {
+				err = e
+				return
+			}
+			srcDsk = nil
+		}
+
+		if srcDsk == nil || srcDsk.Organization != d.Organization ||
+			srcDsk.Id == d.Id {
+
+			errData = &errortypes.ErrorData{
+				Error:   "clone_source_invalid",
+				Message: "Clone source disk not found",
+			}
+			return
+		}
+
+		if srcDsk.Type == Qcow2 && d.Type == Qcow2 &&
+			srcDsk.Node != d.Node {
+
+			errData = &errortypes.ErrorData{
+				Error:   "clone_node_invalid",
+				Message: "Cloned disk must be on the source disk node",
+			}
+			return
+		}
+
+		// The clone is copied by a node with access to both the source
+		// and the cloned disk
+		if srcDsk.IsPooled() != d.IsPooled() {
+			ndeId := d.Node
+			plId := srcDsk.Pool
+			if !srcDsk.IsPooled() {
+				ndeId = srcDsk.Node
+				plId = d.Pool
+			}
+
+			nde, e := node.Get(db, ndeId)
+			if e != nil {
+				if _, ok := e.(*database.NotFoundError); !ok {
+					err = e
+					return
+				}
+				nde = nil
+			}
+
+			if nde == nil || !nde.HasPool(plId) {
+				errData = &errortypes.ErrorData{
+					Error:   "clone_pool_invalid",
+					Message: "Clone pool must be available on the disk node",
+				}
+				return
+			}
+		}
+
+		d.Image = primitive.NilObjectID
+		d.FileSystem = ""
+		d.Backing = false
+		d.SystemType = srcDsk.SystemType
+		d.Size = utils.Max(d.Size, srcDsk.Size)
+	}
+
 	if d.State == Expand {
 		if d.NewSize == 0 {
 			errData = &errortypes.ErrorData{
@@ -322,6 +466,55 @@ func (d *Disk) CommitFields(db *database.Database, fields set.Set) (
 	return
 }
 
+// Disk is stored in a pool that is shared by the nodes of a zone instead of
+// on the local storage of a node.
+func (d *Disk) IsPooled() bool {
+	return d.Type == Lvm || d.Type == Rbd
+}
+
+// Check that the pool of a new pool disk has capacity for the disk.
+func (d *Disk) ValidateCapacity(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if !d.IsPooled() || d.Pool.IsZero() {
+		return
+	}
+
+	pl, err := pool.Get(db, d.Pool)
+	if err != nil {
+		return
+	}
+
+	errData = pl.CheckCapacity(d.Size)
+	if errData != nil {
+		return
+	}
+
+	return
+}
+
+// Check that the pool of a pool disk has capacity for the size increase
+// of an expand.
+func (d *Disk) ValidateExpandCapacity(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if !d.IsPooled() || d.Pool.IsZero() || d.NewSize <= d.Size {
+		return
+	}
+
+	pl, err := pool.Get(db, d.Pool)
+	if err != nil {
+		return
+	}
+
+	errData = pl.CheckCapacity(d.NewSize - d.Size)
+	if errData != nil {
+		return
+	}
+
+	return
+}
+
 func (d *Disk) Insert(db *database.Database) (err error) {
 	coll := db.Disks()
 
@@ -394,6 +587,46 @@ func (d *Disk) Destroy(db *database.Database) (err error) {
 		if err != nil {
 			return
 		}
+	} else if d.Type == Rbd {
+		pl, e := pool.Get(db, d.Pool)
+		if e != nil {
+			err = e
+			return
+		}
+
+		imgName := d.Id.Hex()
+
+		acquired, e := lock.LvmLock(db, pl.CephPool, imgName)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if !acquired {
+			err = &errortypes.WriteError{
+				errors.New("data: Failed to acquire RBD lock"),
+			}
+			return
+		}
+		defer func() {
+			err2 := lock.LvmUnlock(db, pl.CephPool, imgName)
+			if err2 != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": err2,
+				}).Error("data: Failed to unlock rbd")
+			}
+		}()
+
+		logrus.WithFields(logrus.Fields{
+			"disk_id":   d.Id.Hex(),
+			"ceph_pool": pl.CephPool,
+			"image":     imgName,
+		}).Info("qemu: Destroying RBD disk")
+
+		err = rbd.RemoveImage(pl, imgName)
+		if err != nil {
+			return
+		}
 	} else {
 		logrus.WithFields(logrus.Fields{
 			"disk_id":   d.Id.Hex(),
@@ -0,0 +1,29 @@
+package disk
+
+import (
+	"time"
+
+	"github.com/pritunl/pritunl-cloud/cron"
+)
+
+type BackupSchedule struct {
+	Cron       string `bson:"cron" json:"cron"`
+	KeepHourly int    `bson:"keep_hourly" json:"keep_hourly"`
+	KeepDaily  int    `bson:"keep_daily" json:"keep_daily"`
+	KeepWeekly int    `bson:"keep_weekly" json:"keep_weekly"`
+}
+
+func (b *BackupSchedule) HasRetention() bool {
+	return b.KeepHourly > 0 || b.KeepDaily > 0 || b.KeepWeekly > 0
+}
+
+func (b *BackupSchedule) Next(t time.Time) (next time.Time, err error) {
+	sched, err := cron.Parse(b.Cron)
+	if err != nil {
+		return
+	}
+
+	next = sched.Next(t)
+
+	return
+}
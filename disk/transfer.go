@@ -0,0 +1,79 @@
+package disk
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type Transfer struct {
+	Format    string             `bson:"format" json:"format"`
+	Url       string             `bson:"url" json:"url"`
+	Storage   primitive.ObjectID `bson:"storage,omitempty" json:"storage"`
+	Key       string             `bson:"key" json:"key"`
+	Error     string             `bson:"error" json:"error"`
+	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
+}
+
+func (t *Transfer) Validate(export bool) (errData *errortypes.ErrorData) {
+	switch t.Format {
+	case Raw, Qcow2, Vmdk, Vhdx:
+		break
+	case Ova:
+		if export {
+			errData = &errortypes.ErrorData{
+				Error:   "format_invalid",
+				Message: "Disk export format not supported",
+			}
+			return
+		}
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "format_invalid",
+			Message: "Disk transfer format invalid",
+		}
+		return
+	}
+
+	if t.Url != "" {
+		u, err := url.Parse(t.Url)
+		if export || err != nil ||
+			(u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+
+			errData = &errortypes.ErrorData{
+				Error:   "url_invalid",
+				Message: "Disk import URL invalid",
+			}
+			return
+		}
+
+		host := strings.ToLower(u.Hostname())
+		ip := net.ParseIP(host)
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") ||
+			(ip != nil && (ip.IsLoopback() || ip.IsPrivate() ||
+				ip.IsLinkLocalUnicast() || ip.IsUnspecified() ||
+				ip.IsMulticast())) {
+
+			errData = &errortypes.ErrorData{
+				Error:   "url_invalid",
+				Message: "Disk import URL address not allowed",
+			}
+			return
+		}
+	}
+
+	return
+}
+
+// Get qemu-img format of the transfer, ova archives contain vmdk disks.
+func (t *Transfer) QemuFormat() string {
+	if t.Format == Ova {
+		return Vmdk
+	}
+	return t.Format
+}
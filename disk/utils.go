@@ -537,3 +537,158 @@ func SetDeleteProtection(db *database.Database, instId primitive.ObjectID,
 
 	return
 }
+
+// Migrated disks are mirrored in full to the new node and no longer
+// reference a backing image.
+func SetInstanceNode(db *database.Database, instId primitive.ObjectID,
+	ndeId primitive.ObjectID) (err error) {
+
+	coll := db.Disks()
+
+	_, err = coll.UpdateMany(db, &bson.M{
+		"instance": instId,
+	}, &bson.M{
+		"$set": &bson.M{
+			"node":          ndeId,
+			"backing":       false,
+			"backing_image": "",
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// Mark available disks of an instance for a group snapshot, returns the
+// number of disks marked.
+func SetSnapshotSet(db *database.Database, instId primitive.ObjectID,
+	dskIds []primitive.ObjectID, setId primitive.ObjectID) (
+	count int, err error) {
+
+	coll := db.Disks()
+
+	resp, err := coll.UpdateMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": dskIds,
+		},
+		"instance": instId,
+		"state":    Available,
+	}, &bson.M{
+		"$set": &bson.M{
+			"state":        Snapshot,
+			"snapshot_set": setId,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	count = int(resp.ModifiedCount)
+
+	return
+}
+
+func ClearSnapshotSet(db *database.Database, setId primitive.ObjectID) (
+	err error) {
+
+	coll := db.Disks()
+
+	_, err = coll.UpdateMany(db, &bson.M{
+		"snapshot_set": setId,
+		"state":        Snapshot,
+	}, &bson.M{
+		"$set": &bson.M{
+			"state": Available,
+		},
+		"$unset": &bson.M{
+			"snapshot_set": 1,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// Mark an uploading disk for provision after the import upload completes,
+// returns false if the disk is no longer waiting for an upload.
+func SetUploaded(db *database.Database, dskId primitive.ObjectID,
+	imprt *Transfer) (updated bool, err error) {
+
+	coll := db.Disks()
+
+	resp, err := coll.UpdateOne(db, &bson.M{
+		"_id":   dskId,
+		"state": Upload,
+	}, &bson.M{
+		"$set": &bson.M{
+			"state":  Provision,
+			"import": imprt,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	updated = resp.ModifiedCount > 0
+
+	return
+}
+
+// Mark an available disk for restore from an image, returns false if the
+// disk is no longer available.
+func SetRestore(db *database.Database, dskId, imgId primitive.ObjectID) (
+	updated bool, err error) {
+
+	coll := db.Disks()
+
+	resp, err := coll.UpdateOne(db, &bson.M{
+		"_id":   dskId,
+		"state": Available,
+	}, &bson.M{
+		"$set": &bson.M{
+			"state":         Restore,
+			"restore_image": imgId,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	updated = resp.ModifiedCount > 0
+
+	return
+}
+
+func ClearRestore(db *database.Database, dskId, imgId primitive.ObjectID) (
+	err error) {
+
+	coll := db.Disks()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id":           dskId,
+		"state":         Restore,
+		"restore_image": imgId,
+	}, &bson.M{
+		"$set": &bson.M{
+			"state": Available,
+		},
+		"$unset": &bson.M{
+			"restore_image": 1,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
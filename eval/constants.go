@@ -4,21 +4,62 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 )
 
-type Equal struct{}
-type NotEqual struct{}
-type Less struct{}
-type LessEqual struct{}
-type Greater struct{}
-type GreaterEqual struct{}
-type If struct{}
-type And struct{}
-type Or struct{}
-type For struct{}
-type Then struct{}
+const (
+	If   = "IF"
+	Then = "THEN"
+	For  = "FOR"
+	And  = "AND"
+	Or   = "OR"
+	Not  = "NOT"
+)
+
+const (
+	tokenNumber = iota
+	tokenString
+	tokenBool
+	tokenIdent
+	tokenKeyword
+	tokenOperator
+	tokenLparen
+	tokenRparen
+	tokenComma
+)
 
 const (
 	StatementMaxLength = 1024
-	StatementMaxParts  = 30
+	StatementMaxParts  = 128
+	StatementMaxDepth  = 32
+)
+
+var binaryOps = map[string]bool{
+	"==": true,
+	"!=": true,
+	"<":  true,
+	"<=": true,
+	">":  true,
+	">=": true,
+	"+":  true,
+	"-":  true,
+	"*":  true,
+	"/":  true,
+	"%":  true,
+}
+
+var aggregateFuncs = set.NewSet(
+	"avg",
+	"min",
+	"max",
+	"sum",
+	"count",
+)
+
+var stringFuncs = set.NewSet(
+	"contains",
+	"starts_with",
+	"ends_with",
+	"matches",
+	"lower",
+	"upper",
 )
 
 var StatementSafeCharacters = set.NewSet(
@@ -95,4 +136,16 @@ var StatementSafeCharacters = set.NewSet(
 	'\'',
 	'(',
 	')',
+	'+',
+	'*',
+	'/',
+	'%',
+	',',
+	'^',
+	'$',
+	'[',
+	']',
+	'|',
+	'?',
+	':',
 )
@@ -1,526 +1,506 @@
 package eval
 
 import (
-	"reflect"
-	"strconv"
 	"strings"
 )
 
 type Data map[string]map[string]interface{}
 
 type Parser struct {
-	statement string
-	parts     []string
-	partsLen  int
-	data      Data
+	statement   string
+	tokens      []*token
+	pos         int
+	depth       int
+	inAggregate bool
+	data        Data
+	aggregate   []Data
 }
 
-func (p *Parser) parseRef(ref string, pos int) (val interface{}, err error) {
-	n := len(ref)
-	if n == 0 {
-		return
+func (p *Parser) newError(index int, templMsg string,
+	args ...interface{}) error {
+
+	return NewEvalError(
+		p.statement,
+		index,
+		index,
+		len(p.tokens),
+		templMsg,
+		args...,
+	)
+}
+
+func (p *Parser) peek() *token {
+	if p.pos >= len(p.tokens) {
+		return nil
 	}
+	return p.tokens[p.pos]
+}
 
-	if ref[0] == '\'' {
-		if ref[n-1] != '\'' {
-			err = NewEvalError(
-				p.statement,
-				pos,
-				pos,
-				p.partsLen,
-				"eval: Invalid string {{.ErrIndex}}",
-			)
-			return
+func (p *Parser) isKeyword(value string) bool {
+	tok := p.peek()
+	return tok != nil && tok.kind == tokenKeyword && tok.value == value
+}
+
+func (p *Parser) isOperator(values ...string) bool {
+	tok := p.peek()
+	if tok == nil || tok.kind != tokenOperator {
+		return false
+	}
+
+	for _, value := range values {
+		if tok.value == value {
+			return true
 		}
-		val = ref[1 : n-1]
-		return
 	}
 
-	switch ref {
-	case "true":
-		val = true
-		return
-	case "false":
-		val = true
-		return
-	case "==":
-		val = Equal{}
-		return
-	case "!=":
-		val = NotEqual{}
-		return
-	case "<":
-		val = Less{}
-		return
-	case "<=":
-		val = LessEqual{}
-		return
-	case ">":
-		val = Greater{}
-		return
-	case ">=":
-		val = GreaterEqual{}
-		return
-	case "IF":
-		val = If{}
-		return
-	case "AND":
-		val = And{}
-		return
-	case "OR":
-		val = Or{}
-		return
-	case "FOR":
-		val = For{}
-		return
-	case "THEN":
-		val = Then{}
+	return false
+}
+
+func (p *Parser) enter() (err error) {
+	p.depth += 1
+	if p.depth > StatementMaxDepth {
+		err = p.newError(p.pos, "eval: Expression exceeds max depth")
 		return
 	}
+	return
+}
 
-	if ref == "true" {
-		val = true
-		return
-	} else if ref == "false" {
-		val = false
+func (p *Parser) exit() {
+	p.depth -= 1
+}
+
+func (p *Parser) parseOr() (nde node, err error) {
+	nde, err = p.parseAnd()
+	if err != nil {
 		return
 	}
 
-	intVal, e := strconv.Atoi(ref)
-	if e == nil {
-		val = intVal
+	for p.isKeyword(Or) {
+		index := p.pos
+		p.pos += 1
+
+		right, e := p.parseAnd()
+		if e != nil {
+			err = e
+			return
+		}
+
+		nde = &logicNode{
+			index: index,
+			op:    Or,
+			left:  nde,
+			right: right,
+		}
+	}
+
+	return
+}
+
+func (p *Parser) parseAnd() (nde node, err error) {
+	nde, err = p.parseNot()
+	if err != nil {
 		return
 	}
 
-	floatVal, e := strconv.ParseFloat(ref, 64)
-	if e == nil {
-		val = floatVal
+	for p.isKeyword(And) {
+		index := p.pos
+		p.pos += 1
+
+		right, e := p.parseNot()
+		if e != nil {
+			err = e
+			return
+		}
+
+		nde = &logicNode{
+			index: index,
+			op:    And,
+			left:  nde,
+			right: right,
+		}
+	}
+
+	return
+}
+
+func (p *Parser) parseNot() (nde node, err error) {
+	if !p.isKeyword(Not) {
+		nde, err = p.parseComp()
 		return
 	}
 
-	split := strings.Split(ref, ".")
-	if len(split) != 2 {
-		err = NewEvalError(
-			p.statement,
-			pos,
-			pos,
-			p.partsLen,
-			"eval: Invalid reference {{.ErrIndex}}",
-		)
+	index := p.pos
+	p.pos += 1
+
+	err = p.enter()
+	if err != nil {
 		return
 	}
+	defer p.exit()
 
-	group := p.data[split[0]]
-	if group == nil {
-		err = NewEvalError(
-			p.statement,
-			pos,
-			pos,
-			p.partsLen,
-			"eval: Invalid reference group {{.ErrIndex}}",
-		)
+	operand, err := p.parseNot()
+	if err != nil {
 		return
 	}
 
-	groupVal := group[split[1]]
-	if groupVal == nil {
-		err = NewEvalError(
-			p.statement,
-			pos,
-			pos,
-			p.partsLen,
-			"eval: Invalid reference group key {{.ErrIndex}}",
-		)
+	nde = &notNode{
+		index:   index,
+		operand: operand,
+	}
+
+	return
+}
+
+func (p *Parser) parseComp() (nde node, err error) {
+	nde, err = p.parseAdd()
+	if err != nil {
 		return
-	} else {
-		val = groupVal
+	}
+
+	if p.isOperator("==", "!=", "<", "<=", ">", ">=") {
+		index := p.pos
+		op := p.tokens[index].value
+		p.pos += 1
+
+		right, e := p.parseAdd()
+		if e != nil {
+			err = e
+			return
+		}
+
+		nde = &binaryNode{
+			index: index,
+			op:    op,
+			left:  nde,
+			right: right,
+		}
 	}
 
 	return
 }
 
-func (p *Parser) parseComp(left, right, comp interface{}) bool {
-	if reflect.TypeOf(left) != reflect.TypeOf(right) {
-		return false
+func (p *Parser) parseAdd() (nde node, err error) {
+	nde, err = p.parseMul()
+	if err != nil {
+		return
 	}
 
-	switch leftVal := left.(type) {
-	case bool:
-		switch comp.(type) {
-		case Equal:
-			return leftVal == right.(bool)
-		case NotEqual:
-			return leftVal != right.(bool)
-		case Less:
-			return false
-		case LessEqual:
-			return false
-		case Greater:
-			return false
-		case GreaterEqual:
-			return false
-		default:
-			panic("Invalid comp")
+	for p.isOperator("+", "-") {
+		index := p.pos
+		op := p.tokens[index].value
+		p.pos += 1
+
+		right, e := p.parseMul()
+		if e != nil {
+			err = e
+			return
 		}
-	case string:
-		switch comp.(type) {
-		case Equal:
-			return leftVal == right.(string)
-		case NotEqual:
-			return leftVal != right.(string)
-		case Less:
-			return leftVal < right.(string)
-		case LessEqual:
-			return leftVal <= right.(string)
-		case Greater:
-			return leftVal < right.(string)
-		case GreaterEqual:
-			return leftVal <= right.(string)
-		default:
-			panic("Invalid comp")
+
+		nde = &binaryNode{
+			index: index,
+			op:    op,
+			left:  nde,
+			right: right,
 		}
-	case int:
-		switch comp.(type) {
-		case Equal:
-			return leftVal == right.(int)
-		case NotEqual:
-			return leftVal != right.(int)
-		case Less:
-			return leftVal < right.(int)
-		case LessEqual:
-			return leftVal <= right.(int)
-		case Greater:
-			return leftVal < right.(int)
-		case GreaterEqual:
-			return leftVal <= right.(int)
-		default:
-			panic("Invalid comp")
+	}
+
+	return
+}
+
+func (p *Parser) parseMul() (nde node, err error) {
+	nde, err = p.parseUnary()
+	if err != nil {
+		return
+	}
+
+	for p.isOperator("*", "/", "%") {
+		index := p.pos
+		op := p.tokens[index].value
+		p.pos += 1
+
+		right, e := p.parseUnary()
+		if e != nil {
+			err = e
+			return
 		}
-	case float64:
-		switch comp.(type) {
-		case Equal:
-			return leftVal == right.(float64)
-		case NotEqual:
-			return leftVal != right.(float64)
-		case Less:
-			return leftVal < right.(float64)
-		case LessEqual:
-			return leftVal <= right.(float64)
-		case Greater:
-			return leftVal < right.(float64)
-		case GreaterEqual:
-			return leftVal <= right.(float64)
-		default:
-			panic("Invalid comp")
+
+		nde = &binaryNode{
+			index: index,
+			op:    op,
+			left:  nde,
+			right: right,
 		}
-	default:
-		panic("Invalid type")
 	}
 
-	return false
+	return
 }
 
-func (p *Parser) Eval() (resp string, threshold int, err error) {
-	p.parts = strings.Fields(p.statement)
-	p.partsLen = len(p.parts)
-	if p.partsLen < 6 {
-		err = NewEvalError(
-			p.statement,
-			0,
-			0,
-			p.partsLen,
-			"eval: Statement under min parts",
-		)
+func (p *Parser) parseUnary() (nde node, err error) {
+	if !p.isOperator("-") {
+		nde, err = p.parsePrimary()
 		return
-	} else if p.partsLen > 30 {
-		err = NewEvalError(
-			p.statement,
-			0,
-			0,
-			p.partsLen,
-			"eval: Statement exceeds max parts",
-		)
+	}
+
+	index := p.pos
+	p.pos += 1
+
+	err = p.enter()
+	if err != nil {
 		return
-	} else if len(p.statement) > 1024 {
-		err = NewEvalError(
-			p.statement,
-			0,
-			0,
-			p.partsLen,
-			"eval: Statement exceeds max length",
-		)
+	}
+	defer p.exit()
+
+	operand, err := p.parseUnary()
+	if err != nil {
 		return
 	}
 
-	if p.parts[0] != "IF" {
-		err = NewEvalError(
-			p.statement,
-			0,
-			0,
-			p.partsLen,
-			"eval: Statement part {{.ErrorIndex}} invalid",
-		)
+	nde = &negNode{
+		index:   index,
+		operand: operand,
+	}
+
+	return
+}
+
+func (p *Parser) parseFunc(index int, name string) (nde node, err error) {
+	isAggregate := aggregateFuncs.Contains(name)
+	if !isAggregate && !stringFuncs.Contains(name) {
+		err = p.newError(index, "eval: Unknown function {{.ErrIndex}}")
 		return
 	}
 
-	i := 1
-	var expr interface{}
-	results := []bool{}
-	final := false
-	for x := 0; x < 100; x++ {
-		if p.partsLen < i+4 {
-			err = NewEvalError(
-				p.statement,
-				i,
-				i,
-				p.partsLen,
-				"eval: Incomplete expression",
-			)
+	if isAggregate {
+		if p.inAggregate {
+			err = p.newError(index,
+				"eval: Cannot nest aggregate function {{.ErrIndex}}")
 			return
 		}
-		index := i
-		i += 4
+		p.inAggregate = true
+		defer func() {
+			p.inAggregate = false
+		}()
+	}
 
-		leftOp, e := p.parseRef(p.parts[index], index)
-		if e != nil {
-			err = e
+	// Skip opening parenthesis
+	p.pos += 1
+
+	args := []node{}
+	for {
+		tok := p.peek()
+		if tok == nil {
+			err = p.newError(index, "eval: Incomplete function {{.ErrIndex}}")
 			return
 		}
-		comp, e := p.parseRef(p.parts[index+1], index+1)
+
+		if tok.kind == tokenRparen {
+			p.pos += 1
+			break
+		}
+
+		if len(args) > 0 {
+			if tok.kind != tokenComma {
+				err = p.newError(p.pos, "eval: Expected comma at {{.ErrIndex}}")
+				return
+			}
+			p.pos += 1
+		}
+
+		arg, e := p.parseOr()
 		if e != nil {
 			err = e
 			return
 		}
-		rightOp, e := p.parseRef(p.parts[index+2], index+2)
-		if e != nil {
-			err = e
+
+		args = append(args, arg)
+	}
+
+	argsLen := len(args)
+	switch name {
+	case "count":
+		if argsLen > 1 {
+			err = p.newError(index,
+				"eval: Function {{.ErrIndex}} expects at most 1 argument")
 			return
 		}
-		next, e := p.parseRef(p.parts[index+3], index+3)
-		if e != nil {
-			err = e
+		break
+	case "avg", "min", "max", "sum", "lower", "upper":
+		if argsLen != 1 {
+			err = p.newError(index,
+				"eval: Function {{.ErrIndex}} expects 1 argument")
 			return
 		}
+		break
+	default:
+		if argsLen != 2 {
+			err = p.newError(index,
+				"eval: Function {{.ErrIndex}} expects 2 arguments")
+			return
+		}
+	}
 
-		switch leftOp.(type) {
-		case string:
-			break
-		case int:
-			break
-		case float64:
-			break
-		case bool:
-			break
-		default:
-			err = NewEvalError(
-				p.statement,
-				index,
-				index,
-				p.partsLen,
-				"eval: Invalid left operator {{.ErrIndex}}",
-			)
+	nde = &funcNode{
+		index: index,
+		name:  name,
+		args:  args,
+	}
+
+	return
+}
+
+func (p *Parser) parsePrimary() (nde node, err error) {
+	tok := p.peek()
+	if tok == nil {
+		err = p.newError(p.pos, "eval: Incomplete expression")
+		return
+	}
+
+	err = p.enter()
+	if err != nil {
+		return
+	}
+	defer p.exit()
+
+	index := p.pos
+
+	switch tok.kind {
+	case tokenNumber, tokenString, tokenBool:
+		p.pos += 1
+		nde = &literalNode{
+			val: tok.val,
+		}
+		return
+	case tokenLparen:
+		p.pos += 1
+
+		nde, err = p.parseOr()
+		if err != nil {
 			return
 		}
 
-		switch rightOp.(type) {
-		case string:
-			break
-		case int:
-			break
-		case float64:
-			break
-		case bool:
-			break
-		default:
-			err = NewEvalError(
-				p.statement,
-				index,
-				index+2,
-				p.partsLen,
-				"eval: Invalid right operator {{.ErrIndex}}",
-			)
+		next := p.peek()
+		if next == nil || next.kind != tokenRparen {
+			err = p.newError(index,
+				"eval: Unclosed parenthesis {{.ErrIndex}}")
 			return
 		}
+		p.pos += 1
 
-		switch comp.(type) {
-		case Equal:
-			break
-		case NotEqual:
-			break
-		case Less:
-			break
-		case LessEqual:
-			break
-		case Greater:
-			break
-		case GreaterEqual:
-			break
-		default:
-			err = NewEvalError(
-				p.statement,
-				index,
-				index+1,
-				p.partsLen,
-				"eval: Invalid comparison operator {{.ErrIndex}}",
-			)
+		return
+	case tokenIdent:
+		p.pos += 1
+
+		next := p.peek()
+		if next != nil && next.kind == tokenLparen {
+			nde, err = p.parseFunc(index, tok.value)
 			return
 		}
 
-		result := p.parseComp(leftOp, rightOp, comp)
-		results = append(results, result)
-
-		if _, ok := next.(For); ok {
-			if index+6 != p.partsLen-1 {
-				err = NewEvalError(
-					p.statement,
-					index,
-					index+6,
-					p.partsLen,
-					"eval: Expected %d length", index+6,
-				)
-				return
-			}
+		split := strings.Split(tok.value, ".")
+		if len(split) != 2 || split[0] == "" || split[1] == "" {
+			err = p.newError(index, "eval: Invalid reference {{.ErrIndex}}")
+			return
+		}
 
-			next, e = p.parseRef(p.parts[index+5], index+5)
-			if e != nil {
-				err = e
-				return
-			}
+		nde = &refNode{
+			index: index,
+			group: split[0],
+			key:   split[1],
+		}
+		return
+	}
 
-			if _, ok := next.(Then); !ok {
-				err = NewEvalError(
-					p.statement,
-					index,
-					index+5,
-					p.partsLen,
-					"eval: Expected THAN at {{.ErrIndex}}",
-				)
-				return
-			}
+	err = p.newError(index, "eval: Unexpected token {{.ErrIndex}}")
+	return
+}
 
-			forVal, e := p.parseRef(p.parts[index+4], index+4)
-			if e != nil {
-				err = e
-				return
-			}
+func (p *Parser) Eval() (resp string, threshold int, err error) {
+	if len(p.statement) > StatementMaxLength {
+		err = NewEvalError(
+			p.statement,
+			0,
+			0,
+			0,
+			"eval: Statement exceeds max length",
+		)
+		return
+	}
 
-			if forInt, ok := forVal.(int); ok {
-				threshold = forInt
-			} else {
-				err = NewEvalError(
-					p.statement,
-					index,
-					index+4,
-					p.partsLen,
-					"eval: Expected FOR value to be int",
-				)
-				return
-			}
+	err = p.tokenize()
+	if err != nil {
+		return
+	}
 
-			index += 2
-		}
+	if len(p.tokens) < 4 {
+		err = p.newError(0, "eval: Statement under min parts")
+		return
+	}
 
-		switch next.(type) {
-		case And:
-			if expr == nil {
-				expr = And{}
-			} else if _, ok := expr.(And); !ok {
-				err = NewEvalError(
-					p.statement,
-					index,
-					index+2,
-					p.partsLen,
-					"eval: Cannot mix OR with AND",
-				)
-				return
-			}
-			break
-		case Or:
-			if expr == nil {
-				expr = Or{}
-			} else if _, ok := expr.(Or); !ok {
-				err = NewEvalError(
-					p.statement,
-					index,
-					index+2,
-					p.partsLen,
-					"eval: Cannot mix OR with AND",
-				)
-				return
-			}
-			break
-		case Then:
-			if index+4 != p.partsLen-1 {
-				err = NewEvalError(
-					p.statement,
-					index,
-					index,
-					p.partsLen,
-					"eval: Expected %d length", index+4,
-				)
-				return
-			}
+	if !p.isKeyword(If) {
+		err = p.newError(0, "eval: Statement part {{.ErrIndex}} invalid")
+		return
+	}
+	p.pos += 1
 
-			if _, ok := expr.(Or); ok {
-				for _, result := range results {
-					if result {
-						final = true
-						break
-					}
-				}
-			} else {
-				if len(results) == 0 {
-					final = false
-				} else {
-					final = true
-					for _, result := range results {
-						if !result {
-							final = false
-							break
-						}
-					}
-				}
-			}
+	expr, err := p.parseOr()
+	if err != nil {
+		return
+	}
 
-			if final {
-				respInf, e := p.parseRef(p.parts[index+4], index+4)
-				if e != nil {
-					err = e
-					return
-				}
-				if respStr, ok := respInf.(string); ok {
-					resp = respStr
-				} else {
-					err = NewEvalError(
-						p.statement,
-						index,
-						index+4,
-						p.partsLen,
-						"eval: Result must be string",
-					)
-					return
-				}
-			}
+	if p.isKeyword(For) {
+		p.pos += 1
+
+		tok := p.peek()
+		if tok == nil || tok.kind != tokenNumber {
+			err = p.newError(p.pos, "eval: Expected FOR value to be int")
 			return
-		default:
-			err = NewEvalError(
-				p.statement,
-				index,
-				index+3,
-				p.partsLen,
-				"eval: Invalid continuation",
-			)
+		}
+
+		forInt, ok := tok.val.(int)
+		if !ok {
+			err = p.newError(p.pos, "eval: Expected FOR value to be int")
 			return
 		}
+		threshold = forInt
+		p.pos += 1
+	}
+
+	if !p.isKeyword(Then) {
+		err = p.newError(p.pos, "eval: Expected THEN at {{.ErrIndex}}")
+		return
+	}
+	p.pos += 1
+
+	tok := p.peek()
+	if tok == nil || tok.kind != tokenString {
+		err = p.newError(p.pos, "eval: Result must be string")
+		return
+	}
+	p.pos += 1
+
+	if p.pos != len(p.tokens) {
+		err = p.newError(p.pos, "eval: Invalid continuation")
+		return
+	}
+
+	result, err := expr.eval(&context{
+		parser: p,
+		data:   p.data,
+	})
+	if err != nil {
+		return
+	}
+
+	final, ok := result.(bool)
+	if !ok {
+		err = p.newError(1, "eval: Expression must be boolean")
+		return
+	}
+
+	if final {
+		resp = tok.value
 	}
 
-	err = NewEvalError(
-		p.statement,
-		0,
-		0,
-		p.partsLen,
-		"eval: Infinite loop",
-	)
 	return
 }
 
@@ -539,3 +519,26 @@ func Eval(data Data, statement string) (resp string,
 
 	return
 }
+
+// Evaluate statement with aggregate functions computed across the data of
+// all deployments in the unit.
+func EvalAggregate(data Data, aggregate []Data, statement string) (
+	resp string, threshold int, err error) {
+
+	if aggregate == nil {
+		aggregate = []Data{}
+	}
+
+	parsr := &Parser{
+		statement: statement,
+		data:      data,
+		aggregate: aggregate,
+	}
+
+	resp, threshold, err = parsr.Eval()
+	if err != nil {
+		return
+	}
+
+	return
+}
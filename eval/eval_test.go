@@ -0,0 +1,224 @@
+package eval
+
+import (
+	"testing"
+)
+
+var testData = Data{
+	"instance": {
+		"name":   "web-1",
+		"cpu":    0.75,
+		"memory": 2048,
+		"uptime": 7200,
+		"ready":  true,
+	},
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		statement string
+		resp      string
+		threshold int
+	}{
+		{"IF instance.cpu > 0.5 THEN 'scale'", "scale", 0},
+		{"IF instance.cpu > 0.9 THEN 'scale'", "", 0},
+		{"IF instance.memory == 2048 THEN 'match'", "match", 0},
+		{"IF instance.memory != 2048 THEN 'match'", "", 0},
+		{"IF instance.memory >= 2048 AND instance.cpu < 1 THEN 'ok'",
+			"ok", 0},
+		{"IF instance.memory > 4096 OR instance.ready THEN 'ok'", "ok", 0},
+		{"IF NOT instance.ready THEN 'down'", "", 0},
+		{"IF NOT NOT instance.ready THEN 'up'", "up", 0},
+		{"IF instance.uptime > 1h FOR 30 THEN 'old'", "old", 30},
+		{"IF instance.uptime >= 2h AND instance.uptime < 1d THEN 'day'",
+			"day", 0},
+		{"IF instance.uptime == 120m THEN 'exact'", "exact", 0},
+		{"IF 1 + 2 * 3 == 7 THEN 'prec'", "prec", 0},
+		{"IF (1 + 2) * 3 == 9 THEN 'paren'", "paren", 0},
+		{"IF 7 % 4 == 3 AND 7 / 2 == 3.5 THEN 'div'", "div", 0},
+		{"IF 5 / 0 == 0 AND 5 % 0 == 0 THEN 'zero'", "zero", 0},
+		{"IF -instance.memory < 0 THEN 'neg'", "neg", 0},
+		{"IF 10 - 2 - 3 == 5 THEN 'left'", "left", 0},
+		{"IF 'ab' + 'cd' == 'abcd' THEN 'concat'", "concat", 0},
+		{"IF false AND instance.missing THEN 'short'", "", 0},
+		{"IF true OR instance.missing THEN 'short'", "short", 0},
+		{"IF starts_with(instance.name, 'web') THEN 'web'", "web", 0},
+		{"IF ends_with(instance.name, '-1') THEN 'first'", "first", 0},
+		{"IF contains(upper(instance.name), 'WEB') THEN 'upper'",
+			"upper", 0},
+		{"IF lower('WEB') == 'web' THEN 'lower'", "lower", 0},
+		{"IF matches(instance.name, '^web-[0-9]+$') THEN 'match'",
+			"match", 0},
+		{"IF instance.name == 1 THEN 'mixed'", "", 0},
+		{"IF count() == 1 AND sum(instance.memory) == 2048 THEN 'agg'",
+			"agg", 0},
+	}
+
+	for _, test := range tests {
+		resp, threshold, err := Eval(testData, test.statement)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.statement, err)
+			continue
+		}
+
+		if resp != test.resp {
+			t.Errorf("%s: resp %q != %q", test.statement, resp, test.resp)
+		}
+
+		if threshold != test.threshold {
+			t.Errorf("%s: threshold %d != %d",
+				test.statement, threshold, test.threshold)
+		}
+	}
+}
+
+func TestEvalAggregate(t *testing.T) {
+	aggregate := []Data{
+		{"instance": {"cpu": 0.2, "memory": 1024, "ready": true}},
+		{"instance": {"cpu": 0.6, "memory": 2048, "ready": false}},
+		{"instance": {"cpu": 0.4, "memory": 4096, "ready": true}},
+	}
+
+	tests := []struct {
+		statement string
+		resp      string
+	}{
+		{"IF count() == 3 THEN 'ok'", "ok"},
+		{"IF count(instance.ready) == 2 THEN 'ok'", "ok"},
+		{"IF avg(instance.cpu) > 0.39 AND avg(instance.cpu) < 0.41 " +
+			"THEN 'ok'", "ok"},
+		{"IF min(instance.memory) == 1024 THEN 'ok'", "ok"},
+		{"IF max(instance.memory) == 4096 THEN 'ok'", "ok"},
+		{"IF sum(instance.memory) == 7168 THEN 'ok'", "ok"},
+		{"IF max(instance.cpu) > 0.5 AND instance.cpu > 0.7 THEN 'ok'",
+			"ok"},
+		{"IF sum(instance.memory) / count() < 1024 THEN 'ok'", ""},
+	}
+
+	for _, test := range tests {
+		resp, _, err := EvalAggregate(testData, aggregate, test.statement)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.statement, err)
+			continue
+		}
+
+		if resp != test.resp {
+			t.Errorf("%s: resp %q != %q", test.statement, resp, test.resp)
+		}
+	}
+
+	resp, _, err := EvalAggregate(testData, nil,
+		"IF count() == 0 AND avg(instance.cpu) == 0 THEN 'empty'")
+	if err != nil {
+		t.Error(err)
+	} else if resp != "empty" {
+		t.Errorf("empty aggregate: resp %q != %q", resp, "empty")
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"IF THEN 'x'",
+		"instance.cpu > 1 THEN 'x'",
+		"IF instance.cpu > 1 'x'",
+		"IF instance.cpu > 1 THEN x",
+		"IF instance.cpu > 1 THEN 'x' 'y'",
+		"IF instance.cpu > 1 FOR 1.5 THEN 'x'",
+		"IF instance.cpu > 1 FOR THEN 'x'",
+		"IF (instance.cpu > 1 THEN 'x'",
+		"IF instance.cpu > 1) THEN 'x'",
+		"IF instance.cpu > 1 THEN 'x",
+		"IF instance.cpu # 1 THEN 'x'",
+		"IF 1.2.3 > 1 THEN 'x'",
+		"IF instance > 1 THEN 'x'",
+		"IF instance.cpu.total > 1 THEN 'x'",
+		"IF other.cpu > 1 THEN 'x'",
+		"IF instance.missing > 1 THEN 'x'",
+		"IF instance.cpu THEN 'x'",
+		"IF instance.memory + 1 THEN 'x'",
+		"IF NOT instance.cpu THEN 'x'",
+		"IF instance.ready AND 1 THEN 'x'",
+		"IF -instance.name == 1 THEN 'x'",
+		"IF instance.name * 2 > 1 THEN 'x'",
+		"IF unknown(instance.cpu) THEN 'x'",
+		"IF contains(instance.name) THEN 'x'",
+		"IF lower(instance.name, 'a') == 'a' THEN 'x'",
+		"IF count(instance.ready, true) > 1 THEN 'x'",
+		"IF contains(instance.name 'web') THEN 'x'",
+		"IF contains(instance.name, 'web' THEN 'x'",
+		"IF contains(instance.memory, 'web') THEN 'x'",
+		"IF matches(instance.name, '[') THEN 'x'",
+		"IF sum(max(instance.cpu)) > 1 THEN 'x'",
+		"IF sum(instance.name) > 1 THEN 'x'",
+		"IF count(instance.cpu) > 1 THEN 'x'",
+	}
+
+	for _, statement := range tests {
+		_, _, err := Eval(testData, statement)
+		if err == nil {
+			t.Errorf("%s: expected error", statement)
+		}
+	}
+}
+
+func TestEvalLimits(t *testing.T) {
+	statement := "IF "
+	for i := 0; i < StatementMaxDepth+1; i++ {
+		statement += "("
+	}
+	statement += "true"
+	for i := 0; i < StatementMaxDepth+1; i++ {
+		statement += ")"
+	}
+	statement += " THEN 'x'"
+
+	_, _, err := Eval(testData, statement)
+	if err == nil {
+		t.Error("depth: expected error")
+	}
+
+	statement = "IF true"
+	for i := 0; i < StatementMaxParts; i++ {
+		statement += " AND true"
+	}
+	statement += " THEN 'x'"
+
+	_, _, err = Eval(testData, statement)
+	if err == nil {
+		t.Error("parts: expected error")
+	}
+
+	statement = "IF instance.name == '"
+	for len(statement) <= StatementMaxLength {
+		statement += "a"
+	}
+	statement += "' THEN 'x'"
+
+	_, _, err = Eval(testData, statement)
+	if err == nil {
+		t.Error("length: expected error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	err := Validate("IF instance.cpu > 0.5 AND " +
+		"matches(instance.name, '^web-[0-9]+$') THEN 'scale'")
+	if err != nil {
+		t.Error(err)
+	}
+
+	tests := []string{
+		"",
+		"IF instance.cpu > 1 THEN \"x\"",
+		"IF instance.cpu > 1 THEN 'x';",
+		"IF instance.cpu > 1 THEN 'x'\n",
+	}
+
+	for _, statement := range tests {
+		err = Validate(statement)
+		if err == nil {
+			t.Errorf("%q: expected error", statement)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package eval
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+type context struct {
+	parser *Parser
+	data   Data
+}
+
+type node interface {
+	eval(ctx *context) (val interface{}, err error)
+}
+
+type literalNode struct {
+	val interface{}
+}
+
+func (n *literalNode) eval(ctx *context) (val interface{}, err error) {
+	val = n.val
+	return
+}
+
+type refNode struct {
+	index int
+	group string
+	key   string
+}
+
+func (n *refNode) eval(ctx *context) (val interface{}, err error) {
+	group := ctx.data[n.group]
+	if group == nil {
+		err = ctx.parser.newError(n.index,
+			"eval: Invalid reference group {{.ErrIndex}}")
+		return
+	}
+
+	val = group[n.key]
+	if val == nil {
+		err = ctx.parser.newError(n.index,
+			"eval: Invalid reference group key {{.ErrIndex}}")
+		return
+	}
+
+	return
+}
+
+type notNode struct {
+	index   int
+	operand node
+}
+
+func (n *notNode) eval(ctx *context) (val interface{}, err error) {
+	operand, err := n.operand.eval(ctx)
+	if err != nil {
+		return
+	}
+
+	boolVal, ok := operand.(bool)
+	if !ok {
+		err = ctx.parser.newError(n.index,
+			"eval: Expected boolean at {{.ErrIndex}}")
+		return
+	}
+
+	val = !boolVal
+	return
+}
+
+type negNode struct {
+	index   int
+	operand node
+}
+
+func (n *negNode) eval(ctx *context) (val interface{}, err error) {
+	operand, err := n.operand.eval(ctx)
+	if err != nil {
+		return
+	}
+
+	switch operandVal := operand.(type) {
+	case int:
+		val = -operandVal
+		break
+	case float64:
+		val = -operandVal
+		break
+	default:
+		err = ctx.parser.newError(n.index,
+			"eval: Expected number at {{.ErrIndex}}")
+		return
+	}
+
+	return
+}
+
+type logicNode struct {
+	index int
+	op    string
+	left  node
+	right node
+}
+
+func (n *logicNode) eval(ctx *context) (val interface{}, err error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return
+	}
+
+	leftVal, ok := left.(bool)
+	if !ok {
+		err = ctx.parser.newError(n.index,
+			"eval: Expected boolean before {{.ErrIndex}}")
+		return
+	}
+
+	if n.op == And && !leftVal {
+		val = false
+		return
+	} else if n.op == Or && leftVal {
+		val = true
+		return
+	}
+
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return
+	}
+
+	rightVal, ok := right.(bool)
+	if !ok {
+		err = ctx.parser.newError(n.index,
+			"eval: Expected boolean after {{.ErrIndex}}")
+		return
+	}
+
+	val = rightVal
+	return
+}
+
+type binaryNode struct {
+	index int
+	op    string
+	left  node
+	right node
+}
+
+func toFloat(val interface{}) (floatVal float64, ok bool) {
+	switch numVal := val.(type) {
+	case int:
+		floatVal = float64(numVal)
+		ok = true
+		break
+	case float64:
+		floatVal = numVal
+		ok = true
+		break
+	}
+	return
+}
+
+func compare(op string, left, right interface{}) bool {
+	leftNum, leftOk := toFloat(left)
+	rightNum, rightOk := toFloat(right)
+	if leftOk && rightOk {
+		switch op {
+		case "==":
+			return leftNum == rightNum
+		case "!=":
+			return leftNum != rightNum
+		case "<":
+			return leftNum < rightNum
+		case "<=":
+			return leftNum <= rightNum
+		case ">":
+			return leftNum > rightNum
+		case ">=":
+			return leftNum >= rightNum
+		}
+		return false
+	}
+
+	switch leftVal := left.(type) {
+	case string:
+		rightVal, ok := right.(string)
+		if !ok {
+			return false
+		}
+
+		switch op {
+		case "==":
+			return leftVal == rightVal
+		case "!=":
+			return leftVal != rightVal
+		case "<":
+			return leftVal < rightVal
+		case "<=":
+			return leftVal <= rightVal
+		case ">":
+			return leftVal > rightVal
+		case ">=":
+			return leftVal >= rightVal
+		}
+	case bool:
+		rightVal, ok := right.(bool)
+		if !ok {
+			return false
+		}
+
+		switch op {
+		case "==":
+			return leftVal == rightVal
+		case "!=":
+			return leftVal != rightVal
+		}
+	}
+
+	return false
+}
+
+// Division by zero evaluates to zero, plans are validated against empty data
+// where zero values are expected.
+func (n *binaryNode) eval(ctx *context) (val interface{}, err error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return
+	}
+
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return
+	}
+
+	switch n.op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		val = compare(n.op, left, right)
+		return
+	}
+
+	if n.op == "+" {
+		leftStr, leftOk := left.(string)
+		rightStr, rightOk := right.(string)
+		if leftOk && rightOk {
+			val = leftStr + rightStr
+			return
+		}
+	}
+
+	leftInt, leftIsInt := left.(int)
+	rightInt, rightIsInt := right.(int)
+	if leftIsInt && rightIsInt && n.op != "/" {
+		switch n.op {
+		case "+":
+			val = leftInt + rightInt
+			break
+		case "-":
+			val = leftInt - rightInt
+			break
+		case "*":
+			val = leftInt * rightInt
+			break
+		case "%":
+			if rightInt == 0 {
+				val = 0
+			} else {
+				val = leftInt % rightInt
+			}
+			break
+		}
+		return
+	}
+
+	leftNum, leftOk := toFloat(left)
+	rightNum, rightOk := toFloat(right)
+	if !leftOk || !rightOk {
+		err = ctx.parser.newError(n.index,
+			"eval: Invalid operands for {{.ErrIndex}}")
+		return
+	}
+
+	switch n.op {
+	case "+":
+		val = leftNum + rightNum
+		break
+	case "-":
+		val = leftNum - rightNum
+		break
+	case "*":
+		val = leftNum * rightNum
+		break
+	case "/":
+		if rightNum == 0 {
+			val = 0.0
+		} else {
+			val = leftNum / rightNum
+		}
+		break
+	case "%":
+		if rightNum == 0 {
+			val = 0.0
+		} else {
+			val = math.Mod(leftNum, rightNum)
+		}
+		break
+	}
+
+	return
+}
+
+type funcNode struct {
+	index int
+	name  string
+	args  []node
+}
+
+func (n *funcNode) evalAggregate(ctx *context) (val interface{}, err error) {
+	datas := ctx.parser.aggregate
+	if datas == nil {
+		datas = []Data{ctx.data}
+	}
+
+	aggCtx := &context{
+		parser: ctx.parser,
+	}
+
+	if n.name == "count" {
+		count := 0
+		for _, data := range datas {
+			if len(n.args) == 0 {
+				count += 1
+				continue
+			}
+
+			aggCtx.data = data
+			argVal, e := n.args[0].eval(aggCtx)
+			if e != nil {
+				err = e
+				return
+			}
+
+			boolVal, ok := argVal.(bool)
+			if !ok {
+				err = ctx.parser.newError(n.index,
+					"eval: Expected boolean argument at {{.ErrIndex}}")
+				return
+			}
+
+			if boolVal {
+				count += 1
+			}
+		}
+
+		val = count
+		return
+	}
+
+	total := 0.0
+	result := 0.0
+	allInt := true
+	for i, data := range datas {
+		aggCtx.data = data
+		argVal, e := n.args[0].eval(aggCtx)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if _, ok := argVal.(int); !ok {
+			allInt = false
+		}
+
+		numVal, ok := toFloat(argVal)
+		if !ok {
+			err = ctx.parser.newError(n.index,
+				"eval: Expected number argument at {{.ErrIndex}}")
+			return
+		}
+
+		total += numVal
+		switch n.name {
+		case "min":
+			if i == 0 || numVal < result {
+				result = numVal
+			}
+			break
+		case "max":
+			if i == 0 || numVal > result {
+				result = numVal
+			}
+			break
+		}
+	}
+
+	switch n.name {
+	case "avg":
+		if len(datas) == 0 {
+			val = 0.0
+		} else {
+			val = total / float64(len(datas))
+		}
+		return
+	case "sum":
+		result = total
+		break
+	}
+
+	if allInt {
+		val = int(result)
+	} else {
+		val = result
+	}
+
+	return
+}
+
+func (n *funcNode) eval(ctx *context) (val interface{}, err error) {
+	if aggregateFuncs.Contains(n.name) {
+		val, err = n.evalAggregate(ctx)
+		return
+	}
+
+	args := []string{}
+	for _, arg := range n.args {
+		argVal, e := arg.eval(ctx)
+		if e != nil {
+			err = e
+			return
+		}
+
+		argStr, ok := argVal.(string)
+		if !ok {
+			err = ctx.parser.newError(n.index,
+				"eval: Expected string argument at {{.ErrIndex}}")
+			return
+		}
+
+		args = append(args, argStr)
+	}
+
+	switch n.name {
+	case "contains":
+		val = strings.Contains(args[0], args[1])
+		break
+	case "starts_with":
+		val = strings.HasPrefix(args[0], args[1])
+		break
+	case "ends_with":
+		val = strings.HasSuffix(args[0], args[1])
+		break
+	case "matches":
+		re, e := regexp.Compile(args[1])
+		if e != nil {
+			err = ctx.parser.newError(n.index,
+				"eval: Invalid pattern at {{.ErrIndex}}")
+			return
+		}
+		val = re.MatchString(args[0])
+		break
+	case "lower":
+		val = strings.ToLower(args[0])
+		break
+	case "upper":
+		val = strings.ToUpper(args[0])
+		break
+	}
+
+	return
+}
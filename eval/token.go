@@ -0,0 +1,238 @@
+package eval
+
+import (
+	"strconv"
+	"strings"
+)
+
+type token struct {
+	kind  int
+	value string
+	val   interface{}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+}
+
+func isIdent(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '.'
+}
+
+func parseNumber(value string) (val interface{}, ok bool) {
+	mult := 0
+	switch value[len(value)-1] {
+	case 's':
+		mult = 1
+		break
+	case 'm':
+		mult = 60
+		break
+	case 'h':
+		mult = 3600
+		break
+	case 'd':
+		mult = 86400
+		break
+	}
+
+	if mult != 0 {
+		num := value[:len(value)-1]
+
+		intVal, e := strconv.Atoi(num)
+		if e == nil {
+			val = intVal * mult
+			ok = true
+			return
+		}
+
+		floatVal, e := strconv.ParseFloat(num, 64)
+		if e == nil {
+			val = int(floatVal * float64(mult))
+			ok = true
+			return
+		}
+
+		return
+	}
+
+	intVal, e := strconv.Atoi(value)
+	if e == nil {
+		val = intVal
+		ok = true
+		return
+	}
+
+	floatVal, e := strconv.ParseFloat(value, 64)
+	if e == nil {
+		val = floatVal
+		ok = true
+		return
+	}
+
+	return
+}
+
+func (p *Parser) tokenize() (err error) {
+	p.tokens = []*token{}
+	statement := p.statement
+	n := len(statement)
+
+	for i := 0; i < n; {
+		c := statement[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i += 1
+			continue
+		case c == '\'':
+			end := strings.IndexByte(statement[i+1:], '\'')
+			if end == -1 {
+				err = NewEvalError(
+					p.statement,
+					len(p.tokens),
+					len(p.tokens),
+					len(p.tokens),
+					"eval: Invalid string {{.ErrIndex}}",
+				)
+				return
+			}
+
+			value := statement[i+1 : i+1+end]
+			p.tokens = append(p.tokens, &token{
+				kind:  tokenString,
+				value: value,
+				val:   value,
+			})
+			i += end + 2
+			continue
+		case isDigit(c):
+			start := i
+			for i < n && (isDigit(statement[i]) || statement[i] == '.') {
+				i += 1
+			}
+			if i < n && strings.IndexByte("smhd", statement[i]) != -1 &&
+				(i+1 >= n || !isIdent(statement[i+1])) {
+
+				i += 1
+			}
+
+			value := statement[start:i]
+			val, ok := parseNumber(value)
+			if !ok {
+				err = NewEvalError(
+					p.statement,
+					len(p.tokens),
+					len(p.tokens),
+					len(p.tokens),
+					"eval: Invalid number {{.ErrIndex}}",
+				)
+				return
+			}
+
+			p.tokens = append(p.tokens, &token{
+				kind:  tokenNumber,
+				value: value,
+				val:   val,
+			})
+			continue
+		case isIdentStart(c):
+			start := i
+			for i < n && isIdent(statement[i]) {
+				i += 1
+			}
+
+			value := statement[start:i]
+			tok := &token{
+				kind:  tokenIdent,
+				value: value,
+			}
+
+			switch value {
+			case If, Then, For, And, Or, Not:
+				tok.kind = tokenKeyword
+				break
+			case "true":
+				tok.kind = tokenBool
+				tok.val = true
+				break
+			case "false":
+				tok.kind = tokenBool
+				tok.val = false
+				break
+			}
+
+			p.tokens = append(p.tokens, tok)
+			continue
+		case c == '(':
+			p.tokens = append(p.tokens, &token{
+				kind:  tokenLparen,
+				value: "(",
+			})
+			i += 1
+			continue
+		case c == ')':
+			p.tokens = append(p.tokens, &token{
+				kind:  tokenRparen,
+				value: ")",
+			})
+			i += 1
+			continue
+		case c == ',':
+			p.tokens = append(p.tokens, &token{
+				kind:  tokenComma,
+				value: ",",
+			})
+			i += 1
+			continue
+		}
+
+		if i+1 < n {
+			op := statement[i : i+2]
+			if binaryOps[op] {
+				p.tokens = append(p.tokens, &token{
+					kind:  tokenOperator,
+					value: op,
+				})
+				i += 2
+				continue
+			}
+		}
+
+		op := statement[i : i+1]
+		if binaryOps[op] {
+			p.tokens = append(p.tokens, &token{
+				kind:  tokenOperator,
+				value: op,
+			})
+			i += 1
+			continue
+		}
+
+		err = NewEvalError(
+			p.statement,
+			len(p.tokens),
+			len(p.tokens),
+			len(p.tokens),
+			"eval: Invalid character {{.ErrIndex}}",
+		)
+		return
+	}
+
+	if len(p.tokens) > StatementMaxParts {
+		err = NewEvalError(
+			p.statement,
+			0,
+			0,
+			len(p.tokens),
+			"eval: Statement exceeds max parts",
+		)
+		return
+	}
+
+	return
+}
@@ -48,7 +48,18 @@ type Resources struct {
 }
 
 var tokenRe = regexp.MustCompile(
-	`\+\/([a-zA-Z0-9-]*)\/([a-zA-Z0-9-]*)(?:(?:\/|\:)([a-zA-Z0-9-_.]*)(?:\/([a-zA-Z0-9-_.]*))?)?`)
+	`\+\/([a-zA-Z0-9-]*)\/([a-zA-Z0-9-_.]*)(?:(?:\/|\:)([a-zA-Z0-9-_.]*)(?:\/([a-zA-Z0-9-_.]*))?)?`)
+
+// IsImageFamily returns true for image tokens that reference the current
+// image of a family channel.
+func IsImageFamily(token string) bool {
+	matches := tokenRe.FindStringSubmatch(token)
+	if len(matches) < 4 || matches[1] != ImageKind {
+		return false
+	}
+
+	return strings.Contains(token, ":") && matches[3] != ""
+}
 
 func (r *Resources) Find(db *database.Database, token string) (
 	kind string, err error) {
@@ -177,19 +188,23 @@ func (r *Resources) Find(db *database.Database, token string) (
 		}
 		break
 	case ImageKind:
-		r.Image, err = image.GetOne(db, &bson.M{
-			"name": resource,
-			"$or": []*bson.M{
-				&bson.M{
-					"organization": r.Organization,
-				},
-				&bson.M{
-					"organization": &bson.M{
-						"$exists": false,
+		if tag != "" {
+			r.Image, err = image.GetFamily(db, r.Organization, resource, tag)
+		} else {
+			r.Image, err = image.GetOne(db, &bson.M{
+				"name": resource,
+				"$or": []*bson.M{
+					&bson.M{
+						"organization": r.Organization,
+					},
+					&bson.M{
+						"organization": &bson.M{
+							"$exists": false,
+						},
 					},
 				},
-			},
-		})
+			})
+		}
 		if err != nil {
 			if _, ok := err.(*database.NotFoundError); ok {
 				err = nil
@@ -0,0 +1,162 @@
+package image
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+)
+
+// Cache records a copy of an image held in the cache directory of a node.
+// The hash is the sha256 of the decrypted image content and is used to
+// verify copies transferred between nodes.
+type Cache struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Image     primitive.ObjectID `bson:"image" json:"image"`
+	Node      primitive.ObjectID `bson:"node" json:"node"`
+	Zone      primitive.ObjectID `bson:"zone" json:"zone"`
+	Etag      string             `bson:"etag" json:"etag"`
+	Hash      string             `bson:"hash" json:"hash"`
+	Size      int64              `bson:"size" json:"size"`
+	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
+}
+
+func (c *Cache) Upsert(db *database.Database) (err error) {
+	coll := db.ImageCaches()
+
+	opts := &options.UpdateOptions{}
+	opts.SetUpsert(true)
+	_, err = coll.UpdateOne(
+		db,
+		&bson.M{
+			"image": c.Image,
+			"node":  c.Node,
+		},
+		&bson.M{
+			"$set": &bson.M{
+				"image":     c.Image,
+				"node":      c.Node,
+				"zone":      c.Zone,
+				"etag":      c.Etag,
+				"hash":      c.Hash,
+				"size":      c.Size,
+				"timestamp": c.Timestamp,
+			},
+		},
+		opts,
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// GetCaches returns the cached copies of an image etag held by other nodes
+// in the zone ordered by most recent.
+func GetCaches(db *database.Database, img *Image, zoneId,
+	ndeId primitive.ObjectID) (caches []*Cache, err error) {
+
+	coll := db.ImageCaches()
+	caches = []*Cache{}
+
+	cursor, err := coll.Find(
+		db,
+		&bson.M{
+			"image": img.Id,
+			"etag":  img.Etag,
+			"zone":  zoneId,
+			"node": &bson.M{
+				"$ne": ndeId,
+			},
+		},
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"timestamp", -1},
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		cache := &Cache{}
+		err = cursor.Decode(cache)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		caches = append(caches, cache)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetCacheNode(db *database.Database, imgId, ndeId primitive.ObjectID) (
+	cache *Cache, err error) {
+
+	coll := db.ImageCaches()
+	cache = &Cache{}
+
+	err = coll.FindOne(db, &bson.M{
+		"image": imgId,
+		"node":  ndeId,
+	}).Decode(cache)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func RemoveCache(db *database.Database, imgId, ndeId primitive.ObjectID) (
+	err error) {
+
+	coll := db.ImageCaches()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"image": imgId,
+		"node":  ndeId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveCaches(db *database.Database, imgId primitive.ObjectID) (
+	err error) {
+
+	coll := db.ImageCaches()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"image": imgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -4,4 +4,15 @@ const (
 	Uefi    = "uefi"
 	Bios    = "bios"
 	Unknown = "unknown"
+
+	Stable  = "stable"
+	Testing = "testing"
+
+	Active     = "active"
+	Deprecated = "deprecated"
+	Obsolete   = "obsolete"
+
+	SignatureValid   = "valid"
+	SignatureInvalid = "invalid"
+	SignatureMissing = "missing"
 )
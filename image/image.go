@@ -13,6 +13,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/deployment"
 	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/organization"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
@@ -31,6 +32,27 @@ type Image struct {
 	LastModified time.Time          `bson:"last_modified" json:"last_modified"`
 	StorageClass string             `bson:"storage_class" json:"storage_class"`
 	Etag         string             `bson:"etag" json:"etag"`
+	Scheduled    bool               `bson:"scheduled" json:"scheduled"`
+	Parent       primitive.ObjectID `bson:"parent,omitempty" json:"parent"`
+	Encryption   primitive.ObjectID `bson:"encryption,omitempty" json:"encryption"`
+	DataKey      string             `bson:"data_key" json:"-"`
+	SnapshotSet  primitive.ObjectID `bson:"snapshot_set,omitempty" json:"snapshot_set"`
+	Family       string             `bson:"family" json:"family"`
+	Version      int                `bson:"version" json:"version"`
+	Channel      string             `bson:"channel" json:"channel"`
+	Lifecycle    string             `bson:"lifecycle" json:"lifecycle"`
+	Signature    *Signature         `bson:"signature,omitempty" json:"signature"`
+}
+
+// Signature records the result of the last signature verification of the
+// image content identified by the etag.
+type Signature struct {
+	State       string             `bson:"state" json:"state"`
+	Etag        string             `bson:"etag" json:"etag"`
+	Key         primitive.ObjectID `bson:"key,omitempty" json:"key"`
+	Fingerprint string             `bson:"fingerprint" json:"fingerprint"`
+	Message     string             `bson:"message" json:"message"`
+	Timestamp   time.Time          `bson:"timestamp" json:"timestamp"`
 }
 
 func (i *Image) Validate(db *database.Database) (
@@ -42,6 +64,121 @@ func (i *Image) Validate(db *database.Database) (
 		i.Firmware = Unknown
 	}
 
+	i.Family = utils.FilterName(i.Family)
+	if i.Family == "" {
+		i.Version = 0
+		i.Channel = ""
+	} else {
+		switch i.Channel {
+		case Stable, Testing:
+			break
+		case "":
+			i.Channel = Stable
+			break
+		default:
+			errData = &errortypes.ErrorData{
+				Error:   "channel_invalid",
+				Message: "Image channel is invalid",
+			}
+			return
+		}
+
+		if i.Version < 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "version_invalid",
+				Message: "Image version cannot be negative",
+			}
+			return
+		}
+	}
+
+	switch i.Lifecycle {
+	case Active, Deprecated, Obsolete:
+		break
+	case "":
+		i.Lifecycle = Active
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "lifecycle_invalid",
+			Message: "Image lifecycle is invalid",
+		}
+		return
+	}
+
+	return
+}
+
+func (i *Image) IsDeprecated() bool {
+	return i.Lifecycle == Deprecated
+}
+
+func (i *Image) IsObsolete() bool {
+	return i.Lifecycle == Obsolete
+}
+
+// IsSystem returns true for snapshot and backup images created from disks.
+func (i *Image) IsSystem() bool {
+	return strings.HasPrefix(i.Key, "backup/") ||
+		strings.HasPrefix(i.Key, "snapshot/")
+}
+
+// GetSignatureState returns the verification state of the current image
+// content or an empty string if it has not been verified.
+func (i *Image) GetSignatureState() string {
+	if i.Signature == nil || i.Signature.Etag != i.Etag {
+		return ""
+	}
+	return i.Signature.State
+}
+
+// IsSignatureRequired returns true if the image is a private image of an
+// organization that requires signed images.
+func (i *Image) IsSignatureRequired(db *database.Database) (
+	required bool, err error) {
+
+	if i.Organization.IsZero() || i.IsSystem() {
+		return
+	}
+
+	org, err := organization.Get(db, i.Organization)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	required = org.RequireSigned
+	return
+}
+
+// CheckSignature returns error data if the image failed signature
+// verification or is unsigned and the organization requires signed images.
+func (i *Image) CheckSignature(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if i.GetSignatureState() == SignatureInvalid {
+		errData = &errortypes.ErrorData{
+			Error:   "image_signature_invalid",
+			Message: "Image signature verification failed",
+		}
+		return
+	}
+
+	required, err := i.IsSignatureRequired(db)
+	if err != nil {
+		return
+	}
+
+	if required && !i.Signed {
+		errData = &errortypes.ErrorData{
+			Error:   "image_unsigned",
+			Message: "Organization requires signed images",
+		}
+		return
+	}
+
 	return
 }
 
@@ -296,6 +433,11 @@ func (i *Image) Upsert(db *database.Database) (err error) {
 				"last_modified": i.LastModified,
 				"storage_class": i.StorageClass,
 				"etag":          i.Etag,
+				"scheduled":     i.Scheduled,
+				"parent":        i.Parent,
+				"encryption":    i.Encryption,
+				"data_key":      i.DataKey,
+				"snapshot_set":  i.SnapshotSet,
 			},
 		},
 		opts,
@@ -317,9 +459,7 @@ func (i *Image) Sync(db *database.Database) (err error) {
 
 	i.Parse()
 
-	if strings.HasPrefix(i.Key, "backup/") ||
-		strings.HasPrefix(i.Key, "snapshot/") {
-
+	if i.IsSystem() {
 		resp, e := coll.UpdateOne(
 			db,
 			&bson.M{
@@ -120,6 +120,46 @@ func GetOne(db *database.Database, query *bson.M) (img *Image, err error) {
 	return
 }
 
+func GetFamily(db *database.Database, orgId primitive.ObjectID,
+	family, channel string) (img *Image, err error) {
+
+	coll := db.Images()
+	img = &Image{}
+
+	if channel == "" {
+		channel = Stable
+	}
+
+	err = coll.FindOne(db, &bson.M{
+		"family":  family,
+		"channel": channel,
+		"lifecycle": &bson.M{
+			"$nin": []string{Deprecated, Obsolete},
+		},
+		"$or": []*bson.M{
+			&bson.M{
+				"organization": orgId,
+			},
+			&bson.M{
+				"organization": &bson.M{
+					"$exists": false,
+				},
+			},
+		},
+	}, &options.FindOneOptions{
+		Sort: &bson.D{
+			{"version", -1},
+			{"last_modified", -1},
+		},
+	}).Decode(img)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func Distinct(db *database.Database, storeId primitive.ObjectID) (
 	keys []string, err error) {
 
@@ -143,6 +183,47 @@ func Distinct(db *database.Database, storeId primitive.ObjectID) (
 	return
 }
 
+// Check if any image is encrypted with the encryption secret.
+func ExistsEncryption(db *database.Database, secrId primitive.ObjectID) (
+	exists bool, err error) {
+
+	coll := db.Images()
+
+	n, err := coll.CountDocuments(db, &bson.M{
+		"encryption": secrId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if n > 0 {
+		exists = true
+	}
+
+	return
+}
+
+func ExistsParent(db *database.Database, imgId primitive.ObjectID) (
+	exists bool, err error) {
+
+	coll := db.Images()
+
+	n, err := coll.CountDocuments(db, &bson.M{
+		"parent": imgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if n > 0 {
+		exists = true
+	}
+
+	return
+}
+
 func ExistsOrg(db *database.Database, orgId, imgId primitive.ObjectID) (
 	exists bool, err error) {
 
@@ -234,6 +315,89 @@ func GetAll(db *database.Database, query *bson.M, page, pageCount int64) (
 	return
 }
 
+func GetAllScheduled(db *database.Database, diskId primitive.ObjectID) (
+	imgs []*Image, err error) {
+
+	coll := db.Images()
+	imgs = []*Image{}
+
+	cursor, err := coll.Find(
+		db,
+		&bson.M{
+			"disk":      diskId,
+			"scheduled": true,
+		},
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"last_modified", -1},
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		img := &Image{}
+		err = cursor.Decode(img)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		imgs = append(imgs, img)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetSnapshotSet(db *database.Database, orgId,
+	setId primitive.ObjectID) (imgs []*Image, err error) {
+
+	coll := db.Images()
+	imgs = []*Image{}
+
+	cursor, err := coll.Find(
+		db,
+		&bson.M{
+			"organization": orgId,
+			"snapshot_set": setId,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		img := &Image{}
+		err = cursor.Decode(img)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		imgs = append(imgs, img)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func GetAllNames(db *database.Database, query *bson.M) (
 	images []*Image, err error) {
 
@@ -252,6 +416,7 @@ func GetAllNames(db *database.Database, query *bson.M) (
 				{"key", 1},
 				{"signed", 1},
 				{"firmware", 1},
+				{"lifecycle", 1},
 			},
 		},
 	)
@@ -342,5 +507,10 @@ func Remove(db *database.Database, imgId primitive.ObjectID) (err error) {
 		}
 	}
 
+	err = RemoveCaches(db, imgId)
+	if err != nil {
+		return
+	}
+
 	return
 }
@@ -6,10 +6,12 @@ import (
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/certificate"
 	"github.com/pritunl/pritunl-cloud/deployment"
+	"github.com/pritunl/pritunl-cloud/disk"
 	"github.com/pritunl/pritunl-cloud/imds/types"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/pod"
 	"github.com/pritunl/pritunl-cloud/secret"
+	"github.com/pritunl/pritunl-cloud/spec"
 	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/pritunl/pritunl-cloud/vpc"
 )
@@ -20,7 +22,8 @@ var (
 )
 
 func BuildConfig(inst *instance.Instance, virt *vm.VirtualMachine,
-	vc *vpc.Vpc, subnet *vpc.Subnet, pods []*pod.Pod,
+	dsks []*disk.Disk, spc *spec.Spec, vc *vpc.Vpc, subnet *vpc.Subnet,
+	pods []*pod.Pod,
 	deployments map[primitive.ObjectID]*deployment.Deployment,
 	secrs []*secret.Secret, certs []*certificate.Certificate) (
 	conf *types.Config, err error) {
@@ -29,11 +32,13 @@ func BuildConfig(inst *instance.Instance, virt *vm.VirtualMachine,
 		ImdsHostSecret: virt.ImdsHostSecret,
 		ClientIps:      inst.PrivateIps,
 		Instance:       types.NewInstance(inst),
+		Disks:          types.NewDisks(dsks),
 		Vpc:            types.NewVpc(vc),
 		Subnet:         types.NewSubnet(subnet),
 		Pods:           types.NewPods(pods, deployments),
 		Secrets:        types.NewSecrets(secrs),
 		Certificates:   types.NewCertificates(certs),
+		HealthChecks:   types.NewHealthChecks(spc),
 	}
 
 	return
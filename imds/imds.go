@@ -147,12 +147,14 @@ func Sync(db *database.Database, instId, deplyId primitive.ObjectID,
 			"$set": &bson.M{
 				"guest": &instance.GuestData{
 					Status:    ste.Status,
+					Health:    ste.Health,
 					Heartbeat: ste.Timestamp,
 					Memory:    ste.Memory,
 					HugePages: ste.HugePages,
 					Load1:     ste.Load1,
 					Load5:     ste.Load5,
 					Load15:    ste.Load15,
+					Uptime:    ste.Uptime,
 				},
 			},
 		})
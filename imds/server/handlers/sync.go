@@ -13,7 +13,9 @@ import (
 )
 
 type syncRespData struct {
-	Hash uint32 `json:"hash"`
+	Hash         uint32               `json:"hash"`
+	HealthChecks []*types.HealthCheck `json:"health_checks"`
+	Disks        []*types.Disk        `json:"disks"`
 }
 
 func syncPut(c *gin.Context) {
@@ -37,6 +39,9 @@ func syncPut(c *gin.Context) {
 	state.Global.State.Load1 = data.Load1
 	state.Global.State.Load5 = data.Load5
 	state.Global.State.Load15 = data.Load15
+	state.Global.State.Uptime = data.Uptime
+	state.Global.State.Health = data.Health
+	state.Global.State.Checks = data.Checks
 
 	if data.Output != nil {
 		for _, entry := range data.Output {
@@ -45,7 +50,9 @@ func syncPut(c *gin.Context) {
 	}
 
 	c.JSON(200, &syncRespData{
-		Hash: config.Config.Hash,
+		Hash:         config.Config.Hash,
+		HealthChecks: config.Config.HealthChecks,
+		Disks:        config.Config.Disks,
 	})
 }
 
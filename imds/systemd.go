@@ -2,6 +2,8 @@ package imds
 
 import (
 	"fmt"
+	"io/ioutil"
+	"strings"
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
@@ -100,6 +102,37 @@ func WriteService(vmId primitive.ObjectID,
 	return
 }
 
+func GetClientSecret(vmId primitive.ObjectID) (secret string, err error) {
+	unitPath := paths.GetUnitPathImds(vmId)
+
+	data, err := ioutil.ReadFile(unitPath)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "imds: Failed to read imds service"),
+		}
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "Environment=\"CLIENT_SECRET=") {
+			continue
+		}
+
+		secret = strings.TrimSuffix(strings.TrimPrefix(
+			line, "Environment=\"CLIENT_SECRET="), "\"")
+		break
+	}
+
+	if secret == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("imds: Failed to find imds client secret"),
+		}
+		return
+	}
+
+	return
+}
+
 func Start(db *database.Database, virt *vm.VirtualMachine) (err error) {
 	namespace := vm.GetNamespace(virt.Id, 0)
 
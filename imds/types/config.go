@@ -11,11 +11,13 @@ type Config struct {
 	ImdsHostSecret string             `json:"-"`
 	ClientIps      []string           `json:"client_ips"`
 	Instance       *Instance          `json:"instance"`
+	Disks          []*Disk            `json:"disks"`
 	Vpc            *Vpc               `json:"vpc"`
 	Subnet         *Subnet            `json:"subnet"`
 	Certificates   []*Certificate     `json:"certificates"`
 	Secrets        []*Secret          `json:"secrets"`
 	Pods           []*Pod             `json:"pods"`
+	HealthChecks   []*HealthCheck     `json:"health_checks"`
 	Hash           uint32             `json:"hash"`
 }
 
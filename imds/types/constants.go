@@ -5,4 +5,7 @@ const (
 	Reloading    = "reloading"
 	Running      = "running"
 	Imaged       = "imaged"
+
+	Healthy   = "healthy"
+	Unhealthy = "unhealthy"
 )
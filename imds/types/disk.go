@@ -0,0 +1,36 @@
+package types
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/disk"
+)
+
+type Disk struct {
+	Id         primitive.ObjectID `json:"id"`
+	Index      string             `json:"index"`
+	Uuid       string             `json:"uuid"`
+	FileSystem string             `json:"file_system"`
+	Size       int                `json:"size"`
+}
+
+func NewDisks(dsks []*disk.Disk) []*Disk {
+	datas := []*Disk{}
+
+	for _, dsk := range dsks {
+		if dsk == nil {
+			continue
+		}
+
+		data := &Disk{
+			Id:         dsk.Id,
+			Index:      dsk.Index,
+			Uuid:       dsk.Uuid,
+			FileSystem: dsk.FileSystem,
+			Size:       dsk.Size,
+		}
+
+		datas = append(datas, data)
+	}
+
+	return datas
+}
@@ -0,0 +1,47 @@
+package types
+
+import (
+	"github.com/pritunl/pritunl-cloud/spec"
+)
+
+type HealthCheck struct {
+	Name               string `json:"name"`
+	Kind               string `json:"kind"`
+	Port               int    `json:"port"`
+	Path               string `json:"path"`
+	Command            string `json:"command"`
+	Interval           int    `json:"interval"`
+	Timeout            int    `json:"timeout"`
+	HealthyThreshold   int    `json:"healthy_threshold"`
+	UnhealthyThreshold int    `json:"unhealthy_threshold"`
+}
+
+type HealthCheckState struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+func NewHealthChecks(spc *spec.Spec) []*HealthCheck {
+	checks := []*HealthCheck{}
+
+	if spc == nil || spc.Instance == nil {
+		return checks
+	}
+
+	for _, check := range spc.Instance.HealthChecks {
+		checks = append(checks, &HealthCheck{
+			Name:               check.Name,
+			Kind:               check.Kind,
+			Port:               check.Port,
+			Path:               check.Path,
+			Command:            check.Command,
+			Interval:           check.Interval,
+			Timeout:            check.Timeout,
+			HealthyThreshold:   check.HealthyThreshold,
+			UnhealthyThreshold: check.UnhealthyThreshold,
+		})
+	}
+
+	return checks
+}
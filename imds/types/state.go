@@ -5,14 +5,17 @@ import (
 )
 
 type State struct {
-	Status    string    `json:"status"`
-	Memory    float64   `json:"memory"`
-	HugePages float64   `json:"hugepages"`
-	Load1     float64   `json:"load1"`
-	Load5     float64   `json:"load5"`
-	Load15    float64   `json:"load15"`
-	Timestamp time.Time `json:"timestamp"`
-	Output    []*Entry  `json:"output,omitempty"`
+	Status    string              `json:"status"`
+	Health    string              `json:"health"`
+	Checks    []*HealthCheckState `json:"checks,omitempty"`
+	Memory    float64             `json:"memory"`
+	HugePages float64             `json:"hugepages"`
+	Load1     float64             `json:"load1"`
+	Load5     float64             `json:"load5"`
+	Load15    float64             `json:"load15"`
+	Uptime    int64               `json:"uptime"`
+	Timestamp time.Time           `json:"timestamp"`
+	Output    []*Entry            `json:"output,omitempty"`
 }
 
 func (s *State) Final() bool {
@@ -25,11 +28,14 @@ func (s *State) Final() bool {
 func (s *State) Copy() *State {
 	return &State{
 		Status:    s.Status,
+		Health:    s.Health,
+		Checks:    s.Checks,
 		Memory:    s.Memory,
 		HugePages: s.HugePages,
 		Load1:     s.Load1,
 		Load5:     s.Load5,
 		Load15:    s.Load15,
+		Uptime:    s.Uptime,
 		Timestamp: s.Timestamp,
 	}
 }
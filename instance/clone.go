@@ -0,0 +1,167 @@
+package instance
+
+import (
+	"fmt"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/iso"
+)
+
+// Create a copy of the instance configuration with clones of all instance
+// disks, host devices are not copied. The clone is inserted stopped and
+// set to start after all disks are inserted, the instance is then created
+// once all disks have been cloned.
+func (i *Instance) Clone(db *database.Database, name string) (
+	clone *Instance, errData *errortypes.ErrorData, err error) {
+
+	if i.Node.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "clone_node_invalid",
+			Message: "Instance must be provisioned on a node to clone",
+		}
+		return
+	}
+
+	if i.Migration != nil && i.Migration.IsActive() {
+		errData = &errortypes.ErrorData{
+			Error:   "migration_active",
+			Message: "Cannot clone instance during migration",
+		}
+		return
+	}
+
+	dsks, err := disk.GetInstance(db, i.Id)
+	if err != nil {
+		return
+	}
+
+	for _, dsk := range dsks {
+		if dsk.State != disk.Available {
+			errData = &errortypes.ErrorData{
+				Error:   "disk_busy",
+				Message: "Instance disks must be available",
+			}
+			return
+		}
+	}
+
+	if name == "" {
+		name = fmt.Sprintf("%s-clone", i.Name)
+	}
+
+	isos := []*iso.Iso{}
+	for _, is := range i.Isos {
+		isos = append(isos, &iso.Iso{
+			Name: is.Name,
+		})
+	}
+
+	clone = &Instance{
+		State:               Stop,
+		Organization:        i.Organization,
+		Zone:                i.Zone,
+		Vpc:                 i.Vpc,
+		Subnet:              i.Subnet,
+		Node:                i.Node,
+		DiskType:            i.DiskType,
+		DiskPool:            i.DiskPool,
+		DiskQos:             i.DiskQos.Copy(),
+		Uefi:                i.Uefi,
+		SecureBoot:          i.SecureBoot,
+		Tpm:                 i.Tpm,
+		DhcpServer:          i.DhcpServer,
+		CloudType:           i.CloudType,
+		CloudScript:         i.CloudScript,
+		SkipSourceDestCheck: i.SkipSourceDestCheck,
+		Name:                name,
+		Comment:             i.Comment,
+		InitDiskSize:        i.InitDiskSize,
+		Memory:              i.Memory,
+		Processors:          i.Processors,
+		NetworkRoles:        i.NetworkRoles,
+		NetworkIngress:      i.NetworkIngress,
+		NetworkEgress:       i.NetworkEgress,
+		Isos:                isos,
+		RootEnabled:         i.RootEnabled,
+		Vnc:                 i.Vnc,
+		Spice:               i.Spice,
+		Gui:                 i.Gui,
+		NoPublicAddress:     i.NoPublicAddress,
+		NoPublicAddress6:    i.NoPublicAddress6,
+		NoHostAddress:       i.NoHostAddress,
+	}
+
+	errData, err = clone.Validate(db)
+	if err != nil || errData != nil {
+		clone = nil
+		return
+	}
+
+	err = clone.GenerateId()
+	if err != nil {
+		clone = nil
+		return
+	}
+
+	cloneDsks := []*disk.Disk{}
+	for _, dsk := range dsks {
+		dskName := clone.Name
+		if dsk.Index != "0" {
+			dskName = fmt.Sprintf("%s-%s", clone.Name, dsk.Index)
+		}
+
+		cloneDsk := &disk.Disk{
+			Id:           primitive.NewObjectID(),
+			Name:         dskName,
+			Organization: dsk.Organization,
+			Instance:     clone.Id,
+			Index:        dsk.Index,
+			Type:         dsk.Type,
+			Node:         dsk.Node,
+			Pool:         dsk.Pool,
+			SystemType:   dsk.SystemType,
+			Size:         dsk.Size,
+			CloneSource:  dsk.Id,
+			Qos:          dsk.Qos.Copy(),
+		}
+
+		errData, err = cloneDsk.Validate(db)
+		if err != nil || errData != nil {
+			clone = nil
+			return
+		}
+
+		cloneDsks = append(cloneDsks, cloneDsk)
+	}
+
+	err = clone.Insert(db)
+	if err != nil {
+		clone = nil
+		return
+	}
+
+	for n, cloneDsk := range cloneDsks {
+		err = cloneDsk.Insert(db)
+		if err != nil {
+			for _, insertedDsk := range cloneDsks[:n] {
+				_ = disk.Remove(db, insertedDsk.Id)
+			}
+			_ = Delete(db, clone.Id)
+			clone = nil
+			return
+		}
+	}
+
+	clone.State = Start
+	err = clone.CommitFields(db, set.NewSet("state"))
+	if err != nil {
+		clone = nil
+		return
+	}
+
+	return
+}
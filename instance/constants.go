@@ -15,6 +15,15 @@ const (
 	BSD       = "bsd"
 )
 
+const (
+	MigrationPending  = "pending"
+	MigrationPrepare  = "prepare"
+	MigrationReady    = "ready"
+	MigrationTransfer = "transfer"
+	MigrationComplete = "complete"
+	MigrationFailed   = "failed"
+)
+
 var (
 	ValidStates = set.NewSet(
 		Provision,
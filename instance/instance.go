@@ -53,6 +53,9 @@ type Instance struct {
 	ImageBacking        bool               `bson:"image_backing" json:"image_backing"`
 	DiskType            string             `bson:"disk_type" json:"disk_type"`
 	DiskPool            primitive.ObjectID `bson:"disk_pool,omitempty" json:"disk_pool"`
+	DiskQos             *vm.DiskQos        `bson:"disk_qos,omitempty" json:"disk_qos"`
+	NetworkIngress      int                `bson:"network_ingress" json:"network_ingress"`
+	NetworkEgress       int                `bson:"network_egress" json:"network_egress"`
 	Status              string             `bson:"-" json:"status"`
 	Uptime              string             `bson:"-" json:"uptime"`
 	State               string             `bson:"state" json:"state"`
@@ -107,6 +110,7 @@ type Instance struct {
 	SpicePort           int                `bson:"spice_port" json:"spice_port"`
 	Gui                 bool               `bson:"gui" json:"gui"`
 	Deployment          primitive.ObjectID `bson:"deployment,omitempty" json:"deployment"`
+	Migration           *Migration         `bson:"migration,omitempty" json:"migration"`
 	Virt                *vm.VirtualMachine `bson:"-" json:"-"`
 	curVpc              primitive.ObjectID `bson:"-" json:"-"`
 	curSubnet           primitive.ObjectID `bson:"-" json:"-"`
@@ -119,12 +123,14 @@ type Instance struct {
 
 type GuestData struct {
 	Status    string    `bson:"status" json:"status"`
+	Health    string    `bson:"health" json:"health"`
 	Heartbeat time.Time `bson:"heartbeat" json:"heartbeat"`
 	Memory    float64   `bson:"memory" json:"memory"`
 	HugePages float64   `bson:"hugepages" json:"hugepages"`
 	Load1     float64   `bson:"load1" json:"load1"`
 	Load5     float64   `bson:"load5" json:"load5"`
 	Load15    float64   `bson:"load15" json:"load15"`
+	Uptime    int64     `bson:"uptime" json:"uptime"`
 }
 
 func (i *Instance) GenerateId() (err error) {
@@ -208,6 +214,9 @@ func (i *Instance) Validate(db *database.Database) (
 		i.Node = nde.Id
 		i.DiskType = shpe.DiskType
 		i.DiskPool = shpe.DiskPool
+		i.DiskQos = shpe.DiskQos.Copy()
+		i.NetworkIngress = shpe.NetworkIngress
+		i.NetworkEgress = shpe.NetworkEgress
 	}
 
 	if i.Vpc.IsZero() {
@@ -223,7 +232,7 @@ func (i *Instance) Validate(db *database.Database) (
 	}
 
 	switch i.DiskType {
-	case disk.Lvm:
+	case disk.Lvm, disk.Rbd:
 		if i.DiskPool.IsZero() {
 			errData = &errortypes.ErrorData{
 				Error:   "pool_required",
@@ -231,6 +240,19 @@ func (i *Instance) Validate(db *database.Database) (
 			}
 			return
 		}
+
+		if i.Id.IsZero() || i.newId {
+			pl, e := pool.Get(db, i.DiskPool)
+			if e != nil {
+				err = e
+				return
+			}
+
+			errData = pl.CheckCapacity(utils.Max(i.InitDiskSize, 10))
+			if errData != nil {
+				return
+			}
+		}
 		break
 	case disk.Qcow2, "":
 		i.DiskType = disk.Qcow2
@@ -266,6 +288,14 @@ func (i *Instance) Validate(db *database.Database) (
 		return
 	}
 
+	if i.NetworkIngress < 0 || i.NetworkEgress < 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "network_limit_invalid",
+			Message: "Network bandwidth limit cannot be negative",
+		}
+		return
+	}
+
 	if i.Memory < 256 {
 		i.Memory = 256
 	}
@@ -653,6 +683,10 @@ func (i *Instance) Json(short bool) {
 		break
 	}
 
+	if i.Migration != nil && i.Migration.IsActive() {
+		i.Status = "Migrating"
+	}
+
 	i.PublicMac = vm.GetMacAddrExternal(i.Id, i.Vpc)
 	if i.VirtTimestamp.IsZero() || !i.IsActive() {
 		i.Uptime = ""
@@ -879,6 +913,29 @@ func (i *Instance) LoadVirt(poolsMap map[primitive.ObjectID]*pool.Pool,
 						Type:   vm.Lvm,
 						VgName: pl.VgName,
 						LvName: dsk.Id.Hex(),
+						Qos:    dsk.Qos.Copy(),
+					},
+				)
+				break
+			case disk.Rbd:
+				if poolsMap == nil {
+					continue
+				}
+
+				pl := poolsMap[dsk.Pool]
+				if pl == nil {
+					continue
+				}
+
+				i.Virt.DriveDevices = append(
+					i.Virt.DriveDevices,
+					&vm.DriveDevice{
+						Id:       dsk.Id.Hex(),
+						Type:     vm.Rbd,
+						RbdPool:  pl.CephPool,
+						RbdUser:  pl.CephUser,
+						RbdImage: dsk.Id.Hex(),
+						Qos:      dsk.Qos.Copy(),
 					},
 				)
 				break
@@ -892,6 +949,7 @@ func (i *Instance) LoadVirt(poolsMap map[primitive.ObjectID]*pool.Pool,
 					Id:    dsk.Id,
 					Index: index,
 					Path:  paths.GetDiskPath(dsk.Id),
+					Qos:   dsk.Qos.Copy(),
 				})
 				break
 			}
@@ -0,0 +1,464 @@
+package instance
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+type Migration struct {
+	Node       primitive.ObjectID `bson:"node" json:"node"`
+	Source     primitive.ObjectID `bson:"source" json:"source"`
+	State      string             `bson:"state" json:"state"`
+	Address    string             `bson:"address" json:"address"`
+	Disks      []*MigrationDisk   `bson:"disks" json:"disks"`
+	SourceCert string             `bson:"source_cert" json:"-"`
+	NodeCert   string             `bson:"node_cert" json:"-"`
+	Error      string             `bson:"error" json:"error"`
+	Timestamp  time.Time          `bson:"timestamp" json:"timestamp"`
+}
+
+type MigrationDisk struct {
+	Id   primitive.ObjectID `bson:"id" json:"id"`
+	Size int64              `bson:"size" json:"size"`
+}
+
+func (m *Migration) IsActive() bool {
+	return m.State != MigrationComplete && m.State != MigrationFailed
+}
+
+func (m *Migration) IsExpired() bool {
+	return time.Since(m.Timestamp) > time.Duration(
+		settings.Hypervisor.MigrationTimeout)*time.Second
+}
+
+func (i *Instance) StartMigration(db *database.Database,
+	ndeId primitive.ObjectID) (errData *errortypes.ErrorData, err error) {
+
+	if i.Migration != nil && i.Migration.IsActive() {
+		errData = &errortypes.ErrorData{
+			Error:   "migration_active",
+			Message: "Instance migration already in progress",
+		}
+		return
+	}
+
+	if i.State != Start || i.VirtState != vm.Running {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_not_running",
+			Message: "Instance must be running to migrate",
+		}
+		return
+	}
+
+	if len(i.UsbDevices) > 0 || len(i.PciDevices) > 0 ||
+		len(i.DriveDevices) > 0 || len(i.IscsiDevices) > 0 ||
+		len(i.Isos) > 0 || i.Tpm || i.DiskType == disk.Lvm ||
+		i.DiskType == disk.Rbd {
+
+		errData = &errortypes.ErrorData{
+			Error:   "migration_unsupported",
+			Message: "Instance has devices that cannot be migrated",
+		}
+		return
+	}
+
+	dsks, err := disk.GetInstance(db, i.Id)
+	if err != nil {
+		return
+	}
+
+	migrationDisks := []*MigrationDisk{}
+	for _, dsk := range dsks {
+		if dsk.IsPooled() || dsk.State != disk.Available {
+			errData = &errortypes.ErrorData{
+				Error:   "migration_unsupported",
+				Message: "Instance disks must be available qcow2 disks",
+			}
+			return
+		}
+
+		migrationDisks = append(migrationDisks, &MigrationDisk{
+			Id: dsk.Id,
+		})
+	}
+
+	if ndeId.IsZero() || ndeId == i.Node {
+		errData = &errortypes.ErrorData{
+			Error:   "node_invalid",
+			Message: "Migration node must differ from current node",
+		}
+		return
+	}
+
+	nde, err := node.Get(db, ndeId)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "node_invalid",
+				Message: "Migration node not found",
+			}
+		}
+		return
+	}
+
+	if nde.Zone != i.Zone || !nde.IsHypervisor() || !nde.IsOnline() {
+		errData = &errortypes.ErrorData{
+			Error:   "node_invalid",
+			Message: "Migration node must be online in the same zone",
+		}
+		return
+	}
+
+	if nde.Maintenance {
+		errData = &errortypes.ErrorData{
+			Error:   "node_maintenance",
+			Message: "Migration node is in maintenance",
+		}
+		return
+	}
+
+	if !nde.SizeResource(i.Memory, i.Processors) {
+		errData = &errortypes.ErrorData{
+			Error:   "node_resources",
+			Message: "Migration node does not have available resources",
+		}
+		return
+	}
+
+	coll := db.Instances()
+
+	ports := []*bson.M{}
+	if i.VncDisplay != 0 {
+		ports = append(ports, &bson.M{
+			"vnc_display": i.VncDisplay,
+		})
+	}
+	if i.SpicePort != 0 {
+		ports = append(ports, &bson.M{
+			"spice_port": i.SpicePort,
+		})
+	}
+
+	if len(ports) > 0 {
+		count, e := coll.CountDocuments(db, &bson.M{
+			"node": nde.Id,
+			"$or":  ports,
+		})
+		if e != nil {
+			err = database.ParseError(e)
+			return
+		}
+
+		if count > 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "node_port_conflict",
+				Message: "Migration node has a conflicting console port",
+			}
+			return
+		}
+	}
+
+	count, err := coll.CountDocuments(db, &bson.M{
+		"migration.node": nde.Id,
+		"migration.state": &bson.M{
+			"$in": []string{
+				MigrationPending,
+				MigrationPrepare,
+				MigrationReady,
+				MigrationTransfer,
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if count > 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "node_busy",
+			Message: "Migration node has a migration in progress",
+		}
+		return
+	}
+
+	migration := &Migration{
+		Node:      nde.Id,
+		Source:    i.Node,
+		State:     MigrationPending,
+		Disks:     migrationDisks,
+		Timestamp: time.Now(),
+	}
+
+	resp, err := coll.UpdateOne(db, &bson.M{
+		"_id":  i.Id,
+		"node": i.Node,
+		"$or": []*bson.M{
+			&bson.M{
+				"migration": nil,
+			},
+			&bson.M{
+				"migration.state": &bson.M{
+					"$in": []string{
+						MigrationComplete,
+						MigrationFailed,
+					},
+				},
+			},
+		},
+	}, &bson.M{
+		"$set": &bson.M{
+			"migration": migration,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if resp.MatchedCount == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "migration_active",
+			Message: "Instance migration already in progress",
+		}
+		return
+	}
+
+	i.Migration = migration
+
+	return
+}
+
+// Move stopped instance to another node in the same zone, all disks must be
+// stored on network pools that are available on the new node.
+func (i *Instance) Relocate(db *database.Database,
+	ndeId primitive.ObjectID) (errData *errortypes.ErrorData, err error) {
+
+	if i.Migration != nil && i.Migration.IsActive() {
+		errData = &errortypes.ErrorData{
+			Error:   "migration_active",
+			Message: "Instance migration already in progress",
+		}
+		return
+	}
+
+	if i.State != Stop || i.VirtState != vm.Stopped {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_not_stopped",
+			Message: "Instance must be stopped to relocate",
+		}
+		return
+	}
+
+	if len(i.UsbDevices) > 0 || len(i.PciDevices) > 0 ||
+		len(i.DriveDevices) > 0 || len(i.Isos) > 0 || i.Tpm {
+
+		errData = &errortypes.ErrorData{
+			Error:   "relocate_unsupported",
+			Message: "Instance has devices that cannot be relocated",
+		}
+		return
+	}
+
+	if ndeId.IsZero() || ndeId == i.Node {
+		errData = &errortypes.ErrorData{
+			Error:   "node_invalid",
+			Message: "Relocation node must differ from current node",
+		}
+		return
+	}
+
+	nde, err := node.Get(db, ndeId)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "node_invalid",
+				Message: "Relocation node not found",
+			}
+		}
+		return
+	}
+
+	if nde.Zone != i.Zone || !nde.IsHypervisor() || !nde.IsOnline() ||
+		nde.Maintenance {
+
+		errData = &errortypes.ErrorData{
+			Error:   "node_invalid",
+			Message: "Relocation node must be online in the same zone",
+		}
+		return
+	}
+
+	dsks, err := disk.GetInstance(db, i.Id)
+	if err != nil {
+		return
+	}
+
+	for _, dsk := range dsks {
+		if dsk.Type != disk.Rbd || dsk.State != disk.Available {
+			errData = &errortypes.ErrorData{
+				Error:   "relocate_unsupported",
+				Message: "Instance disks must be available network disks",
+			}
+			return
+		}
+
+		if !nde.HasPool(dsk.Pool) {
+			errData = &errortypes.ErrorData{
+				Error:   "node_pool_unavailable",
+				Message: "Relocation node does not have disk pool",
+			}
+			return
+		}
+	}
+
+	coll := db.Instances()
+
+	resp, err := coll.UpdateOne(db, &bson.M{
+		"_id":   i.Id,
+		"node":  i.Node,
+		"state": Stop,
+	}, &bson.M{
+		"$set": &bson.M{
+			"node": nde.Id,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if resp.MatchedCount == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_not_stopped",
+			Message: "Instance must be stopped to relocate",
+		}
+		return
+	}
+
+	i.Node = nde.Id
+
+	err = i.commitNode(db, nde.Id)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Move the disks and deployment of an instance to the node after the
+// instance node has been updated.
+func (i *Instance) commitNode(db *database.Database,
+	ndeId primitive.ObjectID) (err error) {
+
+	err = disk.SetInstanceNode(db, i.Id, ndeId)
+	if err != nil {
+		return
+	}
+
+	if !i.Deployment.IsZero() {
+		coll := db.Deployments()
+
+		_, err = coll.UpdateOne(db, &bson.M{
+			"_id": i.Deployment,
+		}, &bson.M{
+			"$set": &bson.M{
+				"node": ndeId,
+			},
+		})
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+	}
+
+	return
+}
+
+// Update migration only if the current state matches, the source and
+// destination nodes advance the migration from their own deploy loops.
+func UpdateMigration(db *database.Database, instId primitive.ObjectID,
+	curState string, doc bson.M) (updated bool, err error) {
+
+	coll := db.Instances()
+
+	doc["migration.timestamp"] = time.Now()
+
+	resp, err := coll.UpdateOne(db, &bson.M{
+		"_id":             instId,
+		"migration.state": curState,
+	}, &bson.M{
+		"$set": doc,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	updated = resp.MatchedCount > 0
+
+	return
+}
+
+func FailMigration(db *database.Database, instId primitive.ObjectID,
+	curState, errMsg string) (err error) {
+
+	_, err = UpdateMigration(db, instId, curState, bson.M{
+		"migration.state": MigrationFailed,
+		"migration.error": errMsg,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func CompleteMigration(db *database.Database, inst *Instance) (
+	updated bool, err error) {
+
+	updated, err = UpdateMigration(db, inst.Id, MigrationTransfer, bson.M{
+		"node":            inst.Migration.Node,
+		"migration.state": MigrationComplete,
+	})
+	if err != nil || !updated {
+		return
+	}
+
+	err = inst.commitNode(db, inst.Migration.Node)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func ClearMigration(db *database.Database, instId primitive.ObjectID) (
+	err error) {
+
+	coll := db.Instances()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id": instId,
+	}, &bson.M{
+		"$unset": &bson.M{
+			"migration": "",
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,162 @@
+package instance
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/image"
+)
+
+// Snapshot all instance disks as a single set to the same point in time.
+func (i *Instance) StartSnapshot(db *database.Database) (
+	setId primitive.ObjectID, errData *errortypes.ErrorData, err error) {
+
+	if i.Migration != nil && i.Migration.IsActive() {
+		errData = &errortypes.ErrorData{
+			Error:   "migration_active",
+			Message: "Cannot snapshot instance during migration",
+		}
+		return
+	}
+
+	dsks, err := disk.GetInstance(db, i.Id)
+	if err != nil {
+		return
+	}
+
+	if len(dsks) == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "snapshot_no_disks",
+			Message: "Instance does not have any disks",
+		}
+		return
+	}
+
+	dskIds := []primitive.ObjectID{}
+	for _, dsk := range dsks {
+		if dsk.Type != disk.Qcow2 {
+			errData = &errortypes.ErrorData{
+				Error:   "snapshot_unsupported",
+				Message: "Instance disks must be qcow2 disks",
+			}
+			return
+		}
+
+		if dsk.State != disk.Available {
+			errData = &errortypes.ErrorData{
+				Error:   "disk_busy",
+				Message: "Instance disks must be available",
+			}
+			return
+		}
+
+		dskIds = append(dskIds, dsk.Id)
+	}
+
+	setId = primitive.NewObjectID()
+
+	count, err := disk.SetSnapshotSet(db, i.Id, dskIds, setId)
+	if err != nil {
+		return
+	}
+
+	if count != len(dskIds) {
+		err = disk.ClearSnapshotSet(db, setId)
+		if err != nil {
+			return
+		}
+
+		setId = primitive.NilObjectID
+		errData = &errortypes.ErrorData{
+			Error:   "disk_busy",
+			Message: "Instance disks must be available",
+		}
+		return
+	}
+
+	return
+}
+
+// Restore all instance disks from a snapshot set.
+func (i *Instance) RestoreSnapshot(db *database.Database,
+	setId primitive.ObjectID) (errData *errortypes.ErrorData, err error) {
+
+	imgs, err := image.GetSnapshotSet(db, i.Organization, setId)
+	if err != nil {
+		return
+	}
+
+	if len(imgs) == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "snapshot_set_invalid",
+			Message: "Snapshot set not found",
+		}
+		return
+	}
+
+	dsks, err := disk.GetInstance(db, i.Id)
+	if err != nil {
+		return
+	}
+
+	dsksMap := map[primitive.ObjectID]*disk.Disk{}
+	for _, dsk := range dsks {
+		dsksMap[dsk.Id] = dsk
+	}
+
+	imgDsks := set.NewSet()
+	for _, img := range imgs {
+		dsk := dsksMap[img.Disk]
+		if dsk == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "snapshot_set_invalid",
+				Message: "Snapshot set disk not attached to instance",
+			}
+			return
+		}
+		imgDsks.Add(img.Disk)
+
+		if dsk.State != disk.Available {
+			errData = &errortypes.ErrorData{
+				Error:   "disk_busy",
+				Message: "Instance disks must be available",
+			}
+			return
+		}
+	}
+
+	if imgDsks.Len() != len(imgs) || imgDsks.Len() != len(dsks) {
+		errData = &errortypes.ErrorData{
+			Error:   "snapshot_set_invalid",
+			Message: "Snapshot set does not include all instance disks",
+		}
+		return
+	}
+
+	restored := []*image.Image{}
+	for _, img := range imgs {
+		updated, e := disk.SetRestore(db, img.Disk, img.Id)
+		if e != nil || !updated {
+			for _, restoredImg := range restored {
+				_ = disk.ClearRestore(db, restoredImg.Disk, restoredImg.Id)
+			}
+
+			if e != nil {
+				err = e
+				return
+			}
+
+			errData = &errortypes.ErrorData{
+				Error:   "disk_busy",
+				Message: "Instance disks must be available",
+			}
+			return
+		}
+
+		restored = append(restored, img)
+	}
+
+	return
+}
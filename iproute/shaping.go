@@ -0,0 +1,100 @@
+package iproute
+
+import (
+	"fmt"
+
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func shapingBurst(rate int) string {
+	return fmt.Sprintf("%d", utils.Max(rate*1250, 32768))
+}
+
+func ShapingClear(namespace, name string) (err error) {
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"handle of zero",
+			"No such file",
+			"Cannot find",
+		},
+		"ip", "netns", "exec", namespace,
+		"tc", "qdisc", "del", "dev", name, "root",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"Invalid handle",
+			"No such file",
+			"Cannot find",
+		},
+		"ip", "netns", "exec", namespace,
+		"tc", "qdisc", "del", "dev", name, "ingress",
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Limit interface bandwidth in megabits per second, transmit limits traffic
+// sent from the interface and receive limits traffic arriving on the
+// interface. A limit of zero is unlimited.
+func ShapingSet(namespace, name string, transmit, receive int) (
+	err error) {
+
+	err = ShapingClear(namespace, name)
+	if err != nil {
+		return
+	}
+
+	if transmit > 0 {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"tc", "qdisc", "replace", "dev", name, "root",
+			"tbf",
+			"rate", fmt.Sprintf("%dmbit", transmit),
+			"burst", shapingBurst(transmit),
+			"latency", "50ms",
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	if receive > 0 {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"tc", "qdisc", "replace", "dev", name,
+			"handle", "ffff:", "ingress",
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"tc", "filter", "add", "dev", name,
+			"parent", "ffff:",
+			"protocol", "all",
+			"prio", "1",
+			"u32", "match", "u32", "0", "0",
+			"police",
+			"rate", fmt.Sprintf("%dmbit", receive),
+			"burst", shapingBurst(receive),
+			"drop",
+			"flowid", ":1",
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,184 @@
+package iptables
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const migrateComment = "pritunl_cloud_migrate"
+
+var (
+	migrateLock  = sync.Mutex{}
+	migrateState string
+	migrateInit  = false
+)
+
+// Migration rules accept the migration and nbd ports from the source node
+// addresses of an incoming migration.
+type Migration struct {
+	Instance  primitive.ObjectID
+	Addresses []string
+}
+
+func migrateStateKey(migrations []*Migration) string {
+	keys := []string{
+		fmt.Sprintf("%d,%d", settings.Hypervisor.MigrationPort,
+			settings.Hypervisor.MigrationNbdPort),
+	}
+
+	for _, migration := range migrations {
+		addrs := append([]string{}, migration.Addresses...)
+		sort.Strings(addrs)
+		keys = append(keys, migration.Instance.Hex()+"="+
+			strings.Join(addrs, ","))
+	}
+	sort.Strings(keys)
+
+	return strings.Join(keys, ";")
+}
+
+func clearMigrate(ipv6 bool) (err error) {
+	iptablesCmd := getIptablesCmd(ipv6)
+
+	Lock()
+	output, err := utils.ExecOutput("", iptablesCmd, "-S", "INPUT")
+	Unlock()
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, migrateComment) {
+			continue
+		}
+
+		cmd := strings.Fields(line)
+		if len(cmd) < 3 || cmd[0] != "-A" {
+			continue
+		}
+		cmd[0] = "-D"
+
+		Lock()
+		output, e := utils.ExecCombinedOutput("", iptablesCmd, cmd...)
+		Unlock()
+		if e != nil {
+			err = e
+			logrus.WithFields(logrus.Fields{
+				"ipv6":    ipv6,
+				"command": cmd,
+				"output":  output,
+				"error":   err,
+			}).Error("iptables: Failed to remove migration rule")
+			return
+		}
+	}
+
+	return
+}
+
+func applyMigrate(migrations []*Migration, ipv6 bool) (err error) {
+	iptablesCmd := getIptablesCmd(ipv6)
+	ports := fmt.Sprintf("%d,%d", settings.Hypervisor.MigrationPort,
+		settings.Hypervisor.MigrationNbdPort)
+
+	cmds := [][]string{}
+	for _, migration := range migrations {
+		for _, addr := range migration.Addresses {
+			if strings.Contains(addr, ":") != ipv6 {
+				continue
+			}
+
+			cmds = append(cmds, []string{
+				"-I", "INPUT", fmt.Sprintf("%d", len(cmds)+1),
+				"-s", addr,
+				"-p", "tcp",
+				"-m", "multiport",
+				"--dports", ports,
+				"-m", "comment",
+				"--comment", migrateComment,
+				"-j", "ACCEPT",
+			})
+		}
+	}
+
+	cmds = append(cmds, []string{
+		"-I", "INPUT", fmt.Sprintf("%d", len(cmds)+1),
+		"-p", "tcp",
+		"-m", "multiport",
+		"--dports", ports,
+		"-m", "comment",
+		"--comment", migrateComment,
+		"-j", "DROP",
+	})
+
+	for _, cmd := range cmds {
+		Lock()
+		output, e := utils.ExecCombinedOutput("", iptablesCmd, cmd...)
+		Unlock()
+		if e != nil {
+			err = e
+			logrus.WithFields(logrus.Fields{
+				"ipv6":    ipv6,
+				"command": cmd,
+				"output":  output,
+				"error":   err,
+			}).Error("iptables: Failed to add migration rule")
+			return
+		}
+	}
+
+	return
+}
+
+// UpdateMigrations sets the host rules for incoming migrations. The rules
+// are inserted before the node firewall and only exist while a migration
+// to this node is active.
+func UpdateMigrations(migrations []*Migration) (err error) {
+	migrateLock.Lock()
+	defer migrateLock.Unlock()
+
+	stateKey := ""
+	if len(migrations) > 0 {
+		stateKey = migrateStateKey(migrations)
+	}
+
+	if migrateInit && stateKey == migrateState {
+		return
+	}
+
+	migrateInit = false
+
+	err = clearMigrate(false)
+	if err != nil {
+		return
+	}
+
+	err = clearMigrate(true)
+	if err != nil {
+		return
+	}
+
+	if len(migrations) > 0 {
+		err = applyMigrate(migrations, false)
+		if err != nil {
+			return
+		}
+
+		err = applyMigrate(migrations, true)
+		if err != nil {
+			return
+		}
+	}
+
+	migrateState = stateKey
+	migrateInit = true
+
+	return
+}
@@ -0,0 +1,132 @@
+package lvm
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/pool"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type lvsReport struct {
+	Report []*lvReport `json:"report"`
+}
+
+type lvReport struct {
+	Lv []*lvDetails `json:"lv"`
+}
+
+type lvDetails struct {
+	LvName      string `json:"lv_name"`
+	VgName      string `json:"vg_name"`
+	LvSize      string `json:"lv_size"`
+	PoolLv      string `json:"pool_lv"`
+	DataPercent string `json:"data_percent"`
+}
+
+func parseBytes(val string) int64 {
+	val = strings.TrimLeft(strings.TrimSpace(val), "<>")
+	n, _ := strconv.ParseInt(val, 10, 64)
+	return n
+}
+
+func getLvs() (lvs []*lvDetails, err error) {
+	output, err := utils.ExecCombinedOutput("",
+		"lvs", "--reportformat", "json", "--units", "b", "--nosuffix",
+		"-o", "lv_name,vg_name,lv_size,pool_lv,data_percent")
+	if err != nil {
+		return
+	}
+
+	reprt := &lvsReport{}
+	err = json.Unmarshal([]byte(output), reprt)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "lvm: Failed to unmarshal lvs report"),
+		}
+		return
+	}
+
+	lvs = []*lvDetails{}
+	for _, reportGroup := range reprt.Report {
+		if reportGroup.Lv != nil {
+			lvs = append(lvs, reportGroup.Lv...)
+		}
+	}
+
+	return
+}
+
+// Update pool size, usage and allocated volume size in the database from the
+// volume groups and thin pools available on this node.
+func updateCapacity(db *database.Database, pools []*pool.Pool,
+	vgs map[string]*vgDetails) (err error) {
+
+	var lvs []*lvDetails
+	for _, pl := range pools {
+		if pl.IsThin() {
+			lvs, err = getLvs()
+			if err != nil {
+				return
+			}
+			break
+		}
+	}
+
+	for _, pl := range pools {
+		vg := vgs[pl.VgName]
+		if vg == nil {
+			continue
+		}
+
+		if pl.IsThin() {
+			found := false
+			allocated := int64(0)
+
+			for _, lv := range lvs {
+				if lv.VgName != pl.VgName {
+					continue
+				}
+
+				if lv.LvName == pl.ThinPool {
+					found = true
+					pl.Size = parseBytes(lv.LvSize)
+
+					percent, _ := strconv.ParseFloat(
+						strings.TrimSpace(lv.DataPercent), 64)
+					pl.Used = int64(float64(pl.Size) * percent / 100)
+				} else if lv.PoolLv == pl.ThinPool {
+					allocated += parseBytes(lv.LvSize)
+				}
+			}
+
+			if !found {
+				continue
+			}
+
+			pl.Free = pl.Size - pl.Used
+			pl.Allocated = allocated
+		} else {
+			pl.Size = parseBytes(vg.VgSize)
+			pl.Free = parseBytes(vg.VgFree)
+			pl.Used = pl.Size - pl.Free
+			pl.Allocated = pl.Used
+		}
+
+		pl.CapacityUpdated = time.Now()
+
+		err = pl.CommitFields(db, set.NewSet(
+			"size", "used", "free", "allocated", "capacity_updated"))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
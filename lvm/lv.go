@@ -9,6 +9,7 @@ import (
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/pool"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
@@ -23,6 +24,50 @@ func CreateLv(vgName, lvName string, size int) (err error) {
 	return
 }
 
+func CreateThinLv(vgName, thinPool, lvName string, size int) (err error) {
+	_, err = utils.ExecCombinedOutputLogged(nil,
+		"lvcreate", "-an", "-V", fmt.Sprintf("%d.1G", size),
+		"-T", fmt.Sprintf("%s/%s", vgName, thinPool),
+		"-n", lvName)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func CreatePoolLv(pl *pool.Pool, lvName string, size int) (err error) {
+	if pl.IsThin() {
+		err = CreateThinLv(pl.VgName, pl.ThinPool, lvName, size)
+	} else {
+		err = CreateLv(pl.VgName, lvName, size)
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Create thin snapshot of a thin volume, the snapshot shares blocks with the
+// origin and does not allocate space until either volume is written.
+func SnapshotThinLv(vgName, lvName, snapName string) (err error) {
+	_, err = utils.ExecCombinedOutputLogged(nil,
+		"lvcreate", "-s", "-n", snapName,
+		fmt.Sprintf("%s/%s", vgName, lvName))
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(nil,
+		"lvchange", "-ay", "-K", fmt.Sprintf("%s/%s", vgName, snapName))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func RemoveLv(vgName, lvName string) (err error) {
 	_, err = utils.ExecCombinedOutputLogged([]string{
 		"Failed to find",
@@ -58,6 +103,20 @@ func WriteLv(vgName, lvName, sourcePth string) (err error) {
 	return
 }
 
+func ReadLv(vgName, lvName, destPth string) (err error) {
+	srcPth := filepath.Join("/dev/mapper",
+		fmt.Sprintf("%s-%s", vgName, lvName))
+
+	_, err = utils.ExecCombinedOutputLogged(nil,
+		"qemu-img", "convert", "-f", "raw",
+		"-O", "qcow2", srcPth, destPth)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func ExtendLv(vgName, lvName string, addSize int) (err error) {
 	_, err = utils.ExecCombinedOutputLogged(nil,
 		"lvextend", "-L", fmt.Sprintf("+%dG", addSize),
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"time"
 
-	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
@@ -12,6 +11,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/pool"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
 )
 
 var (
@@ -46,10 +46,10 @@ func GetAvailablePools(db *database.Database, zoneId primitive.ObjectID) (
 	}
 
 	availablePools = []*pool.Pool{}
-	vgNames := set.NewSet()
+	vgs := map[string]*vgDetails{}
 
 	output, err := utils.ExecCombinedOutput("",
-		"vgs", "--reportformat", "json")
+		"vgs", "--reportformat", "json", "--units", "b", "--nosuffix")
 	if err != nil {
 		return
 	}
@@ -67,13 +67,13 @@ func GetAvailablePools(db *database.Database, zoneId primitive.ObjectID) (
 		for _, reportGroup := range reprt.Report {
 			if reportGroup.Vg != nil {
 				for _, reportVg := range reportGroup.Vg {
-					vgNames.Add(reportVg.VgName)
+					vgs[reportVg.VgName] = reportVg
 				}
 			}
 		}
 	}
 
-	if vgNames.Len() > 0 {
+	if len(vgs) > 0 {
 		pools, e := pool.GetAll(db, &bson.M{
 			"zone": zoneId,
 		})
@@ -83,10 +83,17 @@ func GetAvailablePools(db *database.Database, zoneId primitive.ObjectID) (
 		}
 
 		for _, pl := range pools {
-			if vgNames.Contains(pl.VgName) {
+			if !pl.IsRbd() && vgs[pl.VgName] != nil {
 				availablePools = append(availablePools, pl)
 			}
 		}
+
+		e = updateCapacity(db, availablePools, vgs)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": e,
+			}).Warn("lvm: Failed to update pool capacity")
+		}
 	}
 
 	cachedNodePools = availablePools
@@ -24,5 +24,10 @@ const (
 	Internal = "internal"
 	Oracle   = "oracle"
 
-	Restart = "restart"
+	Restart     = "restart"
+	Maintenance = "maintenance"
+	Resume      = "resume"
+
+	Draining = "draining"
+	Drained  = "drained"
 )
@@ -29,6 +29,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/iso"
 	"github.com/pritunl/pritunl-cloud/lvm"
 	"github.com/pritunl/pritunl-cloud/pci"
+	"github.com/pritunl/pritunl-cloud/rbd"
 	"github.com/pritunl/pritunl-cloud/render"
 	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/usb"
@@ -124,6 +125,8 @@ type Node struct {
 	OraclePrivateKey        string               `bson:"oracle_private_key" json:"-"`
 	OraclePublicKey         string               `bson:"oracle_public_key" json:"oracle_public_key"`
 	Operation               string               `bson:"operation" json:"operation"`
+	Maintenance             bool                 `bson:"maintenance" json:"maintenance"`
+	Drain                   *Drain               `bson:"drain,omitempty" json:"drain"`
 	oracleSubnetsNamed      []*OracleSubnet      `bson:"-" json:"-"`
 	reqLock                 sync.Mutex           `bson:"-" json:"-"`
 	reqCount                *list.List           `bson:"-" json:"-"`
@@ -136,6 +139,17 @@ type OracleSubnet struct {
 	Name string `json:"name"`
 }
 
+type Drain struct {
+	State        string    `bson:"state" json:"state"`
+	Total        int       `bson:"total" json:"total"`
+	Migrating    int       `bson:"migrating" json:"migrating"`
+	Rescheduling int       `bson:"rescheduling" json:"rescheduling"`
+	Remaining    int       `bson:"remaining" json:"remaining"`
+	Message      string    `bson:"message" json:"message"`
+	Started      time.Time `bson:"started" json:"started"`
+	Timestamp    time.Time `bson:"timestamp" json:"timestamp"`
+}
+
 func (n *Node) Copy() *Node {
 	nde := &Node{
 		Id:                      n.Id,
@@ -217,6 +231,8 @@ func (n *Node) Copy() *Node {
 		OraclePrivateKey:        n.OraclePrivateKey,
 		OraclePublicKey:         n.OraclePublicKey,
 		Operation:               n.Operation,
+		Maintenance:             n.Maintenance,
+		Drain:                   n.Drain,
 		dcId:                    n.dcId,
 		dcZoneId:                n.dcZoneId,
 	}
@@ -245,6 +261,31 @@ func (n *Node) GetCachePath() string {
 	return n.CachePath
 }
 
+// GetPrivateAddress returns the first private address of the node sorted by
+// interface name with a fallback to the first public address.
+func (n *Node) GetPrivateAddress() (addr string) {
+	if n.PrivateIps != nil {
+		ifaces := []string{}
+		for iface := range n.PrivateIps {
+			ifaces = append(ifaces, iface)
+		}
+		sort.Strings(ifaces)
+
+		for _, iface := range ifaces {
+			addr = n.PrivateIps[iface]
+			if addr != "" {
+				return
+			}
+		}
+	}
+
+	if len(n.PublicIps) > 0 {
+		addr = n.PublicIps[0]
+	}
+
+	return
+}
+
 func (n *Node) GetTempPath() string {
 	if n.TempPath == "" {
 		return constants.DefaultTemp
@@ -346,6 +387,15 @@ func (n *Node) IsHypervisor() bool {
 	return false
 }
 
+func (n *Node) HasPool(poolId primitive.ObjectID) bool {
+	for _, plId := range n.Pools {
+		if plId == poolId {
+			return true
+		}
+	}
+	return false
+}
+
 func (n *Node) IsOnline() bool {
 	if time.Since(n.Timestamp) > time.Duration(
 		settings.System.NodeTimestampTtl)*time.Second {
@@ -1082,6 +1132,8 @@ func (n *Node) update(db *database.Database) (err error) {
 	n.OraclePrivateKey = nde.OraclePrivateKey
 	n.OraclePublicKey = nde.OraclePublicKey
 	n.Operation = nde.Operation
+	n.Maintenance = nde.Maintenance
+	n.Drain = nde.Drain
 
 	return
 }
@@ -1228,10 +1280,18 @@ func (n *Node) sync() {
 		return
 	}
 
+	rbdPools, err := rbd.GetAvailablePools(db, n.Zone)
+	if err != nil {
+		return
+	}
+
 	poolIds := []primitive.ObjectID{}
 	for _, pl := range pools {
 		poolIds = append(poolIds, pl.Id)
 	}
+	for _, pl := range rbdPools {
+		poolIds = append(poolIds, pl.Id)
+	}
 	n.Pools = poolIds
 
 	drives, err := drive.GetDevices()
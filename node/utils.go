@@ -364,6 +364,27 @@ func GetAllNet(db *database.Database) (nodes []*Node, err error) {
 	return
 }
 
+func SetDrain(db *database.Database, nodeId primitive.ObjectID,
+	drain *Drain) (err error) {
+
+	coll := db.Nodes()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id":         nodeId,
+		"maintenance": true,
+	}, &bson.M{
+		"$set": &bson.M{
+			"drain": drain,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func Remove(db *database.Database, nodeId primitive.ObjectID) (err error) {
 	coll := db.Nodes()
 
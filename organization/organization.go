@@ -6,14 +6,17 @@ import (
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/secret"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
 type Organization struct {
-	Id      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Roles   []string           `bson:"roles" json:"roles"`
-	Name    string             `bson:"name" json:"name"`
-	Comment string             `bson:"comment" json:"comment"`
+	Id            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Roles         []string           `bson:"roles" json:"roles"`
+	Name          string             `bson:"name" json:"name"`
+	Comment       string             `bson:"comment" json:"comment"`
+	Encryption    primitive.ObjectID `bson:"encryption,omitempty" json:"encryption"`
+	RequireSigned bool               `bson:"require_signed" json:"require_signed"`
 }
 
 func (d *Organization) Validate(db *database.Database) (
@@ -25,6 +28,57 @@ func (d *Organization) Validate(db *database.Database) (
 		d.Roles = []string{}
 	}
 
+	if !d.Encryption.IsZero() {
+		if d.Id.IsZero() {
+			errData = &errortypes.ErrorData{
+				Error:   "encryption_secret_invalid",
+				Message: "Encryption requires an existing organization",
+			}
+			return
+		}
+
+		secr, e := secret.GetOrg(db, d.Id, d.Encryption)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); !ok {
+				err = e
+				return
+			}
+			secr = nil
+		}
+
+		if secr == nil || secr.Type != secret.Encryption {
+			errData = &errortypes.ErrorData{
+				Error:   "encryption_secret_invalid",
+				Message: "Encryption secret must be an encryption secret",
+			}
+			return
+		}
+	}
+
+	if d.RequireSigned {
+		if d.Id.IsZero() {
+			errData = &errortypes.ErrorData{
+				Error:   "signing_key_required",
+				Message: "Organization has no signing key secrets",
+			}
+			return
+		}
+
+		secrs, e := secret.GetAllSigningOrg(db, d.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if len(secrs) == 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "signing_key_required",
+				Message: "Organization has no signing key secrets",
+			}
+			return
+		}
+	}
+
 	return
 }
 
@@ -37,6 +37,10 @@ func GetVmPath(instId primitive.ObjectID) string {
 		"instances", instId.Hex())
 }
 
+func GetMigrateTlsPath(instId primitive.ObjectID) string {
+	return path.Join(GetVmPath(instId), "migrate_tls")
+}
+
 func GetDisksPath() string {
 	return path.Join(node.Self.GetVirtPath(), "disks")
 }
@@ -0,0 +1,182 @@
+package peer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+const idleTimeout = 1 * time.Minute
+
+// idleReader cancels the request when no data is received from the peer
+// within the idle timeout.
+type idleReader struct {
+	reader io.Reader
+	timer  *time.Timer
+}
+
+func (r *idleReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	if n > 0 {
+		r.timer.Reset(idleTimeout)
+	}
+	return
+}
+
+func getClient(nde *node.Node) (client *http.Client, err error) {
+	block, _ := pem.Decode([]byte(nde.SelfCertificate))
+	if block == nil || block.Type != "CERTIFICATE" {
+		err = &errortypes.ParseError{
+			errors.New("peer: Peer node has no certificate"),
+		}
+		return
+	}
+	peerCert := block.Bytes
+
+	client = &http.Client{
+		Transport: &http.Transport{
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+			TLSClientConfig: &tls.Config{
+				MinVersion:         tls.VersionTLS12,
+				InsecureSkipVerify: true,
+				VerifyPeerCertificate: func(rawCerts [][]byte,
+					_ [][]*x509.Certificate) error {
+
+					if len(rawCerts) == 0 ||
+						!bytes.Equal(rawCerts[0], peerCert) {
+
+						return &errortypes.AuthenticationError{
+							errors.New("peer: Peer certificate mismatch"),
+						}
+					}
+					return nil
+				},
+			},
+		},
+	}
+
+	return
+}
+
+// GetImage downloads the cached copy of an image from a peer node to the
+// path. The content is verified against the cache hash and size.
+func GetImage(nde *node.Node, img *image.Image, cache *image.Cache,
+	pth string) (err error) {
+
+	addr := nde.GetPrivateAddress()
+	if addr == "" {
+		err = &errortypes.ConnectionError{
+			errors.New("peer: Peer node has no address"),
+		}
+		return
+	}
+
+	client, err := getClient(nde)
+	if err != nil {
+		return
+	}
+
+	reqUrl := fmt.Sprintf(
+		"https://%s/image/%s/%s",
+		utils.FormatHostPort(addr, settings.Hypervisor.ImagePeerPort),
+		img.Id.Hex(),
+		img.Etag,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	timer := time.AfterFunc(idleTimeout, cancel)
+	defer timer.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", reqUrl, nil)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "peer: Failed to create request"),
+		}
+		return
+	}
+
+	err = sign(req)
+	if err != nil {
+		return
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = &errortypes.ConnectionError{
+			errors.Wrap(err, "peer: Failed to request image"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err = &errortypes.RequestError{
+			errors.Newf("peer: Bad status %d from peer", resp.StatusCode),
+		}
+		return
+	}
+
+	file, err := os.OpenFile(pth, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "peer: Failed to create image file"),
+		}
+		return
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), &idleReader{
+		reader: resp.Body,
+		timer:  timer,
+	})
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "peer: Failed to download image"),
+		}
+		return
+	}
+
+	if cache.Size != 0 && size != cache.Size {
+		err = &errortypes.VerificationError{
+			errors.Newf("peer: Image size mismatch %d != %d",
+				size, cache.Size),
+		}
+		return
+	}
+
+	if fmt.Sprintf("%x", hash.Sum(nil)) != cache.Hash {
+		err = &errortypes.VerificationError{
+			errors.New("peer: Image hash mismatch"),
+		}
+		return
+	}
+
+	err = file.Sync()
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "peer: Failed to sync image file"),
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,162 @@
+// Image transfer between nodes in the same zone.
+package peer
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/nonce"
+	"github.com/pritunl/pritunl-cloud/requires"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+const (
+	authWindow = 30 * time.Second
+)
+
+func getSignature(ndeId, timestamp, nce, method, pth string) string {
+	authString := strings.Join([]string{
+		ndeId,
+		timestamp,
+		nce,
+		method,
+		pth,
+	}, "&")
+
+	hashFunc := hmac.New(sha512.New, settings.System.ImagePeerKey)
+	hashFunc.Write([]byte(authString))
+	rawSignature := hashFunc.Sum(nil)
+
+	return base64.StdEncoding.EncodeToString(rawSignature)
+}
+
+func sign(req *http.Request) (err error) {
+	if len(settings.System.ImagePeerKey) == 0 {
+		err = &errortypes.AuthenticationError{
+			errors.New("peer: Missing image peer key"),
+		}
+		return
+	}
+
+	nce, err := utils.RandStr(32)
+	if err != nil {
+		return
+	}
+
+	ndeId := node.Self.Id.Hex()
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set("Pritunl-Node", ndeId)
+	req.Header.Set("Pritunl-Timestamp", timestamp)
+	req.Header.Set("Pritunl-Nonce", nce)
+	req.Header.Set("Pritunl-Signature", getSignature(
+		ndeId, timestamp, nce, req.Method, req.URL.Path))
+
+	return
+}
+
+func validate(db *database.Database, req *http.Request) (
+	ndeId primitive.ObjectID, err error) {
+
+	if len(settings.System.ImagePeerKey) == 0 {
+		err = &errortypes.AuthenticationError{
+			errors.New("peer: Missing image peer key"),
+		}
+		return
+	}
+
+	ndeIdStr := req.Header.Get("Pritunl-Node")
+	timestampStr := req.Header.Get("Pritunl-Timestamp")
+	nce := req.Header.Get("Pritunl-Nonce")
+	sig := req.Header.Get("Pritunl-Signature")
+
+	ndeId, ok := utils.ParseObjectId(ndeIdStr)
+	if !ok {
+		err = &errortypes.AuthenticationError{
+			errors.New("peer: Invalid authentication node"),
+		}
+		return
+	}
+
+	if len(nce) < 16 || len(nce) > 128 {
+		err = &errortypes.AuthenticationError{
+			errors.New("peer: Invalid authentication nonce"),
+		}
+		return
+	}
+
+	timestampInt, e := strconv.ParseInt(timestampStr, 10, 64)
+	if e != nil {
+		err = &errortypes.AuthenticationError{
+			errors.Wrap(e, "peer: Invalid authentication timestamp"),
+		}
+		return
+	}
+
+	if utils.SinceAbs(time.Unix(timestampInt, 0)) > authWindow {
+		err = &errortypes.AuthenticationError{
+			errors.New("peer: Authentication timestamp outside window"),
+		}
+		return
+	}
+
+	testSig := getSignature(ndeIdStr, timestampStr, nce,
+		req.Method, req.URL.Path)
+
+	if subtle.ConstantTimeCompare([]byte(sig), []byte(testSig)) != 1 {
+		err = &errortypes.AuthenticationError{
+			errors.New("peer: Invalid signature"),
+		}
+		return
+	}
+
+	err = nonce.Validate(db, nce)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func init() {
+	module := requires.New("peer")
+	module.After("settings")
+
+	module.Handler = func() (err error) {
+		if len(settings.System.ImagePeerKey) != 0 {
+			return
+		}
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		key, err := utils.RandBytes(64)
+		if err != nil {
+			return
+		}
+
+		settings.System.ImagePeerKey = key
+
+		err = settings.Commit(db, settings.System, set.NewSet(
+			"image_peer_key",
+		))
+		if err != nil {
+			return
+		}
+
+		return
+	}
+}
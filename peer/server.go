@@ -0,0 +1,227 @@
+package peer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	etagReg = regexp.MustCompile("^[a-zA-Z0-9]+$")
+)
+
+type Server struct {
+	lock   sync.Mutex
+	server *http.Server
+	port   int
+	stop   bool
+}
+
+func (s *Server) imageGet(c *gin.Context) {
+	db := database.GetDatabase()
+	defer db.Close()
+
+	ndeId, err := validate(db, c.Request)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"remote": c.ClientIP(),
+			"error":  err,
+		}).Error("peer: Image request authentication failed")
+		utils.AbortWithStatus(c, 401)
+		return
+	}
+
+	imgId, ok := utils.ParseObjectId(c.Param("image_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	etag := c.Param("etag")
+	if !etagReg.MatchString(etag) {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	pth := path.Join(
+		node.Self.GetCachePath(),
+		fmt.Sprintf("image-%s-%s", imgId.Hex(), etag),
+	)
+
+	file, err := os.Open(pth)
+	if err != nil {
+		if os.IsNotExist(err) {
+			utils.AbortWithStatus(c, 404)
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "peer: Failed to open cached image"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "peer: Failed to stat cached image"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"image_id": imgId.Hex(),
+		"etag":     etag,
+		"node_id":  ndeId.Hex(),
+		"size":     stat.Size(),
+	}).Info("peer: Serving cached image to peer")
+
+	http.ServeContent(c.Writer, c.Request, "", stat.ModTime(), file)
+}
+
+func (s *Server) active() bool {
+	return node.Self.IsHypervisor() && !settings.Hypervisor.NoImagePeer
+}
+
+func (s *Server) init() (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.port = settings.Hypervisor.ImagePeerPort
+
+	certPem, keyPem, err := node.SelfCert()
+	if err != nil {
+		return
+	}
+
+	cert, err := tls.X509KeyPair(certPem, keyPem)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "peer: Failed to load certificate"),
+		}
+		return
+	}
+
+	router := gin.New()
+	router.GET("/image/:image_id/:etag", s.imageGet)
+
+	s.server = &http.Server{
+		Addr:              fmt.Sprintf(":%d", s.port),
+		Handler:           router,
+		ReadTimeout:       1 * time.Minute,
+		ReadHeaderTimeout: 30 * time.Second,
+		IdleTimeout:       1 * time.Minute,
+		MaxHeaderBytes:    4096,
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		},
+	}
+
+	return
+}
+
+func (s *Server) watch() {
+	for {
+		time.Sleep(1 * time.Second)
+
+		if s.stop {
+			return
+		}
+
+		s.lock.Lock()
+		restart := s.server != nil && (!s.active() ||
+			s.port != settings.Hypervisor.ImagePeerPort)
+		s.lock.Unlock()
+
+		if restart {
+			s.Restart()
+		}
+	}
+}
+
+func (s *Server) Run() (err error) {
+	go s.watch()
+
+	for {
+		if s.stop {
+			break
+		}
+
+		if !s.active() {
+			time.Sleep(1 * time.Second)
+			continue
+		}
+
+		e := s.init()
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": e,
+			}).Error("peer: Failed to init image peer server")
+			time.Sleep(3 * time.Second)
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"port": s.port,
+		}).Info("peer: Starting image peer server")
+
+		e = s.server.ListenAndServeTLS("", "")
+		if e != nil && e != http.ErrServerClosed {
+			e = &errortypes.UnknownError{
+				errors.Wrap(e, "peer: Server listen failed"),
+			}
+			logrus.WithFields(logrus.Fields{
+				"error": e,
+			}).Error("peer: Image peer server error")
+			time.Sleep(3 * time.Second)
+		}
+
+		s.lock.Lock()
+		s.server = nil
+		s.lock.Unlock()
+	}
+
+	return
+}
+
+func (s *Server) Restart() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		1*time.Second,
+	)
+	defer cancel()
+
+	s.server.Shutdown(ctx)
+	s.server.Close()
+}
+
+func (s *Server) Shutdown() {
+	s.stop = true
+	s.Restart()
+}
@@ -85,6 +85,10 @@ func InitVirt(virt *vm.VirtualMachine) (err error) {
 	}
 
 	for _, device := range virt.DriveDevices {
+		if device.Type == vm.Rbd {
+			continue
+		}
+
 		drivePth := ""
 		if device.Type == vm.Lvm {
 			drivePth = filepath.Join("/dev/mapper",
@@ -152,3 +156,29 @@ func InitImds(virt *vm.VirtualMachine) (err error) {
 
 	return
 }
+
+func InitMigrateTls(virt *vm.VirtualMachine) (err error) {
+	tlsPath := paths.GetMigrateTlsPath(virt.Id)
+
+	err = chown(virt, tlsPath)
+	if err != nil {
+		return
+	}
+
+	entries, err := os.ReadDir(tlsPath)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "permission: Failed to read migrate tls dir"),
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		err = chown(virt, filepath.Join(tlsPath, entry.Name()))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -9,6 +9,9 @@ const (
 	Stop    = "stop"
 	Restart = "restart"
 	Destroy = "destroy"
+
+	ScaleUp   = "scale_up"
+	ScaleDown = "scale_down"
 )
 
 var actions = set.NewSet(
@@ -16,4 +19,6 @@ var actions = set.NewSet(
 	Stop,
 	Restart,
 	Destroy,
+	ScaleUp,
+	ScaleDown,
 )
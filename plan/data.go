@@ -9,9 +9,11 @@ import (
 )
 
 type Data struct {
-	Pod      Pod      `json:"pod"`
-	Unit     Unit     `json:"unit"`
-	Instance Instance `json:"instance"`
+	Pod        Pod        `json:"pod"`
+	Unit       Unit       `json:"unit"`
+	Deployment Deployment `json:"deployment"`
+	Instance   Instance   `json:"instance"`
+	Node       Node       `json:"node"`
 }
 
 type Pod struct {
@@ -19,16 +21,47 @@ type Pod struct {
 }
 
 type Unit struct {
-	Name  string `json:"name"`
-	Count int    `json:"count"`
+	Name        string  `json:"name"`
+	Count       int     `json:"count"`
+	MinCount    int     `json:"min_count"`
+	MaxCount    int     `json:"max_count"`
+	Deployments int     `json:"deployments"`
+	Healthy     int     `json:"healthy"`
+	MemoryUsage float64 `json:"memory_usage"`
+	Load1       float64 `json:"load1"`
+	Load5       float64 `json:"load5"`
+	Load15      float64 `json:"load15"`
+}
+
+type Deployment struct {
+	State  string `json:"state"`
+	Status string `json:"status"`
 }
 
 type Instance struct {
-	Name       string `json:"name"`
-	State      string `json:"state"`
-	VirtState  string `json:"virt_state"`
-	Processors int    `json:"processors"`
-	Memory     int    `json:"memory"`
+	Name           string  `json:"name"`
+	State          string  `json:"state"`
+	VirtState      string  `json:"virt_state"`
+	Health         string  `json:"health"`
+	Processors     int     `json:"processors"`
+	Memory         int     `json:"memory"`
+	GuestStatus    string  `json:"guest_status"`
+	MemoryUsage    float64 `json:"memory_usage"`
+	HugePagesUsage float64 `json:"hugepages_usage"`
+	Load1          float64 `json:"load1"`
+	Load5          float64 `json:"load5"`
+	Load15         float64 `json:"load15"`
+	Uptime         int64   `json:"uptime"`
+	HeartbeatAge   int64   `json:"heartbeat_age"`
+}
+
+type Node struct {
+	Name           string  `json:"name"`
+	MemoryUsage    float64 `json:"memory_usage"`
+	HugePagesUsage float64 `json:"hugepages_usage"`
+	Load1          float64 `json:"load1"`
+	Load5          float64 `json:"load5"`
+	Load15         float64 `json:"load15"`
 }
 
 func (d *Data) Export() (data eval.Data, err error) {
@@ -10,8 +10,10 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/deployment"
 	"github.com/pritunl/pritunl-cloud/eval"
+	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/imds/types"
 	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/plan"
 	"github.com/pritunl/pritunl-cloud/pod"
 	"github.com/pritunl/pritunl-cloud/settings"
@@ -20,7 +22,10 @@ import (
 )
 
 type Planner struct {
-	podsMap map[primitive.ObjectID]*pod.Pod
+	podsMap   map[primitive.ObjectID]*pod.Pod
+	unitStats map[primitive.ObjectID]*unitStats
+	unitData  map[primitive.ObjectID][]eval.Data
+	nodesMap  map[primitive.ObjectID]*node.Node
 }
 
 func (p *Planner) setInstanceState(db *database.Database,
@@ -54,10 +59,130 @@ func (p *Planner) setInstanceState(db *database.Database,
 	return
 }
 
+func (p *Planner) scaleUnit(db *database.Database,
+	deply *deployment.Deployment, unit *pod.Unit, delta int) (err error) {
+
+	if !unit.IsAutoscaled() {
+		logrus.WithFields(logrus.Fields{
+			"deployment": deply.Id.Hex(),
+			"pod":        deply.Pod.Hex(),
+			"unit":       deply.Unit.Hex(),
+		}).Error("scheduler: Cannot scale unit without autoscaling limits")
+		return
+	}
+
+	scaled, err := unit.Scale(db, delta)
+	if err != nil {
+		return
+	}
+
+	if !scaled {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"deployment": deply.Id.Hex(),
+		"pod":        deply.Pod.Hex(),
+		"unit":       deply.Unit.Hex(),
+		"delta":      delta,
+		"count":      unit.Count,
+	}).Info("scheduler: Scaled unit")
+
+	if delta < 0 {
+		err = deployment.RemoveMulti(db, deply.Pod, deply.Unit,
+			[]primitive.ObjectID{deply.Id})
+		if err != nil {
+			return
+		}
+	}
+
+	event.PublishDispatch(db, "pod.change")
+
+	return
+}
+
+func (p *Planner) loadUnitStats(db *database.Database,
+	deployments []*deployment.Deployment) (err error) {
+
+	p.unitStats = map[primitive.ObjectID]*unitStats{}
+
+	instIds := []primitive.ObjectID{}
+	for _, deply := range deployments {
+		if deply.Kind == deployment.Instance &&
+			deply.State == deployment.Deployed &&
+			!deply.Instance.IsZero() {
+
+			instIds = append(instIds, deply.Instance)
+		}
+	}
+
+	insts, err := instance.GetAll(db, &bson.M{
+		"_id": &bson.M{
+			"$in": instIds,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	instsMap := map[primitive.ObjectID]*instance.Instance{}
+	for _, inst := range insts {
+		instsMap[inst.Id] = inst
+	}
+
+	for _, deply := range deployments {
+		if deply.Kind != deployment.Instance ||
+			deply.State != deployment.Deployed {
+
+			continue
+		}
+
+		stats := p.unitStats[deply.Unit]
+		if stats == nil {
+			stats = &unitStats{}
+			p.unitStats[deply.Unit] = stats
+		}
+
+		stats.add(deply, instsMap[deply.Instance])
+	}
+
+	p.unitData = map[primitive.ObjectID][]eval.Data{}
+
+	for _, deply := range deployments {
+		if deply.Kind != deployment.Instance ||
+			deply.State != deployment.Deployed {
+
+			continue
+		}
+
+		inst := instsMap[deply.Instance]
+		pd := p.podsMap[deply.Pod]
+		if inst == nil || pd == nil {
+			continue
+		}
+
+		unit := pd.GetUnit(deply.Unit)
+		if unit == nil {
+			continue
+		}
+
+		data, e := buildEvalData(pd, unit, deply, inst,
+			p.nodesMap[inst.Node], p.unitStats[deply.Unit])
+		if e != nil {
+			err = e
+			return
+		}
+
+		p.unitData[deply.Unit] = append(p.unitData[deply.Unit], data)
+	}
+
+	return
+}
+
 func (p *Planner) checkInstance(db *database.Database,
 	deply *deployment.Deployment) (err error) {
 
-	if deply.State == deployment.Reserved {
+	if deply.State == deployment.Reserved || !deply.Build.IsZero() {
 		return
 	}
 
@@ -157,6 +282,7 @@ func (p *Planner) checkInstance(db *database.Database,
 		heartbeatTtl := time.Duration(
 			settings.System.InstanceTimestampTtl) * time.Second
 		if inst.Guest.Status == types.Running &&
+			inst.Guest.Health != types.Unhealthy &&
 			time.Since(inst.Guest.Heartbeat) <= heartbeatTtl {
 
 			status = deployment.Healthy
@@ -232,7 +358,8 @@ func (p *Planner) checkInstance(db *database.Database,
 		return
 	}
 
-	data, err := buildEvalData(pd, unit, inst)
+	data, err := buildEvalData(pd, unit, deply, inst,
+		p.nodesMap[inst.Node], p.unitStats[unit.Id])
 	if err != nil {
 		return
 	}
@@ -241,7 +368,8 @@ func (p *Planner) checkInstance(db *database.Database,
 	action := ""
 	threshold := 0
 	for _, statement = range pln.Statements {
-		action, threshold, err = eval.Eval(data, statement.Statement)
+		action, threshold, err = eval.EvalAggregate(
+			data, p.unitData[unit.Id], statement.Statement)
 		if err != nil {
 			return
 		}
@@ -306,6 +434,18 @@ func (p *Planner) checkInstance(db *database.Database,
 				return
 			}
 			break
+		case plan.ScaleUp:
+			err = p.scaleUnit(db, deply, unit, 1)
+			if err != nil {
+				return
+			}
+			break
+		case plan.ScaleDown:
+			err = p.scaleUnit(db, deply, unit, -1)
+			if err != nil {
+				return
+			}
+			break
 		default:
 			logrus.WithFields(logrus.Fields{
 				"deployment": deply.Id.Hex(),
@@ -339,6 +479,22 @@ func (p *Planner) ApplyPlans(db *database.Database) (err error) {
 		p.podsMap[pd.Id] = pd
 	}
 
+	nodes, err := node.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	p.nodesMap = map[primitive.ObjectID]*node.Node{}
+
+	for _, nde := range nodes {
+		p.nodesMap[nde.Id] = nde
+	}
+
+	err = p.loadUnitStats(db, deployments)
+	if err != nil {
+		return
+	}
+
 	var waiters sync.WaitGroup
 	batch := make(chan struct{}, settings.System.PlannerBatchSize)
 
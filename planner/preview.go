@@ -0,0 +1,117 @@
+package planner
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/deployment"
+	"github.com/pritunl/pritunl-cloud/eval"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/plan"
+	"github.com/pritunl/pritunl-cloud/pod"
+)
+
+type PreviewResult struct {
+	Deployment primitive.ObjectID `json:"deployment"`
+	Instance   primitive.ObjectID `json:"instance"`
+	Statement  primitive.ObjectID `json:"statement"`
+	Action     string             `json:"action"`
+	Threshold  int                `json:"threshold"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// Evaluate statements against the current data of each deployment in the
+// unit without recording or executing any actions.
+func Preview(db *database.Database, pd *pod.Pod, unit *pod.Unit,
+	statements []*plan.Statement) (results []*PreviewResult, err error) {
+
+	results = []*PreviewResult{}
+
+	deployments, err := deployment.GetAll(db, &bson.M{
+		"pod":  pd.Id,
+		"unit": unit.Id,
+	})
+	if err != nil {
+		return
+	}
+
+	nodes, err := node.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	p := &Planner{
+		podsMap: map[primitive.ObjectID]*pod.Pod{
+			pd.Id: pd,
+		},
+		nodesMap: map[primitive.ObjectID]*node.Node{},
+	}
+
+	for _, nde := range nodes {
+		p.nodesMap[nde.Id] = nde
+	}
+
+	err = p.loadUnitStats(db, deployments)
+	if err != nil {
+		return
+	}
+
+	for _, deply := range deployments {
+		if deply.State == deployment.Reserved {
+			continue
+		}
+
+		switch deply.Kind {
+		case deployment.Instance, deployment.Image:
+			break
+		default:
+			continue
+		}
+
+		inst, e := instance.Get(db, deply.Instance)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				continue
+			}
+			err = e
+			return
+		}
+
+		result := &PreviewResult{
+			Deployment: deply.Id,
+			Instance:   inst.Id,
+		}
+		results = append(results, result)
+
+		data, e := buildEvalData(pd, unit, deply, inst,
+			p.nodesMap[inst.Node], p.unitStats[unit.Id])
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, statement := range statements {
+			action, threshold, e := eval.EvalAggregate(
+				data, p.unitData[unit.Id], statement.Statement)
+			if e != nil {
+				result.Statement = statement.Id
+				if evalErr, ok := e.(*eval.EvalError); ok {
+					result.Error = evalErr.GetMessage()
+				} else {
+					result.Error = e.Error()
+				}
+				break
+			}
+
+			if action != "" {
+				result.Statement = statement.Id
+				result.Action = action
+				result.Threshold = threshold
+				break
+			}
+		}
+	}
+
+	return
+}
@@ -1,28 +1,116 @@
 package planner
 
 import (
+	"time"
+
+	"github.com/pritunl/pritunl-cloud/deployment"
 	"github.com/pritunl/pritunl-cloud/eval"
 	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/plan"
 	"github.com/pritunl/pritunl-cloud/pod"
+	"github.com/pritunl/pritunl-cloud/utils"
 )
 
+type unitStats struct {
+	deployments int
+	healthy     int
+	guests      int
+	memory      float64
+	load1       float64
+	load5       float64
+	load15      float64
+}
+
+func (s *unitStats) add(deply *deployment.Deployment,
+	inst *instance.Instance) {
+
+	s.deployments += 1
+	if deply.IsHealthy() {
+		s.healthy += 1
+	}
+
+	if inst == nil || inst.Guest == nil {
+		return
+	}
+
+	s.guests += 1
+	s.memory += inst.Guest.Memory
+	s.load1 += inst.Guest.Load1
+	s.load5 += inst.Guest.Load5
+	s.load15 += inst.Guest.Load15
+}
+
+func (s *unitStats) average(val float64) float64 {
+	if s.guests == 0 {
+		return 0
+	}
+	return utils.ToFixed(val/float64(s.guests), 2)
+}
+
 func buildEvalData(servc *pod.Pod, unit *pod.Unit,
-	inst *instance.Instance) (data eval.Data, err error) {
+	deply *deployment.Deployment, inst *instance.Instance, nde *node.Node,
+	stats *unitStats) (data eval.Data, err error) {
+
+	if stats == nil {
+		stats = &unitStats{}
+	}
+
+	instData := plan.Instance{
+		Name:       inst.Name,
+		State:      inst.State,
+		VirtState:  inst.VirtState,
+		Processors: inst.Processors,
+		Memory:     inst.Memory,
+	}
+
+	if inst.Guest != nil {
+		instData.Health = inst.Guest.Health
+		instData.GuestStatus = inst.Guest.Status
+		instData.MemoryUsage = inst.Guest.Memory
+		instData.HugePagesUsage = inst.Guest.HugePages
+		instData.Load1 = inst.Guest.Load1
+		instData.Load5 = inst.Guest.Load5
+		instData.Load15 = inst.Guest.Load15
+		instData.Uptime = inst.Guest.Uptime
+		if !inst.Guest.Heartbeat.IsZero() {
+			instData.HeartbeatAge = int64(
+				time.Since(inst.Guest.Heartbeat).Seconds())
+		}
+	}
+
+	nodeData := plan.Node{}
+	if nde != nil {
+		nodeData.Name = nde.Name
+		nodeData.MemoryUsage = nde.Memory
+		nodeData.HugePagesUsage = nde.HugePagesUsed
+		nodeData.Load1 = nde.Load1
+		nodeData.Load5 = nde.Load5
+		nodeData.Load15 = nde.Load15
+	}
 
 	dataStrct := plan.Data{
 		Pod: plan.Pod{
 			Name: servc.Name,
 		},
 		Unit: plan.Unit{
-			Name:  unit.Name,
-			Count: unit.Count,
+			Name:        unit.Name,
+			Count:       unit.Count,
+			MinCount:    unit.MinCount,
+			MaxCount:    unit.MaxCount,
+			Deployments: stats.deployments,
+			Healthy:     stats.healthy,
+			MemoryUsage: stats.average(stats.memory),
+			Load1:       stats.average(stats.load1),
+			Load5:       stats.average(stats.load5),
+			Load15:      stats.average(stats.load15),
 		},
-		Instance: plan.Instance{
-			Name:      inst.Name,
-			State:     inst.State,
-			VirtState: inst.VirtState,
+		Deployment: plan.Deployment{
+			State:  deply.State,
+			Status: deply.Status,
 		},
+		Instance: instData,
+		Node:     nodeData,
 	}
 
 	data, err = dataStrct.Export()
@@ -3,4 +3,14 @@ package pod
 const (
 	Reserved = "reserved"
 	Deployed = "deployed"
+
+	Manual  = ""
+	Rolling = "rolling"
+
+	RolloutActive   = "active"
+	RolloutPaused   = "paused"
+	RolloutComplete = "complete"
+	RolloutAborted  = "aborted"
+
+	DefaultScaleCooldown = 300
 )
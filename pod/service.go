@@ -44,11 +44,27 @@ func (p *Pod) InitUnits(db *database.Database, units []*UnitInput) (
 
 	for _, unitData := range units {
 		unit := &Unit{
-			Pod:         p,
-			Id:          primitive.NewObjectID(),
-			Name:        unitData.Name,
-			Spec:        unitData.Spec,
-			Deployments: []*Deployment{},
+			Pod:            p,
+			Id:             primitive.NewObjectID(),
+			Name:           unitData.Name,
+			Spec:           unitData.Spec,
+			Deployments:    []*Deployment{},
+			UpdateStrategy: unitData.UpdateStrategy,
+			MaxSurge:       unitData.MaxSurge,
+			MaxUnavailable: unitData.MaxUnavailable,
+			MinCount:       unitData.MinCount,
+			MaxCount:       unitData.MaxCount,
+			ScaleCooldown:  unitData.ScaleCooldown,
+		}
+
+		errData = unit.ValidateStrategy()
+		if errData != nil {
+			return
+		}
+
+		errData = unit.ValidateScaling()
+		if errData != nil {
+			return
 		}
 
 		errData, err = unit.Parse(db)
@@ -59,6 +75,8 @@ func (p *Pod) InitUnits(db *database.Database, units []*UnitInput) (
 			return
 		}
 
+		unit.ApplyScaling(0)
+
 		p.Units = append(p.Units, unit)
 	}
 
@@ -101,16 +119,32 @@ func (p *Pod) CommitFieldsUnits(db *database.Database,
 		curUnit := curUnitsMap[unitData.Id]
 		if curUnit == nil {
 			unit := &Unit{
-				Pod:         p,
-				Id:          primitive.NewObjectID(),
-				Name:        unitData.Name,
-				Spec:        unitData.Spec,
-				Deployments: []*Deployment{},
+				Pod:            p,
+				Id:             primitive.NewObjectID(),
+				Name:           unitData.Name,
+				Spec:           unitData.Spec,
+				Deployments:    []*Deployment{},
+				UpdateStrategy: unitData.UpdateStrategy,
+				MaxSurge:       unitData.MaxSurge,
+				MaxUnavailable: unitData.MaxUnavailable,
+				MinCount:       unitData.MinCount,
+				MaxCount:       unitData.MaxCount,
+				ScaleCooldown:  unitData.ScaleCooldown,
 			}
 			curUnitsSet.Add(unit.Id)
 			curUnitsMap[unit.Id] = unit
 			newUnitsSet.Add(unit.Id)
 
+			errData = unit.ValidateStrategy()
+			if errData != nil {
+				return
+			}
+
+			errData = unit.ValidateScaling()
+			if errData != nil {
+				return
+			}
+
 			errData, err = unit.Parse(db)
 			if err != nil {
 				return
@@ -119,6 +153,8 @@ func (p *Pod) CommitFieldsUnits(db *database.Database,
 				return
 			}
 
+			unit.ApplyScaling(0)
+
 			p.Units = append(p.Units, unit)
 
 			arraySelectPush.Push(unit)
@@ -135,10 +171,29 @@ func (p *Pod) CommitFieldsUnits(db *database.Database,
 			return
 		}
 
+		prevDeployCommit := curUnit.DeployCommit
+		prevCount := curUnit.Count
+
 		newUnitsSet.Add(unitData.Id)
 		curUnit.Name = unitData.Name
 		curUnit.Spec = unitData.Spec
 		curUnit.DeployCommit = deploySpec.Id
+		curUnit.UpdateStrategy = unitData.UpdateStrategy
+		curUnit.MaxSurge = unitData.MaxSurge
+		curUnit.MaxUnavailable = unitData.MaxUnavailable
+		curUnit.MinCount = unitData.MinCount
+		curUnit.MaxCount = unitData.MaxCount
+		curUnit.ScaleCooldown = unitData.ScaleCooldown
+
+		errData = curUnit.ValidateStrategy()
+		if errData != nil {
+			return
+		}
+
+		errData = curUnit.ValidateScaling()
+		if errData != nil {
+			return
+		}
 
 		errData, err = curUnit.Parse(db)
 		if err != nil {
@@ -148,14 +203,34 @@ func (p *Pod) CommitFieldsUnits(db *database.Database,
 			return
 		}
 
+		curUnit.ApplyScaling(prevCount)
+
+		if curUnit.DeployCommit != prevDeployCommit {
+			curUnit.StartRollout(prevDeployCommit, curUnit.DeployCommit)
+		}
+
+		if curUnit.Canary != nil &&
+			curUnit.Canary.Commit == curUnit.DeployCommit {
+
+			curUnit.Canary = nil
+		}
+
 		arraySelectSet.Update(unitData.Id, bson.M{
-			"name":          curUnit.Name,
-			"kind":          curUnit.Kind,
-			"count":         curUnit.Count,
-			"spec":          curUnit.Spec,
-			"last_commit":   curUnit.LastCommit,
-			"deploy_commit": curUnit.DeployCommit,
-			"hash":          curUnit.Hash,
+			"name":            curUnit.Name,
+			"kind":            curUnit.Kind,
+			"count":           curUnit.Count,
+			"spec":            curUnit.Spec,
+			"last_commit":     curUnit.LastCommit,
+			"deploy_commit":   curUnit.DeployCommit,
+			"hash":            curUnit.Hash,
+			"update_strategy": curUnit.UpdateStrategy,
+			"max_surge":       curUnit.MaxSurge,
+			"max_unavailable": curUnit.MaxUnavailable,
+			"min_count":       curUnit.MinCount,
+			"max_count":       curUnit.MaxCount,
+			"scale_cooldown":  curUnit.ScaleCooldown,
+			"rollout":         curUnit.Rollout,
+			"canary":          curUnit.Canary,
 		})
 	}
 
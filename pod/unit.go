@@ -1,6 +1,8 @@
 package pod
 
 import (
+	"time"
+
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
@@ -9,34 +11,545 @@ import (
 	"github.com/pritunl/pritunl-cloud/deployment"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/spec"
+	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/tools/errors"
 )
 
 type Unit struct {
-	Pod          *Pod               `bson:"-" json:"-"`
-	Id           primitive.ObjectID `bson:"id" json:"id"`
-	Name         string             `bson:"name" json:"name"`
-	Kind         string             `bson:"kind" json:"kind"`
-	Count        int                `bson:"count" json:"count"`
-	Deployments  []*Deployment      `bson:"deployments" json:"deployments"`
-	Spec         string             `bson:"spec" json:"spec"`
-	LastCommit   primitive.ObjectID `bson:"last_commit" json:"last_commit"`
-	DeployCommit primitive.ObjectID `bson:"deploy_commit" json:"deploy_commit"`
-	Hash         string             `bson:"hash" json:"hash"`
+	Pod            *Pod               `bson:"-" json:"-"`
+	Id             primitive.ObjectID `bson:"id" json:"id"`
+	Name           string             `bson:"name" json:"name"`
+	Kind           string             `bson:"kind" json:"kind"`
+	Count          int                `bson:"count" json:"count"`
+	Deployments    []*Deployment      `bson:"deployments" json:"deployments"`
+	Spec           string             `bson:"spec" json:"spec"`
+	LastCommit     primitive.ObjectID `bson:"last_commit" json:"last_commit"`
+	DeployCommit   primitive.ObjectID `bson:"deploy_commit" json:"deploy_commit"`
+	Hash           string             `bson:"hash" json:"hash"`
+	UpdateStrategy string             `bson:"update_strategy" json:"update_strategy"`
+	MaxSurge       int                `bson:"max_surge" json:"max_surge"`
+	MaxUnavailable int                `bson:"max_unavailable" json:"max_unavailable"`
+	Rollout        *Rollout           `bson:"rollout,omitempty" json:"rollout"`
+	Canary         *Canary            `bson:"canary,omitempty" json:"canary"`
+	MinCount       int                `bson:"min_count" json:"min_count"`
+	MaxCount       int                `bson:"max_count" json:"max_count"`
+	ScaleCooldown  int                `bson:"scale_cooldown" json:"scale_cooldown"`
+	LastScale      time.Time          `bson:"last_scale" json:"last_scale"`
 }
 
 type UnitInput struct {
-	Id           primitive.ObjectID `json:"id"`
-	Name         string             `json:"name"`
-	Spec         string             `json:"spec"`
-	DeployCommit primitive.ObjectID `json:"deploy_commit"`
-	Delete       bool               `json:"delete"`
+	Id             primitive.ObjectID `json:"id"`
+	Name           string             `json:"name"`
+	Spec           string             `json:"spec"`
+	DeployCommit   primitive.ObjectID `json:"deploy_commit"`
+	UpdateStrategy string             `json:"update_strategy"`
+	MaxSurge       int                `json:"max_surge"`
+	MaxUnavailable int                `json:"max_unavailable"`
+	MinCount       int                `json:"min_count"`
+	MaxCount       int                `json:"max_count"`
+	ScaleCooldown  int                `json:"scale_cooldown"`
+	Delete         bool               `json:"delete"`
+}
+
+type Rollout struct {
+	State      string             `bson:"state" json:"state"`
+	FromCommit primitive.ObjectID `bson:"from_commit" json:"from_commit"`
+	Commit     primitive.ObjectID `bson:"commit" json:"commit"`
+	Batch      int                `bson:"batch" json:"batch"`
+	Started    time.Time          `bson:"started" json:"started"`
+	Modified   time.Time          `bson:"modified" json:"modified"`
+	Message    string             `bson:"message" json:"message"`
 }
 
 type Deployment struct {
 	Id primitive.ObjectID `bson:"id" json:"id"`
 }
 
+type Canary struct {
+	Commit  primitive.ObjectID `bson:"commit" json:"commit"`
+	Weight  int                `bson:"weight" json:"weight"`
+	Started time.Time          `bson:"started" json:"started"`
+}
+
+func (u *Unit) IsRolling() bool {
+	return u.Rollout != nil && (u.Rollout.State == RolloutActive ||
+		u.Rollout.State == RolloutPaused)
+}
+
+func (u *Unit) ValidateStrategy() (errData *errortypes.ErrorData) {
+	switch u.UpdateStrategy {
+	case Manual:
+		u.MaxSurge = 0
+		u.MaxUnavailable = 0
+		break
+	case Rolling:
+		if u.MaxSurge < 0 || u.MaxUnavailable < 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "unit_rolling_invalid",
+				Message: "Unit rolling update limits cannot be negative",
+			}
+			return
+		}
+
+		if u.MaxSurge == 0 && u.MaxUnavailable == 0 {
+			u.MaxSurge = 1
+		}
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "unit_update_strategy_invalid",
+			Message: "Unit update strategy is invalid",
+		}
+		return
+	}
+
+	return
+}
+
+func (u *Unit) IsAutoscaled() bool {
+	return u.MaxCount > 0
+}
+
+func (u *Unit) ValidateScaling() (errData *errortypes.ErrorData) {
+	if u.MaxCount == 0 {
+		u.MinCount = 0
+		u.ScaleCooldown = 0
+		return
+	}
+
+	if u.MinCount < 0 || u.MaxCount < 0 || u.MinCount > u.MaxCount {
+		errData = &errortypes.ErrorData{
+			Error:   "unit_scaling_invalid",
+			Message: "Unit scaling count limits are invalid",
+		}
+		return
+	}
+
+	if u.ScaleCooldown < 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "unit_scale_cooldown_invalid",
+			Message: "Unit scale cooldown cannot be negative",
+		}
+		return
+	}
+
+	if u.ScaleCooldown == 0 {
+		u.ScaleCooldown = DefaultScaleCooldown
+	}
+
+	return
+}
+
+// Keep the autoscaled count across spec updates, the spec count is only
+// used as the initial count.
+func (u *Unit) ApplyScaling(prevCount int) {
+	if !u.IsAutoscaled() {
+		return
+	}
+
+	if prevCount > 0 {
+		u.Count = prevCount
+	}
+
+	u.Count = utils.Min(utils.Max(u.Count, u.MinCount), u.MaxCount)
+}
+
+func (u *Unit) Scale(db *database.Database, delta int) (
+	scaled bool, err error) {
+
+	if !u.IsAutoscaled() || delta == 0 {
+		return
+	}
+
+	coll := db.Pods()
+	now := time.Now()
+	cooldown := time.Duration(u.ScaleCooldown) * time.Second
+
+	elemQuery := bson.M{
+		"id": u.Id,
+		"$or": []*bson.M{
+			&bson.M{
+				"last_scale": &bson.M{
+					"$lt": now.Add(-cooldown),
+				},
+			},
+			&bson.M{
+				"last_scale": &bson.M{
+					"$exists": false,
+				},
+			},
+		},
+	}
+	if delta > 0 {
+		elemQuery["count"] = &bson.M{
+			"$lte": u.MaxCount - delta,
+		}
+	} else {
+		elemQuery["count"] = &bson.M{
+			"$gte": u.MinCount - delta,
+		}
+	}
+
+	updateOpts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{
+			bson.M{"elem.id": u.Id},
+		},
+	})
+	resp, err := coll.UpdateOne(db, &bson.M{
+		"_id": u.Pod.Id,
+		"units": &bson.M{
+			"$elemMatch": elemQuery,
+		},
+	}, &bson.M{
+		"$inc": &bson.M{
+			"units.$[elem].count": delta,
+		},
+		"$set": &bson.M{
+			"units.$[elem].last_scale": now,
+		},
+	}, updateOpts)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if resp.ModifiedCount == 0 {
+		return
+	}
+
+	u.Count += delta
+	u.LastScale = now
+	scaled = true
+
+	return
+}
+
+func (u *Unit) StartRollout(fromCommit, commit primitive.ObjectID) {
+	if u.UpdateStrategy != Rolling || fromCommit.IsZero() ||
+		fromCommit == commit || len(u.Deployments) == 0 {
+
+		return
+	}
+
+	u.Rollout = &Rollout{
+		State:      RolloutActive,
+		FromCommit: fromCommit,
+		Commit:     commit,
+		Started:    time.Now(),
+		Modified:   time.Now(),
+	}
+}
+
+func (u *Unit) commitFields(db *database.Database, fields bson.M) (
+	err error) {
+
+	coll := db.Pods()
+
+	update := bson.M{}
+	for key, val := range fields {
+		update["units.$[elem]."+key] = val
+	}
+
+	updateOpts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{
+			bson.M{"elem.id": u.Id},
+		},
+	})
+	_, err = coll.UpdateOne(db, bson.M{
+		"_id": u.Pod.Id,
+	}, bson.M{
+		"$set": update,
+	}, updateOpts)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func (u *Unit) CommitRollout(db *database.Database) (err error) {
+	if u.Rollout != nil {
+		u.Rollout.Modified = time.Now()
+	}
+
+	err = u.commitFields(db, bson.M{
+		"rollout": u.Rollout,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (u *Unit) StartCanary(db *database.Database,
+	commitId primitive.ObjectID, weight int) (
+	errData *errortypes.ErrorData, err error) {
+
+	if u.Kind != deployment.Instance {
+		errData = &errortypes.ErrorData{
+			Error:   "unit_canary_kind_invalid",
+			Message: "Canary only available for instance units",
+		}
+		return
+	}
+
+	if u.Canary != nil {
+		errData = &errortypes.ErrorData{
+			Error:   "unit_canary_active",
+			Message: "Unit already has an active canary",
+		}
+		return
+	}
+
+	if u.IsRolling() {
+		errData = &errortypes.ErrorData{
+			Error:   "unit_rollout_active",
+			Message: "Cannot start canary while rollout is active",
+		}
+		return
+	}
+
+	if commitId == u.DeployCommit {
+		errData = &errortypes.ErrorData{
+			Error:   "unit_canary_commit_invalid",
+			Message: "Canary commit cannot be the deployed commit",
+		}
+		return
+	}
+
+	spc, err := spec.Get(db, commitId)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			spc = nil
+		} else {
+			return
+		}
+	}
+
+	if spc == nil || spc.Pod != u.Pod.Id || spc.Unit != u.Id {
+		errData = &errortypes.ErrorData{
+			Error:   "unit_canary_commit_invalid",
+			Message: "Invalid unit canary commit",
+		}
+		return
+	}
+
+	if weight < 0 || weight > 100 {
+		errData = &errortypes.ErrorData{
+			Error:   "unit_canary_weight_invalid",
+			Message: "Canary weight must be between 0 and 100",
+		}
+		return
+	}
+
+	u.Canary = &Canary{
+		Commit:  spc.Id,
+		Weight:  weight,
+		Started: time.Now(),
+	}
+
+	err = u.commitFields(db, bson.M{
+		"canary": u.Canary,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (u *Unit) SetCanaryWeight(db *database.Database, weight int) (
+	errData *errortypes.ErrorData, err error) {
+
+	if u.Canary == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "unit_canary_missing",
+			Message: "Unit does not have an active canary",
+		}
+		return
+	}
+
+	if weight < 0 || weight > 100 {
+		errData = &errortypes.ErrorData{
+			Error:   "unit_canary_weight_invalid",
+			Message: "Canary weight must be between 0 and 100",
+		}
+		return
+	}
+
+	u.Canary.Weight = weight
+
+	err = u.commitFields(db, bson.M{
+		"canary": u.Canary,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (u *Unit) PromoteCanary(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if u.Canary == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "unit_canary_missing",
+			Message: "Unit does not have an active canary",
+		}
+		return
+	}
+
+	prevCommit := u.DeployCommit
+	u.DeployCommit = u.Canary.Commit
+	u.Canary = nil
+
+	if u.UpdateStrategy == Rolling {
+		u.StartRollout(prevCommit, u.DeployCommit)
+	} else {
+		deplys, e := deployment.GetAll(db, &bson.M{
+			"pod":  u.Pod.Id,
+			"unit": u.Id,
+			"spec": &bson.M{
+				"$ne": u.DeployCommit,
+			},
+			"state": deployment.Deployed,
+		})
+		if e != nil {
+			err = e
+			return
+		}
+
+		deplyIds := []primitive.ObjectID{}
+		for _, deply := range deplys {
+			deplyIds = append(deplyIds, deply.Id)
+		}
+
+		if len(deplyIds) > 0 {
+			err = deployment.RemoveMulti(db, u.Pod.Id, u.Id, deplyIds)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	err = u.commitFields(db, bson.M{
+		"deploy_commit": u.DeployCommit,
+		"canary":        u.Canary,
+		"rollout":       u.Rollout,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (u *Unit) AbortCanary(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if u.Canary == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "unit_canary_missing",
+			Message: "Unit does not have an active canary",
+		}
+		return
+	}
+
+	deplys, err := deployment.GetAll(db, &bson.M{
+		"pod":  u.Pod.Id,
+		"unit": u.Id,
+		"spec": u.Canary.Commit,
+	})
+	if err != nil {
+		return
+	}
+
+	deplyIds := []primitive.ObjectID{}
+	for _, deply := range deplys {
+		deplyIds = append(deplyIds, deply.Id)
+	}
+
+	if len(deplyIds) > 0 {
+		err = deployment.RemoveMulti(db, u.Pod.Id, u.Id, deplyIds)
+		if err != nil {
+			return
+		}
+	}
+
+	u.Canary = nil
+
+	err = u.commitFields(db, bson.M{
+		"canary": u.Canary,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (u *Unit) SetRolloutState(db *database.Database, state string) (
+	errData *errortypes.ErrorData, err error) {
+
+	if u.Rollout == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "unit_rollout_missing",
+			Message: "Unit does not have a rollout",
+		}
+		return
+	}
+
+	switch state {
+	case RolloutActive:
+		if u.Rollout.State != RolloutPaused {
+			errData = &errortypes.ErrorData{
+				Error:   "unit_rollout_not_paused",
+				Message: "Unit rollout is not paused",
+			}
+			return
+		}
+		break
+	case RolloutPaused:
+		if u.Rollout.State != RolloutActive {
+			errData = &errortypes.ErrorData{
+				Error:   "unit_rollout_not_active",
+				Message: "Unit rollout is not active",
+			}
+			return
+		}
+		break
+	case RolloutAborted:
+		if !u.IsRolling() {
+			errData = &errortypes.ErrorData{
+				Error:   "unit_rollout_not_active",
+				Message: "Unit rollout is not active",
+			}
+			return
+		}
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "unit_rollout_state_invalid",
+			Message: "Unit rollout state is invalid",
+		}
+		return
+	}
+
+	u.Rollout.State = state
+	u.Rollout.Message = ""
+
+	err = u.CommitRollout(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func (u *Unit) HasDeployment(deployId primitive.ObjectID) bool {
 	if u.Deployments != nil {
 		for _, deply := range u.Deployments {
@@ -1,7 +1,15 @@
 package pool
 
+import (
+	"time"
+)
+
 const (
-	Lvm = "lvm"
+	capacityTtl = 10 * time.Minute
+
+	Lvm     = "lvm"
+	LvmThin = "lvm_thin"
+	Rbd     = "rbd"
 
 	Active = "active"
 )
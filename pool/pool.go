@@ -1,6 +1,9 @@
 package pool
 
 import (
+	"regexp"
+	"time"
+
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/database"
@@ -8,6 +11,8 @@ import (
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
+var cephNameReg = regexp.MustCompile("^[A-Za-z0-9_.-]+$")
+
 type Pool struct {
 	Id               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name             string             `bson:"name" json:"name"`
@@ -16,6 +21,15 @@ type Pool struct {
 	Zone             primitive.ObjectID `bson:"zone" json:"zone"`
 	Type             string             `bson:"type" json:"type"`
 	VgName           string             `bson:"vg_name" json:"vg_name"`
+	ThinPool         string             `bson:"thin_pool" json:"thin_pool"`
+	Overcommit       float64            `bson:"overcommit" json:"overcommit"`
+	CephPool         string             `bson:"ceph_pool" json:"ceph_pool"`
+	CephUser         string             `bson:"ceph_user" json:"ceph_user"`
+	Size             int64              `bson:"size" json:"size"`
+	Used             int64              `bson:"used" json:"used"`
+	Free             int64              `bson:"free" json:"free"`
+	Allocated        int64              `bson:"allocated" json:"allocated"`
+	CapacityUpdated  time.Time          `bson:"capacity_updated" json:"capacity_updated"`
 }
 
 func (p *Pool) Json(nodeNames map[primitive.ObjectID]string) {
@@ -26,6 +40,113 @@ func (p *Pool) Validate(db *database.Database) (
 
 	p.Name = utils.FilterName(p.Name)
 
+	switch p.Type {
+	case Lvm, "":
+		p.Type = Lvm
+		p.ThinPool = ""
+		p.Overcommit = 0
+		p.CephPool = ""
+		p.CephUser = ""
+		break
+	case LvmThin:
+		if p.ThinPool == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "thin_pool_required",
+				Message: "Missing required LVM thin pool name",
+			}
+			return
+		}
+
+		if p.Overcommit == 0 {
+			p.Overcommit = 1
+		} else if p.Overcommit < 1 {
+			errData = &errortypes.ErrorData{
+				Error:   "overcommit_invalid",
+				Message: "Thin pool overcommit ratio cannot be less then 1",
+			}
+			return
+		}
+
+		p.CephPool = ""
+		p.CephUser = ""
+		break
+	case Rbd:
+		p.VgName = ""
+		p.ThinPool = ""
+		p.Overcommit = 0
+
+		if p.CephPool == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "ceph_pool_required",
+				Message: "Missing required Ceph pool name",
+			}
+			return
+		}
+
+		if !cephNameReg.MatchString(p.CephPool) {
+			errData = &errortypes.ErrorData{
+				Error:   "ceph_pool_invalid",
+				Message: "Ceph pool name contains invalid characters",
+			}
+			return
+		}
+
+		if p.CephUser != "" && !cephNameReg.MatchString(p.CephUser) {
+			errData = &errortypes.ErrorData{
+				Error:   "ceph_user_invalid",
+				Message: "Ceph user contains invalid characters",
+			}
+			return
+		}
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "unknown_type",
+			Message: "Unknown pool type",
+		}
+		return
+	}
+
+	return
+}
+
+func (p *Pool) IsThin() bool {
+	return p.Type == LvmThin
+}
+
+func (p *Pool) IsRbd() bool {
+	return p.Type == Rbd
+}
+
+// Check that the last reported capacity of the pool can fit a new volume of
+// size in gigabytes. Thin pools are limited by the overcommit ratio of the
+// allocated volumes and must have free space remaining.
+func (p *Pool) CheckCapacity(size int) (errData *errortypes.ErrorData) {
+	if p.CapacityUpdated.IsZero() ||
+		time.Since(p.CapacityUpdated) > capacityTtl {
+
+		return
+	}
+
+	sizeBytes := int64(size) * 1073741824
+
+	if p.IsThin() {
+		limit := int64(float64(p.Size) * p.Overcommit)
+		if p.Free <= 0 || p.Allocated+sizeBytes > limit {
+			errData = &errortypes.ErrorData{
+				Error:   "pool_full",
+				Message: "Pool does not have enough capacity",
+			}
+			return
+		}
+	} else if sizeBytes > p.Free {
+		errData = &errortypes.ErrorData{
+			Error:   "pool_full",
+			Message: "Pool does not have enough capacity",
+		}
+		return
+	}
+
 	return
 }
 
@@ -70,6 +70,32 @@ type Domain struct {
 	WebSocketConnsLock sync.Mutex
 }
 
+func selectHandler(hands []*Handler) *Handler {
+	l := len(hands)
+	if l == 1 {
+		return hands[0]
+	}
+
+	total := 0
+	for _, hand := range hands {
+		total += hand.Weight
+	}
+
+	if total <= l {
+		return hands[rand.Intn(l)]
+	}
+
+	n := rand.Intn(total)
+	for _, hand := range hands {
+		n -= hand.Weight
+		if n < 0 {
+			return hand
+		}
+	}
+
+	return hands[l-1]
+}
+
 func (d *Domain) CalculateHash() {
 	h := md5.New()
 
@@ -91,6 +117,7 @@ func (d *Domain) CalculateHash() {
 		h.Write([]byte(backend.Protocol))
 		h.Write([]byte(backend.Hostname))
 		h.Write([]byte(strconv.Itoa(backend.Port)))
+		h.Write([]byte(strconv.Itoa(backend.Weight)))
 	}
 
 	d.Hash = h.Sum(nil)
@@ -169,35 +196,35 @@ func (d *Domain) ServeHTTPFirst(rw http.ResponseWriter, r *http.Request) {
 	onlineWebFirst := d.OnlineWebFirst
 	l := len(onlineWebFirst)
 	if l != 0 {
-		onlineWebFirst[rand.Intn(l)].Serve(rw, r)
+		selectHandler(onlineWebFirst).Serve(rw, r)
 		return
 	}
 
 	unknownHighWebFirst := d.UnknownHighWebFirst
 	l = len(unknownHighWebFirst)
 	if l != 0 {
-		unknownHighWebFirst[rand.Intn(l)].Serve(rw, r)
+		selectHandler(unknownHighWebFirst).Serve(rw, r)
 		return
 	}
 
 	unknownMidWebFirst := d.UnknownMidWebFirst
 	l = len(unknownMidWebFirst)
 	if l != 0 {
-		unknownMidWebFirst[rand.Intn(l)].Serve(rw, r)
+		selectHandler(unknownMidWebFirst).Serve(rw, r)
 		return
 	}
 
 	unknownLowWebFirst := d.UnknownLowWebFirst
 	l = len(unknownLowWebFirst)
 	if l != 0 {
-		unknownLowWebFirst[rand.Intn(l)].Serve(rw, r)
+		selectHandler(unknownLowWebFirst).Serve(rw, r)
 		return
 	}
 
 	offlineWebFirst := d.OfflineWebFirst
 	l = len(offlineWebFirst)
 	if l != 0 {
-		offlineWebFirst[rand.Intn(l)].Serve(rw, r)
+		selectHandler(offlineWebFirst).Serve(rw, r)
 		return
 	}
 
@@ -210,35 +237,35 @@ func (d *Domain) ServeHTTPSecond(rw http.ResponseWriter, r *http.Request) {
 	onlineWebSecond := d.OnlineWebSecond
 	l := len(onlineWebSecond)
 	if l != 0 {
-		onlineWebSecond[rand.Intn(l)].Serve(rw, r)
+		selectHandler(onlineWebSecond).Serve(rw, r)
 		return
 	}
 
 	unknownHighWebSecond := d.UnknownHighWebSecond
 	l = len(unknownHighWebSecond)
 	if l != 0 {
-		unknownHighWebSecond[rand.Intn(l)].Serve(rw, r)
+		selectHandler(unknownHighWebSecond).Serve(rw, r)
 		return
 	}
 
 	unknownMidWebSecond := d.UnknownMidWebSecond
 	l = len(unknownMidWebSecond)
 	if l != 0 {
-		unknownMidWebSecond[rand.Intn(l)].Serve(rw, r)
+		selectHandler(unknownMidWebSecond).Serve(rw, r)
 		return
 	}
 
 	unknownLowWebSecond := d.UnknownLowWebSecond
 	l = len(unknownLowWebSecond)
 	if l != 0 {
-		unknownLowWebSecond[rand.Intn(l)].Serve(rw, r)
+		selectHandler(unknownLowWebSecond).Serve(rw, r)
 		return
 	}
 
 	offlineWebSecond := d.OfflineWebSecond
 	l = len(offlineWebSecond)
 	if l != 0 {
-		offlineWebSecond[rand.Intn(l)].Serve(rw, r)
+		selectHandler(offlineWebSecond).Serve(rw, r)
 		return
 	}
 
@@ -251,35 +278,35 @@ func (d *Domain) ServeHTTPThird(rw http.ResponseWriter, r *http.Request) {
 	onlineWebThird := d.OnlineWebThird
 	l := len(onlineWebThird)
 	if l != 0 {
-		onlineWebThird[rand.Intn(l)].Serve(rw, r)
+		selectHandler(onlineWebThird).Serve(rw, r)
 		return
 	}
 
 	unknownHighWebThird := d.UnknownHighWebThird
 	l = len(unknownHighWebThird)
 	if l != 0 {
-		unknownHighWebThird[rand.Intn(l)].Serve(rw, r)
+		selectHandler(unknownHighWebThird).Serve(rw, r)
 		return
 	}
 
 	unknownMidWebThird := d.UnknownMidWebThird
 	l = len(unknownMidWebThird)
 	if l != 0 {
-		unknownMidWebThird[rand.Intn(l)].Serve(rw, r)
+		selectHandler(unknownMidWebThird).Serve(rw, r)
 		return
 	}
 
 	unknownLowWebThird := d.UnknownLowWebThird
 	l = len(unknownLowWebThird)
 	if l != 0 {
-		unknownLowWebThird[rand.Intn(l)].Serve(rw, r)
+		selectHandler(unknownLowWebThird).Serve(rw, r)
 		return
 	}
 
 	offlineWebThird := d.OfflineWebThird
 	l = len(offlineWebThird)
 	if l != 0 {
-		offlineWebThird[rand.Intn(l)].Serve(rw, r)
+		selectHandler(offlineWebThird).Serve(rw, r)
 		return
 	}
 
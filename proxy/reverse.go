@@ -12,7 +12,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/sirupsen/logrus"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/gorilla/websocket"
 	"github.com/pritunl/pritunl-cloud/balancer"
@@ -21,12 +20,14 @@ import (
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
 )
 
 type Handler struct {
 	Key                string
 	Index              int
 	State              int
+	Weight             int
 	Domain             *Domain
 	CheckUrl           string
 	LastState          time.Time
@@ -152,6 +153,11 @@ func NewHandler(index, state int, proxyProto string, proxyPort int,
 	backendProto := backend.Protocol
 	backendHost := utils.FormatHostPort(backend.Hostname, backend.Port)
 
+	weight := backend.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+
 	backendProtoWs := ""
 	if backendProto == "https" {
 		backendProtoWs = "wss"
@@ -224,6 +230,7 @@ func NewHandler(index, state int, proxyProto string, proxyPort int,
 		Key:            fmt.Sprintf("%s:%d", backend.Hostname, backend.Port),
 		Index:          index,
 		State:          state,
+		Weight:         weight,
 		Domain:         domain,
 		CheckUrl:       checkUrl.String(),
 		BackendHost:    backendHost,
@@ -162,13 +162,14 @@ func writeOvmfVars(virt *vm.VirtualMachine) (err error) {
 	return
 }
 
-func writeService(virt *vm.VirtualMachine) (err error) {
+func writeService(virt *vm.VirtualMachine, incoming bool) (err error) {
 	unitPath := paths.GetUnitPath(virt.Id)
 
 	qm, err := NewQemu(virt)
 	if err != nil {
 		return
 	}
+	qm.Incoming = incoming
 
 	output, err := qm.Marshal()
 	if err != nil {
@@ -189,28 +190,8 @@ func writeService(virt *vm.VirtualMachine) (err error) {
 }
 
 func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
-	vmPath := paths.GetVmPath(virt.Id)
 	unitName := paths.GetUnitName(virt.Id)
 	unitPath := paths.GetUnitPath(virt.Id)
-	unitPathServer4 := paths.GetUnitPathDhcp4(virt.Id, 0)
-	unitPathServer6 := paths.GetUnitPathDhcp6(virt.Id, 0)
-	unitPathServerNdp := paths.GetUnitPathNdp(virt.Id, 0)
-	tpmPath := paths.GetTpmPath(virt.Id)
-	runPath := paths.GetInstRunPath(virt.Id)
-	unitPathTpm := paths.GetUnitPathTpm(virt.Id)
-	sockPath := paths.GetSockPath(virt.Id)
-	sockQmpPath := paths.GetQmpSockPath(virt.Id)
-	// TODO Backward compatibility
-	sockPathOld := paths.GetSockPath(virt.Id)
-	guestPath := paths.GetGuestPath(virt.Id)
-	// TODO Backward compatibility
-	guestPathOld := paths.GetGuestPathOld(virt.Id)
-	pidPath := paths.GetPidPath(virt.Id)
-	// TODO Backward compatibility
-	pidPathOld := paths.GetPidPathOld(virt.Id)
-	ovmfVarsPath := paths.GetOvmfVarsPath(virt.Id)
-	hugepagesPath := paths.GetHugepagePath(virt.Id)
-	cachePath := paths.GetCacheDir(virt.Id)
 
 	logrus.WithFields(logrus.Fields{
 		"id": virt.Id.Hex(),
@@ -341,14 +322,15 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 	}
 
 	for i, dsk := range virt.DriveDevices {
-		if dsk.Type != vm.Lvm {
+		if dsk.Type != vm.Lvm && dsk.Type != vm.Rbd {
 			continue
 		}
 
 		dskId, ok := utils.ParseObjectId(dsk.Id)
 		if dskId.IsZero() || !ok {
 			err = &errortypes.ParseError{
-				errors.Newf("qemu: Failed to parse LVM disk ID '%s'", dsk.Id),
+				errors.Newf("qemu: Failed to parse pool disk ID '%s'",
+					dsk.Id),
 			}
 			return
 		}
@@ -380,6 +362,37 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 		}
 	}
 
+	err = removeData(virt)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func removeData(virt *vm.VirtualMachine) (err error) {
+	vmPath := paths.GetVmPath(virt.Id)
+	unitPath := paths.GetUnitPath(virt.Id)
+	unitPathServer4 := paths.GetUnitPathDhcp4(virt.Id, 0)
+	unitPathServer6 := paths.GetUnitPathDhcp6(virt.Id, 0)
+	unitPathServerNdp := paths.GetUnitPathNdp(virt.Id, 0)
+	tpmPath := paths.GetTpmPath(virt.Id)
+	runPath := paths.GetInstRunPath(virt.Id)
+	unitPathTpm := paths.GetUnitPathTpm(virt.Id)
+	sockPath := paths.GetSockPath(virt.Id)
+	sockQmpPath := paths.GetQmpSockPath(virt.Id)
+	// TODO Backward compatibility
+	sockPathOld := paths.GetSockPath(virt.Id)
+	guestPath := paths.GetGuestPath(virt.Id)
+	// TODO Backward compatibility
+	guestPathOld := paths.GetGuestPathOld(virt.Id)
+	pidPath := paths.GetPidPath(virt.Id)
+	// TODO Backward compatibility
+	pidPathOld := paths.GetPidPathOld(virt.Id)
+	ovmfVarsPath := paths.GetOvmfVarsPath(virt.Id)
+	hugepagesPath := paths.GetHugepagePath(virt.Id)
+	cachePath := paths.GetCacheDir(virt.Id)
+
 	err = utils.RemoveAll(vmPath)
 	if err != nil {
 		return
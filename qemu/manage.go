@@ -387,7 +387,9 @@ func GetVms(db *database.Database,
 
 					inst.State = instance.Cleanup
 					e = virt.CommitState(db, instance.Cleanup)
-				} else {
+				} else if inst != nil {
+					// Skip instances owned by another node such as
+					// the destination of an incoming migration
 					e = virt.Commit(db)
 				}
 				if e != nil {
@@ -518,6 +520,7 @@ func Create(db *database.Database, inst *instance.Instance,
 			Index:            "0",
 			Size:             inst.InitDiskSize,
 			DeleteProtection: inst.DeleteProtection,
+			Qos:              inst.DiskQos.Copy(),
 		}
 
 		backingImage := ""
@@ -563,12 +566,29 @@ func Create(db *database.Database, inst *instance.Instance,
 				Type:   vm.Lvm,
 				VgName: pl.VgName,
 				LvName: dsk.Id.Hex(),
+				Qos:    dsk.Qos.Copy(),
+			})
+		} else if virt.DiskType == disk.Rbd {
+			pl, e := pool.Get(db, dsk.Pool)
+			if e != nil {
+				err = e
+				return
+			}
+
+			virt.DriveDevices = append(virt.DriveDevices, &vm.DriveDevice{
+				Id:       dsk.Id.Hex(),
+				Type:     vm.Rbd,
+				RbdPool:  pl.CephPool,
+				RbdUser:  pl.CephUser,
+				RbdImage: dsk.Id.Hex(),
+				Qos:      dsk.Qos.Copy(),
 			})
 		} else {
 			virt.Disks = append(virt.Disks, &vm.Disk{
 				Id:    dsk.Id,
 				Index: 0,
 				Path:  paths.GetDiskPath(dsk.Id),
+				Qos:   dsk.Qos.Copy(),
 			})
 		}
 	}
@@ -598,7 +618,7 @@ func Create(db *database.Database, inst *instance.Instance,
 		return
 	}
 
-	err = writeService(virt)
+	err = writeService(virt, false)
 	if err != nil {
 		return
 	}
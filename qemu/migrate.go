@@ -0,0 +1,278 @@
+package qemu
+
+import (
+	"strconv"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/cloudinit"
+	"github.com/pritunl/pritunl-cloud/constants"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/dhcps"
+	"github.com/pritunl/pritunl-cloud/features"
+	"github.com/pritunl/pritunl-cloud/imds"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/qmp"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/store"
+	"github.com/pritunl/pritunl-cloud/systemd"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/sirupsen/logrus"
+)
+
+func createMigrateDisks(migration *instance.Migration) (err error) {
+	err = utils.ExistsMkdir(paths.GetDisksPath(), 0755)
+	if err != nil {
+		return
+	}
+
+	for _, dsk := range migration.Disks {
+		diskPath := paths.GetDiskPath(dsk.Id)
+
+		err = utils.RemoveAll(diskPath)
+		if err != nil {
+			return
+		}
+
+		err = utils.Exec("", "qemu-img", "create",
+			"-f", "qcow2", diskPath, strconv.FormatInt(dsk.Size, 10))
+		if err != nil {
+			return
+		}
+
+		err = utils.Chmod(diskPath, 0600)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Create empty disks and start the virtual machine paused waiting for the
+// disk mirror and incoming migration from the source node. The returned
+// authority certificate must be trusted by the source node.
+func PowerOnIncoming(db *database.Database, inst *instance.Instance,
+	virt *vm.VirtualMachine, addr string) (caPem string, err error) {
+
+	unitName := paths.GetUnitName(virt.Id)
+	migration := inst.Migration
+
+	if constants.Interrupt {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"id":          virt.Id.Hex(),
+		"source_node": migration.Source.Hex(),
+	}).Info("qemu: Starting incoming virtual machine migration")
+
+	err = createMigrateDisks(migration)
+	if err != nil {
+		return
+	}
+
+	err = initDirs(virt)
+	if err != nil {
+		return
+	}
+
+	err = cleanRun(virt)
+	if err != nil {
+		return
+	}
+
+	caPem, err = InitMigrateTls(virt, MigrateServer,
+		node.Self.Id.Hex(), addr)
+	if err != nil {
+		return
+	}
+
+	err = SetMigrateTlsPeer(virt, migration.SourceCert)
+	if err != nil {
+		return
+	}
+
+	err = virt.GenerateImdsSecret()
+	if err != nil {
+		return
+	}
+
+	err = cloudinit.Write(db, inst, virt, false)
+	if err != nil {
+		return
+	}
+
+	err = imds.WriteService(virt.Id, vm.GetNamespace(virt.Id, 0),
+		virt.ImdsClientSecret, virt.ImdsHostSecret,
+		features.HasSystemdNamespace())
+	if err != nil {
+		return
+	}
+
+	err = initCache(virt)
+	if err != nil {
+		return
+	}
+
+	err = initHugepage(virt)
+	if err != nil {
+		return
+	}
+
+	err = writeOvmfVars(virt)
+	if err != nil {
+		return
+	}
+
+	err = writeService(virt, true)
+	if err != nil {
+		return
+	}
+
+	err = initPermissions(virt)
+	if err != nil {
+		return
+	}
+
+	err = systemd.Start(unitName)
+	if err != nil {
+		return
+	}
+
+	err = Wait(db, virt)
+	if err != nil {
+		return
+	}
+
+	dskIds := []primitive.ObjectID{}
+	for _, dsk := range migration.Disks {
+		dskIds = append(dskIds, dsk.Id)
+	}
+
+	err = qmp.MigrateIncoming(virt.Id, dskIds, addr,
+		"CN="+migration.Source.Hex(), paths.GetMigrateTlsPath(virt.Id),
+		settings.Hypervisor.MigrationPort,
+		settings.Hypervisor.MigrationNbdPort)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Configure the network and services of a virtual machine after the
+// migration has completed on the destination node.
+func FinalizeIncoming(db *database.Database, inst *instance.Instance,
+	virt, curVirt *vm.VirtualMachine) (err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"id":          virt.Id.Hex(),
+		"source_node": inst.Migration.Source.Hex(),
+	}).Info("qemu: Finalizing incoming virtual machine migration")
+
+	err = qmp.StopNbd(virt.Id)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"id":    virt.Id.Hex(),
+			"error": err,
+		}).Error("qemu: Failed to stop migration nbd server")
+		err = nil
+	}
+
+	virt.ImdsVersion = curVirt.ImdsVersion
+	virt.ImdsHostSecret = curVirt.ImdsHostSecret
+	virt.ImdsClientSecret, err = imds.GetClientSecret(virt.Id)
+	if err != nil {
+		return
+	}
+
+	err = writeService(virt, false)
+	if err != nil {
+		return
+	}
+
+	if inst.Vnc {
+		err = qmp.VncPassword(virt.Id, inst.VncPassword)
+		if err != nil {
+			return
+		}
+	}
+
+	if inst.Spice {
+		err = qmp.SetPassword(virt.Id, qmp.Spice, inst.SpicePassword)
+		if err != nil {
+			return
+		}
+	}
+
+	if virt.DhcpServer {
+		err = dhcps.Start(db, virt)
+		if err != nil {
+			return
+		}
+	}
+
+	err = NetworkConf(db, virt)
+	if err != nil {
+		return
+	}
+
+	err = imds.Start(db, virt)
+	if err != nil {
+		return
+	}
+
+	store.RemVirt(virt.Id)
+	store.RemDisks(virt.Id)
+
+	return
+}
+
+// Stop the virtual machine and remove the local data and disks without
+// modifying the database after the instance has moved to another node.
+func MigrateCleanup(db *database.Database, virt *vm.VirtualMachine,
+	migration *instance.Migration) (err error) {
+
+	unitName := paths.GetUnitName(virt.Id)
+
+	logrus.WithFields(logrus.Fields{
+		"id": virt.Id.Hex(),
+	}).Info("qemu: Cleaning up migrated virtual machine")
+
+	_ = imds.Stop(virt)
+	_ = dhcps.Stop(virt)
+
+	exists, err := utils.Exists(paths.GetUnitPath(virt.Id))
+	if err != nil {
+		return
+	}
+
+	if exists {
+		err = systemd.Stop(unitName)
+		if err != nil {
+			return
+		}
+	}
+
+	err = NetworkConfClear(db, virt)
+	if err != nil {
+		return
+	}
+
+	err = removeData(virt)
+	if err != nil {
+		return
+	}
+
+	for _, dsk := range migration.Disks {
+		err = utils.RemoveAll(paths.GetDiskPath(dsk.Id))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
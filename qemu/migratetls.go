@@ -0,0 +1,236 @@
+package qemu
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"path"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/permission"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+const (
+	MigrateClient = "client"
+	MigrateServer = "server"
+)
+
+func migrateCert(parent *x509.Certificate, parentKey *ecdsa.PrivateKey,
+	endpoint, commonName, addr string) (cert *x509.Certificate,
+	certPem, keyPem []byte, certKey *ecdsa.PrivateKey, err error) {
+
+	certKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to generate migrate key"),
+		}
+		return
+	}
+
+	serialLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serial, err := rand.Int(rand.Reader, serialLimit)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to generate migrate serial"),
+		}
+		return
+	}
+
+	certTempl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: commonName,
+		},
+		NotBefore:             time.Now().Add(-1 * time.Hour),
+		NotAfter:              time.Now().Add(48 * time.Hour),
+		BasicConstraintsValid: true,
+		SignatureAlgorithm:    x509.ECDSAWithSHA256,
+	}
+
+	if parent == nil {
+		certTempl.IsCA = true
+		certTempl.MaxPathLenZero = true
+		certTempl.KeyUsage = x509.KeyUsageCertSign |
+			x509.KeyUsageDigitalSignature
+		parent = certTempl
+		parentKey = certKey
+	} else {
+		certTempl.KeyUsage = x509.KeyUsageDigitalSignature |
+			x509.KeyUsageKeyEncipherment
+
+		if endpoint == MigrateServer {
+			certTempl.ExtKeyUsage = []x509.ExtKeyUsage{
+				x509.ExtKeyUsageServerAuth,
+			}
+
+			ip := net.ParseIP(addr)
+			if ip != nil {
+				certTempl.IPAddresses = []net.IP{ip}
+			} else {
+				certTempl.DNSNames = []string{addr}
+			}
+		} else {
+			certTempl.ExtKeyUsage = []x509.ExtKeyUsage{
+				x509.ExtKeyUsageClientAuth,
+			}
+		}
+	}
+
+	certByt, err := x509.CreateCertificate(rand.Reader, certTempl, parent,
+		certKey.Public(), parentKey)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to create migrate certificate"),
+		}
+		return
+	}
+
+	cert, err = x509.ParseCertificate(certByt)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "qemu: Failed to parse migrate certificate"),
+		}
+		return
+	}
+
+	keyByt, err := x509.MarshalECPrivateKey(certKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "qemu: Failed to marshal migrate key"),
+		}
+		return
+	}
+
+	certPem = pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: certByt,
+	})
+	keyPem = pem.EncodeToMemory(&pem.Block{
+		Type:  "EC PRIVATE KEY",
+		Bytes: keyByt,
+	})
+
+	return
+}
+
+// Generate an ephemeral certificate authority and endpoint certificate for
+// a migration. The authority key is discarded after signing and only the
+// authority certificate is returned to be shared with the peer node.
+func InitMigrateTls(virt *vm.VirtualMachine, endpoint, commonName,
+	addr string) (caPem string, err error) {
+
+	tlsPath := paths.GetMigrateTlsPath(virt.Id)
+
+	err = utils.RemoveAll(tlsPath)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(tlsPath, 0700)
+	if err != nil {
+		return
+	}
+
+	caCert, caCertPem, _, caKey, err := migrateCert(
+		nil, nil, "", "Pritunl Cloud Migration", "")
+	if err != nil {
+		return
+	}
+
+	_, certPem, keyPem, _, err := migrateCert(
+		caCert, caKey, endpoint, commonName, addr)
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(path.Join(tlsPath, "local-ca.pem"),
+		string(caCertPem), 0600)
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(path.Join(tlsPath, "ca-cert.pem"),
+		string(caCertPem), 0600)
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(path.Join(tlsPath, endpoint+"-cert.pem"),
+		string(certPem), 0600)
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(path.Join(tlsPath, endpoint+"-key.pem"),
+		string(keyPem), 0600)
+	if err != nil {
+		return
+	}
+
+	err = permission.InitMigrateTls(virt)
+	if err != nil {
+		return
+	}
+
+	caPem = string(caCertPem)
+
+	return
+}
+
+// Trust the certificate authority of the peer node for a migration.
+func SetMigrateTlsPeer(virt *vm.VirtualMachine, peerCaPem string) (
+	err error) {
+
+	tlsPath := paths.GetMigrateTlsPath(virt.Id)
+
+	block, _ := pem.Decode([]byte(peerCaPem))
+	if block == nil || block.Type != "CERTIFICATE" {
+		err = &errortypes.ParseError{
+			errors.New("qemu: Failed to decode migrate peer certificate"),
+		}
+		return
+	}
+
+	peerCa, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "qemu: Failed to parse migrate peer certificate"),
+		}
+		return
+	}
+
+	if !peerCa.IsCA {
+		err = &errortypes.ParseError{
+			errors.New("qemu: Migrate peer certificate is not an authority"),
+		}
+		return
+	}
+
+	localCaPem, err := utils.Read(path.Join(tlsPath, "local-ca.pem"))
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(path.Join(tlsPath, "ca-cert.pem"),
+		localCaPem+string(pem.EncodeToMemory(block)), 0600)
+	if err != nil {
+		return
+	}
+
+	err = permission.InitMigrateTls(virt)
+	if err != nil {
+		return
+	}
+
+	return
+}
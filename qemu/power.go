@@ -89,7 +89,7 @@ func PowerOn(db *database.Database, inst *instance.Instance,
 		return
 	}
 
-	err = writeService(virt)
+	err = writeService(virt, false)
 	if err != nil {
 		return
 	}
@@ -15,6 +15,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
 	"github.com/pritunl/pritunl-cloud/permission"
+	"github.com/pritunl/pritunl-cloud/rbd"
 	"github.com/pritunl/pritunl-cloud/render"
 	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/usb"
@@ -27,6 +28,7 @@ type Disk struct {
 	Index  int
 	File   string
 	Format string
+	Qos    *vm.DiskQos
 }
 
 type Network struct {
@@ -51,10 +53,14 @@ type PciDevice struct {
 }
 
 type DriveDevice struct {
-	Id     string
-	Type   string
-	VgName string
-	LvName string
+	Id       string
+	Type     string
+	VgName   string
+	LvName   string
+	RbdPool  string
+	RbdUser  string
+	RbdImage string
+	Qos      *vm.DiskQos
 }
 
 type IscsiDevice struct {
@@ -97,6 +103,7 @@ type Qemu struct {
 	PciDevices   []*PciDevice
 	DriveDevices []*DriveDevice
 	IscsiDevices []*IscsiDevice
+	Incoming     bool
 }
 
 func (q *Qemu) GetDiskQueues() (queues int) {
@@ -408,6 +415,8 @@ func (q *Qemu) Marshal() (output string, err error) {
 		dskId := fmt.Sprintf("fd_%s", disk.Id)
 		dskFileId := fmt.Sprintf("fdf_%s", disk.Id)
 		dskDevId := fmt.Sprintf("fdd_%s", disk.Id)
+		dskThrottleId := fmt.Sprintf("fdq_%s", disk.Id)
+		throttleGroupId := fmt.Sprintf("tg_%s", disk.Id)
 
 		cmd = append(cmd, "-blockdev")
 		cmd = append(cmd, fmt.Sprintf(
@@ -427,10 +436,25 @@ func (q *Qemu) Marshal() (output string, err error) {
 			dskFileId,
 		))
 
+		cmd = append(cmd, "-object")
+		cmd = append(cmd, fmt.Sprintf(
+			"throttle-group,id=%s%s",
+			throttleGroupId,
+			getThrottleOptions("limits", disk.Qos),
+		))
+
+		cmd = append(cmd, "-blockdev")
+		cmd = append(cmd, fmt.Sprintf(
+			"driver=throttle,node-name=%s,throttle-group=%s,file=%s",
+			dskThrottleId,
+			throttleGroupId,
+			dskId,
+		))
+
 		cmd = append(cmd, "-device")
 		cmd = append(cmd, fmt.Sprintf(
 			"virtio-blk-pci,drive=%s,num-queues=%d,id=%s,bus=diskbus%d",
-			dskId,
+			dskThrottleId,
 			q.GetDiskQueues(),
 			dskDevId,
 			disk.Index,
@@ -442,6 +466,9 @@ func (q *Qemu) Marshal() (output string, err error) {
 		if device.Type == vm.Lvm {
 			drivePth = filepath.Join("/dev/mapper",
 				fmt.Sprintf("%s-%s", device.VgName, device.LvName))
+		} else if device.Type == vm.Rbd {
+			drivePth = rbd.GetUri(device.RbdPool, device.RbdUser,
+				device.RbdImage)
 		} else {
 			drivePth = paths.GetDrivePath(device.Id)
 		}
@@ -461,9 +488,10 @@ func (q *Qemu) Marshal() (output string, err error) {
 		cmd = append(cmd, "-drive")
 		cmd = append(cmd, fmt.Sprintf(
 			"file=%s,media=disk,format=raw,cache=none,"+
-				"discard=on,if=none,id=%s",
+				"discard=on,if=none,id=%s%s",
 			drivePth,
 			dskId,
+			getThrottleOptions("throttling", device.Qos),
 		))
 
 		cmd = append(cmd, "-device")
@@ -575,6 +603,11 @@ func (q *Qemu) Marshal() (output string, err error) {
 	cmd = append(cmd, "-pidfile")
 	cmd = append(cmd, paths.GetPidPath(q.Id))
 
+	if q.Incoming {
+		cmd = append(cmd, "-incoming")
+		cmd = append(cmd, "defer")
+	}
+
 	if q.Tpm {
 		cmd = append(cmd, "-chardev")
 		cmd = append(cmd,
@@ -2,6 +2,7 @@ package qemu
 
 import (
 	"encoding/json"
+	"fmt"
 	"sort"
 	"strings"
 
@@ -85,6 +86,7 @@ func NewQemu(virt *vm.VirtualMachine) (qm *Qemu, err error) {
 			Index:  disk.Index,
 			File:   disk.Path,
 			Format: "qcow2",
+			Qos:    disk.Qos,
 		})
 	}
 
@@ -141,10 +143,14 @@ func NewQemu(virt *vm.VirtualMachine) (qm *Qemu, err error) {
 
 	for _, device := range virt.DriveDevices {
 		qm.DriveDevices = append(qm.DriveDevices, &DriveDevice{
-			Id:     device.Id,
-			Type:   device.Type,
-			VgName: device.VgName,
-			LvName: device.LvName,
+			Id:       device.Id,
+			Type:     device.Type,
+			VgName:   device.VgName,
+			LvName:   device.LvName,
+			RbdPool:  device.RbdPool,
+			RbdUser:  device.RbdUser,
+			RbdImage: device.RbdImage,
+			Qos:      device.Qos,
 		})
 	}
 
@@ -156,3 +162,38 @@ func NewQemu(virt *vm.VirtualMachine) (qm *Qemu, err error) {
 
 	return
 }
+
+func getThrottleOptions(prefix string, qos *vm.DiskQos) (opts string) {
+	if qos.IsZero() {
+		return
+	}
+
+	limits := []struct {
+		name  string
+		value int64
+	}{
+		{"iops-read", int64(qos.ReadIops)},
+		{"iops-write", int64(qos.WriteIops)},
+		{"bps-read", int64(qos.ReadBandwidth) * 1048576},
+		{"bps-write", int64(qos.WriteBandwidth) * 1048576},
+		{"iops-read-max", int64(qos.ReadIopsBurst)},
+		{"iops-write-max", int64(qos.WriteIopsBurst)},
+		{"bps-read-max", int64(qos.ReadBandwidthBurst) * 1048576},
+		{"bps-write-max", int64(qos.WriteBandwidthBurst) * 1048576},
+	}
+
+	for _, limit := range limits {
+		if limit.value == 0 {
+			continue
+		}
+
+		opts += fmt.Sprintf(",%s.%s=%d", prefix, limit.name, limit.value)
+
+		if strings.HasSuffix(limit.name, "-max") && qos.BurstLength > 0 {
+			opts += fmt.Sprintf(",%s.%s-length=%d",
+				prefix, limit.name, qos.BurstLength)
+		}
+	}
+
+	return
+}
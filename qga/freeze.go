@@ -0,0 +1,55 @@
+package qga
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+// Freeze guest filesystems, returns the number of filesystems frozen.
+func FsFreeze(sockPath string) (count int, err error) {
+	cmd := &Command{
+		Execute: "guest-fsfreeze-freeze",
+	}
+
+	resp := &FsFreezeReturn{}
+	err = runCommand(sockPath, cmd, 60*time.Second, resp)
+	if err != nil {
+		return
+	}
+
+	if resp.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qga: Freeze error %s", resp.Error.Desc),
+		}
+		return
+	}
+
+	count = resp.Return
+
+	return
+}
+
+func FsThaw(sockPath string) (count int, err error) {
+	cmd := &Command{
+		Execute: "guest-fsfreeze-thaw",
+	}
+
+	resp := &FsFreezeReturn{}
+	err = runCommand(sockPath, cmd, 30*time.Second, resp)
+	if err != nil {
+		return
+	}
+
+	if resp.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qga: Thaw error %s", resp.Error.Desc),
+		}
+		return
+	}
+
+	count = resp.Return
+
+	return
+}
@@ -15,6 +15,16 @@ type Command struct {
 	Execute string `json:"execute"`
 }
 
+type CommandError struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+type FsFreezeReturn struct {
+	Return int           `json:"return"`
+	Error  *CommandError `json:"error"`
+}
+
 type Address struct {
 	Type    string `json:"ip-address-type"`
 	Address string `json:"ip-address"`
@@ -60,7 +70,9 @@ func (i *Interfaces) GetAddr(macAddr string) (guestAddr, guestAddr6 string) {
 	return
 }
 
-func GetInterfaces(sockPath string) (ifaces *Interfaces, err error) {
+func runCommand(sockPath string, cmd *Command, timeout time.Duration,
+	resp interface{}) (err error) {
+
 	conn, err := net.DialTimeout(
 		"unix",
 		sockPath,
@@ -74,15 +86,11 @@ func GetInterfaces(sockPath string) (ifaces *Interfaces, err error) {
 	}
 	defer conn.Close()
 
-	err = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	err = conn.SetDeadline(time.Now().Add(timeout))
 	if err != nil {
 		return
 	}
 
-	cmd := &Command{
-		Execute: "guest-network-get-interfaces",
-	}
-
 	cmdByte, err := json.Marshal(cmd)
 	if err != nil {
 		err = &errortypes.ParseError{
@@ -112,8 +120,7 @@ func GetInterfaces(sockPath string) (ifaces *Interfaces, err error) {
 	respByt := bytes.Trim(buffer, "\x00")
 	respByt = bytes.TrimSpace(respByt)
 
-	ifaces = &Interfaces{}
-	err = json.Unmarshal(respByt, ifaces)
+	err = json.Unmarshal(respByt, resp)
 	if err != nil {
 		err = &errortypes.ParseError{
 			errors.Wrap(err, "qga: Failed to parse guest agent response"),
@@ -123,3 +130,17 @@ func GetInterfaces(sockPath string) (ifaces *Interfaces, err error) {
 
 	return
 }
+
+func GetInterfaces(sockPath string) (ifaces *Interfaces, err error) {
+	cmd := &Command{
+		Execute: "guest-network-get-interfaces",
+	}
+
+	ifaces = &Interfaces{}
+	err = runCommand(sockPath, cmd, 5*time.Second, ifaces)
+	if err != nil {
+		return
+	}
+
+	return
+}
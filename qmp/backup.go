@@ -12,6 +12,10 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	BackupBitmap = "pritunl-backup"
+)
+
 type driveBackupArgs struct {
 	Device string `json:"device"`
 	Sync   string `json:"sync"`
@@ -19,12 +23,45 @@ type driveBackupArgs struct {
 	Format string `json:"format"`
 }
 
+type driveBackupBitmapArgs struct {
+	Device      string `json:"device"`
+	Sync        string `json:"sync"`
+	Target      string `json:"target"`
+	Format      string `json:"format"`
+	Bitmap      string `json:"bitmap,omitempty"`
+	AutoDismiss bool   `json:"auto-dismiss"`
+}
+
+type dirtyBitmapArgs struct {
+	Node       string `json:"node"`
+	Name       string `json:"name"`
+	Persistent bool   `json:"persistent,omitempty"`
+}
+
+type transactionAction struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+type transactionArgs struct {
+	Actions []*transactionAction `json:"actions"`
+}
+
 type blockDeviceImage struct {
 	Filename string `json:"filename"`
 }
 
 type blockDeviceInserted struct {
-	Image blockDeviceImage `json:"image"`
+	Image        blockDeviceImage `json:"image"`
+	DirtyBitmaps []*dirtyBitmap   `json:"dirty-bitmaps"`
+}
+
+type dirtyBitmap struct {
+	Name         string `json:"name"`
+	Recording    bool   `json:"recording"`
+	Busy         bool   `json:"busy"`
+	Persistent   bool   `json:"persistent"`
+	Inconsistent bool   `json:"inconsistent"`
 }
 
 type blockDevice struct {
@@ -40,6 +77,21 @@ type blockDeviceReturn struct {
 func driveGetDevice(vmId primitive.ObjectID, dsk *disk.Disk) (
 	name string, err error) {
 
+	blockDev, err := driveGetBlock(vmId, dsk)
+	if err != nil {
+		return
+	}
+
+	if blockDev != nil {
+		name = blockDev.Device
+	}
+
+	return
+}
+
+func driveGetBlock(vmId primitive.ObjectID, dsk *disk.Disk) (
+	block *blockDevice, err error) {
+
 	cmd := &Command{
 		Execute: "query-block",
 	}
@@ -74,7 +126,7 @@ func driveGetDevice(vmId primitive.ObjectID, dsk *disk.Disk) (
 		}
 
 		if diskId == dsk.Id {
-			name = blockDev.Device
+			block = blockDev
 			break
 		}
 	}
@@ -195,3 +247,319 @@ func BackupDisk(vmId primitive.ObjectID, dsk *disk.Disk,
 
 	return
 }
+
+func HasBackupBitmap(vmId primitive.ObjectID, dsk *disk.Disk) (
+	exists bool, err error) {
+
+	blockDev, err := driveGetBlock(vmId, dsk)
+	if err != nil {
+		return
+	}
+
+	if blockDev == nil {
+		err = &DiskNotFound{
+			errors.Newf("qmp: Disk not found %s", dsk.Id.Hex()),
+		}
+		return
+	}
+
+	for _, bitmap := range blockDev.Inserted.DirtyBitmaps {
+		if bitmap.Name == BackupBitmap {
+			exists = bitmap.Persistent && !bitmap.Inconsistent &&
+				!bitmap.Busy
+			return
+		}
+	}
+
+	return
+}
+
+func driveBackupBitmap(vmId primitive.ObjectID, dsk *disk.Disk,
+	destPth string, incremental bool) (deviceName string, err error) {
+
+	blockDev, err := driveGetBlock(vmId, dsk)
+	if err != nil {
+		return
+	}
+
+	if blockDev == nil {
+		err = &DiskNotFound{
+			errors.Newf("qmp: Disk not found %s", dsk.Id.Hex()),
+		}
+		return
+	}
+	deviceName = blockDev.Device
+
+	var cmd *Command
+	if incremental {
+		cmd = &Command{
+			Execute: "drive-backup",
+			Arguments: &driveBackupBitmapArgs{
+				Device:      deviceName,
+				Sync:        "incremental",
+				Target:      destPth,
+				Format:      "qcow2",
+				Bitmap:      BackupBitmap,
+				AutoDismiss: false,
+			},
+		}
+	} else {
+		exists := false
+		for _, bitmap := range blockDev.Inserted.DirtyBitmaps {
+			if bitmap.Name == BackupBitmap {
+				exists = true
+				break
+			}
+		}
+
+		bitmapAction := &transactionAction{
+			Type: "block-dirty-bitmap-add",
+			Data: &dirtyBitmapArgs{
+				Node:       deviceName,
+				Name:       BackupBitmap,
+				Persistent: true,
+			},
+		}
+		if exists {
+			bitmapAction = &transactionAction{
+				Type: "block-dirty-bitmap-clear",
+				Data: &dirtyBitmapArgs{
+					Node: deviceName,
+					Name: BackupBitmap,
+				},
+			}
+		}
+
+		cmd = &Command{
+			Execute: "transaction",
+			Arguments: &transactionArgs{
+				Actions: []*transactionAction{
+					bitmapAction,
+					&transactionAction{
+						Type: "drive-backup",
+						Data: &driveBackupBitmapArgs{
+							Device:      deviceName,
+							Sync:        "full",
+							Target:      destPth,
+							Format:      "qcow2",
+							AutoDismiss: false,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	returnData := &CommandReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	time.Sleep(1 * time.Second)
+
+	return
+}
+
+func driveBackupBitmapCheck(vmId primitive.ObjectID, deviceName string) (
+	complete bool, err error) {
+
+	cmd := &Command{
+		Execute: "query-jobs",
+	}
+
+	returnData := &JobStatusReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	if returnData.Return == nil {
+		err = &errortypes.ParseError{
+			errors.Newf("qmp: Return nil"),
+		}
+		return
+	}
+
+	var job *JobStatus
+	for _, status := range returnData.Return {
+		if status.Type == "backup" && status.Id == deviceName {
+			job = status
+			break
+		}
+	}
+
+	if job == nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Backup job not found %s", deviceName),
+		}
+		return
+	}
+
+	if job.Status != "concluded" {
+		return
+	}
+	complete = true
+
+	dismissCmd := &Command{
+		Execute: "job-dismiss",
+		Arguments: &CommandId{
+			Id: deviceName,
+		},
+	}
+
+	dismissData := &CommandReturn{}
+	err = RunCommand(vmId, dismissCmd, dismissData)
+	if err != nil {
+		return
+	}
+
+	if dismissData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", dismissData.Error.Desc),
+		}
+		return
+	}
+
+	if job.Error != "" {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Backup job error %s", job.Error),
+		}
+		return
+	}
+
+	return
+}
+
+// Backup disk while tracking writes in a persistent dirty bitmap. A full
+// backup resets the bitmap, an incremental backup contains only the
+// clusters written since the previous backup and has no backing file.
+func BackupDiskBitmap(vmId primitive.ObjectID, dsk *disk.Disk,
+	destPth string, incremental bool) (err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"disk_id":     dsk.Id.Hex(),
+		"incremental": incremental,
+	}).Info("qmp: Backing up disk with dirty bitmap")
+
+	deviceName, err := driveBackupBitmap(vmId, dsk, destPth, incremental)
+	if err != nil {
+		return
+	}
+
+	for {
+		complete, e := driveBackupBitmapCheck(vmId, deviceName)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if complete {
+			break
+		}
+
+		time.Sleep(3 * time.Second)
+	}
+
+	return
+}
+
+// Start full backups of multiple disks in a single transaction, all
+// backups are consistent to the same point in time.
+func StartBackupDisks(vmId primitive.ObjectID, dsks []*disk.Disk,
+	destPths []string) (deviceNames []string, err error) {
+
+	actions := []*transactionAction{}
+	for i, dsk := range dsks {
+		deviceName, e := driveGetDevice(vmId, dsk)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if deviceName == "" {
+			err = &DiskNotFound{
+				errors.Newf("qmp: Disk not found %s", dsk.Id.Hex()),
+			}
+			return
+		}
+
+		actions = append(actions, &transactionAction{
+			Type: "drive-backup",
+			Data: &driveBackupBitmapArgs{
+				Device:      deviceName,
+				Sync:        "full",
+				Target:      destPths[i],
+				Format:      "qcow2",
+				AutoDismiss: false,
+			},
+		})
+		deviceNames = append(deviceNames, deviceName)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"disks":       len(dsks),
+	}).Info("qmp: Backing up disks in transaction")
+
+	cmd := &Command{
+		Execute: "transaction",
+		Arguments: &transactionArgs{
+			Actions: actions,
+		},
+	}
+
+	returnData := &CommandReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	return
+}
+
+func WaitBackupDisks(vmId primitive.ObjectID, deviceNames []string) (
+	err error) {
+
+	for _, deviceName := range deviceNames {
+		for {
+			complete, e := driveBackupBitmapCheck(vmId, deviceName)
+			if e != nil {
+				if err == nil {
+					err = e
+				}
+				break
+			}
+
+			if complete {
+				break
+			}
+
+			time.Sleep(3 * time.Second)
+		}
+	}
+
+	return
+}
@@ -58,6 +58,8 @@ func AddDisk(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
 	dskId := fmt.Sprintf("fd_%s", dsk.Id.Hex())
 	dskFileId := fmt.Sprintf("fdf_%s", dsk.Id.Hex())
 	dskDevId := fmt.Sprintf("fdd_%s", dsk.Id.Hex())
+	dskThrottleId := getThrottleNodeName(dsk.Id)
+	throttleGroupId := getThrottleGroupId(dsk.Id)
 
 	logrus.WithFields(logrus.Fields{
 		"instance_id": vmId.Hex(),
@@ -152,12 +154,67 @@ func AddDisk(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
 		return
 	}
 
+	cmd = &Command{
+		Execute: "object-add",
+		Arguments: &throttleGroupArgs{
+			QomType: "throttle-group",
+			Id:      throttleGroupId,
+			Limits:  getThrottleLimits(dsk.Qos),
+		},
+	}
+
+	returnData = &CommandReturn{}
+	err = conn.Send(cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil &&
+		!strings.Contains(
+			strings.ToLower(returnData.Error.Desc),
+			"duplicate",
+		) {
+
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	cmd = &Command{
+		Execute: "blockdev-add",
+		Arguments: &blockDevThrottleArgs{
+			Driver:        "throttle",
+			NodeName:      dskThrottleId,
+			ThrottleGroup: throttleGroupId,
+			File:          dskId,
+		},
+	}
+
+	returnData = &CommandReturn{}
+	err = conn.Send(cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil &&
+		!strings.Contains(
+			strings.ToLower(returnData.Error.Desc),
+			"duplicate",
+		) {
+
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
 	cmd = &Command{
 		Execute: "device_add",
 		Arguments: &deviceAddArgs{
 			Id:     dskDevId,
 			Driver: "virtio-blk-pci",
-			Drive:  dskId,
+			Drive:  dskThrottleId,
 			Bus:    fmt.Sprintf("diskbus%d", dsk.Index),
 		},
 	}
@@ -184,6 +241,8 @@ func RemoveDisk(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
 	dskId := fmt.Sprintf("fd_%s", dsk.Id.Hex())
 	dskFileId := fmt.Sprintf("fdf_%s", dsk.Id.Hex())
 	dskDevId := fmt.Sprintf("fdd_%s", dsk.Id.Hex())
+	dskThrottleId := getThrottleNodeName(dsk.Id)
+	throttleGroupId := getThrottleGroupId(dsk.Id)
 
 	logrus.WithFields(logrus.Fields{
 		"instance_id": vmId.Hex(),
@@ -265,6 +324,33 @@ func RemoveDisk(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
 		}
 	}
 
+	cmd = &Command{
+		Execute: "blockdev-del",
+		Arguments: &CommandNode{
+			NodeName: dskThrottleId,
+		},
+	}
+
+	returnData = &CommandReturn{}
+	err = conn.Send(cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil && !strings.Contains(
+		strings.ToLower(returnData.Error.Desc),
+		"process of unplug") && !strings.Contains(
+		strings.ToLower(returnData.Error.Desc),
+		"not found") && !strings.Contains(
+		strings.ToLower(returnData.Error.Desc),
+		"failed to find") {
+
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
 	cmd = &Command{
 		Execute: "blockdev-del",
 		Arguments: &CommandNode{
@@ -319,6 +405,29 @@ func RemoveDisk(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
 		return
 	}
 
+	cmd = &Command{
+		Execute: "object-del",
+		Arguments: &CommandId{
+			Id: throttleGroupId,
+		},
+	}
+
+	returnData = &CommandReturn{}
+	err = conn.Send(cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil && !strings.Contains(
+		strings.ToLower(returnData.Error.Desc),
+		"not found") {
+
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
 	return
 }
 
@@ -413,7 +522,9 @@ func GetDisks(vmId primitive.ObjectID) (info *QemuInfo, disks []*vm.Disk,
 		var idSpl []string
 		if strings.HasPrefix(disk.Device, "disk_") {
 			idSpl = strings.Split(disk.Device, "_")
-		} else if strings.HasPrefix(disk.Inserted.NodeName, "fd_") {
+		} else if strings.HasPrefix(disk.Inserted.NodeName, "fd_") ||
+			strings.HasPrefix(disk.Inserted.NodeName, "fdq_") {
+
 			idSpl = strings.Split(disk.Inserted.NodeName, "_")
 		} else {
 			continue
@@ -0,0 +1,529 @@
+package qmp
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	migrateTlsId   = "tls_migrate"
+	migrateAuthzId = "authz_migrate"
+)
+
+type tlsCredsArgs struct {
+	QomType    string `json:"qom-type"`
+	Id         string `json:"id"`
+	Dir        string `json:"dir"`
+	Endpoint   string `json:"endpoint"`
+	VerifyPeer bool   `json:"verify-peer"`
+}
+
+type authzSimpleArgs struct {
+	QomType  string `json:"qom-type"`
+	Id       string `json:"id"`
+	Identity string `json:"identity"`
+}
+
+type objectDelArgs struct {
+	Id string `json:"id"`
+}
+
+type nbdServerAddr struct {
+	Type string            `json:"type"`
+	Data map[string]string `json:"data"`
+}
+
+type nbdServerStartArgs struct {
+	Addr     nbdServerAddr `json:"addr"`
+	TlsCreds string        `json:"tls-creds"`
+	TlsAuthz string        `json:"tls-authz"`
+}
+
+type blockExportAddArgs struct {
+	Type     string `json:"type"`
+	Id       string `json:"id"`
+	NodeName string `json:"node-name"`
+	Writable bool   `json:"writable"`
+}
+
+type blockdevNbdServer struct {
+	Type string `json:"type"`
+	Host string `json:"host"`
+	Port string `json:"port"`
+}
+
+type blockdevAddNbdArgs struct {
+	Driver      string            `json:"driver"`
+	NodeName    string            `json:"node-name"`
+	Server      blockdevNbdServer `json:"server"`
+	Export      string            `json:"export"`
+	TlsCreds    string            `json:"tls-creds"`
+	TlsHostname string            `json:"tls-hostname"`
+}
+
+type blockdevDelArgs struct {
+	NodeName string `json:"node-name"`
+}
+
+type blockdevMirrorArgs struct {
+	JobId  string `json:"job-id"`
+	Device string `json:"device"`
+	Target string `json:"target"`
+	Sync   string `json:"sync"`
+}
+
+type blockJobCancelArgs struct {
+	Device string `json:"device"`
+}
+
+type migrateArgs struct {
+	Uri string `json:"uri"`
+}
+
+type migrateParametersArgs struct {
+	MaxBandwidth int64 `json:"max-bandwidth"`
+}
+
+type migrateTlsParametersArgs struct {
+	TlsCreds    string `json:"tls-creds"`
+	TlsAuthz    string `json:"tls-authz,omitempty"`
+	TlsHostname string `json:"tls-hostname,omitempty"`
+}
+
+type BlockJob struct {
+	Device string `json:"device"`
+	Type   string `json:"type"`
+	Len    int64  `json:"len"`
+	Offset int64  `json:"offset"`
+	Ready  bool   `json:"ready"`
+}
+
+type blockJobsReturn struct {
+	Return []*BlockJob   `json:"return"`
+	Error  *CommandError `json:"error"`
+}
+
+type MigrateRam struct {
+	Transferred int64 `json:"transferred"`
+	Remaining   int64 `json:"remaining"`
+	Total       int64 `json:"total"`
+}
+
+type MigrateStatus struct {
+	Status    string      `json:"status"`
+	ErrorDesc string      `json:"error-desc"`
+	Ram       *MigrateRam `json:"ram"`
+}
+
+type migrateStatusReturn struct {
+	Return *MigrateStatus `json:"return"`
+	Error  *CommandError  `json:"error"`
+}
+
+func getDiskNodeName(dskId primitive.ObjectID) string {
+	return fmt.Sprintf("fd_%s", dskId.Hex())
+}
+
+func getMirrorJobId(dskId primitive.ObjectID) string {
+	return fmt.Sprintf("mirror_%s", dskId.Hex())
+}
+
+func getMirrorTargetName(dskId primitive.ObjectID) string {
+	return fmt.Sprintf("mirror_target_%s", dskId.Hex())
+}
+
+func runCommandCheck(vmId primitive.ObjectID, cmd *Command) (err error) {
+	returnData := &CommandReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	return
+}
+
+func GetDiskSizes(vmId primitive.ObjectID) (
+	sizes map[primitive.ObjectID]int64, err error) {
+
+	cmd := &Command{
+		Execute: "query-block",
+	}
+
+	returnData := &blockQueryReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	sizes = map[primitive.ObjectID]int64{}
+	for _, disk := range returnData.Return {
+		nodeName := disk.Inserted.NodeName
+		if strings.HasPrefix(nodeName, "fdq_") {
+			nodeName = strings.TrimPrefix(nodeName, "fdq_")
+		} else if strings.HasPrefix(nodeName, "fd_") {
+			nodeName = strings.TrimPrefix(nodeName, "fd_")
+		} else {
+			continue
+		}
+
+		dskId, ok := utils.ParseObjectId(nodeName)
+		if !ok {
+			continue
+		}
+
+		sizes[dskId] = disk.Inserted.Image.VirtualSize
+	}
+
+	return
+}
+
+// Load the migration tls credentials, a previous object from a failed
+// migration is replaced to load the new certificates.
+func addMigrateTls(vmId primitive.ObjectID, endpoint, dir string) (
+	err error) {
+
+	_ = runCommandCheck(vmId, &Command{
+		Execute: "object-del",
+		Arguments: &objectDelArgs{
+			Id: migrateTlsId,
+		},
+	})
+
+	err = runCommandCheck(vmId, &Command{
+		Execute: "object-add",
+		Arguments: &tlsCredsArgs{
+			QomType:    "tls-creds-x509",
+			Id:         migrateTlsId,
+			Dir:        dir,
+			Endpoint:   endpoint,
+			VerifyPeer: true,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Export disks over NBD and listen for the incoming migration on an
+// instance started with deferred incoming. Both listeners are bound to the
+// address and require a tls client certificate matching the identity.
+func MigrateIncoming(vmId primitive.ObjectID, dskIds []primitive.ObjectID,
+	addr, identity, tlsDir string, port, nbdPort int) (err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"address":     addr,
+		"port":        port,
+		"nbd_port":    nbdPort,
+	}).Info("qmp: Starting incoming migration")
+
+	err = addMigrateTls(vmId, "server", tlsDir)
+	if err != nil {
+		return
+	}
+
+	_ = runCommandCheck(vmId, &Command{
+		Execute: "object-del",
+		Arguments: &objectDelArgs{
+			Id: migrateAuthzId,
+		},
+	})
+
+	err = runCommandCheck(vmId, &Command{
+		Execute: "object-add",
+		Arguments: &authzSimpleArgs{
+			QomType:  "authz-simple",
+			Id:       migrateAuthzId,
+			Identity: identity,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	err = runCommandCheck(vmId, &Command{
+		Execute: "nbd-server-start",
+		Arguments: &nbdServerStartArgs{
+			Addr: nbdServerAddr{
+				Type: "inet",
+				Data: map[string]string{
+					"host": addr,
+					"port": strconv.Itoa(nbdPort),
+				},
+			},
+			TlsCreds: migrateTlsId,
+			TlsAuthz: migrateAuthzId,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	for _, dskId := range dskIds {
+		nodeName := getDiskNodeName(dskId)
+
+		err = runCommandCheck(vmId, &Command{
+			Execute: "block-export-add",
+			Arguments: &blockExportAddArgs{
+				Type:     "nbd",
+				Id:       nodeName,
+				NodeName: nodeName,
+				Writable: true,
+			},
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	err = runCommandCheck(vmId, &Command{
+		Execute: "migrate-set-parameters",
+		Arguments: &migrateTlsParametersArgs{
+			TlsCreds: migrateTlsId,
+			TlsAuthz: migrateAuthzId,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	err = runCommandCheck(vmId, &Command{
+		Execute: "migrate-incoming",
+		Arguments: &migrateArgs{
+			Uri: fmt.Sprintf("tcp:%s",
+				net.JoinHostPort(addr, strconv.Itoa(port))),
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Load the client tls credentials used for the disk mirror and migration
+// connections to the destination node.
+func MigrateTls(vmId primitive.ObjectID, tlsDir string) (err error) {
+	err = addMigrateTls(vmId, "client", tlsDir)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func StopNbd(vmId primitive.ObjectID) (err error) {
+	err = runCommandCheck(vmId, &Command{
+		Execute: "nbd-server-stop",
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func MirrorDisk(vmId, dskId primitive.ObjectID, addr string,
+	nbdPort int) (err error) {
+
+	nodeName := getDiskNodeName(dskId)
+	targetName := getMirrorTargetName(dskId)
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"disk_id":     dskId.Hex(),
+		"address":     addr,
+	}).Info("qmp: Mirroring disk for migration")
+
+	_ = runCommandCheck(vmId, &Command{
+		Execute: "blockdev-del",
+		Arguments: &blockdevDelArgs{
+			NodeName: targetName,
+		},
+	})
+
+	err = runCommandCheck(vmId, &Command{
+		Execute: "blockdev-add",
+		Arguments: &blockdevAddNbdArgs{
+			Driver:   "nbd",
+			NodeName: targetName,
+			Server: blockdevNbdServer{
+				Type: "inet",
+				Host: addr,
+				Port: strconv.Itoa(nbdPort),
+			},
+			Export:      nodeName,
+			TlsCreds:    migrateTlsId,
+			TlsHostname: addr,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	err = runCommandCheck(vmId, &Command{
+		Execute: "blockdev-mirror",
+		Arguments: &blockdevMirrorArgs{
+			JobId:  getMirrorJobId(dskId),
+			Device: nodeName,
+			Target: targetName,
+			Sync:   "full",
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetBlockJobs(vmId primitive.ObjectID) (jobs []*BlockJob, err error) {
+	cmd := &Command{
+		Execute: "query-block-jobs",
+	}
+
+	returnData := &blockJobsReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	jobs = returnData.Return
+	if jobs == nil {
+		jobs = []*BlockJob{}
+	}
+
+	return
+}
+
+// Mirror jobs are cancelled after the migration completes, the target
+// remains consistent with the source at the point of cancel.
+func CancelMirror(vmId, dskId primitive.ObjectID) (err error) {
+	err = runCommandCheck(vmId, &Command{
+		Execute: "block-job-cancel",
+		Arguments: &blockJobCancelArgs{
+			Device: getMirrorJobId(dskId),
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Migrate(vmId primitive.ObjectID, addr string, port int,
+	maxBandwidth int64) (err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"address":     addr,
+		"port":        port,
+	}).Info("qmp: Starting migration")
+
+	if maxBandwidth > 0 {
+		err = runCommandCheck(vmId, &Command{
+			Execute: "migrate-set-parameters",
+			Arguments: &migrateParametersArgs{
+				MaxBandwidth: maxBandwidth,
+			},
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	err = runCommandCheck(vmId, &Command{
+		Execute: "migrate-set-parameters",
+		Arguments: &migrateTlsParametersArgs{
+			TlsCreds:    migrateTlsId,
+			TlsHostname: addr,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	err = runCommandCheck(vmId, &Command{
+		Execute: "migrate",
+		Arguments: &migrateArgs{
+			Uri: fmt.Sprintf("tcp:%s",
+				net.JoinHostPort(addr, strconv.Itoa(port))),
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetMigrateStatus(vmId primitive.ObjectID) (
+	status *MigrateStatus, err error) {
+
+	cmd := &Command{
+		Execute: "query-migrate",
+	}
+
+	returnData := &migrateStatusReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	status = returnData.Return
+	if status == nil {
+		status = &MigrateStatus{}
+	}
+
+	return
+}
+
+func MigrateCancel(vmId primitive.ObjectID) (err error) {
+	err = runCommandCheck(vmId, &Command{
+		Execute: "migrate_cancel",
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
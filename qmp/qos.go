@@ -0,0 +1,218 @@
+package qmp
+
+import (
+	"fmt"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/sirupsen/logrus"
+)
+
+type blockIoThrottleArgs struct {
+	Id              string `json:"id"`
+	Bps             int64  `json:"bps"`
+	BpsRd           int64  `json:"bps_rd"`
+	BpsWr           int64  `json:"bps_wr"`
+	Iops            int64  `json:"iops"`
+	IopsRd          int64  `json:"iops_rd"`
+	IopsWr          int64  `json:"iops_wr"`
+	BpsRdMax        int64  `json:"bps_rd_max,omitempty"`
+	BpsWrMax        int64  `json:"bps_wr_max,omitempty"`
+	IopsRdMax       int64  `json:"iops_rd_max,omitempty"`
+	IopsWrMax       int64  `json:"iops_wr_max,omitempty"`
+	BpsRdMaxLength  int64  `json:"bps_rd_max_length,omitempty"`
+	BpsWrMaxLength  int64  `json:"bps_wr_max_length,omitempty"`
+	IopsRdMaxLength int64  `json:"iops_rd_max_length,omitempty"`
+	IopsWrMaxLength int64  `json:"iops_wr_max_length,omitempty"`
+}
+
+// Set disk IO limits of running instance by qdev device id, limits are
+// removed when qos is nil.
+func SetDiskQos(vmId primitive.ObjectID, devId string,
+	qos *vm.DiskQos) (err error) {
+
+	args := &blockIoThrottleArgs{
+		Id: devId,
+	}
+
+	if !qos.IsZero() {
+		args.IopsRd = int64(qos.ReadIops)
+		args.IopsWr = int64(qos.WriteIops)
+		args.BpsRd = int64(qos.ReadBandwidth) * 1048576
+		args.BpsWr = int64(qos.WriteBandwidth) * 1048576
+		args.IopsRdMax = int64(qos.ReadIopsBurst)
+		args.IopsWrMax = int64(qos.WriteIopsBurst)
+		args.BpsRdMax = int64(qos.ReadBandwidthBurst) * 1048576
+		args.BpsWrMax = int64(qos.WriteBandwidthBurst) * 1048576
+
+		burstLength := int64(qos.BurstLength)
+		if args.IopsRdMax != 0 {
+			args.IopsRdMaxLength = burstLength
+		}
+		if args.IopsWrMax != 0 {
+			args.IopsWrMaxLength = burstLength
+		}
+		if args.BpsRdMax != 0 {
+			args.BpsRdMaxLength = burstLength
+		}
+		if args.BpsWrMax != 0 {
+			args.BpsWrMaxLength = burstLength
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"device_id":   devId,
+		"qos":         qos.Key(),
+	}).Info("qmp: Setting disk qos")
+
+	cmd := &Command{
+		Execute:   "block_set_io_throttle",
+		Arguments: args,
+	}
+
+	returnData := &CommandReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	return
+}
+
+type throttleLimits struct {
+	IopsRead           int64 `json:"iops-read"`
+	IopsWrite          int64 `json:"iops-write"`
+	BpsRead            int64 `json:"bps-read"`
+	BpsWrite           int64 `json:"bps-write"`
+	IopsReadMax        int64 `json:"iops-read-max"`
+	IopsWriteMax       int64 `json:"iops-write-max"`
+	BpsReadMax         int64 `json:"bps-read-max"`
+	BpsWriteMax        int64 `json:"bps-write-max"`
+	IopsReadMaxLength  int64 `json:"iops-read-max-length"`
+	IopsWriteMaxLength int64 `json:"iops-write-max-length"`
+	BpsReadMaxLength   int64 `json:"bps-read-max-length"`
+	BpsWriteMaxLength  int64 `json:"bps-write-max-length"`
+	IopsTotal          int64 `json:"iops-total"`
+	BpsTotal           int64 `json:"bps-total"`
+	IopsTotalMax       int64 `json:"iops-total-max"`
+	BpsTotalMax        int64 `json:"bps-total-max"`
+	IopsTotalMaxLength int64 `json:"iops-total-max-length"`
+	BpsTotalMaxLength  int64 `json:"bps-total-max-length"`
+}
+
+type qomSetLimitsArgs struct {
+	Path     string          `json:"path"`
+	Property string          `json:"property"`
+	Value    *throttleLimits `json:"value"`
+}
+
+type throttleGroupArgs struct {
+	QomType string          `json:"qom-type"`
+	Id      string          `json:"id"`
+	Limits  *throttleLimits `json:"limits"`
+}
+
+type blockDevThrottleArgs struct {
+	Driver        string `json:"driver"`
+	NodeName      string `json:"node-name"`
+	ThrottleGroup string `json:"throttle-group"`
+	File          string `json:"file"`
+}
+
+func getThrottleGroupId(dskId primitive.ObjectID) string {
+	return fmt.Sprintf("tg_%s", dskId.Hex())
+}
+
+func getThrottleNodeName(dskId primitive.ObjectID) string {
+	return fmt.Sprintf("fdq_%s", dskId.Hex())
+}
+
+func getThrottleLimits(qos *vm.DiskQos) (limits *throttleLimits) {
+	limits = &throttleLimits{
+		IopsReadMaxLength:  1,
+		IopsWriteMaxLength: 1,
+		BpsReadMaxLength:   1,
+		BpsWriteMaxLength:  1,
+		IopsTotalMaxLength: 1,
+		BpsTotalMaxLength:  1,
+	}
+
+	if qos.IsZero() {
+		return
+	}
+
+	limits.IopsRead = int64(qos.ReadIops)
+	limits.IopsWrite = int64(qos.WriteIops)
+	limits.BpsRead = int64(qos.ReadBandwidth) * 1048576
+	limits.BpsWrite = int64(qos.WriteBandwidth) * 1048576
+	limits.IopsReadMax = int64(qos.ReadIopsBurst)
+	limits.IopsWriteMax = int64(qos.WriteIopsBurst)
+	limits.BpsReadMax = int64(qos.ReadBandwidthBurst) * 1048576
+	limits.BpsWriteMax = int64(qos.WriteBandwidthBurst) * 1048576
+
+	if qos.BurstLength > 0 {
+		burstLength := int64(qos.BurstLength)
+		if limits.IopsReadMax != 0 {
+			limits.IopsReadMaxLength = burstLength
+		}
+		if limits.IopsWriteMax != 0 {
+			limits.IopsWriteMaxLength = burstLength
+		}
+		if limits.BpsReadMax != 0 {
+			limits.BpsReadMaxLength = burstLength
+		}
+		if limits.BpsWriteMax != 0 {
+			limits.BpsWriteMaxLength = burstLength
+		}
+	}
+
+	return
+}
+
+// Set disk IO limits of running instance on the throttle group of a
+// qcow2 disk, all limits are sent to clear limits that were removed.
+func SetDiskGroupQos(vmId primitive.ObjectID, dskId primitive.ObjectID,
+	qos *vm.DiskQos) (err error) {
+
+	limits := getThrottleLimits(qos)
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"disk_id":     dskId.Hex(),
+		"qos":         qos.Key(),
+	}).Info("qmp: Setting disk group qos")
+
+	cmd := &Command{
+		Execute: "qom-set",
+		Arguments: &qomSetLimitsArgs{
+			Path:     "/objects/" + getThrottleGroupId(dskId),
+			Property: "limits",
+			Value:    limits,
+		},
+	}
+
+	returnData := &CommandReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,61 @@
+package qmp
+
+import (
+	"fmt"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/drive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+type blockResizeArgs struct {
+	Device   string `json:"device,omitempty"`
+	NodeName string `json:"node-name,omitempty"`
+	Size     int64  `json:"size"`
+}
+
+// Resize disk of running instance, qcow2 images are grown by qemu and pool
+// volumes must be extended before the resize.
+func ResizeDisk(vmId primitive.ObjectID, dsk *disk.Disk, size int) (
+	err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"disk_id":     dsk.Id.Hex(),
+		"size":        size,
+	}).Info("qmp: Resizing virtual disk")
+
+	args := &blockResizeArgs{
+		Size: int64(size) * 1073741824,
+	}
+
+	if dsk.IsPooled() {
+		args.Device = fmt.Sprintf("pd_%s",
+			drive.GetDriveHashId(dsk.Id.Hex()))
+	} else {
+		args.NodeName = fmt.Sprintf("fd_%s", dsk.Id.Hex())
+	}
+
+	cmd := &Command{
+		Execute:   "block_resize",
+		Arguments: args,
+	}
+
+	returnData := &CommandReturn{}
+	err = RunCommand(vmId, cmd, returnData)
+	if err != nil {
+		return
+	}
+
+	if returnData.Error != nil {
+		err = &errortypes.ApiError{
+			errors.Newf("qmp: Return error %s", returnData.Error.Desc),
+		}
+		return
+	}
+
+	return
+}
@@ -146,7 +146,8 @@ func GetDisks(vmId primitive.ObjectID) (disks []*vm.Disk, err error) {
 		}
 
 		if !strings.HasPrefix(line, "disk_") &&
-			!strings.HasPrefix(line, "fd_") {
+			!strings.HasPrefix(line, "fd_") &&
+			!strings.HasPrefix(line, "fdq_") {
 
 			continue
 		}
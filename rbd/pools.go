@@ -0,0 +1,127 @@
+package rbd
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/pool"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const cephConf = "/etc/ceph/ceph.conf"
+
+var (
+	cachedNodePools          []*pool.Pool
+	cachedNodePoolsTimestamp time.Time
+)
+
+type dfReport struct {
+	Pools []*dfPool `json:"pools"`
+}
+
+type dfPool struct {
+	Name  string       `json:"name"`
+	Stats *dfPoolStats `json:"stats"`
+}
+
+type dfPoolStats struct {
+	BytesUsed int64 `json:"bytes_used"`
+	MaxAvail  int64 `json:"max_avail"`
+}
+
+func updateCapacity(db *database.Database, pools []*pool.Pool) (err error) {
+	reports := map[string]*dfReport{}
+
+	for _, pl := range pools {
+		reprt := reports[pl.CephUser]
+		if reprt == nil {
+			output, e := utils.ExecCombinedOutput("", "ceph", getArgs(pl,
+				"df", "--format", "json")...)
+			if e != nil {
+				err = e
+				return
+			}
+
+			reprt = &dfReport{}
+			err = json.Unmarshal([]byte(output), reprt)
+			if err != nil {
+				err = &errortypes.ParseError{
+					errors.Wrap(err, "rbd: Failed to unmarshal ceph df"),
+				}
+				return
+			}
+
+			reports[pl.CephUser] = reprt
+		}
+
+		for _, cephPool := range reprt.Pools {
+			if cephPool.Name != pl.CephPool || cephPool.Stats == nil {
+				continue
+			}
+
+			pl.Used = cephPool.Stats.BytesUsed
+			pl.Free = cephPool.Stats.MaxAvail
+			pl.Size = pl.Used + pl.Free
+			pl.Allocated = pl.Used
+			pl.CapacityUpdated = time.Now()
+
+			err = pl.CommitFields(db, set.NewSet(
+				"size", "used", "free", "allocated", "capacity_updated"))
+			if err != nil {
+				return
+			}
+			break
+		}
+	}
+
+	return
+}
+
+// Get rbd pools in the zone, rbd pools are available on all nodes that
+// have a ceph config.
+func GetAvailablePools(db *database.Database, zoneId primitive.ObjectID) (
+	availablePools []*pool.Pool, err error) {
+
+	if time.Since(cachedNodePoolsTimestamp) < 30*time.Second {
+		availablePools = cachedNodePools
+		return
+	}
+
+	availablePools = []*pool.Pool{}
+
+	exists, err := utils.Exists(cephConf)
+	if err != nil {
+		return
+	}
+
+	if exists {
+		availablePools, err = pool.GetAll(db, &bson.M{
+			"zone": zoneId,
+			"type": pool.Rbd,
+		})
+		if err != nil {
+			return
+		}
+
+		if len(availablePools) > 0 {
+			e := updateCapacity(db, availablePools)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": e,
+				}).Warn("rbd: Failed to update pool capacity")
+			}
+		}
+	}
+
+	cachedNodePools = availablePools
+	cachedNodePoolsTimestamp = time.Now()
+
+	return
+}
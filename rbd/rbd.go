@@ -0,0 +1,165 @@
+package rbd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/pool"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type imageInfo struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+func getArgs(pl *pool.Pool, args ...string) []string {
+	if pl.CephUser != "" {
+		args = append(args, "--id", pl.CephUser)
+	}
+	return args
+}
+
+func getSpec(pl *pool.Pool, imgName string) string {
+	return fmt.Sprintf("%s/%s", pl.CephPool, imgName)
+}
+
+// Get qemu block uri of a rbd image, the ceph config and keyring of the node
+// are used to connect to the cluster.
+func GetUri(cephPool, cephUser, imgName string) string {
+	uri := fmt.Sprintf("rbd:%s/%s", cephPool, imgName)
+	if cephUser != "" {
+		uri += fmt.Sprintf(":id=%s", cephUser)
+	}
+	return uri
+}
+
+func CreateImage(pl *pool.Pool, imgName string, size int) (err error) {
+	_, err = utils.ExecCombinedOutputLogged(nil, "rbd", getArgs(pl,
+		"create", "--size", fmt.Sprintf("%dG", size),
+		getSpec(pl, imgName))...)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func RemoveImage(pl *pool.Pool, imgName string) (err error) {
+	_, err = utils.ExecCombinedOutputLogged([]string{
+		"No such file",
+	}, "rbd", getArgs(pl, "rm", "--no-progress",
+		getSpec(pl, imgName))...)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func ResizeImage(pl *pool.Pool, imgName string, size int) (err error) {
+	_, err = utils.ExecCombinedOutputLogged(nil, "rbd", getArgs(pl,
+		"resize", "--no-progress", "--size", fmt.Sprintf("%dG", size),
+		getSpec(pl, imgName))...)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetSizeImage(pl *pool.Pool, imgName string) (size int, err error) {
+	output, err := utils.ExecCombinedOutput("", "rbd", getArgs(pl,
+		"info", "--format", "json", getSpec(pl, imgName))...)
+	if err != nil {
+		return
+	}
+
+	info := &imageInfo{}
+	err = json.Unmarshal([]byte(output), info)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "rbd: Failed to parse rbd image info"),
+		}
+		return
+	}
+
+	size = int(math.Round(float64(info.Size) / 1073741824))
+
+	return
+}
+
+// Write qcow2 source image to an existing rbd image.
+func WriteImage(pl *pool.Pool, imgName, sourcePth string) (err error) {
+	_, err = utils.ExecCombinedOutputLogged(nil,
+		"qemu-img", "convert", "-n", "-f", "qcow2", "-O", "raw",
+		sourcePth, GetUri(pl.CephPool, pl.CephUser, imgName))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Read rbd image or snapshot to a qcow2 image.
+func ReadImage(pl *pool.Pool, imgName, destPth string) (err error) {
+	_, err = utils.ExecCombinedOutputLogged(nil,
+		"qemu-img", "convert", "-f", "raw", "-O", "qcow2",
+		GetUri(pl.CephPool, pl.CephUser, imgName), destPth)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func CreateSnapshot(pl *pool.Pool, imgName, snapName string) (err error) {
+	_, err = utils.ExecCombinedOutputLogged(nil, "rbd", getArgs(pl,
+		"snap", "create", "--no-progress",
+		fmt.Sprintf("%s@%s", getSpec(pl, imgName), snapName))...)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func RemoveSnapshot(pl *pool.Pool, imgName, snapName string) (err error) {
+	_, err = utils.ExecCombinedOutputLogged([]string{
+		"No such file",
+	}, "rbd", getArgs(pl, "snap", "rm", "--no-progress",
+		fmt.Sprintf("%s@%s", getSpec(pl, imgName), snapName))...)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Map rbd image to a local block device for host side formatting.
+func MapImage(pl *pool.Pool, imgName string) (devPth string, err error) {
+	output, err := utils.ExecCombinedOutputLogged(nil, "rbd", getArgs(pl,
+		"map", getSpec(pl, imgName))...)
+	if err != nil {
+		return
+	}
+
+	devPth = strings.TrimSpace(output)
+
+	return
+}
+
+func UnmapImage(pl *pool.Pool, imgName string) (err error) {
+	_, err = utils.ExecCombinedOutputLogged([]string{
+		"not mapped",
+	}, "rbd", getArgs(pl, "unmap", getSpec(pl, imgName))...)
+	if err != nil {
+		return
+	}
+
+	return
+}
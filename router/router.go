@@ -465,6 +465,16 @@ func (r *Router) updateState() (err error) {
 			return
 		}
 
+		for _, balnc := range balncs {
+			e = balnc.LoadUnitBackends(db)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"balancer": balnc.Id.Hex(),
+					"error":    e,
+				}).Error("router: Failed to load balancer unit backends")
+			}
+		}
+
 		r.balancers = balncs
 	} else {
 		r.balancers = []*balancer.Balancer{}
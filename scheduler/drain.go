@@ -0,0 +1,381 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/deployment"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/pod"
+	"github.com/pritunl/pritunl-cloud/spec"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+type NodeDrain struct {
+	node     *node.Node
+	nodes    spec.Nodes
+	drain    *node.Drain
+	messages []string
+}
+
+func (d *NodeDrain) migrate(db *database.Database,
+	inst *instance.Instance) (started bool, err error) {
+
+	for _, nde := range d.nodes {
+		errData, e := inst.StartMigration(db, nde.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if errData == nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"source_node": d.node.Id.Hex(),
+				"node":        nde.Id.Hex(),
+			}).Info("scheduler: Migrating instance from draining node")
+
+			started = true
+			return
+		}
+
+		switch errData.Error {
+		case "node_invalid", "node_maintenance", "node_resources",
+			"node_port_conflict", "node_busy":
+
+			continue
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func (d *NodeDrain) reschedule(db *database.Database, unit *pod.Unit,
+	deplys []*deployment.Deployment) (err error) {
+
+	if unit.IsRolling() || unit.Canary != nil {
+		d.messages = append(d.messages, fmt.Sprintf(
+			"Unit %s waiting for rollout", unit.Name))
+		return
+	}
+
+	exists, err := Exists(db, Resource{
+		Pod:  unit.Pod.Id,
+		Unit: unit.Id,
+	})
+	if err != nil {
+		return
+	}
+
+	if exists {
+		return
+	}
+
+	otherDeplys, err := deployment.GetAll(db, &bson.M{
+		"pod":  unit.Pod.Id,
+		"unit": unit.Id,
+		"node": &bson.M{
+			"$ne": d.node.Id,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	active := 0
+	available := 0
+	for _, deply := range otherDeplys {
+		switch deply.State {
+		case deployment.Destroy, deployment.Archive,
+			deployment.Archived, deployment.Restore:
+
+			continue
+		}
+
+		active += 1
+		if deply.State == deployment.Deployed && deply.IsHealthy() {
+			available += 1
+		}
+	}
+
+	create := utils.Min(unit.Count-active, len(deplys))
+	if create > 0 {
+		logrus.WithFields(logrus.Fields{
+			"pod":    unit.Pod.Id.Hex(),
+			"unit":   unit.Id.Hex(),
+			"node":   d.node.Id.Hex(),
+			"create": create,
+		}).Info("scheduler: Scheduling deployments from draining node")
+
+		errData, e := ManualSchedule(db, unit, primitive.NilObjectID, create)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if errData != nil {
+			d.messages = append(d.messages, fmt.Sprintf(
+				"Unit %s: %s", unit.Name, errData.Message))
+		}
+		return
+	}
+
+	if available < unit.Count {
+		return
+	}
+
+	destroyIds := []primitive.ObjectID{}
+	for _, deply := range deplys {
+		destroyIds = append(destroyIds, deply.Id)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"pod":     unit.Pod.Id.Hex(),
+		"unit":    unit.Id.Hex(),
+		"node":    d.node.Id.Hex(),
+		"destroy": len(destroyIds),
+	}).Info("scheduler: Destroying deployments on draining node")
+
+	err = deployment.RemoveMulti(db, unit.Pod.Id, unit.Id, destroyIds)
+	if err != nil {
+		return
+	}
+
+	event.PublishDispatch(db, "pod.change")
+
+	return
+}
+
+func (d *NodeDrain) commit(db *database.Database) (err error) {
+	drain := d.drain
+	prevDrain := d.node.Drain
+
+	if prevDrain != nil {
+		drain.Started = prevDrain.Started
+
+		if drain.State == prevDrain.State &&
+			drain.Total == prevDrain.Total &&
+			drain.Migrating == prevDrain.Migrating &&
+			drain.Rescheduling == prevDrain.Rescheduling &&
+			drain.Remaining == prevDrain.Remaining &&
+			drain.Message == prevDrain.Message {
+
+			return
+		}
+	}
+
+	if drain.Started.IsZero() {
+		drain.Started = time.Now()
+	}
+	drain.Timestamp = time.Now()
+
+	if drain.State == node.Drained {
+		logrus.WithFields(logrus.Fields{
+			"node":     d.node.Id.Hex(),
+			"duration": time.Since(drain.Started).String(),
+		}).Info("scheduler: Node drain complete")
+	}
+
+	err = node.SetDrain(db, d.node.Id, drain)
+	if err != nil {
+		return
+	}
+
+	event.PublishDispatch(db, "node.change")
+
+	return
+}
+
+// Move deployments off a node in maintenance, instances are live migrated
+// when supported otherwise a replacement deployment is scheduled and the
+// original deployment is destroyed once the replacement is healthy.
+func (d *NodeDrain) Process(db *database.Database) (err error) {
+	deplys, err := deployment.GetAll(db, &bson.M{
+		"node": d.node.Id,
+	})
+	if err != nil {
+		return
+	}
+
+	instIds := []primitive.ObjectID{}
+	for _, deply := range deplys {
+		if !deply.Instance.IsZero() {
+			instIds = append(instIds, deply.Instance)
+		}
+	}
+
+	instsMap := map[primitive.ObjectID]*instance.Instance{}
+	if len(instIds) > 0 {
+		insts, e := instance.GetAll(db, &bson.M{
+			"_id": &bson.M{
+				"$in": instIds,
+			},
+		})
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, inst := range insts {
+			instsMap[inst.Id] = inst
+		}
+	}
+
+	reschedule := map[primitive.ObjectID][]*deployment.Deployment{}
+	podIds := []primitive.ObjectID{}
+
+	for _, deply := range deplys {
+		switch deply.State {
+		case deployment.Archive, deployment.Archived:
+			continue
+		case deployment.Destroy:
+			d.drain.Total += 1
+			d.drain.Remaining += 1
+			continue
+		}
+
+		if deply.Kind != deployment.Instance {
+			// Firewall and domain deployments do not run on the node and
+			// image deployments only require the node until complete
+			if deply.Kind == deployment.Image {
+				switch deply.GetImageState() {
+				case deployment.Complete, deployment.Failed:
+					break
+				default:
+					d.drain.Total += 1
+					d.drain.Remaining += 1
+				}
+			}
+			continue
+		}
+
+		d.drain.Total += 1
+
+		inst := instsMap[deply.Instance]
+		if inst == nil {
+			d.drain.Remaining += 1
+			continue
+		}
+
+		if inst.Migration != nil && inst.Migration.IsActive() {
+			d.drain.Migrating += 1
+			continue
+		}
+
+		if inst.Migration == nil ||
+			inst.Migration.State != instance.MigrationFailed ||
+			inst.Migration.Source != d.node.Id {
+
+			started, e := d.migrate(db, inst)
+			if e != nil {
+				err = e
+				return
+			}
+
+			if started {
+				d.drain.Migrating += 1
+				continue
+			}
+		}
+
+		d.drain.Rescheduling += 1
+		if _, ok := reschedule[deply.Unit]; !ok {
+			podIds = append(podIds, deply.Pod)
+		}
+		reschedule[deply.Unit] = append(reschedule[deply.Unit], deply)
+	}
+
+	if len(reschedule) > 0 {
+		pods, e := pod.GetAll(db, &bson.M{
+			"_id": &bson.M{
+				"$in": podIds,
+			},
+		})
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, pd := range pods {
+			for _, unit := range pd.Units {
+				unitDeplys := reschedule[unit.Id]
+				if unitDeplys == nil {
+					continue
+				}
+				unit.Pod = pd
+
+				err = d.reschedule(db, unit, unitDeplys)
+				if err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	if d.drain.Total == 0 {
+		d.drain.State = node.Drained
+	}
+
+	if len(d.messages) > 0 {
+		d.drain.Message = d.messages[0]
+	}
+
+	err = d.commit(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func NewNodeDrain(nde *node.Node, ndes []*node.Node) (drn *NodeDrain) {
+	nodes := spec.Nodes{}
+	for _, n := range ndes {
+		if n.Id == nde.Id || n.Zone != nde.Zone || n.Maintenance ||
+			!n.IsHypervisor() || !n.IsOnline() {
+
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	nodes.Sort()
+
+	drn = &NodeDrain{
+		node:  nde,
+		nodes: nodes,
+		drain: &node.Drain{
+			State: node.Draining,
+		},
+	}
+
+	return
+}
+
+func Drain(db *database.Database) (err error) {
+	ndes, err := node.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	for _, nde := range ndes {
+		if !nde.Maintenance {
+			continue
+		}
+
+		drn := NewNodeDrain(nde, ndes)
+		err = drn.Process(db)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,258 @@
+package scheduler
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/deployment"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/spec"
+)
+
+type placementCounts map[primitive.ObjectID]map[primitive.ObjectID]int
+
+func (p placementCounts) get(unitId, id primitive.ObjectID) int {
+	counts := p[unitId]
+	if counts == nil {
+		return 0
+	}
+	return counts[id]
+}
+
+func (p placementCounts) add(unitId, id primitive.ObjectID) {
+	counts := p[unitId]
+	if counts == nil {
+		counts = map[primitive.ObjectID]int{}
+		p[unitId] = counts
+	}
+	counts[id] += 1
+}
+
+type Placement struct {
+	unitId     primitive.ObjectID
+	instance   *spec.Instance
+	nodes      spec.Nodes
+	nodeCounts placementCounts
+	zoneCounts placementCounts
+	unitCounts map[primitive.ObjectID]int
+}
+
+func (p *Placement) targets(affinity spec.Affinity) []primitive.ObjectID {
+	if len(affinity.Units) == 0 {
+		return []primitive.ObjectID{p.unitId}
+	}
+	return affinity.Units
+}
+
+func (p *Placement) count(topology string, unitId primitive.ObjectID,
+	nde *node.Node) int {
+
+	if topology == spec.TopologyZone {
+		return p.zoneCounts.get(unitId, nde.Zone)
+	}
+	return p.nodeCounts.get(unitId, nde.Id)
+}
+
+func (p *Placement) minCount(topology string) (minimum int) {
+	domains := set.NewSet()
+	minimum = -1
+
+	for _, nde := range p.nodes {
+		domain := nde.Id
+		if topology == spec.TopologyZone {
+			domain = nde.Zone
+		}
+
+		if domains.Contains(domain) {
+			continue
+		}
+		domains.Add(domain)
+
+		count := p.count(topology, p.unitId, nde)
+		if minimum == -1 || count < minimum {
+			minimum = count
+		}
+	}
+
+	if minimum == -1 {
+		minimum = 0
+	}
+
+	return
+}
+
+func (p *Placement) violations(nde *node.Node, mode string) (
+	violations int) {
+
+	for _, affinity := range p.instance.Affinity {
+		if affinity.Mode != mode {
+			continue
+		}
+
+		total := 0
+		exists := false
+		for _, unitId := range p.targets(affinity) {
+			total += p.count(affinity.Topology, unitId, nde)
+			if p.unitCounts[unitId] > 0 {
+				exists = true
+			}
+		}
+
+		switch affinity.Kind {
+		case spec.AffinityKind:
+			if exists && total == 0 {
+				violations += 1
+			}
+			break
+		case spec.AntiAffinityKind:
+			if total > 0 {
+				violations += 1
+			}
+			break
+		}
+	}
+
+	for _, spread := range p.instance.Spread {
+		if spread.Mode != mode {
+			continue
+		}
+
+		count := p.count(spread.Topology, p.unitId, nde)
+		if count+1-p.minCount(spread.Topology) > spread.MaxSkew {
+			violations += 1
+		}
+	}
+
+	return
+}
+
+// Check if a deployment can be placed on the node without breaking any
+// hard constraints.
+func (p *Placement) Allowed(nde *node.Node) bool {
+	return p.violations(nde, spec.Hard) == 0
+}
+
+func (p *Placement) Violations(nde *node.Node) int {
+	return p.violations(nde, spec.Soft)
+}
+
+func (p *Placement) Add(nde *node.Node) {
+	p.nodeCounts.add(p.unitId, nde.Id)
+	p.zoneCounts.add(p.unitId, nde.Zone)
+	p.unitCounts[p.unitId] += 1
+}
+
+func (p *Placement) Copy() (plcmt *Placement) {
+	plcmt = &Placement{
+		unitId:     p.unitId,
+		instance:   p.instance,
+		nodes:      p.nodes,
+		nodeCounts: placementCounts{},
+		zoneCounts: placementCounts{},
+		unitCounts: map[primitive.ObjectID]int{},
+	}
+
+	for unitId, counts := range p.nodeCounts {
+		plcmt.nodeCounts[unitId] = map[primitive.ObjectID]int{}
+		for id, count := range counts {
+			plcmt.nodeCounts[unitId][id] = count
+		}
+	}
+	for unitId, counts := range p.zoneCounts {
+		plcmt.zoneCounts[unitId] = map[primitive.ObjectID]int{}
+		for id, count := range counts {
+			plcmt.zoneCounts[unitId][id] = count
+		}
+	}
+	for unitId, count := range p.unitCounts {
+		plcmt.unitCounts[unitId] = count
+	}
+
+	return
+}
+
+func NewPlacement(db *database.Database, unitId primitive.ObjectID,
+	inst *spec.Instance, nodes spec.Nodes) (plcmt *Placement, err error) {
+
+	plcmt = &Placement{
+		unitId:     unitId,
+		instance:   inst,
+		nodes:      nodes,
+		nodeCounts: placementCounts{},
+		zoneCounts: placementCounts{},
+		unitCounts: map[primitive.ObjectID]int{},
+	}
+
+	unitIds := set.NewSet(unitId)
+	for _, affinity := range inst.Affinity {
+		for _, affUnitId := range affinity.Units {
+			unitIds.Add(affUnitId)
+		}
+	}
+
+	unitIdsList := []primitive.ObjectID{}
+	for unitIdInf := range unitIds.Iter() {
+		unitIdsList = append(unitIdsList, unitIdInf.(primitive.ObjectID))
+	}
+
+	deplys, err := deployment.GetAll(db, &bson.M{
+		"unit": &bson.M{
+			"$in": unitIdsList,
+		},
+		"state": &bson.M{
+			"$nin": []string{
+				deployment.Destroy,
+				deployment.Archive,
+				deployment.Archived,
+			},
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	for _, deply := range deplys {
+		if deply.Node.IsZero() {
+			continue
+		}
+
+		plcmt.nodeCounts.add(deply.Unit, deply.Node)
+		plcmt.zoneCounts.add(deply.Unit, deply.Zone)
+		plcmt.unitCounts[deply.Unit] += 1
+	}
+
+	return
+}
+
+// Recheck hard constraints when a ticket is consumed, earlier tickets may
+// have placed deployments since the unit was scheduled.
+func CheckPlacement(db *database.Database, schd *Scheduler,
+	spc *spec.Spec, nde *node.Node) (allowed bool, err error) {
+
+	if spc.Instance == nil || !spc.Instance.HasPlacement() {
+		allowed = true
+		return
+	}
+
+	ndes, err := node.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	nodes := spec.Nodes{}
+	for _, n := range ndes {
+		if _, ok := schd.Tickets[n.Id]; ok {
+			nodes = append(nodes, n)
+		}
+	}
+
+	plcmt, err := NewPlacement(db, schd.Id.Unit, spc.Instance, nodes)
+	if err != nil {
+		return
+	}
+
+	allowed = plcmt.Allowed(nde)
+
+	return
+}
@@ -0,0 +1,234 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/deployment"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/pod"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+type RollingUnit struct {
+	unit    *pod.Unit
+	rollout *pod.Rollout
+}
+
+func (r *RollingUnit) pause(db *database.Database, msg string) (err error) {
+	logrus.WithFields(logrus.Fields{
+		"pod":     r.unit.Pod.Id.Hex(),
+		"unit":    r.unit.Id.Hex(),
+		"commit":  r.rollout.Commit.Hex(),
+		"batch":   r.rollout.Batch,
+		"message": msg,
+	}).Warning("scheduler: Pausing unit rollout")
+
+	r.rollout.State = pod.RolloutPaused
+	r.rollout.Message = msg
+
+	err = r.unit.CommitRollout(db)
+	if err != nil {
+		return
+	}
+
+	event.PublishDispatch(db, "pod.change")
+
+	return
+}
+
+func (r *RollingUnit) complete(db *database.Database) (err error) {
+	logrus.WithFields(logrus.Fields{
+		"pod":    r.unit.Pod.Id.Hex(),
+		"unit":   r.unit.Id.Hex(),
+		"commit": r.rollout.Commit.Hex(),
+		"batch":  r.rollout.Batch,
+	}).Info("scheduler: Unit rollout complete")
+
+	r.rollout.State = pod.RolloutComplete
+	r.rollout.Message = ""
+
+	err = r.unit.CommitRollout(db)
+	if err != nil {
+		return
+	}
+
+	event.PublishDispatch(db, "pod.change")
+
+	return
+}
+
+func (r *RollingUnit) Process(db *database.Database) (err error) {
+	if r.rollout == nil || r.rollout.State != pod.RolloutActive {
+		return
+	}
+
+	if r.unit.Kind != deployment.Instance {
+		err = r.complete(db)
+		return
+	}
+
+	exists, err := Exists(db, Resource{
+		Pod:  r.unit.Pod.Id,
+		Unit: r.unit.Id,
+	})
+	if err != nil {
+		return
+	}
+
+	if exists {
+		return
+	}
+
+	deplys, err := deployment.GetAll(db, &bson.M{
+		"pod":  r.unit.Pod.Id,
+		"unit": r.unit.Id,
+	})
+	if err != nil {
+		return
+	}
+
+	healthTimeout := time.Duration(
+		settings.System.RolloutHealthTimeout) * time.Second
+
+	newDeplys := []*deployment.Deployment{}
+	oldDeplys := []*deployment.Deployment{}
+	terminating := 0
+	available := 0
+
+	for _, deply := range deplys {
+		switch deply.State {
+		case deployment.Destroy:
+			terminating += 1
+			continue
+		case deployment.Archive, deployment.Archived, deployment.Restore:
+			continue
+		}
+
+		if deply.Spec == r.rollout.Commit ||
+			deply.NewSpec == r.rollout.Commit {
+
+			newDeplys = append(newDeplys, deply)
+		} else {
+			oldDeplys = append(oldDeplys, deply)
+		}
+
+		if deply.State == deployment.Deployed && deply.IsHealthy() {
+			available += 1
+		}
+	}
+
+	for _, deply := range newDeplys {
+		if deply.State == deployment.Deployed && deply.IsHealthy() {
+			continue
+		}
+
+		if time.Since(deply.Timestamp) > healthTimeout {
+			err = r.pause(db, fmt.Sprintf(
+				"Deployment %s unhealthy after %d seconds",
+				deply.Id.Hex(), settings.System.RolloutHealthTimeout,
+			))
+			return
+		}
+
+		return
+	}
+
+	if len(oldDeplys) == 0 {
+		err = r.complete(db)
+		return
+	}
+
+	minAvailable := utils.Max(0, r.unit.Count-r.unit.MaxUnavailable)
+	destroyIds := []primitive.ObjectID{}
+
+	for _, deply := range oldDeplys {
+		if deply.State != deployment.Deployed || !deply.IsHealthy() {
+			destroyIds = append(destroyIds, deply.Id)
+		}
+	}
+
+	for _, deply := range oldDeplys {
+		if available <= minAvailable {
+			break
+		}
+
+		if deply.State == deployment.Deployed && deply.IsHealthy() {
+			destroyIds = append(destroyIds, deply.Id)
+			available -= 1
+		}
+	}
+
+	total := len(newDeplys) + len(oldDeplys) - len(destroyIds) + terminating
+	create := utils.Min(
+		r.unit.Count+r.unit.MaxSurge-total,
+		r.unit.Count-len(newDeplys),
+	)
+
+	if len(destroyIds) == 0 && create <= 0 {
+		return
+	}
+
+	if len(destroyIds) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"pod":     r.unit.Pod.Id.Hex(),
+			"unit":    r.unit.Id.Hex(),
+			"commit":  r.rollout.Commit.Hex(),
+			"batch":   r.rollout.Batch,
+			"destroy": len(destroyIds),
+		}).Info("scheduler: Destroying rollout deployments")
+
+		err = deployment.RemoveMulti(
+			db, r.unit.Pod.Id, r.unit.Id, destroyIds)
+		if err != nil {
+			return
+		}
+	}
+
+	if create > 0 {
+		logrus.WithFields(logrus.Fields{
+			"pod":    r.unit.Pod.Id.Hex(),
+			"unit":   r.unit.Id.Hex(),
+			"commit": r.rollout.Commit.Hex(),
+			"batch":  r.rollout.Batch + 1,
+			"create": create,
+		}).Info("scheduler: Scheduling rollout deployments")
+
+		errData, e := ManualSchedule(db, r.unit, r.rollout.Commit, create)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if errData != nil {
+			err = r.pause(db, errData.Message)
+			return
+		}
+
+		r.rollout.Batch += 1
+	}
+
+	r.rollout.Message = ""
+	err = r.unit.CommitRollout(db)
+	if err != nil {
+		return
+	}
+
+	event.PublishDispatch(db, "pod.change")
+
+	return
+}
+
+func NewRollingUnit(unit *pod.Unit) (rollUnit *RollingUnit) {
+	rollUnit = &RollingUnit{
+		unit:    unit,
+		rollout: unit.Rollout,
+	}
+
+	return
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/deployment"
 	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/pod"
 	"github.com/pritunl/pritunl-cloud/spec"
 	"github.com/sirupsen/logrus"
@@ -65,7 +66,16 @@ func (u *InstanceUnit) Schedule(db *database.Database, count int) (err error) {
 	if err != nil {
 		return
 	}
-	u.nodes = ndes
+
+	maintenanceCount := 0
+	u.nodes = spec.Nodes{}
+	for _, nde := range ndes {
+		if nde.Maintenance {
+			maintenanceCount += 1
+			continue
+		}
+		u.nodes = append(u.nodes, nde)
+	}
 
 	if len(u.nodes) == 0 {
 		logrus.WithFields(logrus.Fields{
@@ -74,6 +84,7 @@ func (u *InstanceUnit) Schedule(db *database.Database, count int) (err error) {
 			"shape":               u.spec.Instance.Shape.Hex(),
 			"offline_count":       offlineCount,
 			"missing_mount_count": noMountCount,
+			"maintenance_count":   maintenanceCount,
 		}).Error("scheduler: Failed to find nodes to schedule")
 		return
 	}
@@ -88,7 +99,31 @@ func (u *InstanceUnit) Schedule(db *database.Database, count int) (err error) {
 	primaryNodes, backupNodes := u.processNodes(u.nodes)
 
 	var tickets TicketsStore
-	if u.count < len(primaryNodes) {
+	if u.spec.Instance.HasPlacement() {
+		placed := 0
+		tickets, placed, err = u.schedulePlacement(db)
+		if err != nil {
+			return
+		}
+
+		if placed < u.count {
+			logrus.WithFields(logrus.Fields{
+				"pod":    u.unit.Pod.Id.Hex(),
+				"unit":   u.unit.Id.Hex(),
+				"count":  u.count,
+				"placed": placed,
+			}).Error("scheduler: Placement constraints limit unit count")
+
+			if placed == 0 {
+				return
+			}
+
+			schd.Count = placed
+			if overrideCount != 0 {
+				schd.OverrideCount = len(u.unit.Deployments) + placed
+			}
+		}
+	} else if u.count < len(primaryNodes) {
 		tickets, err = u.scheduleSimple(db, primaryNodes, backupNodes)
 		if err != nil {
 			return
@@ -319,6 +354,84 @@ func (u *InstanceUnit) scheduleComplex(db *database.Database,
 	return
 }
 
+func (u *InstanceUnit) selectNode(plcmt *Placement) (selected *node.Node) {
+	selectedViolations := 0
+	selectedPrimary := false
+
+	u.nodes.Sort()
+
+	for _, nde := range u.nodes {
+		if !plcmt.Allowed(nde) {
+			continue
+		}
+
+		violations := plcmt.Violations(nde)
+		primary := nde.SizeResource(u.spec.Instance.Memory,
+			u.spec.Instance.Processors)
+
+		if selected == nil || violations < selectedViolations ||
+			(violations == selectedViolations &&
+				primary && !selectedPrimary) {
+
+			selected = nde
+			selectedViolations = violations
+			selectedPrimary = primary
+		}
+	}
+
+	return
+}
+
+// Each offset round is a complete placement that satisfies the hard
+// constraints, soft constraints are preferred over node resources.
+func (u *InstanceUnit) schedulePlacement(db *database.Database) (
+	tickets TicketsStore, placed int, err error) {
+
+	plcmt, err := NewPlacement(db, u.unit.Id, u.spec.Instance, u.nodes)
+	if err != nil {
+		return
+	}
+
+	tickets = TicketsStore{}
+	offset := 0
+
+	for i := 0; i < OffsetCount; i++ {
+		roundPlcmt := plcmt.Copy()
+		count := 0
+
+		for count < u.count {
+			nde := u.selectNode(roundPlcmt)
+			if nde == nil {
+				break
+			}
+
+			tickets[nde.Id] = append(tickets[nde.Id], &Ticket{
+				Node:   nde.Id,
+				Offset: offset,
+			})
+			roundPlcmt.Add(nde)
+			count += 1
+
+			if i == 0 {
+				nde.CpuUnitsRes += u.spec.Instance.Processors
+				nde.MemoryUnitsRes += u.spec.Instance.MemoryUnits()
+			}
+		}
+
+		if i == 0 {
+			placed = count
+		}
+
+		if offset == 0 {
+			offset += OffsetInit
+		} else {
+			offset += OffsetInc
+		}
+	}
+
+	return
+}
+
 func NewInstanceUnit(unit *pod.Unit, spc *spec.Commit) (
 	instUnit *InstanceUnit) {
 
@@ -158,6 +158,20 @@ func Schedule(db *database.Database, unit *pod.Unit) (err error) {
 	return
 }
 
+func Rollout(db *database.Database, unit *pod.Unit) (err error) {
+	if unit.Rollout == nil || unit.Rollout.State != pod.RolloutActive {
+		return
+	}
+
+	rollUnit := NewRollingUnit(unit)
+	err = rollUnit.Process(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func ManualSchedule(db *database.Database, unit *pod.Unit,
 	specId primitive.ObjectID, count int) (
 	errData *errortypes.ErrorData, err error) {
@@ -4,4 +4,6 @@ const (
 	AWS         = "aws"
 	Cloudflare  = "cloudflare"
 	OracleCloud = "oracle_cloud"
+	Encryption  = "encryption"
+	Signing     = "signing"
 )
@@ -0,0 +1,89 @@
+package secret
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+func (c *Secret) loadPublicKey() (key *rsa.PublicKey, err error) {
+	block, _ := pem.Decode([]byte(c.PublicKey))
+	if block == nil {
+		err = &errortypes.ParseError{
+			errors.New("secret: Failed to decode public key"),
+		}
+		return
+	}
+
+	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "secret: Failed to parse public key"),
+		}
+		return
+	}
+
+	key, ok := pubKey.(*rsa.PublicKey)
+	if !ok {
+		err = &errortypes.ParseError{
+			errors.New("secret: Invalid public key type"),
+		}
+		return
+	}
+
+	return
+}
+
+// Encrypt data key with the secret public key
+func (c *Secret) WrapKey(dataKey []byte) (wrapped string, err error) {
+	pubKey, err := c.loadPublicKey()
+	if err != nil {
+		return
+	}
+
+	ciphertext, err := rsa.EncryptOAEP(
+		sha256.New(), rand.Reader, pubKey, dataKey, nil)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "secret: Failed to encrypt data key"),
+		}
+		return
+	}
+
+	wrapped = base64.StdEncoding.EncodeToString(ciphertext)
+
+	return
+}
+
+// Decrypt data key with the secret private key
+func (c *Secret) UnwrapKey(wrapped string) (dataKey []byte, err error) {
+	privKey, _, err := loadPrivateKey(c)
+	if err != nil {
+		return
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "secret: Failed to decode data key"),
+		}
+		return
+	}
+
+	dataKey, err = rsa.DecryptOAEP(
+		sha256.New(), rand.Reader, privKey, ciphertext, nil)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "secret: Failed to decrypt data key"),
+		}
+		return
+	}
+
+	return
+}
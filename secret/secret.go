@@ -45,6 +45,28 @@ func (c *Secret) Validate(db *database.Database) (
 		break
 	case OracleCloud:
 		break
+	case Encryption:
+		c.Key = ""
+		c.Value = ""
+		c.Region = ""
+
+		break
+	case Signing:
+		c.Region = ""
+		c.PublicKey = ""
+		c.PrivateKey = ""
+
+		fingerprints, e := c.parseSigningKey()
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "signing_key_invalid",
+				Message: "Signing key must be an armored PGP public key",
+			}
+			return
+		}
+		c.Key = strings.Join(fingerprints, ",")
+
+		return
 	default:
 		errData = &errortypes.ErrorData{
 			Error:   "invalid_secret_type",
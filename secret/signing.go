@@ -0,0 +1,79 @@
+package secret
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"golang.org/x/crypto/openpgp"
+)
+
+// Parse the armored PGP public key stored in the value of a signing secret
+// and return the primary key fingerprints.
+func (c *Secret) parseSigningKey() (fingerprints []string, err error) {
+	keyring, err := c.GetKeyring()
+	if err != nil {
+		return
+	}
+
+	fingerprints = []string{}
+	for _, entity := range keyring {
+		if entity.PrivateKey != nil {
+			err = &errortypes.ParseError{
+				errors.New("secret: Signing key contains private key"),
+			}
+			return
+		}
+
+		fingerprints = append(fingerprints,
+			fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint))
+	}
+
+	return
+}
+
+func (c *Secret) GetKeyring() (keyring openpgp.EntityList, err error) {
+	if c.Type != Signing {
+		err = &errortypes.TypeError{
+			errors.New("secret: Secret is not a signing key"),
+		}
+		return
+	}
+
+	keyring, err = openpgp.ReadArmoredKeyRing(
+		strings.NewReader(strings.TrimSpace(c.Value)))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "secret: Failed to parse signing key"),
+		}
+		return
+	}
+
+	if len(keyring) == 0 {
+		err = &errortypes.ParseError{
+			errors.New("secret: Signing key is empty"),
+		}
+		return
+	}
+
+	return
+}
+
+// GetAllSigningOrg returns the signing key secrets of the organization.
+func GetAllSigningOrg(db *database.Database, orgId primitive.ObjectID) (
+	secrs []*Secret, err error) {
+
+	secrs, err = GetAll(db, &bson.M{
+		"organization": orgId,
+		"type":         Signing,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
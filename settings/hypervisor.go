@@ -45,6 +45,14 @@ type hypervisor struct {
 	DnsServerSecondary  string `bson:"dns_server_secondary" default:"8.8.4.4"`
 	DnsServerPrimary6   string `bson:"dns_server_primary6" default:"2001:4860:4860::8888"`
 	DnsServerSecondary6 string `bson:"dns_server_secondary6" default:"2001:4860:4860::8844"`
+	MigrationPort       int    `bson:"migration_port" default:"4810"`
+	MigrationNbdPort    int    `bson:"migration_nbd_port" default:"4811"`
+	MigrationSpeed      int    `bson:"migration_speed"`
+	MigrationTimeout    int    `bson:"migration_timeout" default:"1800"`
+	DiskImportMaxSize   int    `bson:"disk_import_max_size" default:"1024"`
+	NoImagePeer         bool   `bson:"no_image_peer"`
+	ImagePeerPort       int    `bson:"image_peer_port" default:"4812"`
+	ImagePeerMax        int    `bson:"image_peer_max" default:"3"`
 }
 
 func newHypervisor() interface{} {
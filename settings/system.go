@@ -3,26 +3,29 @@ package settings
 var System *system
 
 type system struct {
-	Id                   string `bson:"_id"`
-	Name                 string `bson:"name"`
-	DatabaseVersion      int    `bson:"database_version"`
-	Demo                 bool   `bson:"demo"`
-	License              string `bson:"license"`
-	AdminCookieAuthKey   []byte `bson:"admin_cookie_auth_key"`
-	AdminCookieCryptoKey []byte `bson:"admin_cookie_crypto_key"`
-	UserCookieAuthKey    []byte `bson:"user_cookie_auth_key"`
-	UserCookieCryptoKey  []byte `bson:"user_cookie_crypto_key"`
-	NodeTimestampTtl     int    `bson:"node_timestamp_ttl" default:"15"`
-	InstanceTimestampTtl int    `bson:"instance_timestamp_ttl" default:"10"`
-	AcmeKeyAlgorithm     string `bson:"acme_key_algorithm" default:"rsa"`
-	DiskBackupWindow     int    `bson:"disk_backup_window" default:"6"`
-	DiskBackupTime       int    `bson:"disk_backup_time" default:"10"`
-	PlannerBatchSize     int    `bson:"planner_batch_size" default:"10"`
-	OracleApiRetryRate   int    `bson:"oracle_api_retry_rate" default:"1"`
-	OracleApiRetryCount  int    `bson:"oracle_api_retry_count" default:"120"`
-	TwilioAccount        string `bson:"twilio_account"`
-	TwilioSecret         string `bson:"twilio_secret"`
-	TwilioNumber         string `bson:"twilio_number"`
+	Id                     string `bson:"_id"`
+	Name                   string `bson:"name"`
+	DatabaseVersion        int    `bson:"database_version"`
+	Demo                   bool   `bson:"demo"`
+	License                string `bson:"license"`
+	AdminCookieAuthKey     []byte `bson:"admin_cookie_auth_key"`
+	AdminCookieCryptoKey   []byte `bson:"admin_cookie_crypto_key"`
+	UserCookieAuthKey      []byte `bson:"user_cookie_auth_key"`
+	UserCookieCryptoKey    []byte `bson:"user_cookie_crypto_key"`
+	ImagePeerKey           []byte `bson:"image_peer_key"`
+	NodeTimestampTtl       int    `bson:"node_timestamp_ttl" default:"15"`
+	InstanceTimestampTtl   int    `bson:"instance_timestamp_ttl" default:"10"`
+	AcmeKeyAlgorithm       string `bson:"acme_key_algorithm" default:"rsa"`
+	DiskBackupWindow       int    `bson:"disk_backup_window" default:"6"`
+	DiskBackupTime         int    `bson:"disk_backup_time" default:"10"`
+	DiskBackupFullInterval int    `bson:"disk_backup_full_interval" default:"7"`
+	PlannerBatchSize       int    `bson:"planner_batch_size" default:"10"`
+	RolloutHealthTimeout   int    `bson:"rollout_health_timeout" default:"600"`
+	OracleApiRetryRate     int    `bson:"oracle_api_retry_rate" default:"1"`
+	OracleApiRetryCount    int    `bson:"oracle_api_retry_count" default:"120"`
+	TwilioAccount          string `bson:"twilio_account"`
+	TwilioSecret           string `bson:"twilio_secret"`
+	TwilioNumber           string `bson:"twilio_number"`
 }
 
 func newSystem() interface{} {
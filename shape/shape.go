@@ -8,6 +8,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/pritunl/pritunl-cloud/zone"
 )
 
@@ -22,6 +23,9 @@ type Shape struct {
 	Flexible         bool               `bson:"flexible" json:"flexible"`
 	DiskType         string             `bson:"disk_type" json:"disk_type"`
 	DiskPool         primitive.ObjectID `bson:"disk_pool" json:"disk_pool"`
+	DiskQos          *vm.DiskQos        `bson:"disk_qos,omitempty" json:"disk_qos"`
+	NetworkIngress   int                `bson:"network_ingress" json:"network_ingress"`
+	NetworkEgress    int                `bson:"network_egress" json:"network_egress"`
 	Memory           int                `bson:"memory" json:"memory"`
 	Processors       int                `bson:"processors" json:"processors"`
 	NodeCount        int                `bson:"-" json:"node_count"`
@@ -55,6 +59,25 @@ func (s *Shape) Validate(db *database.Database) (
 		return
 	}
 
+	if s.NetworkIngress < 0 || s.NetworkEgress < 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "network_limit_invalid",
+			Message: "Network bandwidth limit cannot be negative",
+		}
+		return
+	}
+
+	if s.DiskQos != nil {
+		errData = s.DiskQos.Validate()
+		if errData != nil {
+			return
+		}
+
+		if s.DiskQos.IsZero() {
+			s.DiskQos = nil
+		}
+	}
+
 	return
 }
 
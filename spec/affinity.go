@@ -0,0 +1,155 @@
+package spec
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/finder"
+)
+
+type Affinity struct {
+	Kind     string               `bson:"kind" json:"kind"`
+	Mode     string               `bson:"mode" json:"mode"`
+	Topology string               `bson:"topology" json:"topology"`
+	Units    []primitive.ObjectID `bson:"units" json:"units"`
+}
+
+type Spread struct {
+	Mode     string `bson:"mode" json:"mode"`
+	Topology string `bson:"topology" json:"topology"`
+	MaxSkew  int    `bson:"max_skew" json:"max_skew"`
+}
+
+type AffinityYaml struct {
+	Kind     string   `yaml:"kind"`
+	Mode     string   `yaml:"mode"`
+	Topology string   `yaml:"topology"`
+	Units    []string `yaml:"units"`
+}
+
+type SpreadYaml struct {
+	Mode     string `yaml:"mode"`
+	Topology string `yaml:"topology"`
+	MaxSkew  int    `yaml:"max-skew"`
+}
+
+func parseConstraint(mode, topology string) (
+	modeOut, topologyOut string, errData *errortypes.ErrorData) {
+
+	switch mode {
+	case "":
+		modeOut = Soft
+		break
+	case Hard, Soft:
+		modeOut = mode
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "constraint_mode_invalid",
+			Message: "Scheduling constraint mode is invalid",
+		}
+		return
+	}
+
+	switch topology {
+	case "":
+		topologyOut = TopologyNode
+		break
+	case TopologyNode, TopologyZone:
+		topologyOut = topology
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "constraint_topology_invalid",
+			Message: "Scheduling constraint topology is invalid",
+		}
+		return
+	}
+
+	return
+}
+
+func parseAffinity(db *database.Database, resources *finder.Resources,
+	affinitiesYaml []AffinityYaml) (affinities []Affinity,
+	errData *errortypes.ErrorData, err error) {
+
+	affinities = []Affinity{}
+
+	for _, affinityYaml := range affinitiesYaml {
+		affinity := Affinity{
+			Kind:  affinityYaml.Kind,
+			Units: []primitive.ObjectID{},
+		}
+
+		switch affinity.Kind {
+		case AffinityKind, AntiAffinityKind:
+			break
+		default:
+			errData = &errortypes.ErrorData{
+				Error:   "affinity_kind_invalid",
+				Message: "Affinity kind is invalid",
+			}
+			return
+		}
+
+		affinity.Mode, affinity.Topology, errData = parseConstraint(
+			affinityYaml.Mode, affinityYaml.Topology)
+		if errData != nil {
+			return
+		}
+
+		for _, unitRef := range affinityYaml.Units {
+			kind, e := resources.Find(db, unitRef)
+			if e != nil {
+				err = e
+				return
+			}
+
+			if kind != finder.UnitKind || resources.Unit == nil {
+				errData = &errortypes.ErrorData{
+					Error:   "affinity_unit_invalid",
+					Message: "Affinity unit not found",
+				}
+				return
+			}
+
+			affinity.Units = append(affinity.Units, resources.Unit.Id)
+		}
+
+		affinities = append(affinities, affinity)
+	}
+
+	return
+}
+
+func parseSpread(spreadsYaml []SpreadYaml) (spreads []Spread,
+	errData *errortypes.ErrorData) {
+
+	spreads = []Spread{}
+
+	for _, spreadYaml := range spreadsYaml {
+		spread := Spread{
+			MaxSkew: spreadYaml.MaxSkew,
+		}
+
+		spread.Mode, spread.Topology, errData = parseConstraint(
+			spreadYaml.Mode, spreadYaml.Topology)
+		if errData != nil {
+			return
+		}
+
+		if spread.MaxSkew == 0 {
+			spread.MaxSkew = 1
+		} else if spread.MaxSkew < 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "spread_max_skew_invalid",
+				Message: "Spread max skew is invalid",
+			}
+			return
+		}
+
+		spreads = append(spreads, spread)
+	}
+
+	return
+}
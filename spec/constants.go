@@ -24,6 +24,20 @@ const (
 	OraclePrivate = "oracle_private"
 
 	TokenPrefix = "{{"
+
+	HealthCheckHttp  = "http"
+	HealthCheckHttps = "https"
+	HealthCheckTcp   = "tcp"
+	HealthCheckExec  = "exec"
+
+	AffinityKind     = "affinity"
+	AntiAffinityKind = "anti_affinity"
+
+	Hard = "hard"
+	Soft = "soft"
+
+	TopologyNode = "node"
+	TopologyZone = "zone"
 )
 
 type Base struct {
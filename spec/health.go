@@ -0,0 +1,128 @@
+package spec
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type HealthCheck struct {
+	Name               string `bson:"name" json:"name"`
+	Kind               string `bson:"kind" json:"kind"`
+	Port               int    `bson:"port" json:"port"`
+	Path               string `bson:"path" json:"path"`
+	Command            string `bson:"command" json:"command"`
+	Interval           int    `bson:"interval" json:"interval"`
+	Timeout            int    `bson:"timeout" json:"timeout"`
+	HealthyThreshold   int    `bson:"healthy_threshold" json:"healthy_threshold"`
+	UnhealthyThreshold int    `bson:"unhealthy_threshold" json:"unhealthy_threshold"`
+}
+
+type HealthCheckYaml struct {
+	Name               string `yaml:"name"`
+	Kind               string `yaml:"kind"`
+	Port               int    `yaml:"port"`
+	Path               string `yaml:"path"`
+	Command            string `yaml:"command"`
+	Interval           int    `yaml:"interval"`
+	Timeout            int    `yaml:"timeout"`
+	HealthyThreshold   int    `yaml:"healthy-threshold"`
+	UnhealthyThreshold int    `yaml:"unhealthy-threshold"`
+}
+
+func parseHealthChecks(checksYaml []HealthCheckYaml) (
+	checks []HealthCheck, errData *errortypes.ErrorData) {
+
+	checks = []HealthCheck{}
+	names := map[string]bool{}
+
+	for i, checkYaml := range checksYaml {
+		check := HealthCheck{
+			Name:               checkYaml.Name,
+			Kind:               checkYaml.Kind,
+			Port:               checkYaml.Port,
+			Path:               checkYaml.Path,
+			Command:            strings.TrimSpace(checkYaml.Command),
+			Interval:           checkYaml.Interval,
+			Timeout:            checkYaml.Timeout,
+			HealthyThreshold:   checkYaml.HealthyThreshold,
+			UnhealthyThreshold: checkYaml.UnhealthyThreshold,
+		}
+
+		if check.Name == "" {
+			check.Name = fmt.Sprintf("%s-%d", check.Kind, i)
+		}
+
+		if names[check.Name] {
+			errData = &errortypes.ErrorData{
+				Error:   "health_check_name_duplicate",
+				Message: "Health check name must be unique",
+			}
+			return
+		}
+		names[check.Name] = true
+
+		switch check.Kind {
+		case HealthCheckHttp, HealthCheckHttps:
+			if check.Path == "" {
+				check.Path = "/"
+			} else if !strings.HasPrefix(check.Path, "/") {
+				check.Path = "/" + check.Path
+			}
+			check.Command = ""
+			break
+		case HealthCheckTcp:
+			check.Path = ""
+			check.Command = ""
+			break
+		case HealthCheckExec:
+			if check.Command == "" {
+				errData = &errortypes.ErrorData{
+					Error:   "health_check_command_missing",
+					Message: "Health check command is missing",
+				}
+				return
+			}
+			check.Port = 0
+			check.Path = ""
+			break
+		default:
+			errData = &errortypes.ErrorData{
+				Error:   "health_check_kind_invalid",
+				Message: "Health check kind is invalid",
+			}
+			return
+		}
+
+		if check.Kind != HealthCheckExec &&
+			(check.Port < 1 || check.Port > 65535) {
+
+			errData = &errortypes.ErrorData{
+				Error:   "health_check_port_invalid",
+				Message: "Health check port is invalid",
+			}
+			return
+		}
+
+		if check.Interval <= 0 {
+			check.Interval = 10
+		}
+		if check.Timeout <= 0 {
+			check.Timeout = 5
+		}
+		if check.Timeout > check.Interval {
+			check.Timeout = check.Interval
+		}
+		if check.HealthyThreshold <= 0 {
+			check.HealthyThreshold = 1
+		}
+		if check.UnhealthyThreshold <= 0 {
+			check.UnhealthyThreshold = 3
+		}
+
+		checks = append(checks, check)
+	}
+
+	return
+}
@@ -5,27 +5,34 @@ import (
 )
 
 type Instance struct {
-	Plan         primitive.ObjectID   `bson:"plan,omitempty" json:"plan"`       // clear
-	Zone         primitive.ObjectID   `bson:"zone" json:"zone"`                 // hard
-	Node         primitive.ObjectID   `bson:"node,omitempty" json:"node"`       // hard
-	Shape        primitive.ObjectID   `bson:"shape,omitempty" json:"shape"`     // hard
-	Vpc          primitive.ObjectID   `bson:"vpc" json:"vpc"`                   // hard
-	Subnet       primitive.ObjectID   `bson:"subnet" json:"subnet"`             // hard
-	Roles        []string             `bson:"roles" json:"roles"`               // soft
-	Processors   int                  `bson:"processors" json:"processors"`     // soft
-	Memory       int                  `bson:"memory" json:"memory"`             // soft
-	Image        primitive.ObjectID   `bson:"image" json:"image"`               // hard
-	DiskSize     int                  `bson:"disk_size" json:"disk_size"`       // hard
-	Mounts       []Mount              `bson:"mounts" json:"mounts"`             // hard
-	Certificates []primitive.ObjectID `bson:"certificates" json:"certificates"` // soft
-	Secrets      []primitive.ObjectID `bson:"secrets" json:"secrets"`           // soft
-	Pods         []primitive.ObjectID `bson:"pods" json:"pods"`                 // soft
+	Plan         primitive.ObjectID   `bson:"plan,omitempty" json:"plan"`         // clear
+	Zone         primitive.ObjectID   `bson:"zone" json:"zone"`                   // hard
+	Node         primitive.ObjectID   `bson:"node,omitempty" json:"node"`         // hard
+	Shape        primitive.ObjectID   `bson:"shape,omitempty" json:"shape"`       // hard
+	Vpc          primitive.ObjectID   `bson:"vpc" json:"vpc"`                     // hard
+	Subnet       primitive.ObjectID   `bson:"subnet" json:"subnet"`               // hard
+	Roles        []string             `bson:"roles" json:"roles"`                 // soft
+	Processors   int                  `bson:"processors" json:"processors"`       // soft
+	Memory       int                  `bson:"memory" json:"memory"`               // soft
+	Image        primitive.ObjectID   `bson:"image" json:"image"`                 // hard
+	DiskSize     int                  `bson:"disk_size" json:"disk_size"`         // hard
+	Mounts       []Mount              `bson:"mounts" json:"mounts"`               // hard
+	Certificates []primitive.ObjectID `bson:"certificates" json:"certificates"`   // soft
+	Secrets      []primitive.ObjectID `bson:"secrets" json:"secrets"`             // soft
+	Pods         []primitive.ObjectID `bson:"pods" json:"pods"`                   // soft
+	HealthChecks []HealthCheck        `bson:"health_checks" json:"health_checks"` // soft
+	Affinity     []Affinity           `bson:"affinity" json:"affinity"`           // soft
+	Spread       []Spread             `bson:"spread" json:"spread"`               // soft
 }
 
 func (i *Instance) MemoryUnits() float64 {
 	return float64(i.Memory) / float64(1024)
 }
 
+func (i *Instance) HasPlacement() bool {
+	return len(i.Affinity) > 0 || len(i.Spread) > 0
+}
+
 type Mount struct {
 	Path  string               `bson:"path" json:"path"`
 	Disks []primitive.ObjectID `bson:"disks" json:"disks"`
@@ -50,6 +57,9 @@ type InstanceYaml struct {
 	Secrets      []string            `yaml:"secrets"`
 	Pods         []string            `yaml:"pods"`
 	DiskSize     int                 `yaml:"disk-size"`
+	HealthChecks []HealthCheckYaml   `yaml:"health-checks"`
+	Affinity     []AffinityYaml      `yaml:"affinity"`
+	Spread       []SpreadYaml        `yaml:"spread"`
 }
 
 type InstanceMountYaml struct {
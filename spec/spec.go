@@ -355,7 +355,18 @@ func (s *Spec) parseInstance(db *database.Database,
 		return
 	}
 
-	if dataYaml.Image != "" {
+	prevImage := primitive.NilObjectID
+	if s.Instance != nil {
+		prevImage = s.Instance.Image
+	}
+
+	if dataYaml.Image != "" && !prevImage.IsZero() &&
+		finder.IsImageFamily(dataYaml.Image) {
+
+		// Family images are resolved once when the spec is committed,
+		// existing specs keep the pinned image.
+		data.Image = prevImage
+	} else if dataYaml.Image != "" {
 		kind, e := resources.Find(db, dataYaml.Image)
 		if e != nil {
 			err = e
@@ -363,6 +374,20 @@ func (s *Spec) parseInstance(db *database.Database,
 		}
 
 		if kind == finder.ImageKind && resources.Image != nil {
+			if resources.Image.Id != prevImage &&
+				resources.Image.IsObsolete() {
+				errData = &errortypes.ErrorData{
+					Error:   "unit_image_obsolete",
+					Message: "Unit image is obsolete",
+				}
+				return
+			}
+
+			errData, err = resources.Image.CheckSignature(db)
+			if err != nil || errData != nil {
+				return
+			}
+
 			data.Image = resources.Image.Id
 		}
 
@@ -472,6 +497,22 @@ func (s *Spec) parseInstance(db *database.Database,
 	data.Roles = dataYaml.Roles
 	data.DiskSize = dataYaml.DiskSize
 
+	data.HealthChecks, errData = parseHealthChecks(dataYaml.HealthChecks)
+	if errData != nil {
+		return
+	}
+
+	data.Affinity, errData, err = parseAffinity(
+		db, resources, dataYaml.Affinity)
+	if err != nil || errData != nil {
+		return
+	}
+
+	data.Spread, errData = parseSpread(dataYaml.Spread)
+	if errData != nil {
+		return
+	}
+
 	s.Name = dataYaml.Name
 	s.Kind = dataYaml.Kind
 	s.Count = dataYaml.Count
@@ -26,9 +26,11 @@ type Runtimes struct {
 	Iptables    time.Duration
 	Disks       time.Duration
 	Instances   time.Duration
+	Migrations  time.Duration
 	Namespaces  time.Duration
 	Pods        time.Duration
 	Deployments time.Duration
+	Builds      time.Duration
 	Imds        time.Duration
 	Total       time.Duration
 }
@@ -52,9 +54,11 @@ func (r *Runtimes) Log() {
 		"ipset":       fmt.Sprintf("%v", r.Ipset),
 		"iptables":    fmt.Sprintf("%v", r.Iptables),
 		"disks":       fmt.Sprintf("%v", r.Disks),
+		"migrations":  fmt.Sprintf("%v", r.Migrations),
 		"namespaces":  fmt.Sprintf("%v", r.Namespaces),
 		"pods":        fmt.Sprintf("%v", r.Pods),
 		"deployments": fmt.Sprintf("%v", r.Deployments),
+		"builds":      fmt.Sprintf("%v", r.Builds),
 		"imds":        fmt.Sprintf("%v", r.Imds),
 		"total":       fmt.Sprintf("%v", r.Total),
 	}).Warn("sync: Excess state sync runtime")
@@ -60,6 +60,14 @@ func scheduleUnits(db *database.Database) (err error) {
 	}
 
 	for _, unit := range units {
+		if unit.IsRolling() {
+			err = scheduler.Rollout(db, unit)
+			if err != nil {
+				return
+			}
+			continue
+		}
+
 		if len(unit.Deployments) >= unit.Count {
 			continue
 		}
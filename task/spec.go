@@ -26,6 +26,9 @@ func specsHandler(db *database.Database) (err error) {
 
 	specIdsSet := set.NewSet()
 	for _, deply := range deplys {
+		if !deply.Build.IsZero() {
+			continue
+		}
 		specIdsSet.Add(deply.Spec)
 	}
 
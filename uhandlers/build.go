@@ -0,0 +1,392 @@
+package uhandlers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/build"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/datacenter"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/zone"
+)
+
+type buildData struct {
+	Id         primitive.ObjectID `json:"id"`
+	Name       string             `json:"name"`
+	Comment    string             `json:"comment"`
+	Node       primitive.ObjectID `json:"node"`
+	Vpc        primitive.ObjectID `json:"vpc"`
+	Subnet     primitive.ObjectID `json:"subnet"`
+	BaseImage  primitive.ObjectID `json:"base_image"`
+	Processors int                `json:"processors"`
+	Memory     int                `json:"memory"`
+	DiskSize   int                `json:"disk_size"`
+	Script     string             `json:"script"`
+}
+
+type buildsData struct {
+	Builds []*build.Build `json:"builds"`
+	Count  int64          `json:"count"`
+}
+
+func buildNodeAllowed(db *database.Database, orgId,
+	ndeId primitive.ObjectID) (allowed bool, err error) {
+
+	if ndeId.IsZero() {
+		allowed = true
+		return
+	}
+
+	nde, err := node.Get(db, ndeId)
+	if err != nil {
+		return
+	}
+
+	if nde.Zone.IsZero() {
+		return
+	}
+
+	zne, err := zone.Get(db, nde.Zone)
+	if err != nil {
+		return
+	}
+
+	allowed, err = datacenter.ExistsOrg(db, orgId, zne.Datacenter)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func buildPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := &buildData{}
+
+	buildId, ok := utils.ParseObjectId(c.Param("build_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	allowed, err := buildNodeAllowed(db, userOrg, data.Node)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	if !allowed {
+		utils.AbortWithStatus(c, 405)
+		return
+	}
+
+	bld, err := build.GetOrg(db, userOrg, buildId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if bld.IsActive() {
+		errData := &errortypes.ErrorData{
+			Error:   "build_active",
+			Message: "Cannot modify running build",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	bld.Name = data.Name
+	bld.Comment = data.Comment
+	bld.Node = data.Node
+	bld.Vpc = data.Vpc
+	bld.Subnet = data.Subnet
+	bld.BaseImage = data.BaseImage
+	bld.Processors = data.Processors
+	bld.Memory = data.Memory
+	bld.DiskSize = data.DiskSize
+	bld.Script = data.Script
+
+	fields := set.NewSet(
+		"name",
+		"comment",
+		"zone",
+		"node",
+		"vpc",
+		"subnet",
+		"base_image",
+		"processors",
+		"memory",
+		"disk_size",
+		"script",
+	)
+
+	errData, err := bld.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = bld.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "build.change")
+
+	c.JSON(200, bld)
+}
+
+func buildPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := &buildData{
+		Name: "New Build",
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	allowed, err := buildNodeAllowed(db, userOrg, data.Node)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	if !allowed {
+		utils.AbortWithStatus(c, 405)
+		return
+	}
+
+	bld := &build.Build{
+		Name:         data.Name,
+		Comment:      data.Comment,
+		Organization: userOrg,
+		Node:         data.Node,
+		Vpc:          data.Vpc,
+		Subnet:       data.Subnet,
+		BaseImage:    data.BaseImage,
+		Processors:   data.Processors,
+		Memory:       data.Memory,
+		DiskSize:     data.DiskSize,
+		Script:       data.Script,
+	}
+
+	errData, err := bld.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = bld.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "build.change")
+
+	c.JSON(200, bld)
+}
+
+func buildRunPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	buildId, ok := utils.ParseObjectId(c.Param("build_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	bld, err := build.GetOrg(db, userOrg, buildId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if bld.IsActive() {
+		errData := &errortypes.ErrorData{
+			Error:   "build_active",
+			Message: "Build is already running",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	bld.State = build.Queued
+	bld.Error = ""
+	err = bld.CommitFields(db, set.NewSet("state", "error"))
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "build.change")
+
+	c.JSON(200, bld)
+}
+
+func buildDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	buildId, ok := utils.ParseObjectId(c.Param("build_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	bld, err := build.GetOrg(db, userOrg, buildId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if bld.IsActive() {
+		errData := &errortypes.ErrorData{
+			Error:   "build_active",
+			Message: "Cannot delete running build",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	err = build.RemoveOrg(db, userOrg, buildId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "build.change")
+
+	c.JSON(200, nil)
+}
+
+func buildsDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := []primitive.ObjectID{}
+
+	err := c.Bind(&data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = build.RemoveMultiOrg(db, userOrg, data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "build.change")
+
+	c.JSON(200, nil)
+}
+
+func buildGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	buildId, ok := utils.ParseObjectId(c.Param("build_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	bld, err := build.GetOrg(db, userOrg, buildId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, bld)
+}
+
+func buildsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{
+		"organization": userOrg,
+	}
+
+	buildId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = buildId
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		query["name"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", regexp.QuoteMeta(name)),
+			"$options": "i",
+		}
+	}
+
+	builds, count, err := build.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &buildsData{
+		Builds: builds,
+		Count:  count,
+	}
+
+	c.JSON(200, data)
+}
@@ -2,6 +2,7 @@ package uhandlers
 
 import (
 	"fmt"
+	"mime"
 	"regexp"
 	"strconv"
 	"strings"
@@ -22,29 +23,37 @@ import (
 	"github.com/pritunl/pritunl-cloud/image"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/pool"
+	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/zone"
 )
 
 type diskData struct {
-	Id               primitive.ObjectID `json:"id"`
-	Name             string             `json:"name"`
-	Comment          string             `json:"comment"`
-	Instance         primitive.ObjectID `json:"instance"`
-	Index            string             `json:"index"`
-	Type             string             `json:"type"`
-	Node             primitive.ObjectID `json:"node"`
-	Pool             primitive.ObjectID `json:"pool"`
-	DeleteProtection bool               `json:"delete_protection"`
-	FileSystem       string             `json:"file_system"`
-	Image            primitive.ObjectID `json:"image"`
-	RestoreImage     primitive.ObjectID `json:"restore_image"`
-	Backing          bool               `json:"backing"`
-	State            string             `json:"state"`
-	Size             int                `json:"size"`
-	NewSize          int                `json:"new_size"`
-	Backup           bool               `json:"backup"`
+	Id               primitive.ObjectID   `json:"id"`
+	Name             string               `json:"name"`
+	Comment          string               `json:"comment"`
+	Instance         primitive.ObjectID   `json:"instance"`
+	Index            string               `json:"index"`
+	Type             string               `json:"type"`
+	Node             primitive.ObjectID   `json:"node"`
+	Pool             primitive.ObjectID   `json:"pool"`
+	DeleteProtection bool                 `json:"delete_protection"`
+	FileSystem       string               `json:"file_system"`
+	Image            primitive.ObjectID   `json:"image"`
+	RestoreImage     primitive.ObjectID   `json:"restore_image"`
+	Backing          bool                 `json:"backing"`
+	State            string               `json:"state"`
+	Size             int                  `json:"size"`
+	NewSize          int                  `json:"new_size"`
+	Backup           bool                 `json:"backup"`
+	BackupSchedule   *disk.BackupSchedule `json:"backup_schedule"`
+	Import           *disk.Transfer       `json:"import"`
+}
+
+type diskExportData struct {
+	Format string `json:"format"`
 }
 
 type disksMultiData struct {
@@ -95,6 +104,8 @@ func diskPut(c *gin.Context) {
 		"delete_protection",
 		"index",
 		"backup",
+		"backup_schedule",
+		"next_backup",
 		"new_size",
 	)
 
@@ -118,7 +129,9 @@ func diskPut(c *gin.Context) {
 	dsk.DeleteProtection = dta.DeleteProtection
 	dsk.Index = dta.Index
 	dsk.Backup = dta.Backup
+	dsk.BackupSchedule = dta.BackupSchedule
 
+	expand := false
 	if dsk.State == disk.Available && dta.State == disk.Snapshot {
 		dsk.State = disk.Snapshot
 		fields.Add("state")
@@ -128,6 +141,7 @@ func diskPut(c *gin.Context) {
 	} else if dsk.State == disk.Available && dta.State == disk.Expand {
 		dsk.State = disk.Expand
 		dsk.NewSize = dta.NewSize
+		expand = true
 		fields.Add("state")
 	} else if dta.State == disk.Restore {
 		if dsk.State == disk.Available {
@@ -174,6 +188,19 @@ func diskPut(c *gin.Context) {
 		return
 	}
 
+	if expand {
+		errData, err = dsk.ValidateExpandCapacity(db)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		if errData != nil {
+			c.JSON(400, errData)
+			return
+		}
+	}
+
 	err = dsk.CommitFields(db, fields)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -185,6 +212,49 @@ func diskPut(c *gin.Context) {
 	c.JSON(200, dsk)
 }
 
+func diskTargetExistsOrg(db *database.Database, userOrg, ndeId,
+	plId primitive.ObjectID) (exists bool, err error) {
+
+	zoneIds := []primitive.ObjectID{}
+
+	if !ndeId.IsZero() {
+		nde, e := node.Get(db, ndeId)
+		if e != nil {
+			err = e
+			return
+		}
+		zoneIds = append(zoneIds, nde.Zone)
+	}
+
+	if !plId.IsZero() {
+		pl, e := pool.Get(db, plId)
+		if e != nil {
+			err = e
+			return
+		}
+		zoneIds = append(zoneIds, pl.Zone)
+	}
+
+	if len(zoneIds) == 0 {
+		return
+	}
+
+	for _, zoneId := range zoneIds {
+		zne, e := zone.Get(db, zoneId)
+		if e != nil {
+			err = e
+			return
+		}
+
+		exists, err = datacenter.ExistsOrg(db, userOrg, zne.Datacenter)
+		if err != nil || !exists {
+			return
+		}
+	}
+
+	return
+}
+
 func diskPost(c *gin.Context) {
 	if demo.Blocked(c) {
 		return
@@ -239,6 +309,19 @@ func diskPost(c *gin.Context) {
 		return
 	}
 
+	if !dta.Pool.IsZero() {
+		exists, err = diskTargetExistsOrg(db, userOrg,
+			primitive.NilObjectID, dta.Pool)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+		if !exists {
+			utils.AbortWithStatus(c, 405)
+			return
+		}
+	}
+
 	if !dta.Image.IsZero() {
 		img, err := image.GetOrgPublic(db, userOrg, dta.Image)
 		if err != nil {
@@ -291,6 +374,18 @@ func diskPost(c *gin.Context) {
 		Backing:          dta.Backing,
 		Size:             dta.Size,
 		Backup:           dta.Backup,
+		BackupSchedule:   dta.BackupSchedule,
+	}
+
+	if dta.Import != nil {
+		dsk.Import = &disk.Transfer{
+			Format: dta.Import.Format,
+			Url:    dta.Import.Url,
+		}
+
+		if dsk.Import.Url == "" {
+			dsk.State = disk.Upload
+		}
 	}
 
 	errData, err := dsk.Validate(db)
@@ -304,6 +399,17 @@ func diskPost(c *gin.Context) {
 		return
 	}
 
+	errData, err = dsk.ValidateCapacity(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
 	err = dsk.Insert(db)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -315,6 +421,296 @@ func diskPost(c *gin.Context) {
 	c.JSON(200, dsk)
 }
 
+func diskClonePost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	dta := &diskData{}
+
+	diskId, ok := utils.ParseObjectId(c.Param("disk_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(dta)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	srcDsk, err := disk.GetOrg(db, userOrg, diskId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if !dta.Instance.IsZero() {
+		exists, err := instance.ExistsOrg(db, userOrg, dta.Instance)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+		if !exists {
+			utils.AbortWithStatus(c, 405)
+			return
+		}
+	}
+
+	if dta.Name == "" {
+		dta.Name = fmt.Sprintf("%s-clone", srcDsk.Name)
+	}
+	if dta.Type == "" {
+		dta.Type = srcDsk.Type
+	}
+	if dta.Node.IsZero() && dta.Pool.IsZero() {
+		dta.Node = srcDsk.Node
+		dta.Pool = srcDsk.Pool
+	}
+
+	dsk := &disk.Disk{
+		Name:             dta.Name,
+		Comment:          dta.Comment,
+		Organization:     srcDsk.Organization,
+		Instance:         dta.Instance,
+		Index:            dta.Index,
+		Type:             dta.Type,
+		Node:             dta.Node,
+		Pool:             dta.Pool,
+		DeleteProtection: dta.DeleteProtection,
+		SystemType:       srcDsk.SystemType,
+		Size:             utils.Max(dta.Size, srcDsk.Size),
+		CloneSource:      srcDsk.Id,
+		Qos:              srcDsk.Qos.Copy(),
+	}
+
+	errData, err := dsk.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	exists, err := diskTargetExistsOrg(db, userOrg, dsk.Node, dsk.Pool)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	if !exists {
+		utils.AbortWithStatus(c, 405)
+		return
+	}
+
+	errData, err = dsk.ValidateCapacity(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = dsk.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "disk.change")
+
+	c.JSON(200, dsk)
+}
+
+func diskUploadPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	diskId, ok := utils.ParseObjectId(c.Param("disk_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	if c.Request.ContentLength <= 0 {
+		utils.AbortWithStatus(c, 411)
+		return
+	}
+
+	if c.Request.ContentLength > int64(
+		settings.Hypervisor.DiskImportMaxSize)*1073741824 {
+
+		errData := &errortypes.ErrorData{
+			Error:   "disk_upload_size",
+			Message: "Disk upload exceeds maximum import size",
+		}
+		c.JSON(413, errData)
+		return
+	}
+
+	dsk, err := disk.GetOrg(db, userOrg, diskId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if dsk.State != disk.Upload || dsk.Import == nil {
+		errData := &errortypes.ErrorData{
+			Error:   "disk_not_uploading",
+			Message: "Disk is not waiting for an upload",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	err = data.UploadDiskImport(db, dsk, c.Request.Body,
+		c.Request.ContentLength)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	updated, err := disk.SetUploaded(db, dsk.Id, dsk.Import)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if !updated {
+		errData := &errortypes.ErrorData{
+			Error:   "disk_not_uploading",
+			Message: "Disk is not waiting for an upload",
+		}
+		c.JSON(400, errData)
+		return
+	}
+	dsk.State = disk.Provision
+
+	event.PublishDispatch(db, "disk.change")
+
+	c.JSON(200, dsk)
+}
+
+func diskExportPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	dta := &diskExportData{}
+
+	diskId, ok := utils.ParseObjectId(c.Param("disk_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(dta)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	dsk, err := disk.GetOrg(db, userOrg, diskId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if dsk.State != disk.Available {
+		errData := &errortypes.ErrorData{
+			Error:   "disk_not_available",
+			Message: "Disk must be available to export",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	newExport := &disk.Transfer{
+		Format: dta.Format,
+	}
+
+	// Keep the previous export object to remove it before the new export
+	if dsk.Export != nil {
+		newExport.Storage = dsk.Export.Storage
+		newExport.Key = dsk.Export.Key
+	}
+	dsk.Export = newExport
+
+	errData := dsk.Export.Validate(true)
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	dsk.State = disk.Export
+	err = dsk.CommitFields(db, set.NewSet("state", "export"))
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "disk.change")
+
+	c.JSON(200, dsk)
+}
+
+func diskExportGet(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	diskId, ok := utils.ParseObjectId(c.Param("disk_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	dsk, err := disk.GetOrg(db, userOrg, diskId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	obj, size, err := data.GetDiskExport(db, dsk)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+	defer obj.Close()
+
+	c.DataFromReader(200, size, "application/octet-stream", obj,
+		map[string]string{
+			"Content-Disposition": mime.FormatMediaType("attachment",
+				map[string]string{
+					"filename": fmt.Sprintf("%s.%s",
+						dsk.Name, dsk.Export.Format),
+				}),
+		})
+}
+
 func disksPut(c *gin.Context) {
 	if demo.Blocked(c) {
 		return
@@ -72,6 +72,14 @@ func Register(engine *gin.Engine) {
 	orgGroup.DELETE("/balancer", balancersDelete)
 	orgGroup.DELETE("/balancer/:balancer_id", balancerDelete)
 
+	orgGroup.GET("/build", buildsGet)
+	orgGroup.GET("/build/:build_id", buildGet)
+	orgGroup.PUT("/build/:build_id", buildPut)
+	orgGroup.POST("/build", buildPost)
+	orgGroup.POST("/build/:build_id/run", buildRunPost)
+	orgGroup.DELETE("/build", buildsDelete)
+	orgGroup.DELETE("/build/:build_id", buildDelete)
+
 	orgGroup.GET("/certificate", certificatesGet)
 	orgGroup.GET("/certificate/:cert_id", certificateGet)
 	orgGroup.PUT("/certificate/:cert_id", certificatePut)
@@ -100,6 +108,10 @@ func Register(engine *gin.Engine) {
 	orgGroup.PUT("/disk", disksPut)
 	orgGroup.PUT("/disk/:disk_id", diskPut)
 	orgGroup.POST("/disk", diskPost)
+	orgGroup.POST("/disk/:disk_id/clone", diskClonePost)
+	orgGroup.PUT("/disk/:disk_id/upload", diskUploadPut)
+	orgGroup.POST("/disk/:disk_id/export", diskExportPost)
+	orgGroup.GET("/disk/:disk_id/export", diskExportGet)
 	orgGroup.DELETE("/disk", disksDelete)
 	orgGroup.DELETE("/disk/:disk_id", diskDelete)
 
@@ -123,6 +135,12 @@ func Register(engine *gin.Engine) {
 	orgGroup.GET("/instance/:instance_id", instanceGet)
 	orgGroup.GET("/instance/:instance_id/vnc", instanceVncGet)
 	orgGroup.PUT("/instance/:instance_id", instancePut)
+	orgGroup.POST("/instance/:instance_id/migrate", instanceMigratePost)
+	orgGroup.POST("/instance/:instance_id/relocate", instanceRelocatePost)
+	orgGroup.POST("/instance/:instance_id/snapshot", instanceSnapshotPost)
+	orgGroup.POST("/instance/:instance_id/snapshot/restore",
+		instanceSnapshotRestorePost)
+	orgGroup.POST("/instance/:instance_id/clone", instanceClonePost)
 	orgGroup.POST("/instance", instancePost)
 	orgGroup.DELETE("/instance", instancesDelete)
 	orgGroup.DELETE("/instance/:instance_id", instanceDelete)
@@ -159,6 +177,14 @@ func Register(engine *gin.Engine) {
 		podUnitDeploymentPut)
 	orgGroup.POST("/pod/:pod_id/unit/:unit_id/deployment",
 		podUnitDeploymentPost)
+	orgGroup.PUT("/pod/:pod_id/unit/:unit_id/rollout",
+		podUnitRolloutPut)
+	orgGroup.POST("/pod/:pod_id/unit/:unit_id/preview",
+		podUnitPreviewPost)
+	orgGroup.POST("/pod/:pod_id/unit/:unit_id/canary",
+		podUnitCanaryPost)
+	orgGroup.PUT("/pod/:pod_id/unit/:unit_id/canary",
+		podUnitCanaryPut)
 	orgGroup.GET(
 		"/pod/:pod_id/unit/:unit_id/deployment/:deployment_id/log",
 		podUnitDeploymentLogGet,
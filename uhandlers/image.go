@@ -22,9 +22,13 @@ import (
 )
 
 type imageData struct {
-	Id      primitive.ObjectID `json:"id"`
-	Name    string             `json:"name"`
-	Comment string             `json:"comment"`
+	Id        primitive.ObjectID `json:"id"`
+	Name      string             `json:"name"`
+	Comment   string             `json:"comment"`
+	Family    string             `json:"family"`
+	Version   int                `json:"version"`
+	Channel   string             `json:"channel"`
+	Lifecycle string             `json:"lifecycle"`
 }
 
 type imagesData struct {
@@ -64,10 +68,18 @@ func imagePut(c *gin.Context) {
 
 	img.Name = dta.Name
 	img.Comment = dta.Comment
+	img.Family = dta.Family
+	img.Version = dta.Version
+	img.Channel = dta.Channel
+	img.Lifecycle = dta.Lifecycle
 
 	fields := set.NewSet(
 		"name",
 		"comment",
+		"family",
+		"version",
+		"channel",
+		"lifecycle",
 	)
 
 	errData, err := img.Validate(db)
@@ -106,12 +118,17 @@ func imageDelete(c *gin.Context) {
 		return
 	}
 
-	err := data.DeleteImageOrg(db, userOrg, imageId)
+	errData, err := data.DeleteImageOrg(db, userOrg, imageId)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
 	event.PublishDispatch(db, "image.change")
 	event.PublishDispatch(db, "pod.change")
 
@@ -133,12 +150,17 @@ func imagesDelete(c *gin.Context) {
 		return
 	}
 
-	err = data.DeleteImagesOrg(db, userOrg, dta)
+	errData, err := data.DeleteImagesOrg(db, userOrg, dta)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
 	event.PublishDispatch(db, "image.change")
 	event.PublishDispatch(db, "pod.change")
 
@@ -287,6 +309,16 @@ func imagesGet(c *gin.Context) {
 			query["type"] = typ
 		}
 
+		family := strings.TrimSpace(c.Query("family"))
+		if family != "" {
+			query["family"] = family
+		}
+
+		channel := strings.TrimSpace(c.Query("channel"))
+		if channel != "" {
+			query["channel"] = channel
+		}
+
 		images, count, err := image.GetAll(db, &query, page, pageCount)
 		if err != nil {
 			utils.AbortWithError(c, 500, err)
@@ -2,6 +2,9 @@ package utils
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -191,6 +194,29 @@ func Open(path string, perm os.FileMode) (file *os.File, err error) {
 	return
 }
 
+func Sha256File(path string) (hash string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrapf(err, "utils: Failed to open '%s'", path),
+		}
+		return
+	}
+	defer file.Close()
+
+	hashFunc := sha256.New()
+	_, err = io.Copy(hashFunc, file)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrapf(err, "utils: Failed to read '%s'", path),
+		}
+		return
+	}
+
+	hash = fmt.Sprintf("%x", hashFunc.Sum(nil))
+	return
+}
+
 func Read(path string) (data string, err error) {
 	dataByt, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -3,6 +3,7 @@ package utils
 import (
 	"encoding/binary"
 	"runtime"
+	"time"
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-cloud/errortypes"
@@ -114,3 +115,17 @@ func LoadAverage() (ld *LoadStat, err error) {
 
 	return
 }
+
+func GetUptime() (uptime int64, err error) {
+	bootTime, err := unix.SysctlTimeval("kern.boottime")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "utils: Failed to read boottime"),
+		}
+		return
+	}
+
+	uptime = time.Now().Unix() - bootTime.Sec
+
+	return
+}
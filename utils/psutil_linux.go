@@ -241,3 +241,33 @@ func LoadAverage() (ld *LoadStat, err error) {
 
 	return
 }
+
+func GetUptime() (uptime int64, err error) {
+	line, err := ioutil.ReadFile("/proc/uptime")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "utils: Failed to read uptime"),
+		}
+		return
+	}
+
+	values := strings.Fields(string(line))
+	if len(values) < 1 {
+		err = &errortypes.ParseError{
+			errors.New("utils: Invalid uptime data"),
+		}
+		return
+	}
+
+	uptimeFloat, err := strconv.ParseFloat(values[0], 64)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "utils: Invalid uptime value"),
+		}
+		return
+	}
+
+	uptime = int64(uptimeFloat)
+
+	return
+}
@@ -12,4 +12,5 @@ const (
 
 	Physical = "physical"
 	Lvm      = "lvm"
+	Rbd      = "rbd"
 )
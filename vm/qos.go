@@ -0,0 +1,88 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type DiskQos struct {
+	ReadIops            int `bson:"read_iops" json:"read_iops"`
+	WriteIops           int `bson:"write_iops" json:"write_iops"`
+	ReadBandwidth       int `bson:"read_bandwidth" json:"read_bandwidth"`
+	WriteBandwidth      int `bson:"write_bandwidth" json:"write_bandwidth"`
+	ReadIopsBurst       int `bson:"read_iops_burst" json:"read_iops_burst"`
+	WriteIopsBurst      int `bson:"write_iops_burst" json:"write_iops_burst"`
+	ReadBandwidthBurst  int `bson:"read_bandwidth_burst" json:"read_bandwidth_burst"`
+	WriteBandwidthBurst int `bson:"write_bandwidth_burst" json:"write_bandwidth_burst"`
+	BurstLength         int `bson:"burst_length" json:"burst_length"`
+}
+
+func (q *DiskQos) Validate() (errData *errortypes.ErrorData) {
+	if q.ReadIops < 0 || q.WriteIops < 0 || q.ReadBandwidth < 0 ||
+		q.WriteBandwidth < 0 || q.ReadIopsBurst < 0 ||
+		q.WriteIopsBurst < 0 || q.ReadBandwidthBurst < 0 ||
+		q.WriteBandwidthBurst < 0 || q.BurstLength < 0 {
+
+		errData = &errortypes.ErrorData{
+			Error:   "qos_invalid",
+			Message: "Disk QoS limits cannot be negative",
+		}
+		return
+	}
+
+	bursts := [][2]int{
+		{q.ReadIops, q.ReadIopsBurst},
+		{q.WriteIops, q.WriteIopsBurst},
+		{q.ReadBandwidth, q.ReadBandwidthBurst},
+		{q.WriteBandwidth, q.WriteBandwidthBurst},
+	}
+	hasBurst := false
+	for _, burst := range bursts {
+		if burst[1] == 0 {
+			continue
+		}
+		hasBurst = true
+
+		if burst[0] == 0 || burst[1] < burst[0] {
+			errData = &errortypes.ErrorData{
+				Error:   "qos_burst_invalid",
+				Message: "Disk QoS burst must be greater then the limit",
+			}
+			return
+		}
+	}
+
+	if !hasBurst {
+		q.BurstLength = 0
+	} else if q.BurstLength == 0 {
+		q.BurstLength = 1
+	}
+
+	return
+}
+
+func (q *DiskQos) IsZero() bool {
+	return q == nil || (q.ReadIops == 0 && q.WriteIops == 0 &&
+		q.ReadBandwidth == 0 && q.WriteBandwidth == 0)
+}
+
+func (q *DiskQos) Copy() *DiskQos {
+	if q == nil {
+		return nil
+	}
+
+	qos := *q
+	return &qos
+}
+
+func (q *DiskQos) Key() string {
+	if q.IsZero() {
+		return "none"
+	}
+
+	return fmt.Sprintf("%d-%d-%d-%d-%d-%d-%d-%d-%d",
+		q.ReadIops, q.WriteIops, q.ReadBandwidth, q.WriteBandwidth,
+		q.ReadIopsBurst, q.WriteIopsBurst, q.ReadBandwidthBurst,
+		q.WriteBandwidthBurst, q.BurstLength)
+}
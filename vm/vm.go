@@ -93,6 +93,7 @@ type Disk struct {
 	Id    primitive.ObjectID `json:"id"`
 	Index int                `json:"index"`
 	Path  string             `json:"path"`
+	Qos   *DiskQos           `json:"qos,omitempty"`
 }
 
 type Iso struct {
@@ -122,10 +123,14 @@ type PciDevice struct {
 }
 
 type DriveDevice struct {
-	Id     string `json:"id"`
-	Type   string `json:"type"`
-	VgName string `json:"vg_name"`
-	LvName string `json:"lv_name"`
+	Id       string   `json:"id"`
+	Type     string   `json:"type"`
+	VgName   string   `json:"vg_name"`
+	LvName   string   `json:"lv_name"`
+	RbdPool  string   `json:"rbd_pool,omitempty"`
+	RbdUser  string   `json:"rbd_user,omitempty"`
+	RbdImage string   `json:"rbd_image,omitempty"`
+	Qos      *DiskQos `json:"qos,omitempty"`
 }
 
 type IscsiDevice struct {
@@ -147,6 +152,7 @@ func (d *Disk) Copy() (dsk *Disk) {
 		Id:    d.Id,
 		Index: d.Index,
 		Path:  d.Path,
+		Qos:   d.Qos.Copy(),
 	}
 
 	return